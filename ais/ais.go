@@ -0,0 +1,223 @@
+// Package ais decodes AIS messages from NMEA 0183 !AIVDM and !AIVDO sentences.
+//
+// The payload of a sentence is armored as 6-bit ASCII, one character per 6 bits
+// of an MSB-first bitstream, with fill bits padding the last character. Long
+// messages are split over several sentences and reassembled with an Assembler.
+// A Payload is read with a big-endian bitreader.Reader, and Decode reads the
+// common message types from it.
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pektezol/bitreader"
+)
+
+var (
+	// ErrSentence is returned when a sentence is not a valid AIVDM or AIVDO sentence.
+	ErrSentence = errors.New("ais: invalid sentence")
+	// ErrChecksum is returned when the checksum of a sentence doesn't match.
+	ErrChecksum = errors.New("ais: invalid checksum")
+	// ErrArmor is returned when the payload has a character outside of the 6-bit armor.
+	ErrArmor = errors.New("ais: invalid payload character")
+	// ErrFragment is returned when a fragment doesn't fit the message being reassembled.
+	ErrFragment = errors.New("ais: unexpected fragment")
+)
+
+// Sentence is one parsed !AIVDM or !AIVDO sentence.
+//
+// Talker string		Talker ID, "AI" for most receivers
+// Format string		"VDM" for received messages, "VDO" for the own vessel
+// Count int			Number of sentences of the message
+// Number int			Number of this sentence, from 1
+// Sequence string		Sequential message ID tying the sentences of a message together, empty for single sentences
+// Channel string		Radio channel, "A" or "B"
+// Payload string		The armored payload
+// FillBits int			Number of bits to ignore at the end of the payload
+type Sentence struct {
+	Talker   string
+	Format   string
+	Count    int
+	Number   int
+	Sequence string
+	Channel  string
+	Payload  string
+	FillBits int
+}
+
+// Payload is a de-armored message.
+//
+// Data []byte		The bits of the message, MSB-first, zero padded to whole bytes
+// Bits uint64		Number of bits in the message
+type Payload struct {
+	Data []byte
+	Bits uint64
+}
+
+// ParseSentence is a function that parses one !AIVDM or !AIVDO sentence, with or
+// without a trailing line ending. The checksum is verified if there is one.
+func ParseSentence(line string) (*Sentence, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 7 || line[0] != '!' {
+		return nil, ErrSentence
+	}
+	body := line[1:]
+	if star := strings.LastIndexByte(body, '*'); star >= 0 {
+		want, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil || len(body)-star-1 != 2 {
+			return nil, ErrSentence
+		}
+		if checksum(body[:star]) != byte(want) {
+			return nil, ErrChecksum
+		}
+		body = body[:star]
+	}
+	fields := strings.Split(body, ",")
+	if len(fields) != 7 || len(fields[0]) != 5 {
+		return nil, ErrSentence
+	}
+	sentence := &Sentence{
+		Talker:   fields[0][:2],
+		Format:   fields[0][2:],
+		Sequence: fields[3],
+		Channel:  fields[4],
+		Payload:  fields[5],
+	}
+	if sentence.Format != "VDM" && sentence.Format != "VDO" {
+		return nil, ErrSentence
+	}
+	var err error
+	if sentence.Count, err = strconv.Atoi(fields[1]); err != nil || sentence.Count < 1 {
+		return nil, ErrSentence
+	}
+	if sentence.Number, err = strconv.Atoi(fields[2]); err != nil || sentence.Number < 1 || sentence.Number > sentence.Count {
+		return nil, ErrSentence
+	}
+	if sentence.FillBits, err = strconv.Atoi(fields[6]); err != nil || sentence.FillBits < 0 || sentence.FillBits > 5 {
+		return nil, ErrSentence
+	}
+	return sentence, nil
+}
+
+// Dearmor is a function that turns an armored payload into its bits, dropping
+// fillBits bits from the end.
+func Dearmor(armored string, fillBits int) (*Payload, error) {
+	if fillBits < 0 || fillBits > 5 || fillBits > 6*len(armored) {
+		return nil, fmt.Errorf("ais: fill bits should be between 0 and 5, got %d", fillBits)
+	}
+	bits := uint64(6*len(armored) - fillBits)
+	data := make([]byte, (6*len(armored)+7)/8)
+	var acc uint32
+	var count uint
+	n := 0
+	for i := 0; i < len(armored); i++ {
+		value, ok := unarmor(armored[i])
+		if !ok {
+			return nil, ErrArmor
+		}
+		acc = acc<<6 | uint32(value)
+		count += 6
+		if count >= 8 {
+			count -= 8
+			data[n] = byte(acc >> count)
+			n++
+		}
+	}
+	if count > 0 {
+		data[n] = byte(acc << (8 - count))
+	}
+	// Clear the fill bits so they can't be mistaken for data
+	for i := bits / 8; i < uint64(len(data)); i++ {
+		if i == bits/8 {
+			data[i] &^= 0xff >> (bits % 8)
+		} else {
+			data[i] = 0
+		}
+	}
+	return &Payload{Data: data, Bits: bits}, nil
+}
+
+// Reader is a function that returns a big-endian Reader for the payload, limited
+// to the bits of the message so reading into the padding fails.
+func (payload *Payload) Reader() *bitreader.Reader {
+	reader := bitreader.NewReaderFromBytes(payload.Data, false)
+	reader.SetLimits(bitreader.Limits{MaxTotalBits: payload.Bits})
+	return reader
+}
+
+// Assembler reassembles messages split over several sentences. Fragments of a message
+// are matched by their sequential message ID and channel, and may arrive out of order.
+//
+// pending map[string]*fragments	The messages being reassembled, by talker, format, sequence and channel
+type Assembler struct {
+	pending map[string]*fragments
+}
+
+// fragments is the part of a message received so far.
+//
+// parts []string		The armored payload of each fragment
+// received []bool		Whether each fragment has arrived
+// fillBits int			The fill bits of the last fragment
+type fragments struct {
+	parts    []string
+	received []bool
+	fillBits int
+}
+
+// NewAssembler is the constructor that creates an empty Assembler.
+func NewAssembler() *Assembler {
+	return &Assembler{pending: make(map[string]*fragments)}
+}
+
+// Add is a function that adds a sentence and returns the message payload once all of its
+// fragments have arrived, or nil while fragments are missing. Single sentence messages
+// are returned right away. A repeated fragment number starts the message over.
+func (assembler *Assembler) Add(sentence *Sentence) (*Payload, error) {
+	if sentence.Count == 1 {
+		return Dearmor(sentence.Payload, sentence.FillBits)
+	}
+	if sentence.Number < sentence.Count && sentence.FillBits != 0 {
+		return nil, ErrFragment
+	}
+	key := sentence.Talker + sentence.Format + "," + sentence.Sequence + "," + sentence.Channel
+	message := assembler.pending[key]
+	if message == nil || len(message.parts) != sentence.Count || message.received[sentence.Number-1] {
+		message = &fragments{parts: make([]string, sentence.Count), received: make([]bool, sentence.Count)}
+		assembler.pending[key] = message
+	}
+	message.parts[sentence.Number-1] = sentence.Payload
+	message.received[sentence.Number-1] = true
+	if sentence.Number == sentence.Count {
+		message.fillBits = sentence.FillBits
+	}
+	for _, received := range message.received {
+		if !received {
+			return nil, nil
+		}
+	}
+	delete(assembler.pending, key)
+	return Dearmor(strings.Join(message.parts, ""), message.fillBits)
+}
+
+// checksum is a private function that returns the NMEA checksum, the XOR of every byte.
+func checksum(body string) byte {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
+
+// unarmor is a private function that returns the 6-bit value of an armor character.
+func unarmor(char byte) (byte, bool) {
+	switch {
+	case char >= '0' && char <= 'W':
+		return char - '0', true
+	case char >= '`' && char <= 'w':
+		return char - '0' - 8, true
+	}
+	return 0, false
+}
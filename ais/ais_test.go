@@ -0,0 +1,353 @@
+package ais
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Sample sentences from the gpsd AIS test suite.
+const (
+	sampleType1   = "!AIVDM,1,1,,A,15RTgt0PAso;90TKcjM8h6g208CQ,0*4A"
+	sampleType5a  = "!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C"
+	sampleType5b  = "!AIVDM,2,2,1,A,88888888880,2*25"
+	sampleType18  = "!AIVDM,1,1,,A,B52K>;h00Fc>jpUlNV@ikwpUoP06,0*4C"
+	sampleType24  = "!AIVDM,1,1,,A,H42O55i18tMET00000000000000,2*6D"
+	sampleType24B = "!AIVDM,1,1,,A,H42O55lti4hhhilD3nink000?050,0*40"
+)
+
+// armor turns MSB-first bits into an armored payload and its fill bits.
+func armor(data []byte, bits int) (string, int) {
+	var out []byte
+	for i := 0; i < bits; i += 6 {
+		var value byte
+		for j := i; j < i+6; j++ {
+			value <<= 1
+			if j < bits {
+				value |= data[j/8] >> (7 - j%8) & 1
+			}
+		}
+		if value < 40 {
+			out = append(out, value+'0')
+		} else {
+			out = append(out, value+'0'+8)
+		}
+	}
+	return string(out), (6 - bits%6) % 6
+}
+
+// sentence builds a sentence with its checksum.
+func sentence(count, number int, sequence, payload string, fillBits int) string {
+	body := fmt.Sprintf("AIVDM,%d,%d,%s,B,%s,%d", count, number, sequence, payload, fillBits)
+	return fmt.Sprintf("!%s*%02X", body, checksum(body))
+}
+
+// decodeAll parses and reassembles lines and decodes the messages.
+func decodeAll(t *testing.T, lines ...string) []Message {
+	t.Helper()
+	assembler := NewAssembler()
+	var messages []Message
+	for _, line := range lines {
+		sentence, err := ParseSentence(line)
+		if err != nil {
+			t.Fatalf("ParseSentence(%q) error = %v", line, err)
+		}
+		payload, err := assembler.Add(sentence)
+		if err != nil {
+			t.Fatalf("Add(%q) error = %v", line, err)
+		}
+		if payload == nil {
+			continue
+		}
+		message, err := Decode(payload)
+		if err != nil {
+			t.Fatalf("Decode(%q) error = %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestParseSentence(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *Sentence
+		wantErr error
+	}{
+		{
+			name: "Single",
+			line: sampleType1 + "\r\n",
+			want: &Sentence{Talker: "AI", Format: "VDM", Count: 1, Number: 1, Channel: "A", Payload: "15RTgt0PAso;90TKcjM8h6g208CQ"},
+		},
+		{
+			name: "Fragment",
+			line: sampleType5b,
+			want: &Sentence{Talker: "AI", Format: "VDM", Count: 2, Number: 2, Sequence: "1", Channel: "A", Payload: "88888888880", FillBits: 2},
+		},
+		{
+			name: "NoChecksum",
+			line: "!AIVDO,1,1,,,13u?etPv2;0n:dDPwUM1U1Cb069D,0",
+			want: &Sentence{Talker: "AI", Format: "VDO", Count: 1, Number: 1, Payload: "13u?etPv2;0n:dDPwUM1U1Cb069D"},
+		},
+		{name: "BadChecksum", line: "!AIVDM,1,1,,A,15RTgt0PAso;90TKcjM8h6g208CQ,0*4B", wantErr: ErrChecksum},
+		{name: "Format", line: "!GPGGA,1,1,,A,1,0", wantErr: ErrSentence},
+		{name: "Fields", line: "!AIVDM,1,1,,A,1", wantErr: ErrSentence},
+		{name: "Number", line: "!AIVDM,1,2,,A,1,0", wantErr: ErrSentence},
+		{name: "FillBits", line: "!AIVDM,1,1,,A,1,6", wantErr: ErrSentence},
+		{name: "Start", line: "$AIVDM,1,1,,A,1,0", wantErr: ErrSentence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSentence(tt.line)
+			if err != tt.wantErr {
+				t.Fatalf("ParseSentence() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSentence() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDearmor(t *testing.T) {
+	// "0" to "W" and "`" to "w" are 0 to 63
+	payload, err := Dearmor("0W`w", 0)
+	if err != nil {
+		t.Fatalf("Dearmor() error = %v", err)
+	}
+	if want := []byte{0b000000_10, 0b0111_1010, 0b00_111111}; !reflect.DeepEqual(payload.Data, want) || payload.Bits != 24 {
+		t.Errorf("Dearmor() = %08b/%d, want %08b/24", payload.Data, payload.Bits, want)
+	}
+	// The fill bits are cleared and past the limit of the Reader
+	payload, err = Dearmor("ww", 5)
+	if err != nil {
+		t.Fatalf("Dearmor() error = %v", err)
+	}
+	if want := []byte{0xfe, 0x00}; !reflect.DeepEqual(payload.Data, want) || payload.Bits != 7 {
+		t.Errorf("Dearmor() = %08b/%d, want %08b/7", payload.Data, payload.Bits, want)
+	}
+	reader := payload.Reader()
+	if value := reader.TryReadBits(7); value != 0x7f {
+		t.Errorf("ReadBits(7) = %#x, want 0x7f", value)
+	}
+	if _, err := reader.ReadBits(1); err == nil {
+		t.Errorf("ReadBits(1) past the fill bits error = nil")
+	}
+	for _, armored := range []string{"X", "_", "x", " ", "é"} {
+		if _, err := Dearmor(armored, 0); err != ErrArmor {
+			t.Errorf("Dearmor(%q) error = %v, want %v", armored, err, ErrArmor)
+		}
+	}
+	if _, err := Dearmor("", 1); err == nil {
+		t.Errorf("Dearmor(\"\", 1) error = nil")
+	}
+}
+
+func TestDearmor_RoundTrip(t *testing.T) {
+	data := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89}
+	for bits := 1; bits <= len(data)*8; bits++ {
+		armored, fillBits := armor(data, bits)
+		payload, err := Dearmor(armored, fillBits)
+		if err != nil {
+			t.Fatalf("Bits=%d: Dearmor() error = %v", bits, err)
+		}
+		if payload.Bits != uint64(bits) {
+			t.Fatalf("Bits=%d: Dearmor().Bits = %d", bits, payload.Bits)
+		}
+		for i := 0; i < len(payload.Data)*8; i++ {
+			got := payload.Data[i/8] >> (7 - i%8) & 1
+			want := byte(0)
+			if i < bits {
+				want = data[i/8] >> (7 - i%8) & 1
+			}
+			if got != want {
+				t.Fatalf("Bits=%d: bit %d = %d, want %d", bits, i, got, want)
+			}
+		}
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	data := make([]byte, 60)
+	for i := range data {
+		data[i] = byte(i * 37)
+	}
+	armored, fillBits := armor(data, 476)
+	parts := []string{armored[:30], armored[30:60], armored[60:]}
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{name: "InOrder", lines: []string{sentence(3, 1, "7", parts[0], 0), sentence(3, 2, "7", parts[1], 0), sentence(3, 3, "7", parts[2], fillBits)}},
+		{name: "OutOfOrder", lines: []string{sentence(3, 3, "7", parts[2], fillBits), sentence(3, 1, "7", parts[0], 0), sentence(3, 2, "7", parts[1], 0)}},
+		{
+			name: "Interleaved",
+			lines: []string{
+				sentence(3, 1, "7", parts[0], 0), sentence(2, 1, "8", "00", 0), sentence(3, 2, "7", parts[1], 0),
+				sentence(3, 3, "7", parts[2], fillBits),
+			},
+		},
+		{
+			name: "Restarted",
+			lines: []string{
+				sentence(3, 1, "7", "wwwww", 0), sentence(3, 2, "7", "wwwww", 0),
+				sentence(3, 1, "7", parts[0], 0), sentence(3, 2, "7", parts[1], 0), sentence(3, 3, "7", parts[2], fillBits),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assembler := NewAssembler()
+			var payloads []*Payload
+			for _, line := range tt.lines {
+				sentence, err := ParseSentence(line)
+				if err != nil {
+					t.Fatalf("ParseSentence() error = %v", err)
+				}
+				payload, err := assembler.Add(sentence)
+				if err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+				if payload != nil {
+					payloads = append(payloads, payload)
+				}
+			}
+			if len(payloads) != 1 {
+				t.Fatalf("Add() returned %d payloads, want 1", len(payloads))
+			}
+			want := append([]byte(nil), data...)
+			want[59] &= 0xf0
+			if payloads[0].Bits != 476 || !reflect.DeepEqual(payloads[0].Data, want) {
+				t.Errorf("payload = %x/%d, want %x/476", payloads[0].Data, payloads[0].Bits, want)
+			}
+		})
+	}
+}
+
+func TestAssembler_FillBitsBeforeLast(t *testing.T) {
+	sentence, _ := ParseSentence(sentence(2, 1, "3", "0000", 2))
+	if _, err := NewAssembler().Add(sentence); err != ErrFragment {
+		t.Errorf("Add() error = %v, want %v", err, ErrFragment)
+	}
+}
+
+func TestDecode_PositionReport(t *testing.T) {
+	messages := decodeAll(t, sampleType1)
+	got, ok := messages[0].(*PositionReport)
+	if !ok {
+		t.Fatalf("Decode() = %T, want *PositionReport", messages[0])
+	}
+	want := &PositionReport{
+		Header: Header{Type: 1, MMSI: 371798000},
+		Turn:   -127, Speed: 12.3, Accuracy: true, Lon: -74037230.0 / 600000, Lat: 29028980.0 / 600000,
+		Course: 224, Heading: 215, Second: 33, Radio: 34017,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+	if math.Abs(got.Lon+123.395383) > 1e-6 || math.Abs(got.Lat-48.381633) > 1e-6 {
+		t.Errorf("Decode() position = %f, %f, want -123.395383, 48.381633", got.Lon, got.Lat)
+	}
+}
+
+func TestDecode_StaticVoyage(t *testing.T) {
+	// The two fragments arrive in the wrong order
+	messages := decodeAll(t, sampleType5b, sampleType5a)
+	want := &StaticVoyage{
+		Header:   Header{Type: 5, MMSI: 351759000},
+		IMO:      9134270,
+		CallSign: "3FOF8", ShipName: "EVER DIADEM", ShipType: 70,
+		Dimensions: Dimensions{Bow: 225, Stern: 70, Port: 1, Starboard: 31},
+		EPFD:       1, Month: 5, Day: 15, Hour: 14, Draught: 12.2, Destination: "NEW YORK",
+	}
+	if !reflect.DeepEqual(messages[0], want) {
+		t.Errorf("Decode() = %+v, want %+v", messages[0], want)
+	}
+}
+
+func TestDecode_ClassBPosition(t *testing.T) {
+	messages := decodeAll(t, sampleType18)
+	got, ok := messages[0].(*ClassBPosition)
+	if !ok {
+		t.Fatalf("Decode() = %T, want *ClassBPosition", messages[0])
+	}
+	if got.MMSI != 338087471 || got.Speed != 0.1 || got.Course != 79.6 || got.Heading != 511 || got.Second != 49 {
+		t.Errorf("Decode() = %+v", got)
+	}
+	if math.Abs(got.Lon+74.072132) > 1e-6 || math.Abs(got.Lat-40.684540) > 1e-6 {
+		t.Errorf("Decode() position = %f, %f, want -74.072132, 40.684540", got.Lon, got.Lat)
+	}
+	if !got.CS || got.Display || !got.DSC || !got.Band || !got.Msg22 || got.Assigned || !got.RAIM {
+		t.Errorf("Decode() flags = %+v", got)
+	}
+}
+
+func TestDecode_StaticDataReport(t *testing.T) {
+	messages := decodeAll(t, sampleType24, sampleType24B)
+	want := []Message{
+		&StaticDataReport{Header: Header{Type: 24, MMSI: 271041815}, ShipName: "PROGUY"},
+		&StaticDataReport{
+			Header:     Header{Type: 24, MMSI: 271041815},
+			PartNumber: 1, ShipType: 60, Vendor: "1D0", Model: 12, Serial: 199796, CallSign: "TC6163",
+			Dimensions: Dimensions{Stern: 15, Starboard: 5},
+		},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("Decode() = %+v, want %+v", messages, want)
+	}
+}
+
+func TestDecode_Mothership(t *testing.T) {
+	// Part B of an auxiliary craft, MMSI 981234567, mothership 235000001
+	data := make([]byte, 21)
+	fields := []struct {
+		bits  int
+		value uint64
+	}{{6, 24}, {2, 0}, {30, 981234567}, {2, 1}, {8, 37}, {18, 0}, {4, 0}, {20, 0}, {42, 0}, {30, 235000001}, {6, 0}}
+	position := 0
+	for _, field := range fields {
+		for i := field.bits - 1; i >= 0; i-- {
+			data[position/8] |= byte(field.value>>i&1) << (7 - position%8)
+			position++
+		}
+	}
+	armored, fillBits := armor(data, 168)
+	message, err := Decode(&Payload{Data: data, Bits: 168})
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := message.(*StaticDataReport); got.MothershipMMSI != 235000001 || got.ShipType != 37 || got.Dimensions != (Dimensions{}) {
+		t.Errorf("Decode() = %+v", got)
+	}
+	if messages := decodeAll(t, sentence(1, 1, "", armored, fillBits)); !reflect.DeepEqual(messages[0], message) {
+		t.Errorf("Decode() from sentence = %+v, want %+v", messages[0], message)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		armored string
+		wantErr error
+	}{
+		{name: "Empty", armored: "", wantErr: ErrShort},
+		{name: "Header", armored: "15RTgt", wantErr: ErrShort},
+		{name: "ShortPosition", armored: "15RTgt0PAso;90TKcjM8h6g208C", wantErr: ErrShort},
+		{name: "ShortVoyage", armored: "55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8", wantErr: ErrShort},
+		{name: "Type24PartC", armored: "H42O55p00000000000000000000", wantErr: ErrUnsupported},
+		{name: "Type4", armored: "403OviQuMGCqWrRO9>E6fE700@GO", wantErr: ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := Dearmor(tt.armored, 0)
+			if err != nil {
+				t.Fatalf("Dearmor() error = %v", err)
+			}
+			if _, err := Decode(payload); err != tt.wantErr {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
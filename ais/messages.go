@@ -0,0 +1,372 @@
+package ais
+
+import (
+	"errors"
+
+	"github.com/pektezol/bitreader"
+)
+
+var (
+	// ErrUnsupported is returned when Decode doesn't know the message type.
+	ErrUnsupported = errors.New("ais: unsupported message type")
+	// ErrShort is returned when a payload is shorter than its message type.
+	ErrShort = errors.New("ais: payload too short for its message type")
+)
+
+// Message is a decoded AIS message, one of *PositionReport, *StaticVoyage,
+// *ClassBPosition or *StaticDataReport.
+type Message interface {
+	Common() Header
+}
+
+// Header is the start of every message.
+//
+// Type uint8			Message type, 1 to 27
+// Repeat uint8			Number of times the message was repeated, 3 means never again
+// MMSI uint32			Maritime Mobile Service Identity of the sender
+type Header struct {
+	Type   uint8
+	Repeat uint8
+	MMSI   uint32
+}
+
+// Common is a function that returns the header of the message.
+func (header Header) Common() Header {
+	return header
+}
+
+// PositionReport is a class A position report, message types 1, 2 and 3.
+//
+// Status uint8			Navigation status, 15 is not defined
+// Turn int8			Rate of turn indicator, -128 is not available
+// Speed float64		Speed over ground in knots, 102.3 is not available
+// Accuracy bool		Position accuracy better than 10 m
+// Lon float64			Longitude in degrees, 181 is not available
+// Lat float64			Latitude in degrees, 91 is not available
+// Course float64		Course over ground in degrees, 360 is not available
+// Heading uint16		True heading in degrees, 511 is not available
+// Second uint8			UTC second of the report, 60 and up are not available
+// Maneuver uint8		Special maneuver indicator
+// RAIM bool			Whether RAIM is in use
+// Radio uint32			Radio status
+type PositionReport struct {
+	Header
+	Status   uint8
+	Turn     int8
+	Speed    float64
+	Accuracy bool
+	Lon      float64
+	Lat      float64
+	Course   float64
+	Heading  uint16
+	Second   uint8
+	Maneuver uint8
+	RAIM     bool
+	Radio    uint32
+}
+
+// StaticVoyage is the static and voyage related data of a class A station, message type 5.
+//
+// Version uint8		AIS version of the station
+// IMO uint32			IMO ship number
+// CallSign string		Call sign
+// ShipName string		Name of the vessel
+// ShipType uint8		Type of ship and cargo
+// Dimensions			Dimensions to the reference point of the position
+// EPFD uint8			Type of position fixing device
+// Month uint8			ETA month, 0 is not available
+// Day uint8			ETA day, 0 is not available
+// Hour uint8			ETA hour, 24 is not available
+// Minute uint8			ETA minute, 60 is not available
+// Draught float64		Draught in meters
+// Destination string	Destination
+// DTE bool				Whether the data terminal is not ready
+type StaticVoyage struct {
+	Header
+	Version     uint8
+	IMO         uint32
+	CallSign    string
+	ShipName    string
+	ShipType    uint8
+	Dimensions  Dimensions
+	EPFD        uint8
+	Month       uint8
+	Day         uint8
+	Hour        uint8
+	Minute      uint8
+	Draught     float64
+	Destination string
+	DTE         bool
+}
+
+// ClassBPosition is a standard class B position report, message type 18.
+//
+// Speed float64		Speed over ground in knots, 102.3 is not available
+// Accuracy bool		Position accuracy better than 10 m
+// Lon float64			Longitude in degrees, 181 is not available
+// Lat float64			Latitude in degrees, 91 is not available
+// Course float64		Course over ground in degrees, 360 is not available
+// Heading uint16		True heading in degrees, 511 is not available
+// Second uint8			UTC second of the report, 60 and up are not available
+// CS bool				Whether the unit is a carrier sense unit
+// Display bool			Whether the unit has a display
+// DSC bool				Whether the unit has DSC
+// Band bool			Whether the unit can use the whole marine band
+// Msg22 bool			Whether the unit accepts channel management by message 22
+// Assigned bool		Whether the unit is in assigned mode
+// RAIM bool			Whether RAIM is in use
+// Radio uint32			Radio status
+type ClassBPosition struct {
+	Header
+	Speed    float64
+	Accuracy bool
+	Lon      float64
+	Lat      float64
+	Course   float64
+	Heading  uint16
+	Second   uint8
+	CS       bool
+	Display  bool
+	DSC      bool
+	Band     bool
+	Msg22    bool
+	Assigned bool
+	RAIM     bool
+	Radio    uint32
+}
+
+// StaticDataReport is the static data of a class B station, message type 24. It is sent
+// in two parts, part A with the name and part B with the rest, so only the fields
+// of PartNumber are set.
+//
+// PartNumber uint8			0 for part A, 1 for part B
+// ShipName string			Name of the vessel, part A
+// ShipType uint8			Type of ship and cargo, part B
+// Vendor string			Vendor ID, part B
+// Model uint8				Unit model code, part B
+// Serial uint32			Unit serial number, part B
+// CallSign string			Call sign, part B
+// Dimensions				Dimensions to the reference point of the position, part B
+// MothershipMMSI uint32	MMSI of the mothership instead of Dimensions, part B of auxiliary craft
+type StaticDataReport struct {
+	Header
+	PartNumber     uint8
+	ShipName       string
+	ShipType       uint8
+	Vendor         string
+	Model          uint8
+	Serial         uint32
+	CallSign       string
+	Dimensions     Dimensions
+	MothershipMMSI uint32
+}
+
+// Dimensions is the size of a vessel around the reference point of its position, in meters.
+//
+// Bow uint16			Distance to the bow
+// Stern uint16			Distance to the stern
+// Port uint8			Distance to port
+// Starboard uint8		Distance to starboard
+type Dimensions struct {
+	Bow       uint16
+	Stern     uint16
+	Port      uint8
+	Starboard uint8
+}
+
+// Decode is a function that decodes the message in a payload.
+//
+// Returns ErrUnsupported for other message types and ErrShort if the payload is too short.
+func Decode(payload *Payload) (Message, error) {
+	fields := &fields{reader: payload.Reader()}
+	header := Header{
+		Type:   uint8(fields.uint(6)),
+		Repeat: uint8(fields.uint(2)),
+		MMSI:   uint32(fields.uint(30)),
+	}
+	if fields.err != nil {
+		return nil, ErrShort
+	}
+	var message Message
+	switch header.Type {
+	case 1, 2, 3:
+		if payload.Bits < 168 {
+			return nil, ErrShort
+		}
+		message = fields.positionReport(header)
+	case 5:
+		// Some stations send the type 5 message 2 bits short, the last bits are optional
+		if payload.Bits < 420 {
+			return nil, ErrShort
+		}
+		message = fields.staticVoyage(header, payload.Bits)
+	case 18:
+		if payload.Bits < 168 {
+			return nil, ErrShort
+		}
+		message = fields.classBPosition(header)
+	case 24:
+		message = fields.staticDataReport(header)
+	default:
+		return nil, ErrUnsupported
+	}
+	if fields.err == ErrUnsupported {
+		return nil, ErrUnsupported
+	} else if fields.err != nil {
+		return nil, ErrShort
+	}
+	return message, nil
+}
+
+// fields reads the fields of a message and keeps the first error.
+//
+// reader *bitreader.Reader		The payload
+// err error					The first error
+type fields struct {
+	reader *bitreader.Reader
+	err    error
+}
+
+// uint is a private function that reads an unsigned field.
+func (fields *fields) uint(bits uint64) uint64 {
+	if fields.err != nil {
+		return 0
+	}
+	value, err := fields.reader.ReadBits(bits)
+	fields.err = err
+	return value
+}
+
+// int is a private function that reads a two's complement field.
+func (fields *fields) int(bits uint64) int32 {
+	if fields.err != nil {
+		return 0
+	}
+	value, err := bitreader.ReadN[int32](fields.reader, bits)
+	fields.err = err
+	return value
+}
+
+// bool is a private function that reads a one bit flag.
+func (fields *fields) bool() bool {
+	return fields.uint(1) == 1
+}
+
+// text is a private function that reads a field of count 6-bit characters.
+func (fields *fields) text(count uint64) string {
+	if fields.err != nil {
+		return ""
+	}
+	value, err := fields.reader.ReadPackedString(6, count, bitreader.AISAlphabet)
+	fields.err = err
+	return value
+}
+
+// dimensions is a private function that reads the 30 bits of a Dimensions.
+func (fields *fields) dimensions() Dimensions {
+	return Dimensions{
+		Bow:       uint16(fields.uint(9)),
+		Stern:     uint16(fields.uint(9)),
+		Port:      uint8(fields.uint(6)),
+		Starboard: uint8(fields.uint(6)),
+	}
+}
+
+// position is a private function that reads a longitude and latitude in 1/10000 minutes.
+func (fields *fields) position() (lon, lat float64) {
+	lon = float64(fields.int(28)) / 600000
+	lat = float64(fields.int(27)) / 600000
+	return lon, lat
+}
+
+// positionReport is a private function that reads the body of message types 1, 2 and 3.
+func (fields *fields) positionReport(header Header) *PositionReport {
+	message := &PositionReport{Header: header}
+	message.Status = uint8(fields.uint(4))
+	message.Turn = int8(fields.int(8))
+	message.Speed = float64(fields.uint(10)) / 10
+	message.Accuracy = fields.bool()
+	message.Lon, message.Lat = fields.position()
+	message.Course = float64(fields.uint(12)) / 10
+	message.Heading = uint16(fields.uint(9))
+	message.Second = uint8(fields.uint(6))
+	message.Maneuver = uint8(fields.uint(2))
+	fields.uint(3)
+	message.RAIM = fields.bool()
+	message.Radio = uint32(fields.uint(19))
+	return message
+}
+
+// staticVoyage is a private function that reads the body of message type 5.
+func (fields *fields) staticVoyage(header Header, bits uint64) *StaticVoyage {
+	message := &StaticVoyage{Header: header}
+	message.Version = uint8(fields.uint(2))
+	message.IMO = uint32(fields.uint(30))
+	message.CallSign = fields.text(7)
+	message.ShipName = fields.text(20)
+	message.ShipType = uint8(fields.uint(8))
+	message.Dimensions = fields.dimensions()
+	message.EPFD = uint8(fields.uint(4))
+	message.Month = uint8(fields.uint(4))
+	message.Day = uint8(fields.uint(5))
+	message.Hour = uint8(fields.uint(5))
+	message.Minute = uint8(fields.uint(6))
+	message.Draught = float64(fields.uint(8)) / 10
+	// The destination starts at bit 302 and is cut short with the message
+	chars := (bits - 302) / 6
+	if chars > 20 {
+		chars = 20
+	}
+	message.Destination = fields.text(chars)
+	if bits >= 423 {
+		message.DTE = fields.bool()
+	}
+	return message
+}
+
+// classBPosition is a private function that reads the body of message type 18.
+func (fields *fields) classBPosition(header Header) *ClassBPosition {
+	message := &ClassBPosition{Header: header}
+	fields.uint(8)
+	message.Speed = float64(fields.uint(10)) / 10
+	message.Accuracy = fields.bool()
+	message.Lon, message.Lat = fields.position()
+	message.Course = float64(fields.uint(12)) / 10
+	message.Heading = uint16(fields.uint(9))
+	message.Second = uint8(fields.uint(6))
+	fields.uint(2)
+	message.CS = fields.bool()
+	message.Display = fields.bool()
+	message.DSC = fields.bool()
+	message.Band = fields.bool()
+	message.Msg22 = fields.bool()
+	message.Assigned = fields.bool()
+	message.RAIM = fields.bool()
+	message.Radio = uint32(fields.uint(20))
+	return message
+}
+
+// staticDataReport is a private function that reads the body of message type 24.
+func (fields *fields) staticDataReport(header Header) *StaticDataReport {
+	message := &StaticDataReport{Header: header}
+	message.PartNumber = uint8(fields.uint(2))
+	switch message.PartNumber {
+	case 0:
+		message.ShipName = fields.text(20)
+	case 1:
+		message.ShipType = uint8(fields.uint(8))
+		message.Vendor = fields.text(3)
+		message.Model = uint8(fields.uint(4))
+		message.Serial = uint32(fields.uint(20))
+		message.CallSign = fields.text(7)
+		// Auxiliary craft, with an MMSI of the form 98XXXYYYY, give their mothership instead
+		if header.MMSI/10000000 == 98 {
+			message.MothershipMMSI = uint32(fields.uint(30))
+		} else {
+			message.Dimensions = fields.dimensions()
+		}
+	default:
+		fields.err = ErrUnsupported
+	}
+	return message
+}
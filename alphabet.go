@@ -0,0 +1,143 @@
+package bitreader
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// NoChar marks the codes of an Alphabet table that have no character.
+const NoChar rune = -1
+
+// Alphabet maps the codes of a packed character set to characters.
+//
+// Tables [][]rune				Characters by code, Tables[0] is used at the start of a string
+// Shifts map[uint64]int		Codes that switch to another table for the rest of the string
+// Escapes map[uint64]int		Codes that switch to another table for the next character only
+// Trim string					Padding characters trimmed from the end of the string
+type Alphabet struct {
+	Tables  [][]rune
+	Shifts  map[uint64]int
+	Escapes map[uint64]int
+	Trim    string
+}
+
+// AISAlphabet is the 6-bit ASCII of AIS text fields, read with a big-endian Reader.
+// Trailing '@' padding and spaces are trimmed.
+var AISAlphabet = &Alphabet{
+	Tables: [][]rune{[]rune("@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_ !\"#$%&'()*+,-./0123456789:;<=>?")},
+	Trim:   "@ ",
+}
+
+// GSMAlphabet is the GSM 03.38 7-bit default alphabet with its extension table,
+// reached through the escape code 0x1B. SMS packs the septets LSB-first, so read
+// them with a little-endian Reader. An escaped code without an extension character
+// is shown as the default character, as the standard asks.
+var GSMAlphabet = &Alphabet{
+	Tables: [][]rune{
+		[]rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\ufffdÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+			"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"),
+		gsmExtension(),
+	},
+	Escapes: map[uint64]int{0x1b: 1},
+}
+
+// BaudotAlphabet is the ITA2 Baudot code, with the letters and figures tables selected by
+// the LTRS (0x1F) and FIGS (0x1B) codes. Codes are numbered with bit 1 as the least
+// significant bit, which is sent first, so read them with a little-endian Reader.
+// The figures left to national use have no character.
+var BaudotAlphabet = &Alphabet{
+	Tables: [][]rune{
+		{0, 'E', '\n', 'A', ' ', 'S', 'I', 'U', '\r', 'D', 'R', 'J', 'N', 'F', 'C', 'K',
+			'T', 'Z', 'L', 'W', 'H', 'Y', 'P', 'Q', 'O', 'B', 'G', NoChar, 'M', 'X', 'V', NoChar},
+		{0, '3', '\n', '-', ' ', '\'', '8', '7', '\r', 0x05, '4', 0x07, ',', NoChar, ':', '(',
+			'5', '+', ')', '2', NoChar, '6', '0', '1', '9', '?', NoChar, NoChar, '.', '/', '=', NoChar},
+	},
+	Shifts: map[uint64]int{0x1f: 0, 0x1b: 1},
+}
+
+// ReadPackedString is a function that reads count characters of charBits bits each and
+// maps them to text with the Alphabet. Shift and escape codes don't count as characters
+// of the result but do count towards count, since count is the number of codes in the stream.
+// Codes without a character become U+FFFD.
+//
+// Returns an error if charBits is not between 1 and 8 or there are not enough remaining bits.
+func (reader *Reader) ReadPackedString(charBits, count uint64, alphabet *Alphabet) (string, error) {
+	const op = "ReadPackedString(charBits, count, alphabet)"
+	if charBits < 1 || charBits > 8 {
+		return "", errors.New(op + " ERROR: Character bits should be between 1 and 8")
+	}
+	if len(alphabet.Tables) == 0 {
+		return "", errors.New(op + " ERROR: Alphabet has no tables")
+	}
+	if err := reader.checkString(op, count); err != nil {
+		return "", err
+	}
+	if err := reader.checkBits(op, charBits*count); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	table := 0
+	escape := -1
+	for i := uint64(0); i < count; i++ {
+		code, err := reader.ReadBits(charBits)
+		if err != nil {
+			return "", err
+		}
+		if escape >= 0 {
+			char := alphabet.lookup(escape, code)
+			escape = -1
+			if char != NoChar {
+				out.WriteRune(char)
+				continue
+			}
+		} else if next, ok := alphabet.Escapes[code]; ok {
+			escape = next
+			continue
+		}
+		if next, ok := alphabet.Shifts[code]; ok {
+			table = next
+			continue
+		}
+		char := alphabet.lookup(table, code)
+		if char == NoChar {
+			char = utf8.RuneError
+		}
+		out.WriteRune(char)
+	}
+	return strings.TrimRight(out.String(), alphabet.Trim), nil
+}
+
+// TryReadPackedString is a wrapper function that returns count packed characters as text.
+//
+// Returns string. Panics on overflow.
+func (reader *Reader) TryReadPackedString(charBits, count uint64, alphabet *Alphabet) string {
+	text, err := reader.ReadPackedString(charBits, count, alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return text
+}
+
+// lookup is a private function that returns the character of code in a table, or NoChar.
+func (alphabet *Alphabet) lookup(table int, code uint64) rune {
+	if table < 0 || table >= len(alphabet.Tables) || code >= uint64(len(alphabet.Tables[table])) {
+		return NoChar
+	}
+	return alphabet.Tables[table][code]
+}
+
+// gsmExtension is a private function that builds the GSM 03.38 extension table.
+func gsmExtension() []rune {
+	table := make([]rune, 128)
+	for i := range table {
+		table[i] = NoChar
+	}
+	for code, char := range map[uint64]rune{
+		0x0a: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2f: '\\',
+		0x3c: '[', 0x3d: '~', 0x3e: ']', 0x40: '|', 0x65: '€',
+	} {
+		table[code] = char
+	}
+	return table
+}
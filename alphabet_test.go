@@ -0,0 +1,121 @@
+package bitreader
+
+import (
+	"testing"
+)
+
+// packCodes packs codes of bits bits each, LSB-first for little-endian and MSB-first for big-endian.
+func packCodes(codes []uint64, bits uint, le bool) []byte {
+	out := make([]byte, (uint(len(codes))*bits+7)/8)
+	position := uint(0)
+	for _, code := range codes {
+		for i := uint(0); i < bits; i++ {
+			var bit uint64
+			if le {
+				bit = code >> i & 1
+			} else {
+				bit = code >> (bits - 1 - i) & 1
+			}
+			if le {
+				out[position/8] |= byte(bit) << (position % 8)
+			} else {
+				out[position/8] |= byte(bit) << (7 - position%8)
+			}
+			position++
+		}
+	}
+	return out
+}
+
+func TestReader_ReadPackedString(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   []byte
+		le       bool
+		charBits uint64
+		count    uint64
+		alphabet *Alphabet
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "AIS",
+			stream:   packCodes([]uint64{0x13, 0x14, 0x01, 0x12, 0x20, 0x37, 0x00, 0x00, 0x00}, 6, false),
+			charBits: 6, count: 9, alphabet: AISAlphabet,
+			want: "STAR 7",
+		},
+		{
+			name:     "AISPaddingInside",
+			stream:   packCodes([]uint64{0x01, 0x00, 0x02, 0x20, 0x00}, 6, false),
+			charBits: 6, count: 5, alphabet: AISAlphabet,
+			want: "A@B",
+		},
+		{
+			// The packed PDU example for "hellohello" from GSM 03.38
+			name:     "GSM",
+			stream:   []byte{0xe8, 0x32, 0x9b, 0xfd, 0x46, 0x97, 0xd9, 0xec, 0x37},
+			le:       true,
+			charBits: 7, count: 10, alphabet: GSMAlphabet,
+			want: "hellohello",
+		},
+		{
+			name:     "GSMExtension",
+			stream:   packCodes([]uint64{0x1b, 0x65, 0x35, 0x1b, 0x28, 0x1b, 0x29, 0x00, 0x10}, 7, true),
+			le:       true,
+			charBits: 7, count: 9, alphabet: GSMAlphabet,
+			want: "€5{}@Δ",
+		},
+		{
+			name:     "GSMUnknownEscape",
+			stream:   packCodes([]uint64{0x1b, 0x41, 0x1b, 0x1b}, 7, true),
+			le:       true,
+			charBits: 7, count: 4, alphabet: GSMAlphabet,
+			want: "A�",
+		},
+		{
+			name:     "Baudot",
+			stream:   packCodes([]uint64{0x0a, 0x15, 0x04, 0x1b, 0x17, 0x13, 0x1c, 0x1f, 0x03, 0x1b, 0x0d}, 5, true),
+			le:       true,
+			charBits: 5, count: 11, alphabet: BaudotAlphabet,
+			want: "RY 12.A�",
+		},
+		{
+			name:     "BaudotBigEndian",
+			stream:   packCodes([]uint64{0x10, 0x01, 0x05, 0x10}, 5, false),
+			charBits: 5, count: 4, alphabet: BaudotAlphabet,
+			want: "TEST",
+		},
+		{name: "Empty", stream: []byte{}, charBits: 6, count: 0, alphabet: AISAlphabet, want: ""},
+		{name: "Short", stream: []byte{0xff}, charBits: 6, count: 2, alphabet: AISAlphabet, wantErr: true},
+		{name: "TooWide", stream: []byte{0xff, 0xff}, charBits: 9, count: 1, alphabet: AISAlphabet, wantErr: true},
+		{name: "NoTables", stream: []byte{0xff}, charBits: 4, count: 1, alphabet: &Alphabet{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, tt.le).ReadPackedString(tt.charBits, tt.count, tt.alphabet)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadPackedString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ReadPackedString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlphabets(t *testing.T) {
+	if len(AISAlphabet.Tables[0]) != 64 {
+		t.Errorf("AISAlphabet has %d codes, want 64", len(AISAlphabet.Tables[0]))
+	}
+	for i, table := range GSMAlphabet.Tables {
+		if len(table) != 128 {
+			t.Errorf("GSMAlphabet table %d has %d codes, want 128", i, len(table))
+		}
+	}
+	for i, table := range BaudotAlphabet.Tables {
+		if len(table) != 32 {
+			t.Errorf("BaudotAlphabet table %d has %d codes, want 32", i, len(table))
+		}
+	}
+}
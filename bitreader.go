@@ -8,6 +8,16 @@ import (
 	"math"
 )
 
+// maxEmptyReads is how many reads in a row may return no data and no error
+// before the stream is considered stuck, like in bufio.
+const maxEmptyReads = 100
+
+// skipChunk is how many bytes SkipBits reads from the stream at a time.
+const skipChunk = 512
+
+// peekSize is the most bytes PeekBits can need from the lookahead.
+const peekSize = 8
+
 // Reader is the main structure of our Reader.
 // Whenever index == 0, we need to read a new byte from stream into currentByte
 //
@@ -15,11 +25,31 @@ import (
 // index uint8			The current index into the byte [0-7]
 // currentByte byte		The byte we're currently reading from
 // le bool 				Whether to read in little-endian order or not
+// lookahead []byte		Bytes read from stream by PeekBits but not consumed yet
+// position uint64		The number of bits consumed so far
+// limits Limits		The allocation and consumption limits to enforce
+// checksums []*Checksum	The Checksums that see the bytes consumed
+// marks []*Mark			The live Marks, oldest first
+// replay []byte			Bytes read from the stream since the oldest Mark
+// replayStart uint64		Number of bytes read from the stream before replay[0]
+// source bytes.Reader		The stream of NewReaderFromBytes and ResetBytes, kept to be reused
+// scratch []byte			Buffer the stream is read into, kept to be reused
+// peekBuffer []byte		Buffer the lookahead is kept in, kept to be reused
 type Reader struct {
 	stream       io.Reader
 	index        uint8
 	currentByte  byte
 	littleEndian bool
+	lookahead    []byte
+	position     uint64
+	limits       Limits
+	checksums    []*Checksum
+	marks        []*Mark
+	replay       []byte
+	replayStart  uint64
+	source       bytes.Reader
+	scratch      []byte
+	peekBuffer   []byte
 }
 
 // NewReader is the main constructor that creates the Reader object
@@ -36,14 +66,35 @@ func NewReader(stream io.Reader, littleEndian bool) *Reader {
 // NewReaderFromBytes is the main constructor that creates the Reader object
 // with stream byte data and little-endian state.
 func NewReaderFromBytes(stream []byte, littleEndian bool) *Reader {
-	return &Reader{
-		stream:       bytes.NewReader(stream),
-		index:        0,
-		currentByte:  0,
+	reader := &Reader{}
+	reader.ResetBytes(stream, littleEndian)
+	return reader
+}
+
+// Reset is a function that makes the Reader read from a new stream with the given
+// little-endian state, as if it was just created by NewReader. Its Limits are kept,
+// and so are its buffers, so that a Reader from a sync.Pool doesn't allocate for every
+// stream. Checksums and Marks of the previous stream are dropped.
+func (reader *Reader) Reset(stream io.Reader, littleEndian bool) {
+	*reader = Reader{
+		stream:       stream,
 		littleEndian: littleEndian,
+		limits:       reader.limits,
+		replay:       reader.replay[:0],
+		scratch:      reader.scratch,
+		peekBuffer:   reader.peekBuffer,
 	}
 }
 
+// ResetBytes is a function that makes the Reader read from a new byte slice with the
+// given little-endian state, as if it was just created by NewReaderFromBytes. Like Reset,
+// it keeps the Limits and the buffers of the Reader.
+func (reader *Reader) ResetBytes(stream []byte, littleEndian bool) {
+	reader.Reset(nil, littleEndian)
+	reader.source.Reset(stream)
+	reader.stream = &reader.source
+}
+
 // Fork is a function that copies the original reader into a new reader
 // with all of its current values.
 func (reader *Reader) Fork() (*Reader, error) {
@@ -53,12 +104,20 @@ func (reader *Reader) Fork() (*Reader, error) {
 	if err != nil {
 		return nil, err // Will only happen when there's no memory, lol
 	}
+	// Bytes that were peeked but not consumed still belong in front of the stream,
+	// copied so that the streams don't share the peek buffer
+	if len(reader.lookahead) > 0 {
+		byteStream = append(append(make([]byte, 0, len(reader.lookahead)+len(byteStream)), reader.lookahead...), byteStream...)
+	}
+	reader.lookahead = nil
 	reader.stream = bytes.NewReader(byteStream)
 	return &Reader{
 		stream:       bytes.NewReader(byteStream),
 		index:        uint8(originalIndex),
 		currentByte:  originalCurrentByte,
 		littleEndian: reader.littleEndian,
+		position:     reader.position,
+		limits:       reader.limits,
 	}, nil
 }
 
@@ -245,35 +304,9 @@ func (reader *Reader) TryReadStringLength(length uint64) string {
 //
 // Returns []byte. Panics on overflow.
 func (reader *Reader) TryReadBitsToSlice(bits uint64) []byte {
-	bytes := (bits / 8)
-	if bits%8 != 0 {
-		bytes++
-	}
-	out := make([]byte, bytes)
-	var i uint64
-	for i = 0; i < bytes; i++ {
-		if i == bytes-1 { // Not enough to fill a whole byte
-			if bits%8 != 0 {
-				val, err := reader.ReadBits(bits % 8)
-				if err != nil {
-					panic(err)
-				}
-				out[i] = byte(val)
-			} else {
-				val, err := reader.ReadBytes(1)
-				if err != nil {
-					panic(err)
-				}
-				out[i] = byte(val)
-			}
-			break
-		} else {
-			val, err := reader.ReadBytes(1)
-			if err != nil {
-				panic(err)
-			}
-			out[i] = byte(val)
-		}
+	out, err := reader.ReadBitsToSlice(bits)
+	if err != nil {
+		panic(err)
 	}
 	return out
 }
@@ -283,14 +316,9 @@ func (reader *Reader) TryReadBitsToSlice(bits uint64) []byte {
 //
 // Returns []byte. Panics on overflow.
 func (reader *Reader) TryReadBytesToSlice(bytes uint64) []byte {
-	var out []byte
-	var i uint64
-	for i = 0; i < bytes; i++ {
-		val, err := reader.ReadBytes(1)
-		if err != nil {
-			panic(err)
-		}
-		out = append(out, byte(val))
+	out, err := reader.ReadBytesToSlice(bytes)
+	if err != nil {
+		panic(err)
 	}
 	return out
 }
@@ -329,6 +357,9 @@ func (reader *Reader) ReadBits(bits uint64) (uint64, error) {
 	if bits < 1 || bits > 64 {
 		return 0, errors.New("ReadBits(bits) ERROR: Bits number should be between 1 and 64")
 	}
+	if err := reader.checkBits("ReadBits(bits)", bits); err != nil {
+		return 0, err
+	}
 	var val uint64
 	var i uint64
 	for i = 0; i < bits; i++ {
@@ -377,6 +408,9 @@ func (reader *Reader) ReadString() (string, error) {
 		if value == 0 {
 			break
 		}
+		if err := reader.checkString("ReadString()", uint64(len(out))+1); err != nil {
+			return string(out), err
+		}
 		out = append(out, byte(value))
 	}
 	return string(out), nil
@@ -389,6 +423,9 @@ func (reader *Reader) ReadString() (string, error) {
 //
 // Returns an error if there are no remaining bits.
 func (reader *Reader) ReadStringLength(length uint64) (string, error) {
+	if err := reader.checkString("ReadStringLength(length)", length); err != nil {
+		return "", err
+	}
 	var out []byte
 	var i uint64
 	for i = 0; i < length; i++ {
@@ -397,7 +434,9 @@ func (reader *Reader) ReadStringLength(length uint64) (string, error) {
 			return string(out), err
 		}
 		if value == 0 {
-			reader.SkipBytes(length - 1 - i)
+			if err := reader.SkipBytes(length - 1 - i); err != nil {
+				return string(out), err
+			}
 			break
 		}
 		out = append(out, byte(value))
@@ -414,6 +453,12 @@ func (reader *Reader) ReadBitsToSlice(bits uint64) ([]byte, error) {
 	if bits%8 != 0 {
 		bytes++
 	}
+	if err := reader.checkSlice("ReadBitsToSlice(bits)", bytes); err != nil {
+		return nil, err
+	}
+	if err := reader.checkBits("ReadBitsToSlice(bits)", bits); err != nil {
+		return nil, err
+	}
 	out := make([]byte, bytes)
 	var i uint64
 	for i = 0; i < bytes; i++ {
@@ -448,6 +493,15 @@ func (reader *Reader) ReadBitsToSlice(bits uint64) ([]byte, error) {
 //
 // Returns an error if there are no remaining bytes.
 func (reader *Reader) ReadBytesToSlice(bytes uint64) ([]byte, error) {
+	if err := reader.checkSlice("ReadBytesToSlice(bytes)", bytes); err != nil {
+		return nil, err
+	}
+	if bytes > math.MaxUint64/8 {
+		return nil, errors.New("ReadBytesToSlice(bytes) ERROR: Bytes number is too large")
+	}
+	if err := reader.checkBits("ReadBytesToSlice(bytes)", bytes*8); err != nil {
+		return nil, err
+	}
 	var out []byte
 	var i uint64
 	for i = 0; i < bytes; i++ {
@@ -462,24 +516,39 @@ func (reader *Reader) ReadBytesToSlice(bytes uint64) ([]byte, error) {
 
 // SkipBits is a function that increases Reader index
 // based on given input bits number.
+// Skipping is equivalent to reading the bits: from an unaligned index the rest
+// of the current byte is consumed first, then whole bytes are skipped from the
+// stream and the remaining bits are read from the next byte.
 //
 // Returns an error if there are no remaining bits.
 func (reader *Reader) SkipBits(bits uint64) error {
-	// Read as many raw bytes as we can
-	bytes := bits / 8
-	if bytes > 0 {
-		buf := make([]byte, bytes)
-		_, err := reader.stream.Read(buf)
+	if err := reader.checkBits("SkipBits(bits)", bits); err != nil {
+		return err
+	}
+	// Finish the current byte so that whole bytes can be skipped
+	for ; bits > 0 && reader.index != 0; bits-- {
+		if _, err := reader.readBit(); err != nil {
+			return err
+		}
+	}
+	// Skip as many raw bytes as we can, without keeping them around
+	for bits >= 8 {
+		chunk := bits / 8
+		if chunk > skipChunk {
+			chunk = skipChunk
+		}
+		buffer := reader.scratchBuffer(int(chunk))
+		n, err := reader.readFull(buffer)
+		reader.commit(buffer[:n])
+		reader.position += uint64(n) * 8
+		bits -= uint64(n) * 8
 		if err != nil {
 			return err
 		}
-		// The final read byte should be the new current byte
-		reader.currentByte = buf[bytes-1]
 	}
 	// Read the extra bits
-	for i := bytes * 8; i < bits; i++ {
-		_, err := reader.readBit()
-		if err != nil {
+	for ; bits > 0; bits-- {
+		if _, err := reader.readBit(); err != nil {
 			return err
 		}
 	}
@@ -498,20 +567,115 @@ func (reader *Reader) SkipBytes(bytes uint64) error {
 	return nil
 }
 
+// PeekBits is a function that returns the value of the specified amount of bits
+// from the parameter without consuming them. It can peek up to 64 bits. Returns the
+// value in type uint64, in the same bit order ReadBits would return it.
+//
+// Returns io.EOF if there are no remaining bits, and io.ErrUnexpectedEOF
+// if there are fewer remaining bits than requested.
+func (reader *Reader) PeekBits(bits uint64) (uint64, error) {
+	if bits < 1 || bits > 64 {
+		return 0, errors.New("PeekBits(bits) ERROR: Bits number should be between 1 and 64")
+	}
+	// Bits still left in the current byte
+	var available uint64
+	if reader.index != 0 {
+		available = uint64(8 - reader.index)
+	}
+	if bits > available {
+		needed := int((bits - available + 7) / 8)
+		if have := len(reader.lookahead); have < needed {
+			// Read straight into the lookahead, moved to the front of the peek buffer
+			// if it has no room, which holds any peek
+			if cap(reader.lookahead) < needed {
+				if cap(reader.peekBuffer) < peekSize {
+					reader.peekBuffer = make([]byte, 0, peekSize)
+				}
+				reader.lookahead = append(reader.peekBuffer[:0], reader.lookahead...)
+			}
+			n, err := reader.readStreamFull(reader.lookahead[have:needed])
+			reader.lookahead = reader.lookahead[:have+n]
+			if len(reader.lookahead) == 0 {
+				reader.lookahead = nil
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				if available == 0 && len(reader.lookahead) == 0 {
+					return 0, io.EOF
+				}
+				return 0, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	var val uint64
+	var i uint64
+	index := reader.index
+	currentByte := reader.currentByte
+	next := 0
+	for i = 0; i < bits; i++ {
+		if index == 0 {
+			currentByte = reader.lookahead[next]
+			next++
+		}
+		var bit uint64
+		if reader.littleEndian {
+			bit = uint64(currentByte>>index) & 1
+			val |= bit << i
+		} else {
+			bit = uint64(currentByte>>(7-index)) & 1
+			val |= bit << (bits - 1 - i)
+		}
+		index = (index + 1) % 8
+	}
+	return val, nil
+}
+
+// AlignToByte is a function that skips the remaining bits of the current byte,
+// so that the next read starts at a byte boundary. It does nothing if the
+// Reader is already aligned.
+//
+// Returns an error if there are no remaining bits.
+func (reader *Reader) AlignToByte() error {
+	if reader.index == 0 {
+		return nil
+	}
+	return reader.SkipBits(uint64(8 - reader.index))
+}
+
+// BitPosition is a function that returns the number of bits
+// consumed from the stream since the Reader was created.
+func (reader *Reader) BitPosition() uint64 {
+	return reader.position
+}
+
+// LittleEndian is a function that returns whether the Reader
+// reads in little-endian (LSB-first) order or not.
+func (reader *Reader) LittleEndian() bool {
+	return reader.littleEndian
+}
+
 // ReadRemainingBits is a function that reads the total amount of remaining bits in the stream.
 // It first forks the original reader to check this count, so that it does not interfere with the original stream.
+// The count is of the stream and not of what the Limits allow, so the fork counts without
+// them; the Limits of the original reader are left as they are.
 //
-// Returns an error if there are no remaining bits.
+// Returns an error if the stream fails with anything other than io.EOF.
 func (reader *Reader) ReadRemainingBits() (uint64, error) {
 	newReader, err := reader.Fork()
 	if err != nil {
 		return 0, err
 	}
+	newReader.limits = Limits{}
 	var bits uint64 = 0
 	for {
 		err := newReader.SkipBits(1)
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			break // EOF
+			return bits, err
 		}
 		bits++
 	}
@@ -521,16 +685,18 @@ func (reader *Reader) ReadRemainingBits() (uint64, error) {
 // readBit is a private function that reads a single bit from the stream.
 // This is the main function that makes us read stream data.
 func (reader *Reader) readBit() (uint8, error) {
+	if err := reader.checkBits("readBit()", 1); err != nil {
+		return 0, err
+	}
 	if reader.index == 0 {
 		// Read a byte from stream into currentByte
-		buffer := make([]byte, 1)
-		// We are not checking for the n return value from stream.Read, because we are only reading 1 byte at a time.
-		// Meaning if an EOF happens with a 1 byte read, we dont have any extra byte reading anyways.
-		_, err := reader.stream.Read(buffer)
+		buffer := reader.scratchBuffer(1)
+		_, err := reader.readFull(buffer)
 		if err != nil {
 			return 0, err
 		}
 		reader.currentByte = buffer[0]
+		reader.commit(buffer)
 	}
 	var val bool
 	if reader.littleEndian {
@@ -539,9 +705,129 @@ func (reader *Reader) readBit() (uint8, error) {
 		val = (reader.currentByte & (1 << (7 - reader.index))) != 0
 	}
 	reader.index = (reader.index + 1) % 8
+	reader.position++
 	if val {
 		return 1, nil
 	} else {
 		return 0, nil
 	}
 }
+
+// scratchBuffer is a private function that returns a buffer of size bytes to read the
+// stream into. The buffer is kept by the Reader so that reads don't allocate, and the next
+// call overwrites it.
+func (reader *Reader) scratchBuffer(size int) []byte {
+	if cap(reader.scratch) < size {
+		reader.scratch = make([]byte, size)
+	}
+	return reader.scratch[:size]
+}
+
+// unread is a private function that gives bytes back in front of the lookahead, so that
+// they are read again. The lookahead is kept in the peek buffer, which grows to fit.
+func (reader *Reader) unread(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	size := len(data) + len(reader.lookahead)
+	buffer := reader.peekBuffer
+	if cap(buffer) < size {
+		buffer = make([]byte, size, size+peekSize)
+	}
+	buffer = buffer[:size]
+	copy(buffer[len(data):], reader.lookahead)
+	copy(buffer, data)
+	reader.peekBuffer = buffer[:0]
+	reader.lookahead = buffer
+}
+
+// readFull is a private function that reads exactly len(buffer) bytes, taking
+// any peeked bytes from lookahead before reading from the stream.
+//
+// Returns io.EOF if no bytes were read, io.ErrUnexpectedEOF if only some were.
+func (reader *Reader) readFull(buffer []byte) (int, error) {
+	n := copy(buffer, reader.lookahead)
+	reader.lookahead = reader.lookahead[n:]
+	if len(reader.lookahead) == 0 {
+		reader.lookahead = nil
+	}
+	m, err := reader.readStreamFull(buffer[n:])
+	if err == io.EOF && n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n + m, err
+}
+
+// commit is a private function that takes note of bytes just read from the stream, which
+// will be consumed. They are kept for rewinding while there are Marks, and given to the
+// attached Checksums.
+func (reader *Reader) commit(data []byte) {
+	if len(reader.marks) > 0 {
+		reader.replay = append(reader.replay, data...)
+	}
+	for _, checksum := range reader.checksums {
+		for _, b := range data {
+			// A new byte is only read once the held one was consumed
+			if checksum.holding {
+				checksum.feed(checksum.held, checksum.skip, 8)
+				checksum.skip = 0
+			}
+			checksum.held, checksum.holding = b, true
+		}
+	}
+}
+
+// readSome is a private function that reads at least one byte and at most len(buffer),
+// taking peeked bytes from lookahead first. Unlike readFull it doesn't wait for the stream
+// to fill the whole buffer, so scanning a live stream doesn't stall on data that isn't needed.
+//
+// Returns io.EOF if the stream is over, or io.ErrNoProgress if it keeps returning no data and no error.
+func (reader *Reader) readSome(buffer []byte) (int, error) {
+	if len(reader.lookahead) > 0 {
+		n := copy(buffer, reader.lookahead)
+		reader.lookahead = reader.lookahead[n:]
+		if len(reader.lookahead) == 0 {
+			reader.lookahead = nil
+		}
+		return n, nil
+	}
+	for empty := 0; empty < maxEmptyReads; empty++ {
+		n, err := reader.stream.Read(buffer)
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+	return 0, io.ErrNoProgress
+}
+
+// readStreamFull is a private function that reads exactly len(buffer) bytes from the stream,
+// like io.ReadFull, but gives up with io.ErrNoProgress if the stream keeps returning
+// no data and no error.
+//
+// Returns io.EOF if no bytes were read, io.ErrUnexpectedEOF if only some were.
+func (reader *Reader) readStreamFull(buffer []byte) (int, error) {
+	var n, empty int
+	for n < len(buffer) {
+		m, err := reader.stream.Read(buffer[n:])
+		n += m
+		if n == len(buffer) {
+			// Data returned together with an error is still valid data
+			break
+		}
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		if m > 0 {
+			empty = 0
+			continue
+		}
+		empty++
+		if empty >= maxEmptyReads {
+			return n, io.ErrNoProgress
+		}
+	}
+	return n, nil
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"sync"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNewReader(t *testing.T) {
@@ -78,6 +80,7 @@ func TestNewReaderFromBytes(t *testing.T) {
 				index:        0,
 				currentByte:  0,
 				littleEndian: true,
+				source:       *bytes.NewReader([]byte{0x01, 0x02, 0x03}),
 			},
 		},
 		{
@@ -91,6 +94,7 @@ func TestNewReaderFromBytes(t *testing.T) {
 				index:        0,
 				currentByte:  0,
 				littleEndian: false,
+				source:       *bytes.NewReader([]byte{0x01, 0x02, 0x03}),
 			},
 		},
 	}
@@ -1206,6 +1210,20 @@ func TestReader_ReadStringLength(t *testing.T) {
 			},
 			want: "World",
 		},
+		{
+			name: "ReadStringLengthNullHitShortBE",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{'W', 'o', 'r', 'l', 'd', 0, '!'}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			args: args{
+				length: 16,
+			},
+			want:    "World",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1369,10 +1387,27 @@ func TestReader_ReadRemainingBits(t *testing.T) {
 			want:    16,
 			wantErr: false,
 		},
+		{
+			name: "ReadRemainingBits past MaxTotalBits",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0x11, 0x22, 0x33}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+				position:     4,
+				limits:       Limits{MaxTotalBits: 8},
+			},
+			want:    24,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.reader.Limits()
 			got, err := tt.reader.ReadRemainingBits()
+			if tt.reader.Limits() != limits {
+				t.Errorf("Reader.Limits() = %+v after ReadRemainingBits(), want %+v", tt.reader.Limits(), limits)
+			}
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Reader.ReadRemainingBits() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1383,3 +1418,390 @@ func TestReader_ReadRemainingBits(t *testing.T) {
 		})
 	}
 }
+
+func TestReader_PeekBits(t *testing.T) {
+	type args struct {
+		bits uint64
+	}
+	tests := []struct {
+		name    string
+		reader  *Reader
+		args    args
+		want    uint64
+		wantErr error
+	}{
+		{
+			name: "PeekBitsLE",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0b11110000, 0b01010101}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: true,
+			},
+			args: args{
+				bits: 12,
+			},
+			want: 0b010111110000,
+		},
+		{
+			name: "PeekBitsBE",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0b11110000, 0b01010101}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			args: args{
+				bits: 12,
+			},
+			want: 0b111100000101,
+		},
+		{
+			name: "PeekBitsUnexpectedEOF",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0b11110000}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			args: args{
+				bits: 12,
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "PeekBitsEOF",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			args: args{
+				bits: 1,
+			},
+			wantErr: io.EOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.reader.PeekBits(tt.args.bits)
+			if err != tt.wantErr {
+				t.Errorf("Reader.PeekBits() error = %+v, wantErr %+v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Reader.PeekBits() = %+v, want %+v", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			// Peeking must not consume anything
+			read, err := tt.reader.ReadBits(tt.args.bits)
+			if err != nil || read != tt.want {
+				t.Errorf("Reader.ReadBits() after PeekBits() = %+v, %+v, want %+v", read, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_AlignToByte(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader *Reader
+		skip   uint64
+		want   uint64
+	}{
+		{
+			name: "Aligned",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0x11, 0x22}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			skip: 0,
+			want: 0x11,
+		},
+		{
+			name: "Unaligned",
+			reader: &Reader{
+				stream:       bytes.NewReader([]byte{0x11, 0x22}),
+				index:        0,
+				currentByte:  0,
+				littleEndian: false,
+			},
+			skip: 3,
+			want: 0x22,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.skip > 0 {
+				tt.reader.SkipBits(tt.skip)
+			}
+			if err := tt.reader.AlignToByte(); err != nil {
+				t.Errorf("Reader.AlignToByte() error = %v", err)
+				return
+			}
+			if got := tt.reader.TryReadUInt8(); uint64(got) != tt.want {
+				t.Errorf("Reader.AlignToByte() next byte = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_BitPosition(t *testing.T) {
+	reader := NewReaderFromBytes([]byte{0x11, 0x22, 0x33, 0x44}, true)
+	reader.TryReadBits(3)
+	reader.SkipBits(13)
+	reader.TryReadBool()
+	if got := reader.BitPosition(); got != 17 {
+		t.Errorf("Reader.BitPosition() = %v, want %v", got, 17)
+	}
+	fork, _ := reader.Fork()
+	if got := fork.BitPosition(); got != 17 {
+		t.Errorf("Reader.Fork().BitPosition() = %v, want %v", got, 17)
+	}
+}
+
+// emptyReader returns no data and no error a few times before every read.
+type emptyReader struct {
+	stream io.Reader
+	empty  int
+	count  int
+}
+
+func (reader *emptyReader) Read(p []byte) (int, error) {
+	if reader.count < reader.empty {
+		reader.count++
+		return 0, nil
+	}
+	reader.count = 0
+	return reader.stream.Read(p)
+}
+
+func TestReader_ShortReads(t *testing.T) {
+	data := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03, 0x04, 0x05}
+	tests := []struct {
+		name    string
+		stream  io.Reader
+		wantErr error
+	}{
+		{name: "OneByteReader", stream: iotest.OneByteReader(bytes.NewReader(data))},
+		{name: "HalfReader", stream: iotest.HalfReader(bytes.NewReader(data))},
+		{name: "DataErrReader", stream: iotest.DataErrReader(bytes.NewReader(data))},
+		{name: "EmptyReads", stream: &emptyReader{stream: bytes.NewReader(data), empty: 3}},
+		{name: "TimeoutReader", stream: iotest.TimeoutReader(bytes.NewReader(data)), wantErr: iotest.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(tt.stream, false)
+			// Unaligned reads and skips across byte boundaries
+			first, err := reader.ReadBits(4)
+			if err != nil {
+				t.Fatalf("Reader.ReadBits() error = %v", err)
+			}
+			err = reader.SkipBits(28)
+			if tt.wantErr != nil {
+				if err != tt.wantErr {
+					t.Errorf("Reader.SkipBits() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reader.SkipBits() error = %v", err)
+			}
+			rest, err := reader.ReadBits(36)
+			if err != nil {
+				t.Fatalf("Reader.ReadBits() error = %v", err)
+			}
+			if first != 0xd || rest != 0x010203040 {
+				t.Errorf("Reader.ReadBits() = %x, %x, want %x, %x", first, rest, 0xd, 0x010203040)
+			}
+			if err := reader.SkipBits(8); err != io.ErrUnexpectedEOF && err != io.EOF {
+				t.Errorf("Reader.SkipBits() past the end error = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestReader_NoProgress(t *testing.T) {
+	reader := NewReader(&emptyReader{stream: bytes.NewReader([]byte{0x01}), empty: maxEmptyReads}, true)
+	if _, err := reader.ReadBits(1); err != io.ErrNoProgress {
+		t.Errorf("Reader.ReadBits() error = %v, want %v", err, io.ErrNoProgress)
+	}
+}
+
+func TestReader_SkipBitsShort(t *testing.T) {
+	reader := NewReaderFromBytes([]byte{0x01, 0x02, 0x03}, true)
+	reader.SkipBits(3)
+	if err := reader.SkipBits(32); err != io.ErrUnexpectedEOF {
+		t.Errorf("Reader.SkipBits() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if got := reader.BitPosition(); got != 24 {
+		t.Errorf("Reader.BitPosition() = %v, want %v", got, 24)
+	}
+}
+
+func TestReader_Reset(t *testing.T) {
+	data := []byte("a packet, and another packet after it")
+	limits := Limits{MaxStringLength: 16, MaxTotalBits: 1 << 16}
+	reader := NewReader(iotest.OneByteReader(bytes.NewReader(data)), true)
+	reader.SetLimits(limits)
+	// Leave every part of the Reader in use
+	reader.ReadBits(3)
+	reader.PeekBits(64)
+	crc, _ := NewCRC(CRC8)
+	checksum := reader.BeginChecksum(crc)
+	mark := reader.Mark()
+	reader.SkipBits(100)
+	reader.FindBits(0x61, 8)
+	reader.PeekBits(40)
+	stream := bytes.NewReader(data)
+	reader.Reset(stream, false)
+	want := NewReader(stream, false)
+	want.SetLimits(limits)
+	// Only the buffers are kept, everything else is as new
+	if reader.scratch == nil || reader.peekBuffer == nil {
+		t.Errorf("Reader.Reset() didn't keep the buffers")
+	}
+	if got := withoutBuffers(reader); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Reset() = %+v, want %+v", got, want)
+	}
+	if err := reader.EndChecksum(checksum); err == nil {
+		t.Errorf("Reader.EndChecksum() after Reset() error = nil")
+	}
+	if err := reader.Rewind(mark); err == nil {
+		t.Errorf("Reader.Rewind() after Reset() error = nil")
+	}
+	reader.ReadBits(20)
+	reader.PeekBits(64)
+	reader.ResetBytes(data[2:], true)
+	want = NewReaderFromBytes(data[2:], true)
+	want.SetLimits(limits)
+	if got := withoutBuffers(reader); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.ResetBytes() = %+v, want %+v", got, want)
+	}
+	// And it reads like a new Reader
+	for want.BitPosition()+13 <= uint64(len(data)-2)*8 {
+		if got, want := reader.TryReadBits(13), want.TryReadBits(13); got != want {
+			t.Errorf("Reader.ReadBits() after ResetBytes() = %#x, want %#x", got, want)
+		}
+	}
+}
+
+// withoutBuffers returns the Reader without the buffers that Reset keeps.
+func withoutBuffers(reader *Reader) *Reader {
+	reader.replay = nil
+	reader.scratch = nil
+	reader.peekBuffer = nil
+	return reader
+}
+
+// readPacket reads a made-up packet from reader the way a packet parser would, with
+// every kind of read that doesn't return a new slice or string.
+func readPacket(reader *Reader, values []uint16) error {
+	for _, bits := range []uint64{4, 1, 19} {
+		if _, err := reader.ReadBits(bits); err != nil {
+			return err
+		}
+	}
+	if _, err := reader.PeekBits(64); err != nil {
+		return err
+	}
+	if _, err := reader.ReadBool(); err != nil {
+		return err
+	}
+	if err := reader.AlignToByte(); err != nil {
+		return err
+	}
+	if _, err := reader.ReadBytes(4); err != nil {
+		return err
+	}
+	if err := reader.SkipBytes(19); err != nil {
+		return err
+	}
+	if _, err := reader.SkipUntil(0x7ff, 11); err != nil {
+		return err
+	}
+	return reader.ReadPackedUint16s(values, 11)
+}
+
+// testPacket returns a packet for readPacket, with a sync word after its header.
+func testPacket() []byte {
+	packet := make([]byte, 64)
+	for i := range packet {
+		packet[i] = byte(i * 37)
+	}
+	packet[29], packet[30], packet[31] = 0, 0xff, 0xe0
+	return packet
+}
+
+func TestReader_ResetAllocs(t *testing.T) {
+	packet := testPacket()
+	values := make([]uint16, 16)
+	reader := NewReaderFromBytes(nil, false)
+	stream := bytes.NewReader(nil)
+	tests := []struct {
+		name  string
+		reset func()
+	}{
+		{"ResetBytes", func() { reader.ResetBytes(packet, false) }},
+		{"Reset", func() { stream.Reset(packet); reader.Reset(stream, false) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				tt.reset()
+				if err := readPacket(reader, values); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("%v allocations per packet, want 0", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkReader_ResetBytes(b *testing.B) {
+	packet := testPacket()
+	values := make([]uint16, 16)
+	b.Run("NewReaderFromBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(packet)))
+		for i := 0; i < b.N; i++ {
+			if err := readPacket(NewReaderFromBytes(packet, false), values); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ResetBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(packet)))
+		reader := NewReaderFromBytes(nil, false)
+		for i := 0; i < b.N; i++ {
+			reader.ResetBytes(packet, false)
+			if err := readPacket(reader, values); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Pool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(packet)))
+		pool := sync.Pool{New: func() interface{} { return NewReaderFromBytes(nil, false) }}
+		b.RunParallel(func(pb *testing.PB) {
+			values := make([]uint16, 16)
+			for pb.Next() {
+				reader := pool.Get().(*Reader)
+				reader.ResetBytes(packet, false)
+				if err := readPacket(reader, values); err != nil {
+					b.Error(err)
+				}
+				pool.Put(reader)
+			}
+		})
+	})
+}
@@ -0,0 +1,493 @@
+// Package bzip2 decodes bzip2 streams from a big-endian bitreader.Reader.
+//
+// Unlike compress/bzip2, the Decompressor exposes the metadata of every block:
+// its bit offset, CRC and BWT origin pointer. Since bzip2 blocks are independent,
+// DecodeBlock can decode any block on its own once its bit offset is known,
+// which allows random-access and parallel decompression.
+package bzip2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pektezol/bitreader"
+	"github.com/pektezol/bitreader/huffman"
+)
+
+const (
+	blockMagic = 0x314159265359 // First 48 bits of every block, the BCD digits of pi
+	finalMagic = 0x177245385090 // First 48 bits of the stream footer, the BCD digits of sqrt(pi)
+	groupSize  = 50             // Number of symbols coded with the same Huffman table
+	maxLength  = 20             // Longest Huffman code length
+)
+
+var (
+	// ErrLittleEndian is returned when the Reader is not in big-endian mode.
+	ErrLittleEndian = errors.New("bzip2: reader should be big-endian")
+	// ErrChecksum is returned when a block or stream CRC doesn't match.
+	ErrChecksum = errors.New("bzip2: invalid checksum")
+	// ErrRandomized is returned for blocks using the deprecated randomized mode.
+	ErrRandomized = errors.New("bzip2: randomized blocks are not supported")
+)
+
+// StructuralError is returned when the stream is not valid bzip2 data.
+type StructuralError string
+
+func (err StructuralError) Error() string {
+	return "bzip2: invalid data: " + string(err)
+}
+
+// Block describes one decoded bzip2 block.
+//
+// Start uint64			Bit position of the block magic in the Reader
+// End uint64			Bit position right after the block
+// CRC uint32			The stored CRC of the decompressed block data
+// Randomized bool		Whether the deprecated randomized mode was set
+// OrigPtr uint32		Position of the original string in the BWT matrix
+// Level int			The block size level (1-9) of the stream the block belongs to
+// Size int				Number of decompressed bytes in the block
+type Block struct {
+	Start      uint64
+	End        uint64
+	CRC        uint32
+	Randomized bool
+	OrigPtr    uint32
+	Level      int
+	Size       int
+}
+
+// Decompressor decodes a bzip2 stream block by block.
+// Concatenated streams are decoded as a single stream, like the bzip2 tool does.
+//
+// reader *bitreader.Reader	The big-endian Reader the stream is read from
+// level int				Block size level of the current stream
+// streamCRC uint32			Combined CRC of the blocks of the current stream
+// pending []byte			Decompressed bytes not returned by Read yet
+// done bool				Whether the end of the last stream has been reached
+// err error				The sticky error returned by Read
+type Decompressor struct {
+	reader    *bitreader.Reader
+	level     int
+	streamCRC uint32
+	pending   []byte
+	done      bool
+	err       error
+}
+
+// NewDecompressor is the main constructor that reads the "BZh" stream header
+// from the Reader and creates a Decompressor for the blocks that follow it.
+//
+// Returns an error if the Reader is not big-endian or the header is invalid.
+func NewDecompressor(reader *bitreader.Reader) (*Decompressor, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	level, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Decompressor{reader: reader, level: level}, nil
+}
+
+// Decompress is a function that decompresses every stream in the Reader
+// and returns the decompressed data together with every block.
+func Decompress(reader *bitreader.Reader) ([]byte, []*Block, error) {
+	decompressor, err := NewDecompressor(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	var out []byte
+	var blocks []*Block
+	for {
+		block, data, err := decompressor.NextBlock()
+		if err == io.EOF {
+			return out, blocks, nil
+		}
+		if err != nil {
+			return out, blocks, err
+		}
+		out = append(out, data...)
+		blocks = append(blocks, block)
+	}
+}
+
+// DecodeBlock is a function that decodes a single block starting at the current
+// Reader position, which should be the bit offset of a block magic. The level is
+// the block size level from the header of the stream the block belongs to.
+// The block CRC is verified, the stream CRC is not.
+//
+// Returns an error if the block is invalid or its CRC doesn't match.
+func DecodeBlock(reader *bitreader.Reader, level int) (*Block, []byte, error) {
+	if reader.LittleEndian() {
+		return nil, nil, ErrLittleEndian
+	}
+	if level < 1 || level > 9 {
+		return nil, nil, StructuralError("block size level should be between 1 and 9")
+	}
+	start := reader.BitPosition()
+	magic, err := reader.ReadBits(48)
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if magic != blockMagic {
+		return nil, nil, StructuralError("bad block magic")
+	}
+	return decodeBlock(reader, level, start)
+}
+
+// NextBlock is a function that decodes the next block and returns it
+// together with its decompressed data.
+//
+// Returns io.EOF after the end of the last stream.
+func (decompressor *Decompressor) NextBlock() (*Block, []byte, error) {
+	reader := decompressor.reader
+	for !decompressor.done {
+		start := reader.BitPosition()
+		magic, err := reader.ReadBits(48)
+		if err != nil {
+			return nil, nil, noEOF(err)
+		}
+		switch magic {
+		case blockMagic:
+			block, data, err := decodeBlock(reader, decompressor.level, start)
+			if err != nil {
+				return nil, nil, err
+			}
+			decompressor.streamCRC = (decompressor.streamCRC<<1 | decompressor.streamCRC>>31) ^ block.CRC
+			return block, data, nil
+		case finalMagic:
+			if err := decompressor.nextStream(); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, StructuralError("bad block magic")
+		}
+	}
+	return nil, nil, io.EOF
+}
+
+// Read is a function that implements io.Reader over the decompressed data.
+func (decompressor *Decompressor) Read(p []byte) (int, error) {
+	for len(decompressor.pending) == 0 {
+		if decompressor.err != nil {
+			return 0, decompressor.err
+		}
+		_, data, err := decompressor.NextBlock()
+		if err != nil {
+			decompressor.err = err
+			continue
+		}
+		decompressor.pending = data
+	}
+	n := copy(p, decompressor.pending)
+	decompressor.pending = decompressor.pending[n:]
+	return n, nil
+}
+
+// nextStream is a private function that checks the stream footer
+// and starts the next concatenated stream, if there is one.
+func (decompressor *Decompressor) nextStream() error {
+	reader := decompressor.reader
+	crc, err := reader.ReadBits(32)
+	if err != nil {
+		return noEOF(err)
+	}
+	if uint32(crc) != decompressor.streamCRC {
+		return ErrChecksum
+	}
+	if err := reader.AlignToByte(); err != nil {
+		return noEOF(err)
+	}
+	if _, err := reader.PeekBits(8); err == io.EOF {
+		decompressor.done = true
+		return nil
+	}
+	level, err := readHeader(reader)
+	if err != nil {
+		return err
+	}
+	decompressor.level = level
+	decompressor.streamCRC = 0
+	return nil
+}
+
+// readHeader is a private function that reads the "BZh" magic and the block size level.
+func readHeader(reader *bitreader.Reader) (int, error) {
+	magic, err := reader.ReadBits(24)
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	if magic != 'B'<<16|'Z'<<8|'h' {
+		return 0, StructuralError("bad stream magic")
+	}
+	level, err := reader.ReadBits(8)
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	if level < '1' || level > '9' {
+		return 0, StructuralError("bad block size level")
+	}
+	return int(level - '0'), nil
+}
+
+// decodeBlock is a private function that decodes a block right after its magic.
+func decodeBlock(reader *bitreader.Reader, level int, start uint64) (*Block, []byte, error) {
+	block := &Block{Start: start, Level: level}
+	crc, err := reader.ReadBits(32)
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	block.CRC = uint32(crc)
+	block.Randomized, err = reader.ReadBool()
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if block.Randomized {
+		return nil, nil, ErrRandomized
+	}
+	origPtr, err := reader.ReadBits(24)
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	block.OrigPtr = uint32(origPtr)
+	maxSize := level * 100000
+	symbols, err := readSymbols(reader, maxSize)
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if int(block.OrigPtr) >= len(symbols) {
+		return nil, nil, StructuralError("origPtr out of bounds")
+	}
+	data := decodeRuns(inverseBWT(symbols, block.OrigPtr))
+	if checksum(data) != block.CRC {
+		return nil, nil, ErrChecksum
+	}
+	block.End = reader.BitPosition()
+	block.Size = len(data)
+	return block, data, nil
+}
+
+// readSymbols is a private function that reads the symbol map, the Huffman tables
+// and the Huffman coded symbols of a block, and undoes the move-to-front and
+// RUNA/RUNB run-length stages. It returns the BWT transformed block.
+func readSymbols(reader *bitreader.Reader, maxSize int) ([]byte, error) {
+	// The symbol map is a 16-bit bitmap of which 16-byte ranges are used,
+	// followed by a 16-bit bitmap for every used range
+	ranges, err := reader.ReadBits(16)
+	if err != nil {
+		return nil, err
+	}
+	var seqToUnseq []byte
+	for i := 0; i < 16; i++ {
+		if ranges&(0x8000>>i) == 0 {
+			continue
+		}
+		used, err := reader.ReadBits(16)
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < 16; j++ {
+			if used&(0x8000>>j) != 0 {
+				seqToUnseq = append(seqToUnseq, byte(i*16+j))
+			}
+		}
+	}
+	if len(seqToUnseq) == 0 {
+		return nil, StructuralError("no symbols in use")
+	}
+	alphabetSize := len(seqToUnseq) + 2
+	groups, err := reader.ReadBits(3)
+	if err != nil {
+		return nil, err
+	}
+	if groups < 2 || groups > 6 {
+		return nil, StructuralError("bad number of Huffman tables")
+	}
+	selectorCount, err := reader.ReadBits(15)
+	if err != nil {
+		return nil, err
+	}
+	if selectorCount == 0 {
+		return nil, StructuralError("no selectors")
+	}
+	// Selectors are move-to-front coded, each stored in unary
+	mtf := []byte{0, 1, 2, 3, 4, 5}
+	selectors := make([]byte, selectorCount)
+	for i := range selectors {
+		var index uint64
+		for {
+			bit, err := reader.ReadBool()
+			if err != nil {
+				return nil, err
+			}
+			if !bit {
+				break
+			}
+			index++
+			if index >= groups {
+				return nil, StructuralError("selector out of range")
+			}
+		}
+		selector := mtf[index]
+		copy(mtf[1:index+1], mtf[:index])
+		mtf[0] = selector
+		selectors[i] = selector
+	}
+	// Code lengths are delta coded from a 5-bit starting length
+	tables := make([]*huffman.Table, groups)
+	for i := range tables {
+		lengths := make([]uint8, alphabetSize)
+		length, err := reader.ReadBits(5)
+		if err != nil {
+			return nil, err
+		}
+		for symbol := range lengths {
+			for {
+				if length < 1 || length > maxLength {
+					return nil, StructuralError("bad Huffman code length")
+				}
+				more, err := reader.ReadBool()
+				if err != nil {
+					return nil, err
+				}
+				if !more {
+					break
+				}
+				down, err := reader.ReadBool()
+				if err != nil {
+					return nil, err
+				}
+				if down {
+					length--
+				} else {
+					length++
+				}
+			}
+			lengths[symbol] = uint8(length)
+		}
+		tables[i], err = huffman.New(lengths)
+		if err != nil {
+			return nil, StructuralError(err.Error())
+		}
+	}
+	// Decode the symbols, switching tables every groupSize symbols
+	for i := range mtf {
+		mtf[i] = 0
+	}
+	order := make([]byte, 256)
+	for i := range order {
+		order[i] = byte(i)
+	}
+	endOfBlock := alphabetSize - 1
+	var out []byte
+	var run, runBit int
+	for i := 0; ; i++ {
+		if i/groupSize >= len(selectors) {
+			return nil, StructuralError("ran out of selectors")
+		}
+		symbol, err := tables[selectors[i/groupSize]].Decode(reader)
+		if err != nil {
+			if err == huffman.ErrInvalidCode {
+				return nil, StructuralError("invalid Huffman code")
+			}
+			return nil, err
+		}
+		if symbol <= 1 {
+			// RUNA and RUNB write the run length in bijective base 2
+			if runBit > 20 {
+				return nil, StructuralError("run length too long")
+			}
+			run += (symbol + 1) << runBit
+			runBit++
+			continue
+		}
+		if run > 0 {
+			if len(out)+run > maxSize {
+				return nil, StructuralError("block too long")
+			}
+			value := seqToUnseq[order[0]]
+			for ; run > 0; run-- {
+				out = append(out, value)
+			}
+			runBit = 0
+		}
+		if symbol == endOfBlock {
+			break
+		}
+		index := symbol - 1
+		if index >= len(seqToUnseq) {
+			return nil, StructuralError("symbol out of range")
+		}
+		if len(out) >= maxSize {
+			return nil, StructuralError("block too long")
+		}
+		value := order[index]
+		copy(order[1:index+1], order[:index])
+		order[0] = value
+		out = append(out, seqToUnseq[value])
+	}
+	return out, nil
+}
+
+// inverseBWT is a private function that undoes the Burrows-Wheeler transform.
+func inverseBWT(data []byte, origPtr uint32) []byte {
+	var counts [256]uint32
+	for _, value := range data {
+		counts[value]++
+	}
+	var sum uint32
+	for i, count := range counts {
+		counts[i] = sum
+		sum += count
+	}
+	next := make([]uint32, len(data))
+	for i, value := range data {
+		next[counts[value]] = uint32(i)
+		counts[value]++
+	}
+	out := make([]byte, len(data))
+	position := next[origPtr]
+	for i := range out {
+		out[i] = data[position]
+		position = next[position]
+	}
+	return out
+}
+
+// decodeRuns is a private function that undoes the initial run-length encoding,
+// where 4 equal bytes are followed by a count of up to 255 more.
+func decodeRuns(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	var run int
+	for i := 0; i < len(data); i++ {
+		value := data[i]
+		if run == 4 {
+			for j := 0; j < int(value); j++ {
+				out = append(out, out[len(out)-1])
+			}
+			run = 0
+			continue
+		}
+		if len(out) > 0 && out[len(out)-1] == value {
+			run++
+		} else {
+			run = 1
+		}
+		out = append(out, value)
+	}
+	return out
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// String is a function that returns a short description of the block.
+func (block *Block) String() string {
+	return fmt.Sprintf("block at bit %d: %d bytes, crc %08x, origPtr %d", block.Start, block.Size, block.CRC, block.OrigPtr)
+}
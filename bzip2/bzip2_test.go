@@ -0,0 +1,142 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+func readTestdata(t testing.TB, name string) ([]byte, []byte) {
+	t.Helper()
+	compressed, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(bzip2.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compressed, want
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantBlocks int
+	}{
+		{name: "Text", file: "text.bz2", wantBlocks: 3},
+		{name: "Runs", file: "runs.bz2", wantBlocks: 1},
+		{name: "Concatenated", file: "concat.bz2", wantBlocks: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, want := readTestdata(t, tt.file)
+			got, blocks, err := Decompress(bitreader.NewReaderFromBytes(compressed, false))
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Decompress() output differs from compress/bzip2")
+			}
+			if len(blocks) != tt.wantBlocks {
+				t.Errorf("Decompress() returned %d blocks, want %d", len(blocks), tt.wantBlocks)
+			}
+		})
+	}
+}
+
+func TestDecodeBlock(t *testing.T) {
+	compressed, want := readTestdata(t, "text.bz2")
+	_, blocks, err := Decompress(bitreader.NewReaderFromBytes(compressed, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode the blocks in reverse order, each from its own Reader
+	var offset int
+	offsets := make([]int, len(blocks))
+	for i, block := range blocks {
+		offsets[i] = offset
+		offset += block.Size
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		reader := bitreader.NewReaderFromBytes(compressed, false)
+		if err := reader.SkipBits(blocks[i].Start); err != nil {
+			t.Fatal(err)
+		}
+		block, data, err := DecodeBlock(reader, blocks[i].Level)
+		if err != nil {
+			t.Fatalf("DecodeBlock() block %d error = %v", i, err)
+		}
+		if *block != *blocks[i] {
+			t.Errorf("DecodeBlock() = %v, want %v", block, blocks[i])
+		}
+		if !bytes.Equal(data, want[offsets[i]:offsets[i]+block.Size]) {
+			t.Errorf("DecodeBlock() block %d output differs from compress/bzip2", i)
+		}
+	}
+}
+
+func TestDecompressor_Read(t *testing.T) {
+	compressed, want := readTestdata(t, "concat.bz2")
+	decompressor, err := NewDecompressor(bitreader.NewReaderFromBytes(compressed, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(decompressor)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Decompressor.Read() error = %v, output equal %v", err, bytes.Equal(got, want))
+	}
+}
+
+func TestDecompressChecksum(t *testing.T) {
+	compressed, _ := readTestdata(t, "runs.bz2")
+	// The block CRC follows the 4 byte header and the 6 byte block magic
+	corrupt := append([]byte(nil), compressed...)
+	corrupt[10] ^= 0x01
+	if _, _, err := Decompress(bitreader.NewReaderFromBytes(corrupt, false)); err != ErrChecksum {
+		t.Errorf("Decompress() error = %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestNewDecompressor(t *testing.T) {
+	tests := []struct {
+		name    string
+		reader  *bitreader.Reader
+		wantErr bool
+	}{
+		{name: "Valid", reader: bitreader.NewReaderFromBytes([]byte("BZh9"), false)},
+		{name: "LittleEndian", reader: bitreader.NewReaderFromBytes([]byte("BZh9"), true), wantErr: true},
+		{name: "BadMagic", reader: bitreader.NewReaderFromBytes([]byte("BZx9"), false), wantErr: true},
+		{name: "BadLevel", reader: bitreader.NewReaderFromBytes([]byte("BZh0"), false), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDecompressor(tt.reader); (err != nil) != tt.wantErr {
+				t.Errorf("NewDecompressor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzDecompress(f *testing.F) {
+	for _, name := range []string{"runs.bz2", "concat.bz2"} {
+		compressed, _ := readTestdata(f, name)
+		f.Add(compressed)
+	}
+	f.Fuzz(func(t *testing.T, compressed []byte) {
+		want, wantErr := io.ReadAll(bzip2.NewReader(bytes.NewReader(compressed)))
+		got, _, err := Decompress(bitreader.NewReaderFromBytes(compressed, false))
+		if err == nil && wantErr == nil && !bytes.Equal(got, want) {
+			t.Fatalf("Decompress() output differs from compress/bzip2")
+		}
+		if err == nil && wantErr != nil {
+			t.Fatalf("Decompress() succeeded, compress/bzip2 error = %v", wantErr)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package bzip2
+
+// crcTable is the MSB-first CRC-32 table for polynomial 0x04c11db7 used by bzip2.
+var crcTable = makeCRCTable()
+
+func makeCRCTable() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// checksum is a private function that returns the bzip2 CRC of data.
+func checksum(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, value := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^value]
+	}
+	return ^crc
+}
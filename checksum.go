@@ -0,0 +1,161 @@
+package bitreader
+
+import (
+	"errors"
+	"hash"
+)
+
+// checksumChunk is how many bytes a Checksum collects before writing them to its hashers.
+const checksumChunk = 256
+
+// BitHash is a hash.Hash that can also take single bits, so that a Checksum can end in
+// the middle of a byte. CRC is a BitHash.
+type BitHash interface {
+	hash.Hash
+	WriteBit(bit uint8)
+}
+
+// Checksum is a set of hashers attached to a Reader by BeginChecksum. The hashers see the
+// bits consumed between BeginChecksum and EndChecksum, in whole bytes as ReadBits(8) would
+// return them from the start point, which are the bytes of the stream if it is aligned.
+//
+// reader *Reader			The Reader the hashers are attached to
+// hashers []hash.Hash		The hashers
+// start uint64				Position of the Reader at BeginChecksum
+// held byte				The last byte read from the stream, whose bits may not all be consumed yet
+// holding bool				Whether held is set
+// skip uint8				Bits of held consumed before BeginChecksum
+// acc uint8				Consumed bits that don't make a whole byte yet
+// accBits uint8			Number of bits in acc
+// pending []byte			Whole bytes not written to the hashers yet
+type Checksum struct {
+	reader  *Reader
+	hashers []hash.Hash
+	start   uint64
+	held    byte
+	holding bool
+	skip    uint8
+	acc     uint8
+	accBits uint8
+	pending []byte
+}
+
+// BeginChecksum is a function that attaches hashers to the Reader, from its current bit
+// position, and returns the Checksum to pass to EndChecksum. Any hash.Hash works, such as
+// hash/crc32, hash/adler32 or a CRC with a custom polynomial. Checksums may overlap or nest,
+// like the CRC-8 of a FLAC frame header inside the CRC-16 of the frame. Forks of the Reader
+// don't inherit its Checksums.
+func (reader *Reader) BeginChecksum(hashers ...hash.Hash) *Checksum {
+	checksum := &Checksum{
+		reader:  reader,
+		hashers: hashers,
+		start:   reader.position,
+		pending: make([]byte, 0, checksumChunk),
+	}
+	if reader.index != 0 {
+		checksum.held, checksum.holding, checksum.skip = reader.currentByte, true, reader.index
+	}
+	reader.checksums = append(reader.checksums, checksum)
+	return checksum
+}
+
+// EndChecksum is a function that detaches the hashers of a Checksum after giving them the
+// bits consumed up to the current bit position. If that is not a whole number of bytes,
+// the last bits are given to a BitHash one at a time, and to other hashers as a byte padded
+// with zero bits, like ReadBits(8) would return it if the missing bits were zero.
+//
+// Returns an error if the Checksum is not attached to the Reader.
+func (reader *Reader) EndChecksum(checksum *Checksum) error {
+	for i, attached := range reader.checksums {
+		if attached != checksum {
+			continue
+		}
+		reader.checksums = append(reader.checksums[:i], reader.checksums[i+1:]...)
+		if len(reader.checksums) == 0 {
+			reader.checksums = nil
+		}
+		if checksum.holding {
+			end := reader.index
+			if end == 0 {
+				end = 8
+			}
+			checksum.feed(checksum.held, checksum.skip, end)
+			checksum.holding = false
+		}
+		checksum.flush()
+		if checksum.accBits > 0 {
+			checksum.finish()
+		}
+		return nil
+	}
+	return errors.New("EndChecksum(checksum) ERROR: Checksum is not attached to this Reader")
+}
+
+// Bits is a function that returns the number of bits consumed since BeginChecksum.
+func (checksum *Checksum) Bits() uint64 {
+	return checksum.reader.position - checksum.start
+}
+
+// feed is a private function that adds bits from to end of a byte, in the order they are
+// consumed, to the whole bytes for the hashers.
+func (checksum *Checksum) feed(b byte, from, end uint8) {
+	if from == 0 && end == 8 && checksum.accBits == 0 {
+		checksum.add(b)
+		return
+	}
+	littleEndian := checksum.reader.littleEndian
+	for i := from; i < end; i++ {
+		if littleEndian {
+			checksum.acc |= (b >> i & 1) << checksum.accBits
+		} else {
+			checksum.acc = checksum.acc<<1 | b>>(7-i)&1
+		}
+		checksum.accBits++
+		if checksum.accBits == 8 {
+			checksum.add(checksum.acc)
+			checksum.acc, checksum.accBits = 0, 0
+		}
+	}
+}
+
+// add is a private function that adds a whole byte for the hashers.
+func (checksum *Checksum) add(b byte) {
+	checksum.pending = append(checksum.pending, b)
+	if len(checksum.pending) == checksumChunk {
+		checksum.flush()
+	}
+}
+
+// flush is a private function that writes the pending bytes to the hashers.
+func (checksum *Checksum) flush() {
+	if len(checksum.pending) == 0 {
+		return
+	}
+	for _, hasher := range checksum.hashers {
+		hasher.Write(checksum.pending)
+	}
+	checksum.pending = checksum.pending[:0]
+}
+
+// finish is a private function that gives the bits that don't make a whole byte to the hashers.
+func (checksum *Checksum) finish() {
+	padded := checksum.acc
+	if !checksum.reader.littleEndian {
+		padded <<= 8 - checksum.accBits
+	}
+	for _, hasher := range checksum.hashers {
+		bitHash, ok := hasher.(BitHash)
+		if !ok {
+			hasher.Write([]byte{padded})
+			continue
+		}
+		for i := uint8(0); i < checksum.accBits; i++ {
+			if checksum.reader.littleEndian {
+				bitHash.WriteBit(checksum.acc >> i & 1)
+			} else {
+				bitHash.WriteBit(checksum.acc >> (checksum.accBits - 1 - i) & 1)
+			}
+		}
+	}
+	checksum.acc, checksum.accBits = 0, 0
+}
@@ -0,0 +1,214 @@
+package bitreader
+
+import (
+	"bytes"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// referenceChecksum returns the hash of bits start to end of data, read with ReadBits(8)
+// from start, and the last bits given one at a time to a BitHash or padded with zero bits.
+func referenceChecksum(hasher hash.Hash, data []byte, le bool, start, end uint64) []byte {
+	hasher.Reset()
+	reader := NewReaderFromBytes(data, le)
+	reader.SkipBits(start)
+	for ; end-start >= 8; start += 8 {
+		hasher.Write([]byte{reader.TryReadUInt8()})
+	}
+	if rest := end - start; rest > 0 {
+		value := reader.TryReadBits(rest)
+		bitHash, ok := hasher.(BitHash)
+		switch {
+		case !ok && le:
+			hasher.Write([]byte{byte(value)})
+		case !ok:
+			hasher.Write([]byte{byte(value << (8 - rest))})
+		default:
+			for i := uint64(0); i < rest; i++ {
+				if le {
+					bitHash.WriteBit(uint8(value >> i & 1))
+				} else {
+					bitHash.WriteBit(uint8(value >> (rest - 1 - i) & 1))
+				}
+			}
+		}
+	}
+	return hasher.Sum(nil)
+}
+
+// checksumHashers returns one of every kind of hasher.
+func checksumHashers(t *testing.T) []hash.Hash {
+	t.Helper()
+	hashers := []hash.Hash{crc32.NewIEEE(), adler32.New()}
+	for _, model := range []CRCModel{CRC8, CRC16, CRC16MPEG, CRC32, CRC32MPEG2, {Width: 12, Poly: 0x80f, Reflected: true}} {
+		crc, err := NewCRC(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashers = append(hashers, crc)
+	}
+	return hashers
+}
+
+func TestCRC_Check(t *testing.T) {
+	// The check values of the CRC catalogues, the CRC of "123456789"
+	tests := []struct {
+		name  string
+		model CRCModel
+		want  uint32
+	}{
+		{"CRC8", CRC8, 0xf4},
+		{"CRC16", CRC16, 0xfee8},
+		{"CRC16MPEG", CRC16MPEG, 0xaee7},
+		{"CRC32", CRC32, 0xcbf43926},
+		{"CRC32MPEG2", CRC32MPEG2, 0x0376e6e7},
+		{"CRC-16/ARC", CRCModel{Width: 16, Poly: 0x8005, Reflected: true}, 0xbb3d},
+		{"CRC-8/MAXIM", CRCModel{Width: 8, Poly: 0x31, Reflected: true}, 0xa1},
+		{"CRC-12/UMTS", CRCModel{Width: 12, Poly: 0x80f}, 0xf5b},
+	}
+	for _, tt := range tests {
+		crc, err := NewCRC(tt.model)
+		if err != nil {
+			t.Fatalf("%s: NewCRC() error = %v", tt.name, err)
+		}
+		crc.Write([]byte("123456789"))
+		if got := crc.Sum32(); got != tt.want {
+			t.Errorf("%s: Sum32() = %#x, want %#x", tt.name, got, tt.want)
+		}
+		// Bit by bit, in the bit order of the CRC
+		crc.Reset()
+		for _, b := range []byte("123456789") {
+			for i := 0; i < 8; i++ {
+				if tt.model.Reflected {
+					crc.WriteBit(b >> i & 1)
+				} else {
+					crc.WriteBit(b >> (7 - i) & 1)
+				}
+			}
+		}
+		if got := crc.Sum32(); got != tt.want {
+			t.Errorf("%s: Sum32() after WriteBit = %#x, want %#x", tt.name, got, tt.want)
+		}
+		if sum := crc.Sum(nil); len(sum) != crc.Size() || sum[len(sum)-1] != byte(tt.want) {
+			t.Errorf("%s: Sum() = % x", tt.name, sum)
+		}
+	}
+	for _, model := range []CRCModel{{Width: 7, Poly: 0x09}, {Width: 33}, {Width: 8, Poly: 0x107}, {Width: 16, Init: 0x10000}} {
+		if _, err := NewCRC(model); err == nil {
+			t.Errorf("NewCRC(%+v) error = nil", model)
+		}
+	}
+}
+
+func TestReader_Checksum(t *testing.T) {
+	random := rand.New(rand.NewSource(48))
+	data := make([]byte, 3000)
+	random.Read(data)
+	hashers := checksumHashers(t)
+	for _, le := range []bool{true, false} {
+		for round := 0; round < 40; round++ {
+			var reader *Reader
+			if round%2 == 0 {
+				reader = NewReaderFromBytes(data, le)
+			} else {
+				reader = NewReader(iotest.HalfReader(bytes.NewReader(data)), le)
+			}
+			start := uint64(random.Intn(100))
+			reader.SkipBits(start)
+			// A peek fills the lookahead without consuming anything
+			reader.PeekBits(uint64(random.Intn(64) + 1))
+			for _, hasher := range hashers {
+				hasher.Reset()
+			}
+			checksum := reader.BeginChecksum(hashers...)
+			// Every way of consuming bits, in a random order
+			for op := 0; op < 12 && reader.BitPosition() < 20000; op++ {
+				switch random.Intn(6) {
+				case 0:
+					reader.ReadBits(uint64(random.Intn(64) + 1))
+				case 1:
+					reader.SkipBits(uint64(random.Intn(5000)))
+				case 2:
+					reader.ReadBytesToSlice(uint64(random.Intn(20)))
+				case 3:
+					reader.ReadPackedUint16s(make([]uint16, random.Intn(300)), uint(random.Intn(16)+1))
+				case 4:
+					pattern, _ := NewReaderFromBytes(data[2500:], le).PeekBits(13)
+					if random.Intn(2) == 0 {
+						reader.FindBits(pattern, 13)
+					} else {
+						reader.SkipUntil(pattern, 13)
+					}
+				case 5:
+					reader.AlignToByte()
+				}
+			}
+			end := reader.BitPosition()
+			if err := reader.EndChecksum(checksum); err != nil {
+				t.Fatalf("EndChecksum() error = %v", err)
+			}
+			if checksum.Bits() != end-start {
+				t.Errorf("Bits() = %d, want %d", checksum.Bits(), end-start)
+			}
+			for i, hasher := range hashers {
+				got := hasher.Sum(nil)
+				want := referenceChecksum(checksumHashers(t)[i], data, le, start, end)
+				if !bytes.Equal(got, want) {
+					t.Errorf("le %v, bits %d to %d: hasher %d = % x, want % x", le, start, end, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestReader_ChecksumNested(t *testing.T) {
+	data := []byte("\xff\xf8The header and the frame, with a CRC each")
+	reader := NewReaderFromBytes(data, false)
+	frame, _ := NewCRC(CRC16)
+	header, _ := NewCRC(CRC8)
+	frameChecksum := reader.BeginChecksum(frame)
+	reader.SkipBits(5)
+	headerChecksum := reader.BeginChecksum(header)
+	reader.SkipBits(100)
+	if err := reader.EndChecksum(headerChecksum); err != nil {
+		t.Fatalf("EndChecksum() error = %v", err)
+	}
+	reader.SkipBits(60)
+	if err := reader.EndChecksum(frameChecksum); err != nil {
+		t.Fatalf("EndChecksum() error = %v", err)
+	}
+	wantHeader, _ := NewCRC(CRC8)
+	wantFrame, _ := NewCRC(CRC16)
+	if got, want := header.Sum32(), referenceChecksum(wantHeader, data, false, 5, 105); !bytes.Equal(header.Sum(nil), want) {
+		t.Errorf("header CRC = %#x, want % x", got, want)
+	}
+	if got, want := frame.Sum32(), referenceChecksum(wantFrame, data, false, 0, 165); !bytes.Equal(frame.Sum(nil), want) {
+		t.Errorf("frame CRC = %#x, want % x", got, want)
+	}
+	if err := reader.EndChecksum(frameChecksum); err == nil {
+		t.Errorf("second EndChecksum() error = nil")
+	}
+	if reader.checksums != nil {
+		t.Errorf("checksums = %v after the last EndChecksum", reader.checksums)
+	}
+}
+
+func TestReader_ChecksumEmpty(t *testing.T) {
+	reader := NewReaderFromBytes([]byte{0xa5, 0x5a}, false)
+	reader.SkipBits(3)
+	crc, _ := NewCRC(CRC16MPEG)
+	checksum := reader.BeginChecksum(crc)
+	fork, _ := reader.Fork()
+	fork.SkipBits(8)
+	if err := fork.EndChecksum(checksum); err == nil {
+		t.Errorf("EndChecksum() on a fork error = nil")
+	}
+	reader.EndChecksum(checksum)
+	if crc.Sum32() != CRC16MPEG.Init || checksum.Bits() != 0 {
+		t.Errorf("Sum32() = %#x, Bits() = %d, want the initial value and 0", crc.Sum32(), checksum.Bits())
+	}
+}
@@ -0,0 +1,147 @@
+package bitreader
+
+import "errors"
+
+// CRCModel is the definition of a CRC of 8 to 32 bits, in the model of the CRC catalogues.
+//
+// Width uint			Width of the CRC in bits, 8 to 32
+// Poly uint32			Polynomial, without the top bit
+// Init uint32			Initial value of the register
+// Reflected bool		Whether bytes are processed LSB-first, and the result reflected
+// XorOut uint32		Value XORed into the result
+type CRCModel struct {
+	Width     uint
+	Poly      uint32
+	Init      uint32
+	Reflected bool
+	XorOut    uint32
+}
+
+var (
+	// CRC8 is CRC-8 with polynomial 0x07, used by FLAC frame headers.
+	CRC8 = CRCModel{Width: 8, Poly: 0x07}
+	// CRC16 is CRC-16/UMTS with polynomial 0x8005, used by FLAC frames.
+	CRC16 = CRCModel{Width: 16, Poly: 0x8005}
+	// CRC16MPEG is CRC-16/CMS, used by MPEG audio frames and ADTS.
+	CRC16MPEG = CRCModel{Width: 16, Poly: 0x8005, Init: 0xffff}
+	// CRC32 is the IEEE CRC-32 of hash/crc32, used by zip, PNG and the Source engine.
+	CRC32 = CRCModel{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, Reflected: true, XorOut: 0xffffffff}
+	// CRC32MPEG2 is CRC-32/MPEG-2, used by MPEG transport stream sections.
+	CRC32MPEG2 = CRCModel{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff}
+)
+
+// CRC is a table-driven CRC that implements hash.Hash32 and BitHash.
+//
+// model CRCModel		The definition of the CRC
+// poly uint32			The polynomial, reflected for reflected CRCs
+// table [256]uint32	The byte table, reflected for reflected CRCs
+// crc uint32			The register, reflected for reflected CRCs
+type CRC struct {
+	model CRCModel
+	poly  uint32
+	table [256]uint32
+	crc   uint32
+}
+
+// NewCRC is a function that returns a CRC for the given model.
+//
+// Returns an error if the width is not between 8 and 32, or the polynomial doesn't fit in it.
+func NewCRC(model CRCModel) (*CRC, error) {
+	if model.Width < 8 || model.Width > 32 {
+		return nil, errors.New("NewCRC(model) ERROR: Width should be between 8 and 32")
+	}
+	mask := uint32(1<<model.Width - 1)
+	if model.Poly&^mask != 0 || model.Init&^mask != 0 || model.XorOut&^mask != 0 {
+		return nil, errors.New("NewCRC(model) ERROR: Poly, Init and XorOut should fit in Width bits")
+	}
+	crc := &CRC{model: model, poly: model.Poly}
+	if model.Reflected {
+		crc.poly = reflectBits(model.Poly, model.Width)
+	}
+	for i := range crc.table {
+		if model.Reflected {
+			value := uint32(i)
+			for j := 0; j < 8; j++ {
+				value = value>>1 ^ -(value&1)&crc.poly
+			}
+			crc.table[i] = value
+		} else {
+			value := uint32(i) << (model.Width - 8)
+			for j := 0; j < 8; j++ {
+				value = (value<<1 ^ -(value>>(model.Width-1)&1)&crc.poly) & mask
+			}
+			crc.table[i] = value
+		}
+	}
+	crc.Reset()
+	return crc, nil
+}
+
+// Write is a function that adds bytes to the CRC. It never returns an error.
+func (crc *CRC) Write(data []byte) (int, error) {
+	width := crc.model.Width
+	if crc.model.Reflected {
+		for _, b := range data {
+			crc.crc = crc.crc>>8 ^ crc.table[byte(crc.crc)^b]
+		}
+	} else {
+		mask := uint32(1<<width - 1)
+		for _, b := range data {
+			crc.crc = (crc.crc<<8 ^ crc.table[byte(crc.crc>>(width-8))^b]) & mask
+		}
+	}
+	return len(data), nil
+}
+
+// WriteBit is a function that adds a single bit to the CRC, as the next bit of a byte in
+// the bit order of the CRC, MSB-first unless it is reflected.
+func (crc *CRC) WriteBit(bit uint8) {
+	width := crc.model.Width
+	if crc.model.Reflected {
+		crc.crc = crc.crc>>1 ^ -((crc.crc^uint32(bit))&1)&crc.poly
+	} else {
+		top := crc.crc>>(width-1) ^ uint32(bit)&1
+		crc.crc = (crc.crc<<1 ^ -top&crc.poly) & uint32(1<<width-1)
+	}
+}
+
+// Sum32 is a function that returns the CRC.
+func (crc *CRC) Sum32() uint32 {
+	return crc.crc ^ crc.model.XorOut
+}
+
+// Sum is a function that appends the CRC to b in big-endian order, in Size bytes.
+func (crc *CRC) Sum(b []byte) []byte {
+	sum := crc.Sum32()
+	for i := crc.Size() - 1; i >= 0; i-- {
+		b = append(b, byte(sum>>(8*i)))
+	}
+	return b
+}
+
+// Reset is a function that sets the CRC back to its initial value.
+func (crc *CRC) Reset() {
+	crc.crc = crc.model.Init
+	if crc.model.Reflected {
+		crc.crc = reflectBits(crc.crc, crc.model.Width)
+	}
+}
+
+// Size is a function that returns the number of bytes Sum appends.
+func (crc *CRC) Size() int {
+	return int(crc.model.Width+7) / 8
+}
+
+// BlockSize is a function that returns 1, the CRC takes bytes one at a time.
+func (crc *CRC) BlockSize() int {
+	return 1
+}
+
+// reflectBits is a private function that reverses the low width bits of value.
+func reflectBits(value uint32, width uint) uint32 {
+	var out uint32
+	for i := uint(0); i < width; i++ {
+		out = out<<1 | value>>i&1
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package bitreader
+
+import "errors"
+
+// findChunk is how many bytes a pattern search reads from the stream at once.
+const findChunk = 512
+
+// FindBits is a function that advances the Reader to the next occurrence of a width-bit
+// pattern at any bit offset, such as the 11-bit 0x7FF sync word of MPEG audio, and returns
+// how many bits were skipped before it. The pattern is not consumed, so the next
+// ReadBits(width) returns it. The pattern is the value ReadBits(width) would return, in
+// the bit order of the Reader. The search looks at every bit once, whatever the width.
+//
+// Returns io.EOF if the stream ends before the pattern, after consuming every remaining bit.
+// With MaxTotalBits set, the search stops at the limit with a LimitError after consuming
+// the bits it looked at, instead of before like other reads.
+func (reader *Reader) FindBits(pattern uint64, width uint) (uint64, error) {
+	return reader.findBits("FindBits(pattern, width)", pattern, widthMask(width), width, false)
+}
+
+// FindBitsMasked is a function that advances the Reader to the next occurrence of a
+// width-bit pattern, only comparing the bits set in mask, and returns how many bits were
+// skipped before it. It is used for sync words followed by fixed header bits, like
+// 0xFFF plus a layer of 0 in ADTS headers.
+//
+// Returns an error if pattern has bits outside of mask, and like FindBits at the end of the stream.
+func (reader *Reader) FindBitsMasked(pattern, mask uint64, width uint) (uint64, error) {
+	return reader.findBits("FindBitsMasked(pattern, mask, width)", pattern, mask, width, false)
+}
+
+// SkipUntil is a function that works like FindBits, but also consumes the pattern, so that
+// the next read starts right after it. It returns how many bits were skipped before the pattern.
+//
+// Returns io.EOF if the stream ends before the pattern, after consuming every remaining bit.
+func (reader *Reader) SkipUntil(pattern uint64, width uint) (uint64, error) {
+	return reader.findBits("SkipUntil(pattern, width)", pattern, widthMask(width), width, true)
+}
+
+// SkipUntilMasked is a function that works like FindBitsMasked, but also consumes the pattern.
+// It returns how many bits were skipped before the pattern.
+//
+// Returns an error if pattern has bits outside of mask, and like FindBits at the end of the stream.
+func (reader *Reader) SkipUntilMasked(pattern, mask uint64, width uint) (uint64, error) {
+	return reader.findBits("SkipUntilMasked(pattern, mask, width)", pattern, mask, width, true)
+}
+
+// TryFindBits is a wrapper function that advances to the next occurrence of a pattern.
+//
+// Returns uint64. Panics on overflow.
+func (reader *Reader) TryFindBits(pattern uint64, width uint) uint64 {
+	offset, err := reader.FindBits(pattern, width)
+	if err != nil {
+		panic(err)
+	}
+	return offset
+}
+
+// TryFindBitsMasked is a wrapper function that advances to the next occurrence of a masked pattern.
+//
+// Returns uint64. Panics on overflow.
+func (reader *Reader) TryFindBitsMasked(pattern, mask uint64, width uint) uint64 {
+	offset, err := reader.FindBitsMasked(pattern, mask, width)
+	if err != nil {
+		panic(err)
+	}
+	return offset
+}
+
+// TrySkipUntil is a wrapper function that advances past the next occurrence of a pattern.
+//
+// Returns uint64. Panics on overflow.
+func (reader *Reader) TrySkipUntil(pattern uint64, width uint) uint64 {
+	offset, err := reader.SkipUntil(pattern, width)
+	if err != nil {
+		panic(err)
+	}
+	return offset
+}
+
+// TrySkipUntilMasked is a wrapper function that advances past the next occurrence of a masked pattern.
+//
+// Returns uint64. Panics on overflow.
+func (reader *Reader) TrySkipUntilMasked(pattern, mask uint64, width uint) uint64 {
+	offset, err := reader.SkipUntilMasked(pattern, mask, width)
+	if err != nil {
+		panic(err)
+	}
+	return offset
+}
+
+// widthMask is a private function that returns a mask of the low width bits.
+func widthMask(width uint) uint64 {
+	if width >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<width - 1
+}
+
+// findBits is a private function that slides a width-bit window over the stream one bit at a
+// time, keeping it in the same order ReadBits would return it, so every step is a shift and
+// a compare. Bytes are read in chunks, and the bytes after the match are given back to the
+// Reader as lookahead, with the last few bytes of every chunk kept to rewind to the start
+// of a match that began in the previous chunk.
+func (reader *Reader) findBits(op string, pattern, mask uint64, width uint, skip bool) (uint64, error) {
+	if width < 1 || width > 64 {
+		return 0, errors.New(op + " ERROR: Width should be between 1 and 64")
+	}
+	full := widthMask(width)
+	if mask&^full != 0 || pattern&^mask != 0 {
+		return 0, errors.New(op + " ERROR: Pattern and mask should fit in width bits, with the pattern inside the mask")
+	}
+	// With a limit, the pattern has to end before it
+	budget := ^uint64(0)
+	if max := reader.limits.MaxTotalBits; max != 0 {
+		budget = 0
+		if reader.position < max {
+			budget = max - reader.position
+		}
+	}
+	// Enough history for a 64-bit window that doesn't start on a byte boundary
+	const history = 9
+	buffer := reader.scratchBuffer(history + findChunk)
+	var data []byte
+	var first uint64 // Bit of data the search started at
+	committed := 0   // Bytes at the front of data already given to the Checksums
+	if reader.index != 0 {
+		buffer[0] = reader.currentByte
+		data = buffer[:1]
+		first = uint64(reader.index)
+		committed = 1
+	}
+	var dropped uint64 // Bits removed from the front of data
+	bit := first       // Next bit of data to look at
+	var window uint64
+	var pending error
+	le := reader.littleEndian
+	for {
+		// Stop at the limit if it is in this chunk
+		end := uint64(len(data)) * 8
+		limited := false
+		if left := budget - (dropped + bit - first); left < end-bit {
+			end = bit + left
+			limited = true
+		}
+		for ; bit < end; bit++ {
+			// Bits above width stay in a big-endian window, the mask leaves them out
+			if le {
+				window = window>>1 | uint64(data[bit/8]>>(bit%8)&1)<<(width-1)
+			} else {
+				window = window<<1 | uint64(data[bit/8]>>(7-bit%8)&1)
+			}
+			// The window is full once width bits were looked at
+			if window&mask == pattern && dropped+bit+1-first >= uint64(width) {
+				start := bit + 1 - uint64(width)
+				offset := dropped + start - first
+				if skip {
+					reader.settle(data, committed, bit+1, offset+uint64(width))
+				} else {
+					reader.settle(data, committed, start, offset)
+				}
+				return offset, nil
+			}
+		}
+		if limited {
+			reader.settle(data, committed, bit, dropped+bit-first)
+			return 0, reader.checkBits(op, 1)
+		}
+		// Keep the last bytes around, in case a match started in them
+		keep := len(data)
+		if keep > history {
+			keep = history
+		}
+		shift := len(data) - keep
+		// The bytes dropped were all looked at, so they are consumed whatever happens
+		if shift > committed {
+			reader.commit(data[committed:shift])
+			committed = shift
+		}
+		committed -= shift
+		copy(buffer[:keep], data[shift:])
+		data = buffer[:keep]
+		dropped += uint64(shift) * 8
+		bit -= uint64(shift) * 8
+		if pending != nil {
+			reader.settle(data, committed, bit, dropped+bit-first)
+			return 0, pending
+		}
+		n, err := reader.readSome(buffer[keep:])
+		data = buffer[:keep+n]
+		if err != nil {
+			if n == 0 {
+				reader.settle(data, committed, bit, dropped+bit-first)
+				return 0, err
+			}
+			// Look at the data first, the error is returned if there is no match in it
+			pending = err
+		}
+	}
+}
+
+// settle is a private function that leaves the Reader at bit at of data after a search that
+// consumed the given bits, with the rest of data given back in front of the lookahead. The
+// first committed bytes of data were already given to the Checksums.
+func (reader *Reader) settle(data []byte, committed int, at, consumed uint64) {
+	if end := int((at + 7) / 8); end > committed {
+		reader.commit(data[committed:end])
+	}
+	reader.position += consumed
+	reader.index = uint8(at % 8)
+	rest := data[at/8:]
+	if reader.index != 0 {
+		reader.currentByte = rest[0]
+		rest = rest[1:]
+	}
+	reader.unread(rest)
+}
@@ -0,0 +1,223 @@
+package bitreader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// referenceFind returns the first bit position from start where the masked pattern is,
+// by peeking at every position.
+func referenceFind(data []byte, le bool, start uint64, pattern, mask uint64, width uint) (uint64, bool) {
+	for position := start; position+uint64(width) <= uint64(len(data))*8; position++ {
+		reader := NewReaderFromBytes(data, le)
+		reader.SkipBits(position)
+		if value, _ := reader.PeekBits(uint64(width)); value&mask == pattern {
+			return position, true
+		}
+	}
+	return 0, false
+}
+
+func TestReader_FindBits(t *testing.T) {
+	random := rand.New(rand.NewSource(44))
+	data := make([]byte, 96)
+	random.Read(data)
+	for _, le := range []bool{true, false} {
+		for _, width := range []uint{1, 3, 11, 12, 24, 33, 64} {
+			for start := uint64(0); start < 24; start += 5 {
+				for _, skip := range []bool{false, true} {
+					// Patterns taken from the data are found, random ones usually aren't for wide widths
+					source := NewReaderFromBytes(data, le)
+					source.SkipBits(uint64(random.Intn(len(data)*8 - int(width))))
+					patterns := []uint64{source.TryReadBits(uint64(width)), random.Uint64() & widthMask(width)}
+					for _, pattern := range patterns {
+						reader := NewReaderFromBytes(data, le)
+						reader.SkipBits(start)
+						// Put some bytes in the lookahead as well
+						reader.PeekBits(20)
+						want, found := referenceFind(data, le, start, pattern, widthMask(width), width)
+						var offset uint64
+						var err error
+						if skip {
+							offset, err = reader.SkipUntil(pattern, width)
+						} else {
+							offset, err = reader.FindBits(pattern, width)
+						}
+						if !found {
+							if err != io.EOF || reader.BitPosition() != uint64(len(data))*8 {
+								t.Fatalf("LE=%v/Width=%d/Start=%d: pattern %#x error = %v at %d, want io.EOF at the end", le, width, start, pattern, err, reader.BitPosition())
+							}
+							continue
+						}
+						if err != nil || offset != want-start {
+							t.Fatalf("LE=%v/Width=%d/Start=%d: pattern %#x = %d, %v, want %d", le, width, start, pattern, offset, err, want-start)
+						}
+						if skip {
+							if reader.BitPosition() != want+uint64(width) {
+								t.Fatalf("LE=%v/Width=%d/Start=%d: BitPosition() = %d, want %d", le, width, start, reader.BitPosition(), want+uint64(width))
+							}
+							continue
+						}
+						if reader.BitPosition() != want {
+							t.Fatalf("LE=%v/Width=%d/Start=%d: BitPosition() = %d, want %d", le, width, start, reader.BitPosition(), want)
+						}
+						if value := reader.TryReadBits(uint64(width)); value != pattern {
+							t.Fatalf("LE=%v/Width=%d/Start=%d: ReadBits() after = %#x, want %#x", le, width, start, value, pattern)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReader_FindBitsChunks(t *testing.T) {
+	// A 64-bit pattern across the first chunk boundary of the search, and the rest of the data after it
+	data := make([]byte, 3*findChunk)
+	rand.New(rand.NewSource(3)).Read(data)
+	for _, le := range []bool{true, false} {
+		for _, oneByte := range []bool{false, true} {
+			var stream io.Reader = bytes.NewReader(data)
+			if oneByte {
+				stream = iotest.OneByteReader(stream)
+			}
+			reader := NewReader(stream, le)
+			reader.SkipBits(5)
+			position := uint64(findChunk*8 - 3)
+			source := NewReaderFromBytes(data, le)
+			source.SkipBits(position)
+			pattern := source.TryReadBits(64)
+			want, _ := referenceFind(data, le, 5, pattern, ^uint64(0), 64)
+			offset, err := reader.FindBits(pattern, 64)
+			if err != nil || offset != want-5 {
+				t.Fatalf("LE=%v/OneByte=%v: FindBits() = %d, %v, want %d", le, oneByte, offset, err, want-5)
+			}
+			rest := make([]byte, 0, len(data))
+			for {
+				value, err := reader.ReadBits(8)
+				if err != nil {
+					break
+				}
+				rest = append(rest, byte(value))
+			}
+			source = NewReaderFromBytes(data, le)
+			source.SkipBits(want)
+			if want := source.TryReadBitsToSlice(uint64(len(rest)) * 8); !bytes.Equal(rest, want) {
+				t.Fatalf("LE=%v/OneByte=%v: data after the pattern doesn't match", le, oneByte)
+			}
+		}
+	}
+}
+
+func TestReader_FindBitsMasked(t *testing.T) {
+	// ADTS: 12-bit sync word, then ID, a layer of 00 and protection_absent, MPEG-4 with CRC is 0xFFF0
+	stream := []byte{0x12, 0xff, 0xf7, 0x00, 0xff, 0xf1}
+	reader := NewReaderFromBytes(stream, false)
+	offset, err := reader.FindBitsMasked(0xfff0, 0xfff6, 16)
+	if err != nil || offset != 32 {
+		t.Fatalf("FindBitsMasked() = %d, %v, want 32", offset, err)
+	}
+	if value := reader.TryReadBits(16); value != 0xfff1 {
+		t.Errorf("ReadBits(16) after = %#x, want 0xfff1", value)
+	}
+	// A run of 16 ones on a little-endian stream, the sync word is the last 11 before a zero
+	reader = NewReaderFromBytes([]byte{0x00, 0xf8, 0xff, 0x07}, true)
+	offset, err = reader.SkipUntilMasked(0x7ff, 0xfff, 12)
+	if err != nil || offset != 16 {
+		t.Fatalf("SkipUntilMasked() = %d, %v, want 16", offset, err)
+	}
+	if reader.BitPosition() != 28 {
+		t.Errorf("BitPosition() = %d, want 28", reader.BitPosition())
+	}
+}
+
+func TestReader_FindBitsRepeated(t *testing.T) {
+	// Sync words 0x7FF at bits 5, 31 and 49 of a big-endian stream
+	reader := NewReaderFromBytes([]byte{0x07, 0xff, 0x00, 0x01, 0xff, 0xc0, 0x7f, 0xf0}, false)
+	var offsets []uint64
+	for {
+		offset, err := reader.SkipUntil(0x7ff, 11)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("SkipUntil() error = %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+	if len(offsets) != 3 || offsets[0] != 5 || offsets[1] != 15 || offsets[2] != 7 {
+		t.Errorf("SkipUntil() offsets = %v, want [5 15 7]", offsets)
+	}
+	// FindBits doesn't consume the pattern, so finding it again doesn't move
+	reader = NewReaderFromBytes([]byte{0x00, 0xff, 0xe0}, false)
+	if offset := reader.TryFindBits(0x7ff, 11); offset != 8 {
+		t.Errorf("FindBits() = %d, want 8", offset)
+	}
+	if offset := reader.TryFindBits(0x7ff, 11); offset != 0 || reader.BitPosition() != 8 {
+		t.Errorf("FindBits() again = %d at %d, want 0 at 8", offset, reader.BitPosition())
+	}
+}
+
+func TestReader_FindBitsLimits(t *testing.T) {
+	stream := []byte{0x00, 0x00, 0xff, 0x00}
+	reader := NewReaderFromBytes(stream, false)
+	reader.SetLimits(Limits{MaxTotalBits: 20})
+	var limitErr *LimitError
+	if _, err := reader.FindBits(0xff, 8); !errors.As(err, &limitErr) {
+		t.Fatalf("FindBits() error = %v, want LimitError", err)
+	}
+	if reader.BitPosition() != 20 {
+		t.Errorf("BitPosition() = %d, want 20", reader.BitPosition())
+	}
+	// A pattern that ends right at the limit is found
+	reader = NewReaderFromBytes(stream, false)
+	reader.SetLimits(Limits{MaxTotalBits: 24})
+	if offset, err := reader.SkipUntil(0xff, 8); err != nil || offset != 16 {
+		t.Errorf("SkipUntil() = %d, %v, want 16", offset, err)
+	}
+}
+
+func TestReader_FindBitsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern uint64
+		mask    uint64
+		width   uint
+	}{
+		{name: "WidthZero", pattern: 0, mask: 0, width: 0},
+		{name: "WidthTooBig", pattern: 1, mask: 1, width: 65},
+		{name: "PatternTooWide", pattern: 0x100, mask: 0x1ff, width: 8},
+		{name: "PatternOutsideMask", pattern: 0x3, mask: 0x1, width: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReaderFromBytes([]byte{0xff, 0xff}, false)
+			if _, err := reader.FindBitsMasked(tt.pattern, tt.mask, tt.width); err == nil {
+				t.Errorf("FindBitsMasked() error = nil")
+			}
+			if reader.BitPosition() != 0 {
+				t.Errorf("BitPosition() = %d, want 0", reader.BitPosition())
+			}
+		})
+	}
+	// An empty stream has no pattern
+	if _, err := NewReaderFromBytes(nil, true).SkipUntil(1, 1); err != io.EOF {
+		t.Errorf("SkipUntil() on empty stream error = %v, want io.EOF", err)
+	}
+}
+
+func BenchmarkFindBits(b *testing.B) {
+	data := make([]byte, 1<<16)
+	data[len(data)-2] = 0xff
+	data[len(data)-1] = 0xe0
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		reader := NewReaderFromBytes(data, false)
+		if _, err := reader.FindBits(0x7ff, 11); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package flac
+
+import (
+	"encoding/binary"
+
+	"github.com/pektezol/bitreader"
+)
+
+// The tests write the frames and metadata blocks they need bit by bit with these helpers,
+// to reach the error paths and the subframe codings no stream of testdata has. Decoded
+// streams are checked against the reference output of testdata, see testdata/README.md.
+
+// bitWriter writes MSB-first bits.
+type bitWriter struct {
+	data []byte
+	bits uint
+}
+
+func (w *bitWriter) u(count uint, value uint64) *bitWriter {
+	for i := int(count) - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>uint(i)&1 == 1 {
+			w.data[len(w.data)-1] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+	return w
+}
+
+func (w *bitWriter) s(count uint, value int64) *bitWriter {
+	return w.u(count, uint64(value))
+}
+
+func (w *bitWriter) unary(zeros uint64) *bitWriter {
+	for i := uint64(0); i < zeros; i++ {
+		w.u(1, 0)
+	}
+	return w.u(1, 1)
+}
+
+func (w *bitWriter) align() *bitWriter {
+	for w.bits%8 != 0 {
+		w.u(1, 0)
+	}
+	return w
+}
+
+// writeUTF8 writes a frame or sample number in the UTF-8 like coding.
+func writeUTF8(w *bitWriter, value uint64) {
+	if value < 0x80 {
+		w.u(8, value)
+		return
+	}
+	length := 2
+	for value >= 1<<(5*length+1) && length < 7 {
+		length++
+	}
+	w.u(uint(length+1), 1<<(length+1)-2)
+	w.u(uint(7-length), value>>(6*(length-1)))
+	for i := length - 2; i >= 0; i-- {
+		w.u(2, 2).u(6, value>>(6*i)&0x3f)
+	}
+}
+
+func crc8(data []byte) uint8 {
+	crc, _ := bitreader.NewCRC(bitreader.CRC8)
+	crc.Write(data)
+	return uint8(crc.Sum32())
+}
+
+func crc16(data []byte) uint16 {
+	crc, _ := bitreader.NewCRC(bitreader.CRC16)
+	crc.Write(data)
+	return uint16(crc.Sum32())
+}
+
+// metadataBlock returns a metadata block with its header.
+func metadataBlock(typ BlockType, data []byte) []byte {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(typ)<<24|uint32(len(data)))
+	return append(header, data...)
+}
@@ -0,0 +1,188 @@
+// Package flac decodes FLAC (Free Lossless Audio Codec) streams from a big-endian
+// bitreader.Reader.
+//
+// A FLAC stream is the "fLaC" signature, metadata blocks and frames. Every frame has a
+// header protected by a CRC-8, with a frame or sample number coded like UTF-8, and one
+// subframe per channel, either constant, verbatim or a fixed or linear predictor with a
+// Rice coded residual. The whole frame is protected by a CRC-16. ReadBlock and ReadFrame
+// read one metadata block or frame, and a Decoder reads a whole stream and checks the
+// MD5 of the decoded samples against STREAMINFO.
+package flac
+
+import (
+	"crypto/md5"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/pektezol/bitreader"
+)
+
+var (
+	// ErrLittleEndian is returned when the Reader is not in big-endian mode.
+	ErrLittleEndian = errors.New("flac: reader should be big-endian")
+	// ErrSignature is returned when a stream doesn't start with "fLaC".
+	ErrSignature = errors.New("flac: missing fLaC signature")
+	// ErrMetadata is returned when a metadata block is malformed or STREAMINFO is not the first block.
+	ErrMetadata = errors.New("flac: invalid metadata block")
+	// ErrSync is returned when the Reader is not at a frame sync code.
+	ErrSync = errors.New("flac: no frame sync")
+	// ErrFrame is returned when a frame has a reserved or invalid value.
+	ErrFrame = errors.New("flac: invalid frame")
+	// ErrCRC is returned when the CRC-8 of a frame header or the CRC-16 of a frame doesn't match.
+	ErrCRC = errors.New("flac: frame CRC mismatch")
+	// ErrMD5 is returned at the end of a stream when the MD5 of the decoded samples doesn't match STREAMINFO.
+	ErrMD5 = errors.New("flac: MD5 mismatch")
+)
+
+// Decoder decodes the frames of a FLAC stream.
+//
+// StreamInfo *StreamInfo		The STREAMINFO block
+// Blocks []Block				All metadata blocks, STREAMINFO included
+// reader *bitreader.Reader		The stream
+// md5 hash.Hash				MD5 of the decoded samples, nil after a frame error
+// samples uint64				Number of samples per channel decoded
+// buffer []byte				Buffer of the interleaved little-endian samples for the MD5
+type Decoder struct {
+	StreamInfo *StreamInfo
+	Blocks     []Block
+	reader     *bitreader.Reader
+	md5        hash.Hash
+	samples    uint64
+	buffer     []byte
+}
+
+// NewDecoder is a function that reads the signature and metadata blocks of a FLAC stream,
+// skipping ID3v2 tags before them, and returns a Decoder for its frames.
+//
+// Returns ErrSignature if the stream is not FLAC, ErrMetadata if the metadata is malformed.
+func NewDecoder(reader *bitreader.Reader) (*Decoder, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	if err := skipID3(reader); err != nil {
+		return nil, err
+	}
+	signature, err := reader.ReadBits(32)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if err != nil || signature != 0x664c6143 {
+		return nil, ErrSignature
+	}
+	decoder := &Decoder{reader: reader, md5: md5.New()}
+	for last := false; !last; {
+		var block Block
+		block, last, err = ReadBlock(reader)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if info, ok := block.(*StreamInfo); ok != (len(decoder.Blocks) == 0) {
+			return nil, ErrMetadata
+		} else if ok {
+			decoder.StreamInfo = info
+		}
+		decoder.Blocks = append(decoder.Blocks, block)
+	}
+	return decoder, nil
+}
+
+// Next is a function that decodes the next frame.
+//
+// Returns io.EOF after the last frame, or ErrMD5 if the MD5 of all decoded samples doesn't
+// match STREAMINFO. The MD5 is only checked if every frame was decoded.
+func (decoder *Decoder) Next() (*Frame, error) {
+	frame, err := ReadFrame(decoder.reader, decoder.StreamInfo)
+	if err == io.EOF {
+		if decoder.md5 != nil && decoder.StreamInfo.MD5 != [16]byte{} && !decoder.checkMD5() {
+			return nil, ErrMD5
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		decoder.md5 = nil
+		return nil, err
+	}
+	if frame.Header.Channels != decoder.StreamInfo.Channels || frame.Header.BitsPerSample != decoder.StreamInfo.BitsPerSample {
+		decoder.md5 = nil
+		return nil, ErrFrame
+	}
+	decoder.samples += uint64(frame.Header.BlockSize)
+	if decoder.md5 != nil {
+		decoder.hash(frame)
+	}
+	return frame, nil
+}
+
+// Samples is a function that returns the number of samples per channel decoded so far.
+func (decoder *Decoder) Samples() uint64 {
+	return decoder.samples
+}
+
+// hash is a private function that adds the samples of a frame to the MD5, interleaved and
+// little-endian in whole bytes.
+func (decoder *Decoder) hash(frame *Frame) {
+	width := (frame.Header.BitsPerSample + 7) / 8
+	size := frame.Header.BlockSize * frame.Header.Channels * width
+	if cap(decoder.buffer) < size {
+		decoder.buffer = make([]byte, size)
+	}
+	buffer := decoder.buffer[:size]
+	offset := 0
+	for i := 0; i < frame.Header.BlockSize; i++ {
+		for _, channel := range frame.Samples {
+			for b := 0; b < width; b++ {
+				buffer[offset] = byte(channel[i] >> (8 * b))
+				offset++
+			}
+		}
+	}
+	decoder.md5.Write(buffer)
+}
+
+// checkMD5 is a private function that returns whether the MD5 of the decoded samples matches STREAMINFO.
+func (decoder *Decoder) checkMD5() bool {
+	var sum [16]byte
+	copy(sum[:], decoder.md5.Sum(nil))
+	return sum == decoder.StreamInfo.MD5
+}
+
+// skipID3 is a private function that skips the ID3v2 tags at the start of the stream.
+func skipID3(reader *bitreader.Reader) error {
+	for {
+		if magic, err := reader.PeekBits(24); err != nil || magic != 0x494433 {
+			// "ID3", a stream too short for a tag is left to the signature check
+			return nil
+		}
+		if err := reader.SkipBits(40); err != nil {
+			return noEOF(err)
+		}
+		flags, err := reader.ReadBits(8)
+		if err != nil {
+			return noEOF(err)
+		}
+		// The size is 4 bytes of 7 bits each, so that it has no sync code
+		var size uint64
+		for i := 0; i < 4; i++ {
+			b, err := reader.ReadBits(8)
+			if err != nil {
+				return noEOF(err)
+			}
+			size = size<<7 | b&0x7f
+		}
+		if flags&0x10 != 0 {
+			size += 10
+		}
+		if err := reader.SkipBytes(size); err != nil {
+			return noEOF(err)
+		}
+	}
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pektezol/bitreader"
+)
+
+const (
+	// firstFrame is the offset of the first frame of testdata/rfc9639-d2.flac
+	firstFrame = 108
+	// secondFrame is the offset of its second and last frame
+	secondFrame = 176
+)
+
+// reference returns a stream of testdata and its .raw file, the samples the reference
+// decoder writes for it, see testdata/README.md.
+func reference(t *testing.T, name string) (data []byte, raw []byte) {
+	data, err := os.ReadFile("testdata/" + name + ".flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err = os.ReadFile("testdata/" + name + ".raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, raw
+}
+
+// decodeAll decodes every frame of a stream and returns them with the samples by channel.
+func decodeAll(reader *bitreader.Reader) (*Decoder, []*Frame, [][]int32, error) {
+	decoder, err := NewDecoder(reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var frames []*Frame
+	samples := make([][]int32, decoder.StreamInfo.Channels)
+	for {
+		frame, err := decoder.Next()
+		if err == io.EOF {
+			return decoder, frames, samples, nil
+		}
+		if err != nil {
+			return decoder, frames, samples, err
+		}
+		frames = append(frames, frame)
+		for channel := range samples {
+			samples[channel] = append(samples[channel], frame.Samples[channel]...)
+		}
+	}
+}
+
+// interleave lays out samples the way flac -d --force-raw-format --endian=little --sign=signed does.
+func interleave(samples [][]int32, bitsPerSample int) []byte {
+	var out []byte
+	for i := range samples[0] {
+		for _, channel := range samples {
+			for shift := 0; shift < bitsPerSample; shift += 8 {
+				out = append(out, byte(channel[i]>>shift))
+			}
+		}
+	}
+	return out
+}
+
+func TestDecodeReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      StreamInfo
+		subframes [][]Subframe
+		channels  []ChannelAssignment
+	}{
+		{"rfc9639-d1", StreamInfo{
+			MinBlockSize: 4096, MaxBlockSize: 4096, MinFrameSize: 15, MaxFrameSize: 15, SampleRate: 44100,
+			Channels: 2, BitsPerSample: 16, TotalSamples: 1,
+			MD5: [16]byte{0x3e, 0x84, 0xb4, 0x18, 0x07, 0xdc, 0x69, 0x03, 0x07, 0x58, 0x6a, 0x3d, 0xad, 0x1a, 0x2e, 0x0f},
+		}, [][]Subframe{
+			{{Type: SubframeVerbatim, WastedBits: 2}, {Type: SubframeVerbatim, WastedBits: 4}},
+		}, []ChannelAssignment{Independent}},
+		{"rfc9639-d2", StreamInfo{
+			MinBlockSize: 16, MaxBlockSize: 16, MinFrameSize: 23, MaxFrameSize: 68, SampleRate: 44100,
+			Channels: 2, BitsPerSample: 16, TotalSamples: 19,
+			MD5: [16]byte{0xd5, 0xb0, 0x56, 0x49, 0x75, 0xe9, 0x8b, 0x8d, 0x8b, 0x93, 0x04, 0x22, 0x75, 0x7b, 0x81, 0x03},
+		}, [][]Subframe{
+			{{Type: SubframeFixed, Order: 1}, {Type: SubframeFixed, Order: 1}},
+			{{Type: SubframeVerbatim}, {Type: SubframeVerbatim, WastedBits: 1}},
+		}, []ChannelAssignment{SideRight, Independent}},
+		{"rfc9639-d3", StreamInfo{
+			MinBlockSize: 4096, MaxBlockSize: 4096, MinFrameSize: 31, MaxFrameSize: 31, SampleRate: 32000,
+			Channels: 1, BitsPerSample: 8, TotalSamples: 24,
+			MD5: [16]byte{0xf8, 0xf9, 0xe3, 0x96, 0xf5, 0xcb, 0xcf, 0xc6, 0xdc, 0x80, 0x7f, 0x99, 0x77, 0x90, 0x6b, 0x32},
+		}, [][]Subframe{
+			{{Type: SubframeLPC, Order: 3}},
+		}, []ChannelAssignment{Independent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, raw := reference(t, tt.name)
+			if sum := md5.Sum(raw); sum != tt.info.MD5 {
+				t.Fatalf("MD5 of the .raw file = %x, want the STREAMINFO MD5 %x", sum, tt.info.MD5)
+			}
+			// A nil error at the end means the decoded samples matched the STREAMINFO MD5
+			decoder, frames, samples, err := decodeAll(bitreader.NewReaderFromBytes(data, false))
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(*decoder.StreamInfo, tt.info) {
+				t.Errorf("StreamInfo = %+v, want %+v", *decoder.StreamInfo, tt.info)
+			}
+			if len(frames) != len(tt.subframes) {
+				t.Fatalf("%d frames, want %d", len(frames), len(tt.subframes))
+			}
+			for i, frame := range frames {
+				if !reflect.DeepEqual(frame.Subframes, tt.subframes[i]) || frame.Header.ChannelAssignment != tt.channels[i] {
+					t.Errorf("frame %d: %v %+v, want %v %+v", i, frame.Header.ChannelAssignment, frame.Subframes, tt.channels[i], tt.subframes[i])
+				}
+			}
+			if decoder.Samples() != tt.info.TotalSamples {
+				t.Errorf("Samples() = %d, want %d", decoder.Samples(), tt.info.TotalSamples)
+			}
+			got := interleave(samples, tt.info.BitsPerSample)
+			if len(got) != len(raw) {
+				t.Fatalf("decoded %d bytes, want %d", len(got), len(raw))
+			}
+			for i := range raw {
+				if got[i] != raw[i] {
+					t.Fatalf("byte %d of the decoded samples = %#x, want %#x", i, got[i], raw[i])
+				}
+			}
+			// The same from a stream that returns one byte at a time
+			_, _, streamed, err := decodeAll(bitreader.NewReader(iotest.OneByteReader(bytes.NewReader(data)), false))
+			if err != nil || !reflect.DeepEqual(streamed, samples) {
+				t.Errorf("streamed decode error = %v, samples = %v", err, streamed)
+			}
+		})
+	}
+}
+
+func TestDecoderMD5(t *testing.T) {
+	data, _ := reference(t, "rfc9639-d2")
+	data = append([]byte(nil), data...)
+	data[8+18] ^= 1
+	if _, _, _, err := decodeAll(bitreader.NewReaderFromBytes(data, false)); err != ErrMD5 {
+		t.Errorf("error = %v, want %v", err, ErrMD5)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	valid, _ := reference(t, "rfc9639-d2")
+	modify := func(change func(data []byte) []byte) []byte {
+		return change(append([]byte(nil), valid...))
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"empty", nil, ErrSignature},
+		{"not flac", []byte("RIFF....WAVEfmt "), ErrSignature},
+		{"truncated metadata", valid[:20], io.ErrUnexpectedEOF},
+		{"no streaminfo", modify(func(data []byte) []byte {
+			return append(append([]byte("fLaC"), metadataBlock(BlockPadding, make([]byte, 4))...), data[4:]...)
+		}), ErrMetadata},
+		{"streaminfo twice", modify(func(data []byte) []byte {
+			return append(data[:42], append(data[4:42], data[42:]...)...)
+		}), ErrMetadata},
+		{"header CRC", modify(func(data []byte) []byte {
+			data[firstFrame+5] ^= 1
+			return data
+		}), ErrCRC},
+		{"frame CRC", modify(func(data []byte) []byte {
+			data[secondFrame-1] ^= 1
+			return data
+		}), ErrCRC},
+		{"zeroed frame", modify(func(data []byte) []byte {
+			for i := firstFrame + 8; i < secondFrame; i++ {
+				data[i] = 0
+			}
+			return data
+		}), ErrFrame},
+		// The STREAMINFO MD5 also covers the samples of the second frame
+		{"MD5", valid[:secondFrame], ErrMD5},
+		{"truncated frame", valid[:firstFrame+20], io.ErrUnexpectedEOF},
+		{"trailing garbage", append(append([]byte(nil), valid...), 0x12, 0x34), ErrSync},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := decodeAll(bitreader.NewReaderFromBytes(tt.data, false))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecoderNoMD5(t *testing.T) {
+	data, _ := reference(t, "rfc9639-d2")
+	// A changed sample isn't noticed without an MD5
+	data = append([]byte(nil), data...)
+	data[secondFrame+8] ^= 1
+	data[len(data)-2], data[len(data)-1] = 0, 0
+	crc := crc16(data[secondFrame : len(data)-2])
+	data[len(data)-2], data[len(data)-1] = byte(crc>>8), byte(crc)
+	for i := 4 + 4 + 18; i < 4+4+34; i++ {
+		data[i] = 0
+	}
+	if _, _, _, err := decodeAll(bitreader.NewReaderFromBytes(data, false)); err != nil {
+		t.Errorf("error = %v, want nil without an MD5", err)
+	}
+}
+
+func TestDecoderID3(t *testing.T) {
+	data, _ := reference(t, "rfc9639-d2")
+	_, _, want, err := decodeAll(bitreader.NewReaderFromBytes(data, false))
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	tag := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x05"), "title"...)
+	decoder, _, got, err := decodeAll(bitreader.NewReaderFromBytes(append(tag, data...), false))
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) || decoder.StreamInfo.SampleRate != 44100 {
+		t.Errorf("decoded stream differs after an ID3v2 tag")
+	}
+}
+
+func TestLittleEndian(t *testing.T) {
+	data, _ := reference(t, "rfc9639-d2")
+	if _, err := NewDecoder(bitreader.NewReaderFromBytes(data, true)); err != ErrLittleEndian {
+		t.Errorf("NewDecoder() error = %v, want %v", err, ErrLittleEndian)
+	}
+	if _, _, err := ReadBlock(bitreader.NewReaderFromBytes(data[4:], true)); err != ErrLittleEndian {
+		t.Errorf("ReadBlock() error = %v, want %v", err, ErrLittleEndian)
+	}
+	if _, err := ReadFrame(bitreader.NewReaderFromBytes(data[firstFrame:], true), nil); err != ErrLittleEndian {
+		t.Errorf("ReadFrame() error = %v, want %v", err, ErrLittleEndian)
+	}
+}
@@ -0,0 +1,451 @@
+package flac
+
+import (
+	"io"
+
+	"github.com/pektezol/bitreader"
+)
+
+// ChannelAssignment is how the channels of a frame are coded.
+type ChannelAssignment uint8
+
+const (
+	Independent ChannelAssignment = iota // Every channel is coded as is
+	LeftSide                             // Left and side, right = left - side
+	SideRight                            // Side and right, left = side + right
+	MidSide                              // Mid and side
+)
+
+// SubframeType is the prediction method of a subframe.
+type SubframeType uint8
+
+const (
+	SubframeConstant SubframeType = iota // One value for the whole block
+	SubframeVerbatim                     // Unencoded samples
+	SubframeFixed                        // Fixed polynomial predictor of order 0 to 4
+	SubframeLPC                          // Linear predictor of order 1 to 32
+)
+
+// FrameHeader is the header of a FLAC frame.
+//
+// VariableBlockSize bool					Whether Number is a sample number instead of a frame number
+// BlockSize int							Number of samples per channel in the frame
+// SampleRate int							Sample rate in Hz
+// Channels int								Number of channels
+// ChannelAssignment ChannelAssignment		How the channels are coded
+// BitsPerSample int						Bits per sample
+// Number uint64							Frame number, or the first sample number for variable block sizes
+// CRC8 uint8								CRC-8 of the header
+type FrameHeader struct {
+	VariableBlockSize bool
+	BlockSize         int
+	SampleRate        int
+	Channels          int
+	ChannelAssignment ChannelAssignment
+	BitsPerSample     int
+	Number            uint64
+	CRC8              uint8
+}
+
+// Subframe is the header of a subframe, the coding of one channel in a frame.
+//
+// Type SubframeType	Prediction method
+// Order int			Predictor order of fixed and LPC subframes
+// WastedBits int		Number of zero low bits removed from every sample
+type Subframe struct {
+	Type       SubframeType
+	Order      int
+	WastedBits int
+}
+
+// Frame is a decoded FLAC frame.
+//
+// Header FrameHeader		The frame header
+// Subframes []Subframe		The subframe headers by channel
+// Samples [][]int32		The decoded samples by channel
+// CRC16 uint16				CRC-16 of the frame
+type Frame struct {
+	Header    FrameHeader
+	Subframes []Subframe
+	Samples   [][]int32
+	CRC16     uint16
+}
+
+// sampleRates are the sample rates in Hz of sample rate codes 1 to 11.
+var sampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+// sampleSizes are the bits per sample of sample size codes 1 to 7, 3 is reserved.
+var sampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// ReadFrame is a function that reads and decodes the frame at the current Reader position,
+// which must be byte-aligned like every FLAC frame. Frame header fields that refer to the
+// STREAMINFO block are taken from info, which may be nil if the frame header has them all.
+//
+// Returns io.EOF if there are no more frames, ErrSync if the Reader is not at a frame sync
+// code, ErrFrame if the frame is malformed, ErrCRC if a CRC doesn't match.
+func ReadFrame(reader *bitreader.Reader, info *StreamInfo) (*Frame, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	sync, err := reader.PeekBits(15)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sync != 0x7ffc || reader.BitPosition()%8 != 0 {
+		return nil, ErrSync
+	}
+	r := &frameReader{reader: reader}
+	crc16, _ := bitreader.NewCRC(bitreader.CRC16)
+	checksum := reader.BeginChecksum(crc16)
+	fail := func(err error) (*Frame, error) {
+		reader.EndChecksum(checksum)
+		return nil, err
+	}
+	header, err := r.header(info)
+	if err != nil {
+		return fail(err)
+	}
+	frame := &Frame{Header: *header, Subframes: make([]Subframe, header.Channels)}
+	residuals := make([][]int64, header.Channels)
+	for channel := range residuals {
+		depth := header.BitsPerSample
+		// The side channel has one more bit
+		if ((header.ChannelAssignment == LeftSide || header.ChannelAssignment == MidSide) && channel == 1) ||
+			(header.ChannelAssignment == SideRight && channel == 0) {
+			depth++
+		}
+		residuals[channel] = make([]int64, header.BlockSize)
+		if frame.Subframes[channel], err = r.subframe(residuals[channel], depth); err != nil {
+			return fail(err)
+		}
+	}
+	// Zero padding to a byte boundary, then the CRC-16 of everything before it
+	if padding := r.bits(uint(8-reader.BitPosition()%8) % 8); padding != 0 && r.err == nil {
+		return fail(ErrFrame)
+	}
+	reader.EndChecksum(checksum)
+	frame.CRC16 = uint16(r.bits(16))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if frame.CRC16 != uint16(crc16.Sum32()) {
+		return nil, ErrCRC
+	}
+	frame.Samples = decorrelate(residuals, header.ChannelAssignment)
+	return frame, nil
+}
+
+// frameReader reads the bits of a frame, keeping the first error.
+//
+// reader *bitreader.Reader		The Reader
+// err error					The first error
+type frameReader struct {
+	reader *bitreader.Reader
+	err    error
+}
+
+// bits is a private function that reads an unsigned value.
+func (r *frameReader) bits(count uint) uint64 {
+	if r.err != nil || count == 0 {
+		return 0
+	}
+	value, err := r.reader.ReadBits(uint64(count))
+	if err != nil {
+		r.err = noEOF(err)
+		return 0
+	}
+	return value
+}
+
+// signed is a private function that reads a two's complement value.
+func (r *frameReader) signed(count uint) int64 {
+	if count == 0 {
+		return 0
+	}
+	return int64(r.bits(count)<<(64-count)) >> (64 - count)
+}
+
+// unary is a private function that reads the number of zero bits before a one bit, up to limit.
+func (r *frameReader) unary(limit uint64) uint64 {
+	zeros := uint64(0)
+	for r.err == nil && r.bits(1) == 0 && r.err == nil {
+		if zeros == limit {
+			r.err = ErrFrame
+			return 0
+		}
+		zeros++
+	}
+	return zeros
+}
+
+// header is a private function that reads and checks the frame header.
+func (r *frameReader) header(info *StreamInfo) (*FrameHeader, error) {
+	header := &FrameHeader{}
+	crc8, _ := bitreader.NewCRC(bitreader.CRC8)
+	checksum := r.reader.BeginChecksum(crc8)
+	r.bits(15)
+	header.VariableBlockSize = r.bits(1) == 1
+	blockSizeCode := r.bits(4)
+	sampleRateCode := r.bits(4)
+	channels := r.bits(4)
+	sampleSizeCode := r.bits(3)
+	reserved := r.bits(1)
+	header.Number = r.utf8()
+	switch {
+	case blockSizeCode == 1:
+		header.BlockSize = 192
+	case blockSizeCode >= 2 && blockSizeCode <= 5:
+		header.BlockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		header.BlockSize = int(r.bits(8)) + 1
+	case blockSizeCode == 7:
+		header.BlockSize = int(r.bits(16)) + 1
+	case blockSizeCode >= 8:
+		header.BlockSize = 256 << (blockSizeCode - 8)
+	}
+	switch {
+	case sampleRateCode == 0 && info != nil:
+		header.SampleRate = info.SampleRate
+	case sampleRateCode >= 1 && sampleRateCode <= 11:
+		header.SampleRate = sampleRates[sampleRateCode]
+	case sampleRateCode == 12:
+		header.SampleRate = int(r.bits(8)) * 1000
+	case sampleRateCode == 13:
+		header.SampleRate = int(r.bits(16))
+	case sampleRateCode == 14:
+		header.SampleRate = int(r.bits(16)) * 10
+	}
+	switch {
+	case channels < 8:
+		header.Channels = int(channels) + 1
+	case channels <= 10:
+		header.Channels = 2
+		header.ChannelAssignment = ChannelAssignment(channels - 7)
+	}
+	if sampleSizeCode == 0 && info != nil {
+		header.BitsPerSample = info.BitsPerSample
+	} else {
+		header.BitsPerSample = sampleSizes[sampleSizeCode]
+	}
+	r.reader.EndChecksum(checksum)
+	header.CRC8 = uint8(r.bits(8))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if header.CRC8 != uint8(crc8.Sum32()) {
+		return nil, ErrCRC
+	}
+	if reserved != 0 || header.BlockSize == 0 || header.SampleRate == 0 || header.Channels == 0 || header.BitsPerSample == 0 {
+		return nil, ErrFrame
+	}
+	return header, nil
+}
+
+// utf8 is a private function that reads the frame or sample number, coded like UTF-8 with
+// up to 7 bytes for 36 bits.
+func (r *frameReader) utf8() uint64 {
+	first := r.bits(8)
+	length := 0
+	for first&(0x80>>length) != 0 && length < 8 {
+		length++
+	}
+	switch {
+	case length == 0:
+		return first
+	case length == 1 || length > 7:
+		r.err = ErrFrame
+		return 0
+	}
+	value := first & (0x7f >> length)
+	for i := 1; i < length; i++ {
+		next := r.bits(8)
+		if next&0xc0 != 0x80 && r.err == nil {
+			r.err = ErrFrame
+		}
+		value = value<<6 | next&0x3f
+	}
+	return value
+}
+
+// subframe is a private function that decodes a subframe of samples with depth bits into samples.
+func (r *frameReader) subframe(samples []int64, depth int) (Subframe, error) {
+	var subframe Subframe
+	padding := r.bits(1)
+	typ := r.bits(6)
+	if r.bits(1) == 1 {
+		subframe.WastedBits = int(r.unary(uint64(depth))) + 1
+	}
+	if r.err != nil {
+		return subframe, r.err
+	}
+	depth -= subframe.WastedBits
+	if padding != 0 || depth <= 0 {
+		return subframe, ErrFrame
+	}
+	switch {
+	case typ == 0:
+		subframe.Type = SubframeConstant
+		value := r.signed(uint(depth))
+		for i := range samples {
+			samples[i] = value
+		}
+	case typ == 1:
+		subframe.Type = SubframeVerbatim
+		for i := range samples {
+			samples[i] = r.signed(uint(depth))
+		}
+	case typ >= 8 && typ <= 12:
+		subframe.Type = SubframeFixed
+		subframe.Order = int(typ - 8)
+		if err := r.fixed(samples, subframe.Order, depth); err != nil {
+			return subframe, err
+		}
+	case typ >= 32:
+		subframe.Type = SubframeLPC
+		subframe.Order = int(typ-32) + 1
+		if err := r.lpc(samples, subframe.Order, depth); err != nil {
+			return subframe, err
+		}
+	default:
+		return subframe, ErrFrame
+	}
+	if r.err != nil {
+		return subframe, r.err
+	}
+	if subframe.WastedBits > 0 {
+		for i := range samples {
+			samples[i] <<= subframe.WastedBits
+		}
+	}
+	return subframe, nil
+}
+
+// fixed is a private function that decodes the warm-up samples, residual and fixed prediction
+// of a subframe.
+func (r *frameReader) fixed(samples []int64, order int, depth int) error {
+	if order > len(samples) {
+		return ErrFrame
+	}
+	for i := 0; i < order; i++ {
+		samples[i] = r.signed(uint(depth))
+	}
+	if err := r.residual(samples, order); err != nil {
+		return err
+	}
+	for i := order; i < len(samples); i++ {
+		switch order {
+		case 1:
+			samples[i] += samples[i-1]
+		case 2:
+			samples[i] += 2*samples[i-1] - samples[i-2]
+		case 3:
+			samples[i] += 3*samples[i-1] - 3*samples[i-2] + samples[i-3]
+		case 4:
+			samples[i] += 4*samples[i-1] - 6*samples[i-2] + 4*samples[i-3] - samples[i-4]
+		}
+	}
+	return nil
+}
+
+// lpc is a private function that decodes the warm-up samples, coefficients, residual and
+// linear prediction of a subframe.
+func (r *frameReader) lpc(samples []int64, order int, depth int) error {
+	if order > len(samples) {
+		return ErrFrame
+	}
+	for i := 0; i < order; i++ {
+		samples[i] = r.signed(uint(depth))
+	}
+	precision := r.bits(4) + 1
+	shift := r.signed(5)
+	if r.err == nil && (precision == 16 || shift < 0) {
+		return ErrFrame
+	}
+	coefficients := make([]int64, order)
+	for i := range coefficients {
+		coefficients[i] = r.signed(uint(precision))
+	}
+	if err := r.residual(samples, order); err != nil {
+		return err
+	}
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, coefficient := range coefficients {
+			prediction += coefficient * samples[i-1-j]
+		}
+		samples[i] += prediction >> shift
+	}
+	return nil
+}
+
+// residual is a private function that decodes the Rice coded residual of a subframe into
+// samples after the warm-up samples.
+func (r *frameReader) residual(samples []int64, order int) error {
+	method := r.bits(2)
+	partitionOrder := r.bits(4)
+	if r.err != nil {
+		return r.err
+	}
+	if method > 1 {
+		return ErrFrame
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partitions := 1 << partitionOrder
+	size := len(samples) >> partitionOrder
+	if size<<partitionOrder != len(samples) || size < order {
+		return ErrFrame
+	}
+	i := order
+	for partition := 0; partition < partitions; partition++ {
+		end := (partition + 1) * size
+		param := r.bits(paramBits)
+		if param == escape {
+			raw := uint(r.bits(5))
+			for ; i < end; i++ {
+				samples[i] = r.signed(raw)
+			}
+			continue
+		}
+		for ; i < end && r.err == nil; i++ {
+			// Folded residuals fit 32 bits, a longer quotient is corrupt
+			folded := r.unary(uint64(1)<<32>>param)<<param | r.bits(uint(param))
+			samples[i] = int64(folded>>1) ^ -int64(folded&1)
+		}
+	}
+	return r.err
+}
+
+// decorrelate is a private function that restores the left and right channels of stereo
+// decorrelation and returns the samples.
+func decorrelate(channels [][]int64, assignment ChannelAssignment) [][]int32 {
+	switch assignment {
+	case LeftSide:
+		for i, side := range channels[1] {
+			channels[1][i] = channels[0][i] - side
+		}
+	case SideRight:
+		for i, side := range channels[0] {
+			channels[0][i] = side + channels[1][i]
+		}
+	case MidSide:
+		for i, side := range channels[1] {
+			mid := channels[0][i]<<1 | side&1
+			channels[0][i] = (mid + side) >> 1
+			channels[1][i] = (mid - side) >> 1
+		}
+	}
+	samples := make([][]int32, len(channels))
+	for channel, values := range channels {
+		samples[channel] = make([]int32, len(values))
+		for i, value := range values {
+			samples[channel][i] = int32(value)
+		}
+	}
+	return samples
+}
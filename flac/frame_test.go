@@ -0,0 +1,302 @@
+package flac
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// frameWith returns a frame with the header fields after the sync code from header, and the
+// subframes from body, or a mono 16-bit verbatim subframe of 16 samples if it is nil.
+func frameWith(header func(w *bitWriter), body func(w *bitWriter)) []byte {
+	w := &bitWriter{}
+	w.u(15, 0x7ffc)
+	header(w)
+	w.u(8, uint64(crc8(w.data)))
+	if body == nil {
+		body = func(w *bitWriter) {
+			w.u(8, 1<<1)
+			for i := 0; i < 16; i++ {
+				w.s(16, int64(i*100-800))
+			}
+		}
+	}
+	body(w)
+	w.align()
+	return w.u(16, uint64(crc16(w.data))).data
+}
+
+// monoHeader writes a fixed block size header of 16 samples at 44100 Hz, mono and 16-bit.
+func monoHeader(w *bitWriter) {
+	w.u(1, 0).u(4, 6).u(4, 9).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(8, 15)
+}
+
+func TestReadFrameHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header func(w *bitWriter)
+		info   *StreamInfo
+		want   FrameHeader
+	}{
+		{"16 samples", monoHeader, nil, FrameHeader{BlockSize: 16, SampleRate: 44100, Channels: 1, BitsPerSample: 16}},
+		{"kHz rate", func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 12).u(4, 0).u(3, 4).u(1, 0).u(8, 3).u(8, 15).u(8, 11)
+		}, nil, FrameHeader{BlockSize: 16, SampleRate: 11000, Channels: 1, BitsPerSample: 16, Number: 3}},
+		{"Hz rate", func(w *bitWriter) {
+			w.u(1, 0).u(4, 7).u(4, 13).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(16, 15).u(16, 12345)
+		}, nil, FrameHeader{BlockSize: 16, SampleRate: 12345, Channels: 1, BitsPerSample: 16}},
+		{"tens of Hz rate", func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 14).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(8, 15).u(16, 35280)
+		}, nil, FrameHeader{BlockSize: 16, SampleRate: 352800, Channels: 1, BitsPerSample: 16}},
+		{"from streaminfo", func(w *bitWriter) {
+			w.u(1, 1).u(4, 6).u(4, 0).u(4, 0).u(3, 0).u(1, 0)
+			writeUTF8(w, 1<<35+7)
+			w.u(8, 15)
+		}, &StreamInfo{SampleRate: 7350, BitsPerSample: 16}, FrameHeader{
+			VariableBlockSize: true, BlockSize: 16, SampleRate: 7350, Channels: 1, BitsPerSample: 16, Number: 1<<35 + 7,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := frameWith(tt.header, nil)
+			got, err := ReadFrame(bitreader.NewReaderFromBytes(data, false), tt.info)
+			if err != nil {
+				t.Fatalf("ReadFrame() error = %v", err)
+			}
+			tt.want.CRC8 = got.Header.CRC8
+			if got.Header != tt.want {
+				t.Errorf("ReadFrame() header = %+v, want %+v", got.Header, tt.want)
+			}
+			if want := []Subframe{{Type: SubframeVerbatim}}; !reflect.DeepEqual(got.Subframes, want) {
+				t.Errorf("ReadFrame() subframes = %+v, want %+v", got.Subframes, want)
+			}
+			if got.Samples[0][15] != 700 {
+				t.Errorf("ReadFrame() last sample = %d, want 700", got.Samples[0][15])
+			}
+		})
+	}
+}
+
+// stereoHeader returns a header writer for a 16 sample stereo frame at 44100 Hz with the
+// given channel and sample size codes.
+func stereoHeader(channels, sampleSize uint64) func(w *bitWriter) {
+	return func(w *bitWriter) {
+		w.u(1, 0).u(4, 6).u(4, 9).u(4, channels).u(3, sampleSize).u(1, 0).u(8, 0).u(8, 15)
+	}
+}
+
+// rice writes residuals as Rice codes with the given parameter.
+func rice(w *bitWriter, param uint, residuals ...int64) {
+	for _, residual := range residuals {
+		folded := uint64(residual << 1)
+		if residual < 0 {
+			folded = uint64(-residual<<1 - 1)
+		}
+		w.unary(folded>>param).u(param, folded&(1<<param-1))
+	}
+}
+
+// verbatim writes a verbatim subframe of values with depth bits.
+func verbatim(w *bitWriter, depth uint, values ...int64) {
+	w.u(8, 1<<1)
+	for _, value := range values {
+		w.s(depth, value)
+	}
+}
+
+// TestReadFrameSubframes decodes the subframe codings and channel assignments that no stream
+// of testdata has, see testdata/README.md.
+func TestReadFrameSubframes(t *testing.T) {
+	repeat := func(value int32) []int32 {
+		out := make([]int32, 16)
+		for i := range out {
+			out[i] = value
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		header    func(w *bitWriter)
+		body      func(w *bitWriter)
+		subframes []Subframe
+		want      [][]int32
+	}{
+		{"constant", monoHeader, func(w *bitWriter) {
+			w.u(8, 0).s(16, -300)
+		}, []Subframe{{Type: SubframeConstant}}, [][]int32{repeat(-300)}},
+		{"fixed order 0 escaped", monoHeader, func(w *bitWriter) {
+			w.u(8, 8<<1).u(2, 0).u(4, 0).u(4, 15).u(5, 5)
+			for i := 0; i < 16; i++ {
+				w.s(5, int64(i-8))
+			}
+		}, []Subframe{{Type: SubframeFixed}}, [][]int32{{-8, -7, -6, -5, -4, -3, -2, -1, 0, 1, 2, 3, 4, 5, 6, 7}}},
+		{"fixed order 2 with two partitions", monoHeader, func(w *bitWriter) {
+			w.u(8, 10<<1).s(16, 100).s(16, 90).u(2, 0).u(4, 1)
+			rice(w.u(4, 1), 1, 1, -1, 0, 2, -2, 0)
+			rice(w.u(4, 2), 2, 3, -3, 1, 0, 0, -1, 2, 1)
+		}, []Subframe{{Type: SubframeFixed, Order: 2}}, [][]int32{{100, 90, 81, 71, 61, 53, 43, 33, 26, 16, 7, -2, -11, -21, -29, -36}}},
+		{"fixed order 3", monoHeader, func(w *bitWriter) {
+			// Without a residual the predictor continues the squares
+			w.u(8, 11<<1).s(16, 0).s(16, 1).s(16, 4).u(2, 0).u(4, 0)
+			rice(w.u(4, 0), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+		}, []Subframe{{Type: SubframeFixed, Order: 3}}, [][]int32{{0, 1, 4, 9, 16, 25, 36, 49, 64, 81, 100, 121, 144, 169, 196, 225}}},
+		{"fixed order 4", monoHeader, func(w *bitWriter) {
+			// And this one the cubes
+			w.u(8, 12<<1).s(16, 0).s(16, 1).s(16, 8).s(16, 27).u(2, 0).u(4, 0)
+			rice(w.u(4, 0), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+		}, []Subframe{{Type: SubframeFixed, Order: 4}}, [][]int32{{0, 1, 8, 27, 64, 125, 216, 343, 512, 729, 1000, 1331, 1728, 2197, 2744, 3375}}},
+		{"lpc order 2 with 5-bit parameters", monoHeader, func(w *bitWriter) {
+			// Coefficients 3 and -1 at 4 bits with a shift of 1
+			w.u(8, 33<<1).s(16, 50).s(16, 60).u(4, 3).s(5, 1).s(4, 3).s(4, -1).u(2, 1).u(4, 0)
+			rice(w.u(5, 3), 3, 0, 1, -1, 2, -2, 3, -3, 4, -4, 5, -5, 6, -6, 7)
+		}, []Subframe{{Type: SubframeLPC, Order: 2}}, [][]int32{{50, 60, 65, 68, 68, 70, 69, 71, 69, 72, 69, 72, 68, 72, 68, 73}}},
+		{"left side", stereoHeader(8, 4), func(w *bitWriter) {
+			verbatim(w, 16, 1000, 963, 926, 889, 852, 815, 778, 741, 704, 667, 630, 593, 556, 519, 482, 445)
+			w.u(8, 0).s(17, 5)
+		}, []Subframe{{Type: SubframeVerbatim}, {Type: SubframeConstant}}, [][]int32{
+			{1000, 963, 926, 889, 852, 815, 778, 741, 704, 667, 630, 593, 556, 519, 482, 445},
+			{995, 958, 921, 884, 847, 810, 773, 736, 699, 662, 625, 588, 551, 514, 477, 440},
+		}},
+		{"mid side", stereoHeader(10, 4), func(w *bitWriter) {
+			verbatim(w, 16, 0, 1, -1, 100, -100, -1, -1, 5, 6, 7, 8, 9, 10, 11, 12, 13)
+			verbatim(w, 17, 0, 1, -1, 3, -3, 65535, -65535, 2, -2, 1, -1, 0, 4, -4, 8, -8)
+		}, []Subframe{{Type: SubframeVerbatim}, {Type: SubframeVerbatim}}, [][]int32{
+			{0, 2, -1, 102, -101, 32767, -32768, 6, 5, 8, 8, 9, 12, 9, 16, 9},
+			{0, 1, 0, 99, -98, -32768, 32767, 4, 7, 7, 9, 9, 8, 13, 8, 17},
+		}},
+		{"24-bit independent", stereoHeader(1, 6), func(w *bitWriter) {
+			w.u(8, 0).s(24, -8388608).u(8, 0).s(24, 8388607)
+		}, []Subframe{{Type: SubframeConstant}, {Type: SubframeConstant}}, [][]int32{repeat(-8388608), repeat(8388607)}},
+		{"32-bit left side", stereoHeader(8, 7), func(w *bitWriter) {
+			// The 33-bit side channel of a full scale left and right
+			w.u(8, 0).s(32, 2147483647).u(8, 0).s(33, 4294967295)
+		}, []Subframe{{Type: SubframeConstant}, {Type: SubframeConstant}}, [][]int32{repeat(2147483647), repeat(-2147483648)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFrame(bitreader.NewReaderFromBytes(frameWith(tt.header, tt.body), false), nil)
+			if err != nil {
+				t.Fatalf("ReadFrame() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Subframes, tt.subframes) {
+				t.Errorf("ReadFrame() subframes = %+v, want %+v", got.Subframes, tt.subframes)
+			}
+			if !reflect.DeepEqual(got.Samples, tt.want) {
+				t.Errorf("ReadFrame() samples = %v, want %v", got.Samples, tt.want)
+			}
+		})
+	}
+}
+
+func TestUTF8(t *testing.T) {
+	for _, value := range []uint64{0, 0x7f, 0x80, 0x7ff, 0x800, 0xffff, 1 << 20, 1<<26 - 1, 1 << 30, 1<<36 - 1} {
+		w := &bitWriter{}
+		writeUTF8(w, value)
+		r := &frameReader{reader: bitreader.NewReaderFromBytes(w.data, false)}
+		if got := r.utf8(); got != value || r.err != nil {
+			t.Errorf("utf8() = %#x, %v, want %#x", got, r.err, value)
+		}
+	}
+	for _, data := range [][]byte{{0x80}, {0xff}, {0xc2, 0x41}} {
+		r := &frameReader{reader: bitreader.NewReaderFromBytes(data, false)}
+		if r.utf8(); r.err != ErrFrame {
+			t.Errorf("utf8(% x) error = %v, want %v", data, r.err, ErrFrame)
+		}
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	// lpc writes an order 1 LPC subframe with the given precision and shift
+	lpc := func(precision, shift uint64) func(w *bitWriter) {
+		return func(w *bitWriter) {
+			w.u(8, 32<<1).s(16, 1).u(4, precision-1).u(5, shift).s(uint(precision), 1)
+			w.u(2, 0).u(4, 0).u(4, 0)
+			for i := 1; i < 16; i++ {
+				w.unary(0)
+			}
+		}
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		info    *StreamInfo
+		wantErr error
+	}{
+		{"reserved bit", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 9).u(4, 0).u(3, 4).u(1, 1).u(8, 0).u(8, 15)
+		}, nil), nil, ErrFrame},
+		{"reserved block size", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 0).u(4, 9).u(4, 0).u(3, 4).u(1, 0).u(8, 0)
+		}, nil), nil, ErrFrame},
+		{"reserved sample rate", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 15).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(8, 15)
+		}, nil), nil, ErrFrame},
+		{"rate without streaminfo", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 0).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(8, 15)
+		}, nil), nil, ErrFrame},
+		{"reserved channels", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 9).u(4, 11).u(3, 4).u(1, 0).u(8, 0).u(8, 15)
+		}, nil), nil, ErrFrame},
+		{"reserved sample size", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 9).u(4, 0).u(3, 3).u(1, 0).u(8, 0).u(8, 15)
+		}, nil), nil, ErrFrame},
+		{"reserved subframe type", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 2<<1)
+		}), nil, ErrFrame},
+		{"subframe padding", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 0x80).s(16, 0)
+		}), nil, ErrFrame},
+		{"wasted bits", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 1).unary(15).s(0, 0)
+		}), nil, ErrFrame},
+		{"fixed order", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 13<<1)
+		}), nil, ErrFrame},
+		{"lpc precision", frameWith(monoHeader, lpc(16, 0)), nil, ErrFrame},
+		{"lpc shift", frameWith(monoHeader, lpc(15, 0x1f)), nil, ErrFrame},
+		{"residual method", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 9<<1).s(16, 0).u(2, 2)
+		}), nil, ErrFrame},
+		{"partition order", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 10<<1).s(16, 0).s(16, 0).u(2, 0).u(4, 4)
+		}), nil, ErrFrame},
+		{"partition order not dividing the block size", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 9).u(4, 0).u(3, 4).u(1, 0).u(8, 0).u(8, 14)
+		}, func(w *bitWriter) {
+			w.u(8, 8<<1).u(2, 0).u(4, 1)
+		}), nil, ErrFrame},
+		{"rice quotient", frameWith(monoHeader, func(w *bitWriter) {
+			w.u(8, 8<<1).u(2, 0).u(4, 0).u(4, 14)
+			for i := 0; i < 1<<18+1; i++ {
+				w.u(1, 0)
+			}
+		}), nil, ErrFrame},
+		{"frame padding", frameWith(func(w *bitWriter) {
+			w.u(1, 0).u(4, 6).u(4, 9).u(4, 0).u(3, 2).u(1, 0).u(8, 0).u(8, 15)
+		}, func(w *bitWriter) {
+			w.u(8, 0).s(12, 5).u(3, 1)
+		}), nil, ErrFrame},
+		{"no sync", []byte{0xff, 0xf0, 0}, nil, ErrSync},
+		{"end", nil, nil, io.EOF},
+		{"truncated", frameWith(monoHeader, nil)[:20], nil, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadFrame(bitreader.NewReaderFromBytes(tt.data, false), tt.info); err != tt.wantErr {
+				t.Errorf("ReadFrame() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFrameUnaligned(t *testing.T) {
+	frame := frameWith(monoHeader, nil)
+	reader := bitreader.NewReaderFromBytes(append([]byte{0x03}, frame...), false)
+	reader.SkipBits(6)
+	// The sync code is at bit 6, but a frame starts on a byte
+	if _, err := ReadFrame(reader, nil); err != ErrSync {
+		t.Errorf("ReadFrame() error = %v, want %v", err, ErrSync)
+	}
+}
@@ -0,0 +1,407 @@
+package flac
+
+import (
+	"encoding/binary"
+
+	"github.com/pektezol/bitreader"
+)
+
+// BlockType is the type of a metadata block.
+type BlockType uint8
+
+const (
+	BlockStreamInfo    BlockType = 0 // STREAMINFO, always the first block
+	BlockPadding       BlockType = 1 // PADDING
+	BlockApplication   BlockType = 2 // APPLICATION
+	BlockSeekTable     BlockType = 3 // SEEKTABLE
+	BlockVorbisComment BlockType = 4 // VORBIS_COMMENT, the tags
+	BlockCueSheet      BlockType = 5 // CUESHEET
+	BlockPicture       BlockType = 6 // PICTURE
+)
+
+// Block is a metadata block, one of *StreamInfo, *Padding, *Application, *SeekTable,
+// *VorbisComment, *CueSheet, *Picture and *UnknownBlock.
+type Block interface {
+	Type() BlockType
+}
+
+// StreamInfo is the STREAMINFO block, the properties of the whole stream.
+//
+// MinBlockSize int			Minimum block size in samples, the last block excluded
+// MaxBlockSize int			Maximum block size in samples
+// MinFrameSize int			Minimum frame size in bytes, 0 if unknown
+// MaxFrameSize int			Maximum frame size in bytes, 0 if unknown
+// SampleRate int			Sample rate in Hz
+// Channels int				Number of channels, 1 to 8
+// BitsPerSample int		Bits per sample, 4 to 32
+// TotalSamples uint64		Number of samples per channel, 0 if unknown
+// MD5 [16]byte				MD5 of the decoded samples, all zero if unknown
+type StreamInfo struct {
+	MinBlockSize  int
+	MaxBlockSize  int
+	MinFrameSize  int
+	MaxFrameSize  int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  uint64
+	MD5           [16]byte
+}
+
+// Padding is the PADDING block.
+//
+// Length int		Length of the padding in bytes
+type Padding struct {
+	Length int
+}
+
+// Application is the APPLICATION block.
+//
+// ID uint32		Registered application ID
+// Data []byte		Application data
+type Application struct {
+	ID   uint32
+	Data []byte
+}
+
+// SeekTable is the SEEKTABLE block.
+//
+// Points []SeekPoint	Seek points in ascending order of sample number
+type SeekTable struct {
+	Points []SeekPoint
+}
+
+// SeekPoint is a point of a SeekTable.
+//
+// SampleNumber uint64		First sample of the target frame, all ones for a placeholder
+// Offset uint64			Offset in bytes of the target frame from the first frame
+// Samples int				Number of samples in the target frame
+type SeekPoint struct {
+	SampleNumber uint64
+	Offset       uint64
+	Samples      int
+}
+
+// VorbisComment is the VORBIS_COMMENT block, the tags of the stream.
+//
+// Vendor string			Vendor string of the encoder
+// Comments []string		Comments in the form NAME=value
+type VorbisComment struct {
+	Vendor   string
+	Comments []string
+}
+
+// CueSheet is the CUESHEET block.
+//
+// CatalogNumber string		Media catalog number
+// LeadIn uint64			Number of lead-in samples
+// CompactDisc bool			Whether the cue sheet is of a CD-DA
+// Tracks []CueTrack		The tracks, the last is the lead-out
+type CueSheet struct {
+	CatalogNumber string
+	LeadIn        uint64
+	CompactDisc   bool
+	Tracks        []CueTrack
+}
+
+// CueTrack is a track of a CueSheet.
+//
+// Offset uint64			Offset in samples of the track from the start of the stream
+// Number uint8				Track number, 170 or 255 for the lead-out
+// ISRC string				International Standard Recording Code
+// Audio bool				Whether the track is audio
+// PreEmphasis bool			Whether the track has pre-emphasis
+// Indices []CueIndex		The index points of the track
+type CueTrack struct {
+	Offset      uint64
+	Number      uint8
+	ISRC        string
+	Audio       bool
+	PreEmphasis bool
+	Indices     []CueIndex
+}
+
+// CueIndex is an index point of a CueTrack.
+//
+// Offset uint64	Offset in samples of the index point from the start of the track
+// Number uint8		Index point number
+type CueIndex struct {
+	Offset uint64
+	Number uint8
+}
+
+// Picture is the PICTURE block.
+//
+// PictureType uint32	Picture type, 3 is the front cover
+// MIME string			MIME type of the data, or "-->" if it is a URL
+// Description string	Description of the picture
+// Width int			Width in pixels
+// Height int			Height in pixels
+// Depth int			Colour depth in bits per pixel
+// Colors int			Number of colours of an indexed picture, 0 otherwise
+// Data []byte			The picture data
+type Picture struct {
+	PictureType uint32
+	MIME        string
+	Description string
+	Width       int
+	Height      int
+	Depth       int
+	Colors      int
+	Data        []byte
+}
+
+// UnknownBlock is a metadata block of a reserved type.
+//
+// BlockType BlockType	Type of the block
+// Data []byte			Contents of the block
+type UnknownBlock struct {
+	BlockType BlockType
+	Data      []byte
+}
+
+// Type is a function that returns BlockStreamInfo.
+func (*StreamInfo) Type() BlockType {
+	return BlockStreamInfo
+}
+
+// Type is a function that returns BlockPadding.
+func (*Padding) Type() BlockType {
+	return BlockPadding
+}
+
+// Type is a function that returns BlockApplication.
+func (*Application) Type() BlockType {
+	return BlockApplication
+}
+
+// Type is a function that returns BlockSeekTable.
+func (*SeekTable) Type() BlockType {
+	return BlockSeekTable
+}
+
+// Type is a function that returns BlockVorbisComment.
+func (*VorbisComment) Type() BlockType {
+	return BlockVorbisComment
+}
+
+// Type is a function that returns BlockCueSheet.
+func (*CueSheet) Type() BlockType {
+	return BlockCueSheet
+}
+
+// Type is a function that returns BlockPicture.
+func (*Picture) Type() BlockType {
+	return BlockPicture
+}
+
+// Type is a function that returns the type of the block.
+func (block *UnknownBlock) Type() BlockType {
+	return block.BlockType
+}
+
+// ReadBlock is a function that reads a metadata block and returns it, and whether it is
+// the last metadata block before the frames.
+//
+// Returns ErrMetadata if the block is malformed, io.ErrUnexpectedEOF if it is truncated.
+func ReadBlock(reader *bitreader.Reader) (Block, bool, error) {
+	if reader.LittleEndian() {
+		return nil, false, ErrLittleEndian
+	}
+	header, err := reader.ReadBits(32)
+	if err != nil {
+		return nil, false, err
+	}
+	last := header>>31 == 1
+	typ := BlockType(header >> 24 & 0x7f)
+	if typ == 127 {
+		return nil, false, ErrMetadata
+	}
+	data, err := reader.ReadBytesToSlice(header & 0xffffff)
+	if err != nil {
+		return nil, false, noEOF(err)
+	}
+	var block Block
+	switch typ {
+	case BlockStreamInfo:
+		block, err = parseStreamInfo(data)
+	case BlockPadding:
+		block = &Padding{Length: len(data)}
+	case BlockApplication:
+		if len(data) < 4 {
+			return nil, false, ErrMetadata
+		}
+		block = &Application{ID: binary.BigEndian.Uint32(data), Data: data[4:]}
+	case BlockSeekTable:
+		block, err = parseSeekTable(data)
+	case BlockVorbisComment:
+		block, err = parseVorbisComment(data)
+	case BlockCueSheet:
+		block, err = parseCueSheet(data)
+	case BlockPicture:
+		block, err = parsePicture(data)
+	default:
+		block = &UnknownBlock{BlockType: typ, Data: data}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return block, last, nil
+}
+
+// parseStreamInfo is a private function that parses the contents of a STREAMINFO block.
+func parseStreamInfo(data []byte) (*StreamInfo, error) {
+	if len(data) != 34 {
+		return nil, ErrMetadata
+	}
+	reader := bitreader.NewReaderFromBytes(data, false)
+	info := &StreamInfo{
+		MinBlockSize:  int(reader.TryReadBits(16)),
+		MaxBlockSize:  int(reader.TryReadBits(16)),
+		MinFrameSize:  int(reader.TryReadBits(24)),
+		MaxFrameSize:  int(reader.TryReadBits(24)),
+		SampleRate:    int(reader.TryReadBits(20)),
+		Channels:      int(reader.TryReadBits(3)) + 1,
+		BitsPerSample: int(reader.TryReadBits(5)) + 1,
+		TotalSamples:  reader.TryReadBits(36),
+	}
+	copy(info.MD5[:], data[18:])
+	if info.MinBlockSize < 16 || info.MaxBlockSize < info.MinBlockSize || info.SampleRate == 0 || info.BitsPerSample < 4 {
+		return nil, ErrMetadata
+	}
+	return info, nil
+}
+
+// parseSeekTable is a private function that parses the contents of a SEEKTABLE block.
+func parseSeekTable(data []byte) (*SeekTable, error) {
+	if len(data)%18 != 0 {
+		return nil, ErrMetadata
+	}
+	table := &SeekTable{Points: make([]SeekPoint, len(data)/18)}
+	for i := range table.Points {
+		point := data[i*18:]
+		table.Points[i] = SeekPoint{
+			SampleNumber: binary.BigEndian.Uint64(point),
+			Offset:       binary.BigEndian.Uint64(point[8:]),
+			Samples:      int(binary.BigEndian.Uint16(point[16:])),
+		}
+	}
+	return table, nil
+}
+
+// parseVorbisComment is a private function that parses the contents of a VORBIS_COMMENT
+// block, which unlike the rest of FLAC has little-endian lengths.
+func parseVorbisComment(data []byte) (*VorbisComment, error) {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-4) {
+			return "", false
+		}
+		value := string(data[4 : 4+length])
+		data = data[4+length:]
+		return value, true
+	}
+	vendor, ok := next()
+	if !ok || len(data) < 4 {
+		return nil, ErrMetadata
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	// Every comment takes at least its 4 byte length
+	if uint64(count)*4 > uint64(len(data)) {
+		return nil, ErrMetadata
+	}
+	comment := &VorbisComment{Vendor: vendor, Comments: make([]string, count)}
+	for i := range comment.Comments {
+		if comment.Comments[i], ok = next(); !ok {
+			return nil, ErrMetadata
+		}
+	}
+	return comment, nil
+}
+
+// parseCueSheet is a private function that parses the contents of a CUESHEET block.
+func parseCueSheet(data []byte) (*CueSheet, error) {
+	if len(data) < 396 {
+		return nil, ErrMetadata
+	}
+	sheet := &CueSheet{
+		CatalogNumber: trimNUL(data[:128]),
+		LeadIn:        binary.BigEndian.Uint64(data[128:]),
+		CompactDisc:   data[136]&0x80 != 0,
+		Tracks:        make([]CueTrack, data[395]),
+	}
+	data = data[396:]
+	for i := range sheet.Tracks {
+		if len(data) < 36 {
+			return nil, ErrMetadata
+		}
+		track := &sheet.Tracks[i]
+		track.Offset = binary.BigEndian.Uint64(data)
+		track.Number = data[8]
+		track.ISRC = trimNUL(data[9:21])
+		track.Audio = data[21]&0x80 == 0
+		track.PreEmphasis = data[21]&0x40 != 0
+		track.Indices = make([]CueIndex, data[35])
+		data = data[36:]
+		if len(data) < 12*len(track.Indices) {
+			return nil, ErrMetadata
+		}
+		for j := range track.Indices {
+			track.Indices[j] = CueIndex{Offset: binary.BigEndian.Uint64(data), Number: data[8]}
+			data = data[12:]
+		}
+	}
+	return sheet, nil
+}
+
+// parsePicture is a private function that parses the contents of a PICTURE block.
+func parsePicture(data []byte) (*Picture, error) {
+	failed := false
+	field := func() uint32 {
+		if len(data) < 4 {
+			failed = true
+			return 0
+		}
+		value := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		return value
+	}
+	bytes := func() []byte {
+		length := field()
+		if failed || uint64(length) > uint64(len(data)) {
+			failed = true
+			return nil
+		}
+		value := data[:length]
+		data = data[length:]
+		return value
+	}
+	picture := &Picture{
+		PictureType: field(),
+		MIME:        string(bytes()),
+		Description: string(bytes()),
+		Width:       int(field()),
+		Height:      int(field()),
+		Depth:       int(field()),
+		Colors:      int(field()),
+		Data:        bytes(),
+	}
+	if failed {
+		return nil, ErrMetadata
+	}
+	return picture, nil
+}
+
+// trimNUL is a private function that returns a NUL padded field as a string.
+func trimNUL(field []byte) string {
+	for i, b := range field {
+		if b == 0 {
+			return string(field[:i])
+		}
+	}
+	return string(field)
+}
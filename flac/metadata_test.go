@@ -0,0 +1,173 @@
+package flac
+
+import (
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// streamInfo returns the contents of a STREAMINFO block.
+func streamInfo(minBlock, maxBlock, rate, channels, depth int) []byte {
+	w := &bitWriter{}
+	w.u(16, uint64(minBlock)).u(16, uint64(maxBlock)).u(24, 14).u(24, 8000)
+	w.u(20, uint64(rate)).u(3, uint64(channels-1)).u(5, uint64(depth-1)).u(36, 1<<33+5)
+	for i := 0; i < 16; i++ {
+		w.u(8, uint64(i))
+	}
+	return w.data
+}
+
+// appendUint32 appends a 32-bit value in the given byte order.
+func appendUint32(order binary.ByteOrder, data []byte, value uint32) []byte {
+	var b [4]byte
+	order.PutUint32(b[:], value)
+	return append(data, b[:]...)
+}
+
+// vorbisComment returns the contents of a VORBIS_COMMENT block.
+func vorbisComment(vendor string, comments ...string) []byte {
+	field := func(data []byte, value string) []byte {
+		data = appendUint32(binary.LittleEndian, data, uint32(len(value)))
+		return append(data, value...)
+	}
+	data := field(nil, vendor)
+	data = appendUint32(binary.LittleEndian, data, uint32(len(comments)))
+	for _, comment := range comments {
+		data = field(data, comment)
+	}
+	return data
+}
+
+// cueSheet returns the contents of a CUESHEET block with two tracks, the second the lead-out.
+func cueSheet() []byte {
+	data := make([]byte, 396)
+	copy(data, "1234567890123")
+	binary.BigEndian.PutUint64(data[128:], 88200)
+	data[136] = 0x80
+	data[395] = 2
+	track := make([]byte, 36)
+	binary.BigEndian.PutUint64(track, 0)
+	track[8] = 1
+	copy(track[9:], "USRC17607839")
+	track[21] = 0x40
+	track[35] = 2
+	data = append(data, track...)
+	index := make([]byte, 12)
+	data = append(data, index...)
+	binary.BigEndian.PutUint64(index, 588)
+	index[8] = 1
+	data = append(data, index...)
+	leadOut := make([]byte, 36)
+	binary.BigEndian.PutUint64(leadOut, 441000)
+	leadOut[8] = 170
+	leadOut[21] = 0x80
+	return append(data, leadOut...)
+}
+
+// picture returns the contents of a PICTURE block.
+func picture(data []byte) []byte {
+	var out []byte
+	for _, field := range []interface{}{uint32(3), "image/png", "Cover", uint32(1), uint32(2), uint32(24), uint32(0), data} {
+		switch value := field.(type) {
+		case uint32:
+			out = appendUint32(binary.BigEndian, out, value)
+		case string:
+			out = appendUint32(binary.BigEndian, out, uint32(len(value)))
+			out = append(out, value...)
+		case []byte:
+			out = appendUint32(binary.BigEndian, out, uint32(len(value)))
+			out = append(out, value...)
+		}
+	}
+	return out
+}
+
+func TestReadBlock(t *testing.T) {
+	seekTable := make([]byte, 36)
+	binary.BigEndian.PutUint64(seekTable, 0)
+	binary.BigEndian.PutUint64(seekTable[8:], 0)
+	binary.BigEndian.PutUint16(seekTable[16:], 4096)
+	binary.BigEndian.PutUint64(seekTable[18:], 0xffffffffffffffff)
+	tests := []struct {
+		name    string
+		block   []byte
+		want    Block
+		last    bool
+		wantErr error
+	}{
+		{"streaminfo", metadataBlock(BlockStreamInfo, streamInfo(4096, 4096, 44100, 2, 16)), &StreamInfo{
+			MinBlockSize: 4096, MaxBlockSize: 4096, MinFrameSize: 14, MaxFrameSize: 8000, SampleRate: 44100,
+			Channels: 2, BitsPerSample: 16, TotalSamples: 1<<33 + 5,
+			MD5: [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		}, false, nil},
+		{"last padding", append([]byte{0x80 | byte(BlockPadding)}, metadataBlock(BlockPadding, make([]byte, 10))[1:]...), &Padding{Length: 10}, true, nil},
+		{"application", metadataBlock(BlockApplication, []byte("ATCHdata")), &Application{ID: 0x41544348, Data: []byte("data")}, false, nil},
+		{"seek table", metadataBlock(BlockSeekTable, seekTable), &SeekTable{Points: []SeekPoint{
+			{SampleNumber: 0, Offset: 0, Samples: 4096},
+			{SampleNumber: 0xffffffffffffffff},
+		}}, false, nil},
+		{"vorbis comment", metadataBlock(BlockVorbisComment, vorbisComment("reference libFLAC 1.4.3", "TITLE=Tone", "ARTIST=")), &VorbisComment{
+			Vendor: "reference libFLAC 1.4.3", Comments: []string{"TITLE=Tone", "ARTIST="},
+		}, false, nil},
+		{"cue sheet", metadataBlock(BlockCueSheet, cueSheet()), &CueSheet{
+			CatalogNumber: "1234567890123", LeadIn: 88200, CompactDisc: true,
+			Tracks: []CueTrack{
+				{Number: 1, ISRC: "USRC17607839", Audio: true, PreEmphasis: true, Indices: []CueIndex{{}, {Offset: 588, Number: 1}}},
+				{Offset: 441000, Number: 170, Indices: []CueIndex{}},
+			},
+		}, false, nil},
+		{"picture", metadataBlock(BlockPicture, picture([]byte{0x89, 'P', 'N', 'G'})), &Picture{
+			PictureType: 3, MIME: "image/png", Description: "Cover", Width: 1, Height: 2, Depth: 24, Data: []byte{0x89, 'P', 'N', 'G'},
+		}, false, nil},
+		{"reserved type", metadataBlock(9, []byte{1, 2}), &UnknownBlock{BlockType: 9, Data: []byte{1, 2}}, false, nil},
+		{"invalid type", metadataBlock(127, nil), nil, false, ErrMetadata},
+		{"streaminfo too short", metadataBlock(BlockStreamInfo, streamInfo(4096, 4096, 44100, 2, 16)[:33]), nil, false, ErrMetadata},
+		{"streaminfo block size", metadataBlock(BlockStreamInfo, streamInfo(8, 4096, 44100, 2, 16)), nil, false, ErrMetadata},
+		{"streaminfo sample rate", metadataBlock(BlockStreamInfo, streamInfo(4096, 4096, 0, 2, 16)), nil, false, ErrMetadata},
+		{"streaminfo depth", metadataBlock(BlockStreamInfo, streamInfo(4096, 4096, 44100, 2, 3)), nil, false, ErrMetadata},
+		{"application too short", metadataBlock(BlockApplication, []byte("ATC")), nil, false, ErrMetadata},
+		{"seek table length", metadataBlock(BlockSeekTable, seekTable[:20]), nil, false, ErrMetadata},
+		{"vorbis comment count", metadataBlock(BlockVorbisComment, vorbisComment("x", "A=1")[:13]), nil, false, ErrMetadata},
+		{"vorbis comment length", metadataBlock(BlockVorbisComment, vorbisComment("x", "A=1")[:15]), nil, false, ErrMetadata},
+		{"cue sheet index", metadataBlock(BlockCueSheet, cueSheet()[:396+36+12]), nil, false, ErrMetadata},
+		{"picture length", metadataBlock(BlockPicture, picture([]byte{1, 2, 3})[:40]), nil, false, ErrMetadata},
+		{"truncated", metadataBlock(BlockPadding, make([]byte, 10))[:8], nil, false, io.ErrUnexpectedEOF},
+		{"empty", nil, nil, false, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, last, err := ReadBlock(bitreader.NewReaderFromBytes(tt.block, false))
+			if err != tt.wantErr {
+				t.Fatalf("ReadBlock() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) || last != tt.last {
+				t.Errorf("ReadBlock() = %+v, %v, want %+v, %v", got, last, tt.want, tt.last)
+			}
+			if got != nil && got.Type() != BlockType(tt.block[0]&0x7f) {
+				t.Errorf("Type() = %d, want %d", got.Type(), tt.block[0]&0x7f)
+			}
+		})
+	}
+}
+
+func TestDecoderBlocks(t *testing.T) {
+	data, _ := reference(t, "rfc9639-d2")
+	decoder, err := NewDecoder(bitreader.NewReaderFromBytes(data, false))
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	want := []Block{
+		decoder.StreamInfo,
+		&SeekTable{Points: []SeekPoint{{SampleNumber: 0, Offset: 0, Samples: 16}}},
+		&VorbisComment{Vendor: "reference libFLAC 1.3.3 20190804", Comments: []string{}},
+	}
+	if !reflect.DeepEqual(decoder.Blocks, want) {
+		t.Errorf("Blocks = %+v, want %+v", decoder.Blocks, want)
+	}
+	if decoder.Blocks[0] != decoder.StreamInfo {
+		t.Errorf("Blocks[0] is not StreamInfo")
+	}
+}
@@ -0,0 +1,126 @@
+package bitreader
+
+import (
+	"errors"
+	"math"
+)
+
+// ReadFloat16 is a function that reads 16 bits as an IEEE 754 half-precision
+// float (1 sign, 5 exponent and 10 mantissa bits). Every half-precision value,
+// including subnormals, infinities and NaN payloads, is exact in a float32.
+//
+// Returns an error if there are not enough remaining bits.
+func (reader *Reader) ReadFloat16() (float32, error) {
+	value, err := reader.ReadBits(16)
+	if err != nil {
+		return 0, err
+	}
+	return float16ToFloat32(uint16(value)), nil
+}
+
+// ReadBFloat16 is a function that reads 16 bits as a bfloat16
+// (1 sign, 8 exponent and 7 mantissa bits), the upper half of a float32.
+//
+// Returns an error if there are not enough remaining bits.
+func (reader *Reader) ReadBFloat16() (float32, error) {
+	value, err := reader.ReadBits(16)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(uint32(value) << 16), nil
+}
+
+// ReadMiniFloat is a function that reads a custom IEEE 754 style float of
+// signBits+expBits+mantBits bits, with the sign as the most significant bit
+// of the value and the exponent before the mantissa. Like IEEE 754, an exponent
+// of 0 is subnormal and an exponent of all ones is infinity or NaN. Values that
+// don't fit a float64 become infinity or zero.
+//
+// Returns an error if signBits is more than 1, expBits is 0, mantBits is more
+// than 52, the total is more than 64 or there are not enough remaining bits.
+func (reader *Reader) ReadMiniFloat(signBits, expBits, mantBits uint8, bias int) (float64, error) {
+	if signBits > 1 || expBits < 1 || mantBits > 52 || uint(signBits)+uint(expBits)+uint(mantBits) > 64 {
+		return 0, errors.New("ReadMiniFloat(signBits, expBits, mantBits, bias) ERROR: Invalid float format")
+	}
+	value, err := reader.ReadBits(uint64(signBits) + uint64(expBits) + uint64(mantBits))
+	if err != nil {
+		return 0, err
+	}
+	mant := value & (1<<mantBits - 1)
+	exp := value >> mantBits & (1<<expBits - 1)
+	negative := signBits == 1 && value>>(mantBits+expBits) == 1
+	var out float64
+	switch {
+	case exp == 1<<expBits-1 && mant == 0:
+		out = math.Inf(1)
+	case exp == 1<<expBits-1:
+		out = math.NaN()
+	case exp == 0:
+		out = math.Ldexp(float64(mant), 1-bias-int(mantBits))
+	default:
+		out = math.Ldexp(float64(mant|1<<mantBits), int(exp)-bias-int(mantBits))
+	}
+	if negative {
+		out = math.Copysign(out, -1)
+	}
+	return out, nil
+}
+
+// TryReadFloat16 is a wrapper function that returns the value of 16 bits as a half-precision float.
+//
+// Returns float32. Panics on overflow.
+func (reader *Reader) TryReadFloat16() float32 {
+	value, err := reader.ReadFloat16()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadBFloat16 is a wrapper function that returns the value of 16 bits as a bfloat16.
+//
+// Returns float32. Panics on overflow.
+func (reader *Reader) TryReadBFloat16() float32 {
+	value, err := reader.ReadBFloat16()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadMiniFloat is a wrapper function that returns the value of a custom float.
+//
+// Returns float64. Panics on overflow.
+func (reader *Reader) TryReadMiniFloat(signBits, expBits, mantBits uint8, bias int) float64 {
+	value, err := reader.ReadMiniFloat(signBits, expBits, mantBits, bias)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// float16ToFloat32 is a private function that widens half-precision bits to a float32,
+// keeping the sign of zeros and the payload of NaNs.
+func float16ToFloat32(half uint16) float32 {
+	sign := uint32(half>>15) << 31
+	exp := uint32(half>>10) & 0x1f
+	mant := uint32(half) & 0x3ff
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal, shift the mantissa up until it is normalized
+		exp = 127 - 15 + 1
+		for mant&0x400 == 0 {
+			mant <<= 1
+			exp--
+		}
+		mant &= 0x3ff
+	case 0x1f:
+		exp = 0xff
+	default:
+		exp += 127 - 15
+	}
+	return math.Float32frombits(sign | exp<<23 | mant<<13)
+}
@@ -0,0 +1,122 @@
+package bitreader
+
+import (
+	"math"
+	"testing"
+)
+
+// referenceFloat computes a float16 (or any small IEEE 754 format) value straight from its definition.
+func referenceFloat(bits uint64, expBits, mantBits uint, bias int) float64 {
+	sign := 1.0
+	if bits>>(expBits+mantBits)&1 == 1 {
+		sign = -1
+	}
+	exp := int(bits >> mantBits & (1<<expBits - 1))
+	fraction := float64(bits&(1<<mantBits-1)) / float64(uint64(1)<<mantBits)
+	switch exp {
+	case 1<<expBits - 1:
+		if fraction != 0 {
+			return math.NaN()
+		}
+		return sign * math.Inf(1)
+	case 0:
+		return sign * fraction * math.Pow(2, float64(1-bias))
+	}
+	return sign * (1 + fraction) * math.Pow(2, float64(exp-bias))
+}
+
+// sameFloat reports whether got and want are both NaN or equal with the same sign.
+func sameFloat(got, want float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return got == want && math.Signbit(got) == math.Signbit(want)
+}
+
+func TestReader_ReadFloat16(t *testing.T) {
+	for i := 0; i < 1<<16; i++ {
+		stream := []byte{byte(i >> 8), byte(i)}
+		want := referenceFloat(uint64(i), 5, 10, 15)
+		got, err := NewReaderFromBytes(stream, false).ReadFloat16()
+		if err != nil || !sameFloat(float64(got), want) {
+			t.Fatalf("ReadFloat16(%#04x) = %v, %v, want %v", i, got, err, want)
+		}
+		// The NaN payload and quiet bit are kept
+		if math.IsNaN(want) && math.Float32bits(got)>>13&0x3ff != uint32(i)&0x3ff {
+			t.Fatalf("ReadFloat16(%#04x) = %#08x, NaN payload lost", i, math.Float32bits(got))
+		}
+		mini, err := NewReaderFromBytes(stream, false).ReadMiniFloat(1, 5, 10, 15)
+		if err != nil || !sameFloat(mini, want) {
+			t.Fatalf("ReadMiniFloat(1, 5, 10, 15)(%#04x) = %v, %v, want %v", i, mini, err, want)
+		}
+		// Little-endian reads the same value from swapped bytes
+		swapped, _ := NewReaderFromBytes([]byte{byte(i), byte(i >> 8)}, true).ReadFloat16()
+		if !sameFloat(float64(swapped), want) {
+			t.Fatalf("ReadFloat16(%#04x) LE = %v, want %v", i, swapped, want)
+		}
+	}
+}
+
+func TestReader_ReadBFloat16(t *testing.T) {
+	for i := 0; i < 1<<16; i++ {
+		stream := []byte{byte(i >> 8), byte(i)}
+		want := referenceFloat(uint64(i), 8, 7, 127)
+		got, err := NewReaderFromBytes(stream, false).ReadBFloat16()
+		if err != nil || !sameFloat(float64(got), want) {
+			t.Fatalf("ReadBFloat16(%#04x) = %v, %v, want %v", i, got, err, want)
+		}
+		mini, err := NewReaderFromBytes(stream, false).ReadMiniFloat(1, 8, 7, 127)
+		if err != nil || !sameFloat(mini, want) {
+			t.Fatalf("ReadMiniFloat(1, 8, 7, 127)(%#04x) = %v, %v, want %v", i, mini, err, want)
+		}
+	}
+}
+
+func TestReader_ReadMiniFloat(t *testing.T) {
+	// Every 8-bit pattern of the 1-4-3 format, read from an unaligned position
+	for i := 0; i < 1<<8; i++ {
+		stream := []byte{byte(i >> 3), byte(i << 5)}
+		reader := NewReaderFromBytes(stream, false)
+		reader.SkipBits(3)
+		want := referenceFloat(uint64(i), 4, 3, 7)
+		got, err := reader.ReadMiniFloat(1, 4, 3, 7)
+		if err != nil || !sameFloat(got, want) {
+			t.Fatalf("ReadMiniFloat(1, 4, 3, 7)(%#02x) = %v, %v, want %v", i, got, err, want)
+		}
+	}
+	tests := []struct {
+		name     string
+		stream   []byte
+		sign     uint8
+		exp      uint8
+		mant     uint8
+		bias     int
+		want     float64
+		wantErr  bool
+		wantSign bool
+	}{
+		{name: "Unsigned", stream: []byte{0x88}, exp: 4, mant: 4, bias: 7, want: 1.5 * 2},
+		{name: "UnsignedInf", stream: []byte{0xf0}, exp: 4, mant: 0, bias: 7, want: math.Inf(1)},
+		{name: "Float32", stream: []byte{0xc0, 0x49, 0x0f, 0xdb}, sign: 1, exp: 8, mant: 23, bias: 127, want: -float64(float32(math.Pi)), wantSign: true},
+		{name: "Float64", stream: []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}, sign: 1, exp: 11, mant: 52, bias: 1023, want: math.Pi},
+		{name: "SmallestSubnormal", stream: []byte{0x00, 0x01}, sign: 1, exp: 5, mant: 10, bias: 15, want: math.Ldexp(1, -24)},
+		{name: "NegativeZero", stream: []byte{0x80, 0x00}, sign: 1, exp: 5, mant: 10, bias: 15, want: 0, wantSign: true},
+		{name: "Overflow", stream: []byte{0x7f, 0xfe}, sign: 1, exp: 15, mant: 0, bias: -2000, want: math.Inf(1)},
+		{name: "TooWide", stream: make([]byte, 9), sign: 1, exp: 12, mant: 52, wantErr: true},
+		{name: "NoExponent", stream: []byte{0xff}, sign: 1, exp: 0, mant: 7, wantErr: true},
+		{name: "TwoSignBits", stream: []byte{0xff}, sign: 2, exp: 3, mant: 3, wantErr: true},
+		{name: "Short", stream: []byte{0xff}, sign: 1, exp: 5, mant: 10, bias: 15, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, false).ReadMiniFloat(tt.sign, tt.exp, tt.mant, tt.bias)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadMiniFloat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got != tt.want || math.Signbit(got) != tt.wantSign) {
+				t.Errorf("ReadMiniFloat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
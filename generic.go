@@ -0,0 +1,166 @@
+package bitreader
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+// maxPrealloc caps how many bytes of a ReadSlice count are trusted for preallocation.
+const maxPrealloc = 1 << 20
+
+// Signed is a constraint that permits any signed integer type.
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Unsigned is a constraint that permits any unsigned integer type.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	Signed | Unsigned
+}
+
+// Float is a constraint that permits any floating-point type.
+type Float interface {
+	~float32 | ~float64
+}
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	Integer | Float
+}
+
+// Read is a generic function that reads a value of type T using all of its bits,
+// 8 for int8, 32 for float32 and so on. Floats are read as their IEEE 754 bits.
+//
+// Returns an error if there are no remaining bits.
+func Read[T Number](reader *Reader) (T, error) {
+	var zero T
+	bits := uint64(unsafe.Sizeof(zero)) * 8
+	value, err := reader.ReadBits(bits)
+	if err != nil {
+		return 0, err
+	}
+	if isFloat[T]() {
+		if bits == 32 {
+			return T(math.Float32frombits(uint32(value))), nil
+		}
+		return T(math.Float64frombits(value)), nil
+	}
+	return T(value), nil
+}
+
+// ReadN is a generic function that reads the specified amount of bits from the
+// parameter into an integer of type T. Values of signed types are sign-extended
+// from the highest read bit.
+//
+// Returns an error if bits is 0 or wider than T, or if there are no remaining bits.
+func ReadN[T Integer](reader *Reader, bits uint64) (T, error) {
+	var zero T
+	if bits < 1 || bits > uint64(unsafe.Sizeof(zero))*8 {
+		return 0, errors.New("ReadN(bits) ERROR: Bits number should be between 1 and the size of the type")
+	}
+	value, err := reader.ReadBits(bits)
+	if err != nil {
+		return 0, err
+	}
+	return convert[T](value, bits), nil
+}
+
+// ReadSlice is a generic function that reads count integers of bitsEach bits
+// into a slice of type T. Values of signed types are sign-extended.
+//
+// Returns an error if bitsEach is 0 or wider than T, if the slice would exceed
+// the Reader limits, or if there are no remaining bits.
+func ReadSlice[T Integer](reader *Reader, count uint64, bitsEach uint64) ([]T, error) {
+	var zero T
+	size := uint64(unsafe.Sizeof(zero))
+	if bitsEach < 1 || bitsEach > size*8 {
+		return nil, errors.New("ReadSlice(count, bitsEach) ERROR: Bits number should be between 1 and the size of the type")
+	}
+	if count > math.MaxUint64/size || count > math.MaxUint64/bitsEach {
+		return nil, errors.New("ReadSlice(count, bitsEach) ERROR: Count is too large")
+	}
+	if err := reader.checkSlice("ReadSlice(count, bitsEach)", count*size); err != nil {
+		return nil, err
+	}
+	if err := reader.checkBits("ReadSlice(count, bitsEach)", count*bitsEach); err != nil {
+		return nil, err
+	}
+	capacity := count
+	if capacity > maxPrealloc/size {
+		capacity = maxPrealloc / size
+	}
+	out := make([]T, 0, capacity)
+	for i := uint64(0); i < count; i++ {
+		value, err := reader.ReadBits(bitsEach)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, convert[T](value, bitsEach))
+	}
+	return out, nil
+}
+
+// TryRead is a generic wrapper function that reads a value of type T using all of its bits.
+//
+// Returns T. Panics on overflow.
+func TryRead[T Number](reader *Reader) T {
+	value, err := Read[T](reader)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadN is a generic wrapper function that reads the specified amount of bits
+// from the parameter into an integer of type T.
+//
+// Returns T. Panics on overflow.
+func TryReadN[T Integer](reader *Reader, bits uint64) T {
+	value, err := ReadN[T](reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadSlice is a generic wrapper function that reads count integers
+// of bitsEach bits into a slice of type T.
+//
+// Returns []T. Panics on overflow.
+func TryReadSlice[T Integer](reader *Reader, count uint64, bitsEach uint64) []T {
+	values, err := ReadSlice[T](reader, count, bitsEach)
+	if err != nil {
+		panic(err)
+	}
+	return values
+}
+
+// convert is a private function that converts the low bits of value into T,
+// sign-extending from the highest bit if T is signed.
+func convert[T Integer](value uint64, bits uint64) T {
+	if isSigned[T]() && bits < 64 {
+		shift := 64 - bits
+		return T(int64(value<<shift) >> shift)
+	}
+	return T(value)
+}
+
+// isFloat is a private function that reports whether T is a floating-point type.
+// Integer division truncates one half to zero, floating-point division doesn't.
+func isFloat[T Number]() bool {
+	one := T(1)
+	return one/2 != 0
+}
+
+// isSigned is a private function that reports whether T is a signed type.
+// Subtracting one from zero wraps around for unsigned types.
+func isSigned[T Number]() bool {
+	zero := T(0)
+	return zero-1 < 0
+}
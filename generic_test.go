@@ -0,0 +1,129 @@
+package bitreader
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type playerIndex int16
+
+func TestRead(t *testing.T) {
+	le := []byte{0xfe, 0xff, 0xff, 0xff, 0x00, 0x00, 0x80, 0x3f}
+	if got, err := Read[int8](NewReaderFromBytes(le, true)); err != nil || got != -2 {
+		t.Errorf("Read[int8]() = %v, %v, want %v", got, err, -2)
+	}
+	if got, err := Read[uint16](NewReaderFromBytes(le, true)); err != nil || got != 0xfffe {
+		t.Errorf("Read[uint16]() = %v, %v, want %v", got, err, 0xfffe)
+	}
+	if got, err := Read[int32](NewReaderFromBytes(le, true)); err != nil || got != -2 {
+		t.Errorf("Read[int32]() = %v, %v, want %v", got, err, -2)
+	}
+	if got, err := Read[playerIndex](NewReaderFromBytes(le, true)); err != nil || got != -2 {
+		t.Errorf("Read[playerIndex]() = %v, %v, want %v", got, err, -2)
+	}
+	reader := NewReaderFromBytes(le, true)
+	reader.SkipBytes(4)
+	if got, err := Read[float32](reader); err != nil || got != 1 {
+		t.Errorf("Read[float32]() = %v, %v, want %v", got, err, 1)
+	}
+	be := []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}
+	if got, err := Read[float64](NewReaderFromBytes(be, false)); err != nil || got != math.Pi {
+		t.Errorf("Read[float64]() = %v, %v, want %v", got, err, math.Pi)
+	}
+	if _, err := Read[uint64](NewReaderFromBytes(be[:4], false)); err == nil {
+		t.Errorf("Read[uint64]() error = nil, want EOF")
+	}
+}
+
+func TestReadN(t *testing.T) {
+	tests := []struct {
+		name    string
+		read    func(reader *Reader) (int64, error)
+		stream  []byte
+		le      bool
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:   "SignedNegativeLE",
+			read:   func(reader *Reader) (int64, error) { v, err := ReadN[int16](reader, 11); return int64(v), err },
+			stream: []byte{0xff, 0x04},
+			le:     true,
+			want:   -769,
+		},
+		{
+			name:   "SignedPositiveBE",
+			read:   func(reader *Reader) (int64, error) { v, err := ReadN[int32](reader, 12); return int64(v), err },
+			stream: []byte{0x7f, 0xf0},
+			want:   0x7ff,
+		},
+		{
+			name:   "SignedNegativeBE",
+			read:   func(reader *Reader) (int64, error) { v, err := ReadN[int32](reader, 12); return int64(v), err },
+			stream: []byte{0x80, 0x00},
+			want:   -2048,
+		},
+		{
+			name:   "Unsigned",
+			read:   func(reader *Reader) (int64, error) { v, err := ReadN[uint8](reader, 3); return int64(v), err },
+			stream: []byte{0xe0},
+			want:   7,
+		},
+		{
+			name:    "TooWide",
+			read:    func(reader *Reader) (int64, error) { v, err := ReadN[uint8](reader, 9); return int64(v), err },
+			stream:  []byte{0xff, 0xff},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read(NewReaderFromBytes(tt.stream, tt.le))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadN() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSlice(t *testing.T) {
+	// Four 6-bit values: 1, -1, 31, -32
+	stream := []byte{0b00000111, 0b11110111, 0b11100000}
+	got, err := ReadSlice[int8](NewReaderFromBytes(stream, false), 4, 6)
+	if err != nil || !reflect.DeepEqual(got, []int8{1, -1, 31, -32}) {
+		t.Errorf("ReadSlice[int8]() = %v, %v, want %v", got, err, []int8{1, -1, 31, -32})
+	}
+	unsigned, err := ReadSlice[uint16](NewReaderFromBytes(stream, false), 4, 6)
+	if err != nil || !reflect.DeepEqual(unsigned, []uint16{1, 63, 31, 32}) {
+		t.Errorf("ReadSlice[uint16]() = %v, %v, want %v", unsigned, err, []uint16{1, 63, 31, 32})
+	}
+	reader := NewReaderFromBytes(stream, false)
+	reader.SetLimits(Limits{MaxSliceLength: 16})
+	var limitErr *LimitError
+	if _, err := ReadSlice[uint32](reader, 5, 1); !errors.As(err, &limitErr) {
+		t.Errorf("ReadSlice[uint32]() error = %v, want LimitError", err)
+	}
+	// A huge count on a short stream reads what there is instead of allocating the count
+	huge, err := ReadSlice[uint64](NewReaderFromBytes([]byte{1, 2, 3}, false), 1<<40, 1)
+	if err == nil || len(huge) != 24 {
+		t.Errorf("ReadSlice[uint64]() = %d values, %v, want 24 values and an error", len(huge), err)
+	}
+}
+
+func TestTryRead(t *testing.T) {
+	reader := NewReaderFromBytes([]byte{0x01, 0x80, 0xff}, false)
+	if got := TryRead[uint8](reader); got != 1 {
+		t.Errorf("TryRead[uint8]() = %v, want %v", got, 1)
+	}
+	if got := TryReadN[int8](reader, 1); got != -1 {
+		t.Errorf("TryReadN[int8]() = %v, want %v", got, -1)
+	}
+	if got := TryReadSlice[uint8](reader, 3, 5); !reflect.DeepEqual(got, []uint8{0, 7, 31}) {
+		t.Errorf("TryReadSlice[uint8]() = %v, want %v", got, []uint8{0, 7, 31})
+	}
+}
@@ -0,0 +1,297 @@
+// Package h264 parses the sequence parameter sets, picture parameter sets and slice
+// headers of H.264 / AVC video (ITU-T H.264, ISO/IEC 14496-10) with a big-endian
+// bitreader.Reader.
+//
+// A NAL unit is one byte of header and an RBSP payload with emulation prevention bytes
+// inserted wherever the payload would contain a start code. The parsers take whole NAL
+// units, as found between Annex B start codes or after the length prefix of MP4 samples,
+// remove the emulation prevention bytes and read the syntax elements, most of which are
+// Exp-Golomb codes. ParameterSets keeps the parameter sets that slice headers refer to.
+package h264
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/pektezol/bitreader"
+)
+
+var (
+	// ErrNALType is returned when a NAL unit is not of the type being parsed.
+	ErrNALType = errors.New("h264: unexpected NAL unit type")
+	// ErrCorrupt is returned when a syntax element is out of range or the RBSP is malformed.
+	ErrCorrupt = errors.New("h264: corrupt bitstream")
+	// ErrMissingParameterSet is returned when a parameter set refers to one that was not added.
+	ErrMissingParameterSet = errors.New("h264: missing parameter set")
+	// ErrUnsupported is returned for the slices of the SVC, MVC and 3D extensions.
+	ErrUnsupported = errors.New("h264: unsupported NAL unit")
+)
+
+// NALType is the nal_unit_type of a NAL unit.
+type NALType uint8
+
+const (
+	NALSlice         NALType = 1  // Coded slice of a non-IDR picture
+	NALSliceA        NALType = 2  // Coded slice data partition A
+	NALSliceB        NALType = 3  // Coded slice data partition B
+	NALSliceC        NALType = 4  // Coded slice data partition C
+	NALIDR           NALType = 5  // Coded slice of an IDR picture
+	NALSEI           NALType = 6  // Supplemental enhancement information
+	NALSPS           NALType = 7  // Sequence parameter set
+	NALPPS           NALType = 8  // Picture parameter set
+	NALAUD           NALType = 9  // Access unit delimiter
+	NALEndOfSequence NALType = 10 // End of sequence
+	NALEndOfStream   NALType = 11 // End of stream
+	NALFiller        NALType = 12 // Filler data
+	NALSPSExtension  NALType = 13 // Sequence parameter set extension
+	NALPrefix        NALType = 14 // Prefix NAL unit of the SVC and MVC extensions
+	NALSubsetSPS     NALType = 15 // Subset sequence parameter set
+	NALAuxiliary     NALType = 19 // Coded slice of an auxiliary coded picture
+	NALSliceExt      NALType = 20 // Coded slice of the SVC and MVC extensions
+)
+
+// NALHeader is the first byte of a NAL unit.
+//
+// RefIDC uint8		nal_ref_idc, 0 if the NAL unit is not used for reference
+// Type NALType		nal_unit_type
+type NALHeader struct {
+	RefIDC uint8
+	Type   NALType
+}
+
+// ParseNALHeader is a function that parses the header byte of a NAL unit.
+//
+// Returns ErrCorrupt if the NAL unit is empty or its forbidden_zero_bit is set.
+func ParseNALHeader(nal []byte) (NALHeader, error) {
+	if len(nal) == 0 || nal[0]&0x80 != 0 {
+		return NALHeader{}, ErrCorrupt
+	}
+	return NALHeader{RefIDC: nal[0] >> 5 & 0x3, Type: NALType(nal[0] & 0x1f)}, nil
+}
+
+// SplitAnnexB is a function that splits an Annex B byte stream into its NAL units, without
+// the start codes and the zero bytes around them. The NAL units share the memory of stream.
+func SplitAnnexB(stream []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	zeros := 0
+	for i, b := range stream {
+		if b == 0x01 && zeros >= 2 {
+			if start >= 0 {
+				units = appendUnit(units, stream[start:i-zeros])
+			}
+			start = i + 1
+		}
+		if b == 0x00 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	if start >= 0 {
+		units = appendUnit(units, stream[start:])
+	}
+	return units
+}
+
+// appendUnit is a private function that appends a NAL unit without its trailing zero bytes, if it isn't empty.
+func appendUnit(units [][]byte, unit []byte) [][]byte {
+	for len(unit) > 0 && unit[len(unit)-1] == 0x00 {
+		unit = unit[:len(unit)-1]
+	}
+	if len(unit) == 0 {
+		return units
+	}
+	return append(units, unit)
+}
+
+// UnescapeRBSP is a function that removes the emulation prevention bytes, the 0x03 in every
+// 0x00 0x00 0x03 sequence, from a NAL unit payload. It returns payload itself if there are none.
+func UnescapeRBSP(payload []byte) []byte {
+	var out []byte
+	zeros := 0
+	last := 0
+	for i, b := range payload {
+		if b == 0x03 && zeros >= 2 {
+			out = append(out, payload[last:i]...)
+			last = i + 1
+			zeros = 0
+			continue
+		}
+		if b == 0x00 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	if out == nil {
+		return payload
+	}
+	return append(out, payload[last:]...)
+}
+
+// ReadUE is a function that reads an unsigned Exp-Golomb code, ue(v), of up to 32 bits.
+//
+// Returns ErrCorrupt if the code has more than 31 leading zero bits.
+func ReadUE(reader *bitreader.Reader) (uint32, error) {
+	zeros := uint64(0)
+	for {
+		bit, err := reader.ReadBool()
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		if bit {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, ErrCorrupt
+		}
+	}
+	if zeros == 0 {
+		return 0, nil
+	}
+	suffix, err := reader.ReadBits(zeros)
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	return uint32(1<<zeros - 1 + suffix), nil
+}
+
+// ReadSE is a function that reads a signed Exp-Golomb code, se(v), which maps
+// 0, 1, 2, 3, 4 to 0, 1, -1, 2, -2.
+//
+// Returns ErrCorrupt if the code has more than 31 leading zero bits.
+func ReadSE(reader *bitreader.Reader) (int32, error) {
+	value, err := ReadUE(reader)
+	if err != nil {
+		return 0, err
+	}
+	if value&1 == 1 {
+		return int32(value/2 + 1), nil
+	}
+	return -int32(value / 2), nil
+}
+
+// rbsp reads the syntax elements of an RBSP and keeps the first error.
+//
+// reader *bitreader.Reader		The RBSP, without the NAL unit header
+// end uint64					Bit position of the rbsp_stop_one_bit
+// err error					The first error
+type rbsp struct {
+	reader *bitreader.Reader
+	end    uint64
+	err    error
+}
+
+// newRBSP is a private function that checks the NAL unit type and returns an rbsp
+// for the payload of nal.
+func newRBSP(nal []byte, types ...NALType) (*rbsp, NALHeader, error) {
+	header, err := ParseNALHeader(nal)
+	if err != nil {
+		return nil, header, err
+	}
+	known := false
+	for _, typ := range types {
+		known = known || header.Type == typ
+	}
+	if !known {
+		return nil, header, ErrNALType
+	}
+	payload := UnescapeRBSP(nal[1:])
+	// The stop bit is the last set bit, slices may have cabac_zero_words after it
+	last := len(payload) - 1
+	for last >= 0 && payload[last] == 0 {
+		last--
+	}
+	if last < 0 {
+		return nil, header, ErrCorrupt
+	}
+	end := uint64(last)*8 + 7 - uint64(bits.TrailingZeros8(payload[last]))
+	return &rbsp{reader: bitreader.NewReaderFromBytes(payload, false), end: end}, header, nil
+}
+
+// u is a private function that reads a fixed-length unsigned field, u(n).
+func (r *rbsp) u(bits uint64) uint32 {
+	if r.err != nil || bits == 0 {
+		return 0
+	}
+	value, err := r.reader.ReadBits(bits)
+	r.err = noEOF(err)
+	return uint32(value)
+}
+
+// flag is a private function that reads a one bit flag.
+func (r *rbsp) flag() bool {
+	return r.u(1) == 1
+}
+
+// ue is a private function that reads an unsigned Exp-Golomb code.
+func (r *rbsp) ue() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	value, err := ReadUE(r.reader)
+	r.err = err
+	return value
+}
+
+// ueMax is a private function that reads an unsigned Exp-Golomb code with a maximum value.
+func (r *rbsp) ueMax(max uint32) uint32 {
+	value := r.ue()
+	if value > max && r.err == nil {
+		r.err = ErrCorrupt
+	}
+	return value
+}
+
+// se is a private function that reads a signed Exp-Golomb code.
+func (r *rbsp) se() int32 {
+	if r.err != nil {
+		return 0
+	}
+	value, err := ReadSE(r.reader)
+	r.err = err
+	return value
+}
+
+// seRange is a private function that reads a signed Exp-Golomb code within a range.
+func (r *rbsp) seRange(min, max int32) int32 {
+	value := r.se()
+	if (value < min || value > max) && r.err == nil {
+		r.err = ErrCorrupt
+	}
+	return value
+}
+
+// moreData is a private function that implements more_rbsp_data(), whether there
+// is data before the rbsp_stop_one_bit.
+func (r *rbsp) moreData() bool {
+	return r.err == nil && r.reader.BitPosition() < r.end
+}
+
+// remaining is a private function that returns the number of bits before the rbsp_stop_one_bit.
+func (r *rbsp) remaining() uint64 {
+	if position := r.reader.BitPosition(); position < r.end {
+		return r.end - position
+	}
+	return 0
+}
+
+// finish is a private function that returns the first error, or ErrCorrupt if the
+// syntax elements ran into the rbsp_trailing_bits.
+func (r *rbsp) finish() error {
+	if r.err == nil && r.reader.BitPosition() > r.end {
+		r.err = ErrCorrupt
+	}
+	return r.err
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package h264
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// captured returns the NAL units of testdata/x264.264, an x264 stream described in
+// testdata/README.md: SPS, PPS, SEI, an IDR slice, then P and B slices
+func captured(t *testing.T) [][]byte {
+	data, err := os.ReadFile("testdata/x264.264")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := SplitAnnexB(data)
+	if len(units) != 13 {
+		t.Fatalf("SplitAnnexB() = %d NAL units, want 13", len(units))
+	}
+	return units
+}
+
+// bitWriter builds RBSPs for the syntax the captures in testdata don't cover
+type bitWriter struct {
+	data []byte
+	bits uint
+}
+
+func (w *bitWriter) u(bits uint, value uint64) *bitWriter {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>uint(i)&1 == 1 {
+			w.data[len(w.data)-1] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+	return w
+}
+
+func (w *bitWriter) flag(value bool) *bitWriter {
+	if value {
+		return w.u(1, 1)
+	}
+	return w.u(1, 0)
+}
+
+func (w *bitWriter) ue(value uint32) *bitWriter {
+	code := uint64(value) + 1
+	length := uint(0)
+	for code>>length > 1 {
+		length++
+	}
+	return w.u(length, 0).u(length+1, code)
+}
+
+func (w *bitWriter) se(value int32) *bitWriter {
+	if value > 0 {
+		return w.ue(uint32(2*value - 1))
+	}
+	return w.ue(uint32(-2 * value))
+}
+
+// nal adds the rbsp_trailing_bits, emulation prevention bytes and the NAL unit header
+func (w *bitWriter) nal(refIDC uint8, typ NALType) []byte {
+	w.u(1, 1)
+	for w.bits%8 != 0 {
+		w.u(1, 0)
+	}
+	out := []byte{refIDC<<5 | uint8(typ)}
+	zeros := 0
+	for _, b := range w.data {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 0x03)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+func TestReadExpGolomb(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		unsigned uint32
+		signed   int32
+	}{
+		{[]byte{0x80}, 0, 0},
+		{[]byte{0x40}, 1, 1},
+		{[]byte{0x60}, 2, -1},
+		{[]byte{0x20}, 3, 2},
+		{[]byte{0x28}, 4, -2},
+		{[]byte{0x38}, 6, -3},
+		{[]byte{0x08, 0x80}, 16, -8},
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xfe}, 0xfffffffe, -0x7fffffff},
+	}
+	for _, test := range tests {
+		unsigned, err := ReadUE(bitreader.NewReaderFromBytes(test.data, false))
+		if err != nil || unsigned != test.unsigned {
+			t.Errorf("ReadUE(% x) = %d, %v, want %d", test.data, unsigned, err, test.unsigned)
+		}
+		signed, err := ReadSE(bitreader.NewReaderFromBytes(test.data, false))
+		if err != nil || signed != test.signed {
+			t.Errorf("ReadSE(% x) = %d, %v, want %d", test.data, signed, err, test.signed)
+		}
+		w := (&bitWriter{}).ue(test.unsigned)
+		if got := w.data[:(w.bits+7)/8]; !bytes.Equal(got, test.data[:len(got)]) {
+			t.Errorf("writer ue(%d) = % x, want % x", test.unsigned, got, test.data)
+		}
+	}
+}
+
+func TestReadExpGolombErrors(t *testing.T) {
+	if _, err := ReadUE(bitreader.NewReaderFromBytes([]byte{0, 0, 0, 0, 0x80}, false)); err != ErrCorrupt {
+		t.Errorf("ReadUE(32 leading zeros) error = %v, want ErrCorrupt", err)
+	}
+	if _, err := ReadUE(bitreader.NewReaderFromBytes([]byte{0x00, 0x01}, false)); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadUE(truncated suffix) error = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := ReadSE(bitreader.NewReaderFromBytes([]byte{0x00}, false)); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadSE(zeros) error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		want    []byte
+	}{
+		{[]byte{0x01, 0x02}, []byte{0x01, 0x02}},
+		{[]byte{0x00, 0x00, 0x03, 0x01}, []byte{0x00, 0x00, 0x01}},
+		{[]byte{0x00, 0x00, 0x03, 0x00, 0x00, 0x03}, []byte{0x00, 0x00, 0x00, 0x00}},
+		{[]byte{0x00, 0x00, 0x03, 0x03}, []byte{0x00, 0x00, 0x03}},
+		{[]byte{0x00, 0x03, 0x00, 0x00, 0x03, 0x02}, []byte{0x00, 0x03, 0x00, 0x00, 0x02}},
+	}
+	for _, test := range tests {
+		if got := UnescapeRBSP(test.payload); !bytes.Equal(got, test.want) {
+			t.Errorf("UnescapeRBSP(% x) = % x, want % x", test.payload, got, test.want)
+		}
+	}
+}
+
+func TestSplitAnnexB(t *testing.T) {
+	stream := []byte{
+		0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00,
+		0x00, 0x00, 0x01, 0x68, 0xce,
+		0x00, 0x00, 0x00, 0x00, 0x01, 0x65, 0x88, 0x00, 0x00, 0x03, 0x01,
+		0x00, 0x00,
+	}
+	want := [][]byte{{0x67, 0x42}, {0x68, 0xce}, {0x65, 0x88, 0x00, 0x00, 0x03, 0x01}}
+	got := SplitAnnexB(stream)
+	if len(got) != len(want) {
+		t.Fatalf("SplitAnnexB() = % x, want % x", got, want)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("SplitAnnexB()[%d] = % x, want % x", i, got[i], want[i])
+		}
+	}
+	if got := SplitAnnexB([]byte{0x67, 0x42}); got != nil {
+		t.Errorf("SplitAnnexB(no start code) = % x, want nil", got)
+	}
+}
+
+func TestParseNALHeader(t *testing.T) {
+	header, err := ParseNALHeader([]byte{0x65})
+	if err != nil || header != (NALHeader{RefIDC: 3, Type: NALIDR}) {
+		t.Errorf("ParseNALHeader(65) = %+v, %v", header, err)
+	}
+	header, err = ParseNALHeader([]byte{0x06})
+	if err != nil || header != (NALHeader{RefIDC: 0, Type: NALSEI}) {
+		t.Errorf("ParseNALHeader(06) = %+v, %v", header, err)
+	}
+	if _, err := ParseNALHeader([]byte{0xe5}); err != ErrCorrupt {
+		t.Errorf("ParseNALHeader(forbidden bit) error = %v, want ErrCorrupt", err)
+	}
+	if _, err := ParseNALHeader(nil); err != ErrCorrupt {
+		t.Errorf("ParseNALHeader(nil) error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestMoreRBSPData(t *testing.T) {
+	// The stop bit is found behind trailing cabac_zero_words
+	nal := append((&bitWriter{}).ue(5).nal(0, NALPPS), 0x00, 0x00, 0x03, 0x00, 0x00, 0x03)
+	r, _, err := newRBSP(nal, NALPPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.end != 5 {
+		t.Errorf("end = %d, want 5", r.end)
+	}
+	if !r.moreData() {
+		t.Error("moreData() = false before the syntax element")
+	}
+	if r.ue() != 5 || r.moreData() {
+		t.Error("moreData() = true at the stop bit")
+	}
+	if _, _, err := newRBSP([]byte{0x68, 0x00, 0x00}, NALPPS); err != ErrCorrupt {
+		t.Errorf("newRBSP(no stop bit) error = %v, want ErrCorrupt", err)
+	}
+}
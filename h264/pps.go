@@ -0,0 +1,159 @@
+package h264
+
+import "math/bits"
+
+// PPS is a picture parameter set.
+//
+// ID uint32								pic_parameter_set_id
+// SPSID uint32								seq_parameter_set_id of the SPS it refers to
+// EntropyCodingMode bool					Whether the slices use CABAC instead of CAVLC
+// BottomFieldPicOrderInFramePresent bool	bottom_field_pic_order_in_frame_present_flag
+// NumSliceGroups uint32					Number of slice groups, more than 1 for FMO
+// SliceGroupMapType uint32					slice_group_map_type
+// RunLengthMinus1 []uint32					run_length_minus1 of each slice group, for type 0
+// TopLeft []uint32							top_left of each slice group but the last, for type 2
+// BottomRight []uint32						bottom_right of each slice group but the last, for type 2
+// SliceGroupChangeDirection bool			slice_group_change_direction_flag, for types 3 to 5
+// SliceGroupChangeRate uint32				SliceGroupChangeRate in map units, for types 3 to 5
+// SliceGroupID []uint32					slice_group_id of each map unit, for type 6
+// NumRefIdxL0DefaultActive uint32			Default number of active reference indices of list 0
+// NumRefIdxL1DefaultActive uint32			Default number of active reference indices of list 1
+// WeightedPred bool						Whether P and SP slices have explicit weighted prediction
+// WeightedBipredIDC uint32					weighted_bipred_idc of B slices
+// PicInitQP int32							Initial luma quantiser, 26 + pic_init_qp_minus26
+// PicInitQS int32							Initial SP and SI luma quantiser, 26 + pic_init_qs_minus26
+// ChromaQPIndexOffset int32				chroma_qp_index_offset
+// DeblockingFilterControlPresent bool		Whether slice headers have the deblocking filter fields
+// ConstrainedIntraPred bool				constrained_intra_pred_flag
+// RedundantPicCntPresent bool				Whether slice headers have redundant_pic_cnt
+// Transform8x8Mode bool					Whether macroblocks may use the 8x8 transform
+// ScalingMatrixPresent bool				Whether the PPS has scaling lists
+// ScalingLists4x4 [6][16]uint8				4x4 scaling lists in zig-zag order, the SPS lists if not present
+// ScalingLists8x8 [6][64]uint8				8x8 scaling lists in zig-zag order, the SPS lists if not present
+// SecondChromaQPIndexOffset int32			second_chroma_qp_index_offset, ChromaQPIndexOffset if not present
+type PPS struct {
+	ID                                uint32
+	SPSID                             uint32
+	EntropyCodingMode                 bool
+	BottomFieldPicOrderInFramePresent bool
+	NumSliceGroups                    uint32
+	SliceGroupMapType                 uint32
+	RunLengthMinus1                   []uint32
+	TopLeft                           []uint32
+	BottomRight                       []uint32
+	SliceGroupChangeDirection         bool
+	SliceGroupChangeRate              uint32
+	SliceGroupID                      []uint32
+	NumRefIdxL0DefaultActive          uint32
+	NumRefIdxL1DefaultActive          uint32
+	WeightedPred                      bool
+	WeightedBipredIDC                 uint32
+	PicInitQP                         int32
+	PicInitQS                         int32
+	ChromaQPIndexOffset               int32
+	DeblockingFilterControlPresent    bool
+	ConstrainedIntraPred              bool
+	RedundantPicCntPresent            bool
+	Transform8x8Mode                  bool
+	ScalingMatrixPresent              bool
+	ScalingLists4x4                   [6][16]uint8
+	ScalingLists8x8                   [6][64]uint8
+	SecondChromaQPIndexOffset         int32
+}
+
+// ParsePPS is a function that parses a picture parameter set NAL unit. The SPS it refers
+// to is needed for its chroma format and scaling lists, and must have been added to sets.
+//
+// Returns ErrNALType if nal is not a PPS, ErrMissingParameterSet if its SPS is missing,
+// ErrCorrupt if a syntax element is out of range.
+func (sets *ParameterSets) ParsePPS(nal []byte) (*PPS, error) {
+	r, _, err := newRBSP(nal, NALPPS)
+	if err != nil {
+		return nil, err
+	}
+	pps := &PPS{
+		ID:                                r.ueMax(255),
+		SPSID:                             r.ueMax(31),
+		EntropyCodingMode:                 r.flag(),
+		BottomFieldPicOrderInFramePresent: r.flag(),
+		NumSliceGroups:                    r.ueMax(7) + 1,
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	sps := sets.SPS[pps.SPSID]
+	if sps == nil {
+		return nil, ErrMissingParameterSet
+	}
+	if pps.NumSliceGroups > 1 {
+		pps.SliceGroupMapType = r.ueMax(6)
+		switch pps.SliceGroupMapType {
+		case 0:
+			pps.RunLengthMinus1 = make([]uint32, pps.NumSliceGroups)
+			for i := range pps.RunLengthMinus1 {
+				pps.RunLengthMinus1[i] = r.ue()
+			}
+		case 2:
+			pps.TopLeft = make([]uint32, pps.NumSliceGroups-1)
+			pps.BottomRight = make([]uint32, pps.NumSliceGroups-1)
+			for i := range pps.TopLeft {
+				pps.TopLeft[i] = r.ue()
+				pps.BottomRight[i] = r.ue()
+			}
+		case 3, 4, 5:
+			pps.SliceGroupChangeDirection = r.flag()
+			pps.SliceGroupChangeRate = r.ue() + 1
+		case 6:
+			units := uint64(r.ue()) + 1
+			width := uint64(bits.Len32(pps.NumSliceGroups - 1))
+			// Each slice_group_id has at least one bit, don't allocate more than the RBSP can hold
+			if r.err == nil && units*width > r.remaining() {
+				r.err = ErrCorrupt
+			}
+			if r.err == nil {
+				pps.SliceGroupID = make([]uint32, units)
+				for i := range pps.SliceGroupID {
+					pps.SliceGroupID[i] = r.u(width)
+				}
+			}
+		}
+	}
+	pps.NumRefIdxL0DefaultActive = r.ueMax(31) + 1
+	pps.NumRefIdxL1DefaultActive = r.ueMax(31) + 1
+	pps.WeightedPred = r.flag()
+	pps.WeightedBipredIDC = r.u(2)
+	pps.PicInitQP = 26 + r.seRange(-26-6*int32(sps.BitDepthLuma-8), 25)
+	pps.PicInitQS = 26 + r.seRange(-26, 25)
+	pps.ChromaQPIndexOffset = r.seRange(-12, 12)
+	pps.DeblockingFilterControlPresent = r.flag()
+	pps.ConstrainedIntraPred = r.flag()
+	pps.RedundantPicCntPresent = r.flag()
+	pps.SecondChromaQPIndexOffset = pps.ChromaQPIndexOffset
+	pps.ScalingLists4x4 = sps.ScalingLists4x4
+	pps.ScalingLists8x8 = sps.ScalingLists8x8
+	if r.moreData() {
+		pps.Transform8x8Mode = r.flag()
+		pps.ScalingMatrixPresent = r.flag()
+		if pps.ScalingMatrixPresent {
+			count := 6
+			if pps.Transform8x8Mode && sps.ChromaFormatIDC == 3 {
+				count = 12
+			} else if pps.Transform8x8Mode {
+				count = 8
+			}
+			// Fall-back rule B uses the SPS lists, but only if the SPS has any
+			fallback4x4 := [2]*[16]uint8{&default4x4Intra, &default4x4Inter}
+			fallback8x8 := [2]*[64]uint8{&default8x8Intra, &default8x8Inter}
+			if sps.ScalingMatrixPresent {
+				fallback4x4 = [2]*[16]uint8{&sps.ScalingLists4x4[0], &sps.ScalingLists4x4[3]}
+				fallback8x8 = [2]*[64]uint8{&sps.ScalingLists8x8[0], &sps.ScalingLists8x8[1]}
+			}
+			r.scalingLists(&pps.ScalingLists4x4, &pps.ScalingLists8x8, count, fallback4x4, fallback8x8)
+		}
+		pps.SecondChromaQPIndexOffset = r.seRange(-12, 12)
+	}
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return pps, nil
+}
@@ -0,0 +1,177 @@
+package h264
+
+import "testing"
+
+// testParameterSets returns the x264 SPS as ID 0 and a High profile SPS with a custom
+// first 4x4 scaling list as ID 1
+func testParameterSets(t *testing.T) *ParameterSets {
+	sets := NewParameterSets()
+	if err := sets.Add(x264SPS); err != nil {
+		t.Fatal(err)
+	}
+	w := spsHead(&bitWriter{}, 100, 1).ue(1).ue(0).ue(0).flag(false).flag(true)
+	w.flag(true).se(-4)
+	for i := 1; i < 16; i++ {
+		w.se(1)
+	}
+	w.u(7, 0)
+	spsTail(w, 20, 15, true, nil).flag(false)
+	if err := sets.Add(w.nal(3, NALSPS)); err != nil {
+		t.Fatal(err)
+	}
+	return sets
+}
+
+// ppsBody writes the PPS after the slice groups, with 3 default reference indices in list 0
+func ppsBody(w *bitWriter, weightedPred bool, bipredIDC uint64, deblocking, redundant bool) *bitWriter {
+	return w.ue(2).ue(0).flag(weightedPred).u(2, bipredIDC).se(-3).se(0).se(-2).flag(deblocking).flag(false).flag(redundant)
+}
+
+func TestParsePPS(t *testing.T) {
+	sets := testParameterSets(t)
+	pps, err := sets.ParsePPS(ppsBody((&bitWriter{}).ue(3).ue(0).flag(true).flag(true).ue(0), true, 2, true, false).nal(3, NALPPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pps.ID != 3 || pps.SPSID != 0 || !pps.EntropyCodingMode || !pps.BottomFieldPicOrderInFramePresent || pps.NumSliceGroups != 1 {
+		t.Errorf("ParsePPS() = %+v", *pps)
+	}
+	if pps.NumRefIdxL0DefaultActive != 3 || pps.NumRefIdxL1DefaultActive != 1 || !pps.WeightedPred || pps.WeightedBipredIDC != 2 {
+		t.Errorf("ParsePPS() reference indices and weights = %+v", *pps)
+	}
+	if pps.PicInitQP != 23 || pps.PicInitQS != 26 || pps.ChromaQPIndexOffset != -2 || pps.SecondChromaQPIndexOffset != -2 || !pps.DeblockingFilterControlPresent {
+		t.Errorf("ParsePPS() quantisers = %+v", *pps)
+	}
+	if pps.Transform8x8Mode || pps.ScalingMatrixPresent || pps.ScalingLists4x4 != sets.SPS[0].ScalingLists4x4 {
+		t.Errorf("ParsePPS() without extension = %+v", *pps)
+	}
+}
+
+func TestParsePPSCapture(t *testing.T) {
+	units := captured(t)
+	sets := NewParameterSets()
+	if err := sets.Add(units[0]); err != nil {
+		t.Fatal(err)
+	}
+	pps, err := sets.ParsePPS(units[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pps.ID != 0 || pps.SPSID != 0 || !pps.EntropyCodingMode || pps.BottomFieldPicOrderInFramePresent || pps.NumSliceGroups != 1 {
+		t.Errorf("ParsePPS() = %+v", *pps)
+	}
+	if pps.NumRefIdxL0DefaultActive != 3 || pps.NumRefIdxL1DefaultActive != 1 || !pps.WeightedPred || pps.WeightedBipredIDC != 2 {
+		t.Errorf("ParsePPS() reference indices and weights = %+v", *pps)
+	}
+	if pps.PicInitQP != 26 || pps.PicInitQS != 26 || pps.ChromaQPIndexOffset != -2 || !pps.DeblockingFilterControlPresent || pps.ConstrainedIntraPred || pps.RedundantPicCntPresent {
+		t.Errorf("ParsePPS() quantisers = %+v", *pps)
+	}
+	// x264 sends transform_8x8_mode_flag after more_rbsp_data(), without scaling lists
+	if !pps.Transform8x8Mode || pps.ScalingMatrixPresent || pps.SecondChromaQPIndexOffset != -2 || pps.ScalingLists8x8 != sets.SPS[0].ScalingLists8x8 {
+		t.Errorf("ParsePPS() extension = %+v", *pps)
+	}
+}
+
+func TestParsePPSScalingLists(t *testing.T) {
+	sets := testParameterSets(t)
+	// Fall-back rule A, the SPS has no scaling lists: list 0 uses the default, the rest fall back
+	w := ppsBody((&bitWriter{}).ue(0).ue(0).flag(true).flag(false).ue(0), false, 0, false, false)
+	w.flag(true).flag(true).flag(true).se(-8).u(7, 0).se(3)
+	pps, err := sets.ParsePPS(w.nal(3, NALPPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pps.Transform8x8Mode || pps.SecondChromaQPIndexOffset != 3 {
+		t.Errorf("ParsePPS() extension = %+v", *pps)
+	}
+	if pps.ScalingLists4x4[0] != default4x4Intra || pps.ScalingLists4x4[2] != default4x4Intra || pps.ScalingLists4x4[4] != default4x4Inter {
+		t.Errorf("ParsePPS() rule A 4x4 lists = %v", pps.ScalingLists4x4)
+	}
+	if pps.ScalingLists8x8[0] != default8x8Intra || pps.ScalingLists8x8[1] != default8x8Inter {
+		t.Errorf("ParsePPS() rule A 8x8 lists = %v", pps.ScalingLists8x8[:2])
+	}
+	// Fall-back rule B, lists 0 and 3 and the 8x8 lists fall back to the SPS lists
+	w = ppsBody((&bitWriter{}).ue(1).ue(1).flag(true).flag(false).ue(0), false, 0, false, false)
+	w.flag(true).flag(true).u(8, 0).se(0)
+	if pps, err = sets.ParsePPS(w.nal(3, NALPPS)); err != nil {
+		t.Fatal(err)
+	}
+	sps := sets.SPS[1]
+	if pps.ScalingLists4x4[0] != sps.ScalingLists4x4[0] || pps.ScalingLists4x4[2] != sps.ScalingLists4x4[0] || pps.ScalingLists4x4[0] == default4x4Intra {
+		t.Errorf("ParsePPS() rule B 4x4 lists = %v", pps.ScalingLists4x4[:3])
+	}
+	if pps.ScalingLists4x4[3] != default4x4Inter || pps.ScalingLists8x8[0] != default8x8Intra {
+		t.Errorf("ParsePPS() rule B lists 3 and 6 = %v %v", pps.ScalingLists4x4[3], pps.ScalingLists8x8[0])
+	}
+}
+
+func TestParsePPSSliceGroups(t *testing.T) {
+	sets := testParameterSets(t)
+	tests := []struct {
+		name  string
+		write func(w *bitWriter)
+		check func(pps *PPS) bool
+	}{
+		{
+			"run lengths",
+			func(w *bitWriter) { w.ue(2).ue(0).ue(9).ue(19).ue(29) },
+			func(pps *PPS) bool {
+				return pps.NumSliceGroups == 3 && len(pps.RunLengthMinus1) == 3 && pps.RunLengthMinus1[2] == 29
+			},
+		},
+		{
+			"foreground boxes",
+			func(w *bitWriter) { w.ue(2).ue(2).ue(0).ue(21).ue(22).ue(43) },
+			func(pps *PPS) bool {
+				return len(pps.TopLeft) == 2 && pps.TopLeft[1] == 22 && pps.BottomRight[1] == 43
+			},
+		},
+		{
+			"box-out",
+			func(w *bitWriter) { w.ue(1).ue(3).flag(true).ue(4) },
+			func(pps *PPS) bool {
+				return pps.SliceGroupMapType == 3 && pps.SliceGroupChangeDirection && pps.SliceGroupChangeRate == 5
+			},
+		},
+		{
+			"explicit",
+			func(w *bitWriter) { w.ue(2).ue(6).ue(3).u(2, 0).u(2, 2).u(2, 1).u(2, 2) },
+			func(pps *PPS) bool {
+				return len(pps.SliceGroupID) == 4 && pps.SliceGroupID[1] == 2 && pps.SliceGroupID[2] == 1
+			},
+		},
+	}
+	for _, test := range tests {
+		w := (&bitWriter{}).ue(0).ue(0).flag(false).flag(false)
+		test.write(w)
+		pps, err := sets.ParsePPS(ppsBody(w, false, 0, false, false).nal(3, NALPPS))
+		if err != nil {
+			t.Errorf("%s: ParsePPS() error = %v", test.name, err)
+			continue
+		}
+		if !test.check(pps) {
+			t.Errorf("%s: ParsePPS() = %+v", test.name, *pps)
+		}
+	}
+}
+
+func TestParsePPSErrors(t *testing.T) {
+	sets := testParameterSets(t)
+	tests := []struct {
+		name string
+		nal  []byte
+		err  error
+	}{
+		{"SPS", x264SPS, ErrNALType},
+		{"missing SPS", ppsBody((&bitWriter{}).ue(0).ue(2).flag(false).flag(false).ue(0), false, 0, false, false).nal(3, NALPPS), ErrMissingParameterSet},
+		{"id", (&bitWriter{}).ue(256).nal(3, NALPPS), ErrCorrupt},
+		{"slice groups", (&bitWriter{}).ue(0).ue(0).flag(false).flag(false).ue(8).nal(3, NALPPS), ErrCorrupt},
+		{"explicit map size", (&bitWriter{}).ue(0).ue(0).flag(false).flag(false).ue(1).ue(6).ue(1<<30).u(8, 0).nal(3, NALPPS), ErrCorrupt},
+		{"qp", (&bitWriter{}).ue(0).ue(0).flag(false).flag(false).ue(0).ue(0).ue(0).flag(false).u(2, 0).se(26).nal(3, NALPPS), ErrCorrupt},
+	}
+	for _, test := range tests {
+		if _, err := sets.ParsePPS(test.nal); err != test.err {
+			t.Errorf("%s: ParsePPS() error = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package h264
+
+import "math/bits"
+
+// SliceType is the type of a slice, slice_type modulo 5.
+type SliceType uint8
+
+const (
+	SliceP  SliceType = 0 // Predicted from one reference picture list
+	SliceB  SliceType = 1 // Predicted from two reference picture lists
+	SliceI  SliceType = 2 // Intra only
+	SliceSP SliceType = 3 // Switching P
+	SliceSI SliceType = 4 // Switching I
+)
+
+// ParameterSets keeps the active SPS and PPS of a stream by ID, which PPSs and slice
+// headers refer to.
+//
+// SPS map[uint32]*SPS		Sequence parameter sets by seq_parameter_set_id
+// PPS map[uint32]*PPS		Picture parameter sets by pic_parameter_set_id
+type ParameterSets struct {
+	SPS map[uint32]*SPS
+	PPS map[uint32]*PPS
+}
+
+// SliceHeader is the header of a coded slice.
+//
+// NALHeader NALHeader							Header of the NAL unit
+// FirstMbInSlice uint32						Address of the first macroblock of the slice
+// SliceType SliceType							Type of the slice
+// AllSameType bool								Whether all slices of the picture have the same type, slice_type > 4
+// PPSID uint32									pic_parameter_set_id
+// ColourPlaneID uint8							colour_plane_id, for separately coded colour planes
+// FrameNum uint32								frame_num
+// FieldPic bool								Whether the slice is of a field
+// BottomField bool								Whether the field is the bottom field
+// IDRPicID uint32								idr_pic_id, for IDR pictures
+// PicOrderCntLsb uint32						pic_order_cnt_lsb, for pic_order_cnt_type 0
+// DeltaPicOrderCntBottom int32					delta_pic_order_cnt_bottom, for pic_order_cnt_type 0
+// DeltaPicOrderCnt [2]int32					delta_pic_order_cnt, for pic_order_cnt_type 1
+// RedundantPicCnt uint32						redundant_pic_cnt
+// DirectSpatialMvPred bool						direct_spatial_mv_pred_flag, for B slices
+// NumRefIdxActive [2]uint32					Number of active reference indices of list 0 and 1
+// RefPicListModification [2][]RefPicListModification	Reference picture list modifications of list 0 and 1
+// PredWeightTable *PredWeightTable				Explicit prediction weights, nil if not present
+// DecRefPicMarking *DecRefPicMarking			Decoded reference picture marking, nil for non-reference pictures
+// CABACInitIDC uint32							cabac_init_idc
+// SliceQPDelta int32							slice_qp_delta
+// SPForSwitch bool								sp_for_switch_flag
+// SliceQSDelta int32							slice_qs_delta
+// DisableDeblockingFilterIDC uint32			disable_deblocking_filter_idc
+// SliceAlphaC0OffsetDiv2 int32					slice_alpha_c0_offset_div2
+// SliceBetaOffsetDiv2 int32					slice_beta_offset_div2
+// SliceGroupChangeCycle uint32					slice_group_change_cycle
+// DataOffset uint64							Bit offset of slice_data() in the RBSP, after the NAL unit header
+type SliceHeader struct {
+	NALHeader                  NALHeader
+	FirstMbInSlice             uint32
+	SliceType                  SliceType
+	AllSameType                bool
+	PPSID                      uint32
+	ColourPlaneID              uint8
+	FrameNum                   uint32
+	FieldPic                   bool
+	BottomField                bool
+	IDRPicID                   uint32
+	PicOrderCntLsb             uint32
+	DeltaPicOrderCntBottom     int32
+	DeltaPicOrderCnt           [2]int32
+	RedundantPicCnt            uint32
+	DirectSpatialMvPred        bool
+	NumRefIdxActive            [2]uint32
+	RefPicListModification     [2][]RefPicListModification
+	PredWeightTable            *PredWeightTable
+	DecRefPicMarking           *DecRefPicMarking
+	CABACInitIDC               uint32
+	SliceQPDelta               int32
+	SPForSwitch                bool
+	SliceQSDelta               int32
+	DisableDeblockingFilterIDC uint32
+	SliceAlphaC0OffsetDiv2     int32
+	SliceBetaOffsetDiv2        int32
+	SliceGroupChangeCycle      uint32
+	DataOffset                 uint64
+}
+
+// RefPicListModification is one modification of a reference picture list.
+//
+// IDC uint32		modification_of_pic_nums_idc, 0 and 1 subtract and add, 2 long-term
+// Value uint32		abs_diff_pic_num_minus1 or long_term_pic_num
+type RefPicListModification struct {
+	IDC   uint32
+	Value uint32
+}
+
+// PredWeightTable is the explicit weighted prediction table of a slice. Reference indices
+// without explicit weights have the default weight and an offset of 0.
+//
+// LumaLog2WeightDenom uint32		luma_log2_weight_denom
+// ChromaLog2WeightDenom uint32		chroma_log2_weight_denom
+// Luma [2][]Weight					Luma weights of list 0 and 1
+// Chroma [2][][2]Weight			Cb and Cr weights of list 0 and 1, nil for monochrome
+type PredWeightTable struct {
+	LumaLog2WeightDenom   uint32
+	ChromaLog2WeightDenom uint32
+	Luma                  [2][]Weight
+	Chroma                [2][][2]Weight
+}
+
+// Weight is the weight and offset of a reference picture.
+//
+// Weight int32		Multiplicative weight
+// Offset int32		Additive offset
+type Weight struct {
+	Weight int32
+	Offset int32
+}
+
+// DecRefPicMarking is the decoded reference picture marking of a reference slice.
+//
+// NoOutputOfPriorPics bool			no_output_of_prior_pics_flag, for IDR pictures
+// LongTermReference bool			long_term_reference_flag, for IDR pictures
+// AdaptiveRefPicMarking bool		Whether Operations is used instead of the sliding window
+// Operations []MMCO				The memory management control operations
+type DecRefPicMarking struct {
+	NoOutputOfPriorPics   bool
+	LongTermReference     bool
+	AdaptiveRefPicMarking bool
+	Operations            []MMCO
+}
+
+// MMCO is a memory management control operation.
+//
+// Operation uint32					memory_management_control_operation, 1 to 6
+// DifferenceOfPicNums uint32		difference_of_pic_nums_minus1 + 1, for operations 1 and 3
+// LongTermPicNum uint32			long_term_pic_num, for operation 2
+// LongTermFrameIdx uint32			long_term_frame_idx, for operations 3 and 6
+// MaxLongTermFrameIdxPlus1 uint32	max_long_term_frame_idx_plus1, for operation 4
+type MMCO struct {
+	Operation                uint32
+	DifferenceOfPicNums      uint32
+	LongTermPicNum           uint32
+	LongTermFrameIdx         uint32
+	MaxLongTermFrameIdxPlus1 uint32
+}
+
+// maxModifications is the limit on list modifications and memory management operations,
+// each list has at most 32 entries and an operation for every reference picture is plenty.
+const maxModifications = 66
+
+// NewParameterSets is a function that returns an empty ParameterSets.
+func NewParameterSets() *ParameterSets {
+	return &ParameterSets{SPS: make(map[uint32]*SPS), PPS: make(map[uint32]*PPS)}
+}
+
+// Add is a function that parses an SPS or PPS NAL unit and keeps it, replacing the
+// parameter set with the same ID. Other NAL units are ignored.
+//
+// Returns the errors of ParseSPS and ParsePPS.
+func (sets *ParameterSets) Add(nal []byte) error {
+	header, err := ParseNALHeader(nal)
+	if err != nil {
+		return err
+	}
+	switch header.Type {
+	case NALSPS:
+		sps, err := ParseSPS(nal)
+		if err != nil {
+			return err
+		}
+		sets.SPS[sps.ID] = sps
+	case NALPPS:
+		pps, err := sets.ParsePPS(nal)
+		if err != nil {
+			return err
+		}
+		sets.PPS[pps.ID] = pps
+	}
+	return nil
+}
+
+// ParseSliceHeader is a function that parses the slice header of a coded slice NAL unit,
+// a non-IDR, IDR, auxiliary or data partition A slice. The PPS and SPS it refers to must
+// have been added to sets.
+//
+// Returns ErrNALType if nal is not a slice, ErrUnsupported for the slices of the SVC and MVC
+// extensions, ErrMissingParameterSet if a parameter set is missing, ErrCorrupt if a syntax
+// element is out of range.
+func (sets *ParameterSets) ParseSliceHeader(nal []byte) (*SliceHeader, error) {
+	r, nalHeader, err := newRBSP(nal, NALSlice, NALSliceA, NALIDR, NALAuxiliary)
+	if err == ErrNALType && (nalHeader.Type == NALSliceExt || nalHeader.Type == 21) {
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := &SliceHeader{NALHeader: nalHeader, FirstMbInSlice: r.ue()}
+	sliceType := r.ueMax(9)
+	header.SliceType = SliceType(sliceType % 5)
+	header.AllSameType = sliceType > 4
+	header.PPSID = r.ueMax(255)
+	if r.err != nil {
+		return nil, r.err
+	}
+	pps := sets.PPS[header.PPSID]
+	if pps == nil {
+		return nil, ErrMissingParameterSet
+	}
+	sps := sets.SPS[pps.SPSID]
+	if sps == nil {
+		return nil, ErrMissingParameterSet
+	}
+	idr := nalHeader.Type == NALIDR
+	if idr && header.SliceType != SliceI && header.SliceType != SliceSI {
+		return nil, ErrCorrupt
+	}
+	if sps.SeparateColourPlane {
+		header.ColourPlaneID = uint8(r.u(2))
+	}
+	header.FrameNum = r.u(uint64(sps.Log2MaxFrameNum))
+	if !sps.FrameMbsOnly {
+		header.FieldPic = r.flag()
+		if header.FieldPic {
+			header.BottomField = r.flag()
+		}
+	}
+	if idr {
+		header.IDRPicID = r.ueMax(65535)
+	}
+	switch sps.PicOrderCntType {
+	case 0:
+		header.PicOrderCntLsb = r.u(uint64(sps.Log2MaxPicOrderCntLsb))
+		if pps.BottomFieldPicOrderInFramePresent && !header.FieldPic {
+			header.DeltaPicOrderCntBottom = r.se()
+		}
+	case 1:
+		if !sps.DeltaPicOrderAlwaysZero {
+			header.DeltaPicOrderCnt[0] = r.se()
+			if pps.BottomFieldPicOrderInFramePresent && !header.FieldPic {
+				header.DeltaPicOrderCnt[1] = r.se()
+			}
+		}
+	}
+	if pps.RedundantPicCntPresent {
+		header.RedundantPicCnt = r.ueMax(127)
+	}
+	if header.SliceType == SliceB {
+		header.DirectSpatialMvPred = r.flag()
+	}
+	header.NumRefIdxActive = [2]uint32{pps.NumRefIdxL0DefaultActive, pps.NumRefIdxL1DefaultActive}
+	lists := 0
+	switch header.SliceType {
+	case SliceP, SliceSP:
+		lists = 1
+	case SliceB:
+		lists = 2
+	}
+	if lists > 0 && r.flag() {
+		for list := 0; list < lists; list++ {
+			header.NumRefIdxActive[list] = r.ueMax(31) + 1
+		}
+	}
+	for list := 0; list < lists; list++ {
+		header.RefPicListModification[list] = r.refPicListModification()
+	}
+	if (pps.WeightedPred && lists == 1) || (pps.WeightedBipredIDC == 1 && lists == 2) {
+		header.PredWeightTable = r.predWeightTable(header.NumRefIdxActive[:lists], sps.ChromaArrayType() != 0)
+	}
+	if nalHeader.RefIDC != 0 {
+		header.DecRefPicMarking = r.decRefPicMarking(idr)
+	}
+	if pps.EntropyCodingMode && header.SliceType != SliceI && header.SliceType != SliceSI {
+		header.CABACInitIDC = r.ueMax(2)
+	}
+	header.SliceQPDelta = r.se()
+	if header.SliceType == SliceSP || header.SliceType == SliceSI {
+		if header.SliceType == SliceSP {
+			header.SPForSwitch = r.flag()
+		}
+		header.SliceQSDelta = r.se()
+	}
+	if pps.DeblockingFilterControlPresent {
+		header.DisableDeblockingFilterIDC = r.ueMax(2)
+		if header.DisableDeblockingFilterIDC != 1 {
+			header.SliceAlphaC0OffsetDiv2 = r.seRange(-6, 6)
+			header.SliceBetaOffsetDiv2 = r.seRange(-6, 6)
+		}
+	}
+	if pps.NumSliceGroups > 1 && pps.SliceGroupMapType >= 3 && pps.SliceGroupMapType <= 5 {
+		// Ceil(Log2(PicSizeInMapUnits / SliceGroupChangeRate + 1)) with an exact division
+		units := uint64(sps.PicWidthInMbs) * uint64(sps.PicHeightInMapUnits)
+		rate := uint64(pps.SliceGroupChangeRate)
+		header.SliceGroupChangeCycle = r.u(uint64(bits.Len64((units + rate - 1) / rate)))
+	}
+	header.DataOffset = r.reader.BitPosition()
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// refPicListModification is a private function that reads the modifications of one
+// reference picture list in ref_pic_list_modification().
+func (r *rbsp) refPicListModification() []RefPicListModification {
+	if !r.flag() {
+		return nil
+	}
+	var modifications []RefPicListModification
+	for r.err == nil {
+		idc := r.ueMax(5)
+		if idc == 3 {
+			break
+		}
+		if len(modifications) == maxModifications {
+			r.err = ErrCorrupt
+			break
+		}
+		modifications = append(modifications, RefPicListModification{IDC: idc, Value: r.ue()})
+	}
+	return modifications
+}
+
+// predWeightTable is a private function that reads pred_weight_table() for the lists
+// with the given numbers of active reference indices.
+func (r *rbsp) predWeightTable(active []uint32, chroma bool) *PredWeightTable {
+	table := &PredWeightTable{LumaLog2WeightDenom: r.ueMax(7)}
+	if chroma {
+		table.ChromaLog2WeightDenom = r.ueMax(7)
+	}
+	for list, count := range active {
+		table.Luma[list] = make([]Weight, count)
+		if chroma {
+			table.Chroma[list] = make([][2]Weight, count)
+		}
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			table.Luma[list][i] = Weight{Weight: 1 << table.LumaLog2WeightDenom}
+			if r.flag() {
+				table.Luma[list][i] = Weight{Weight: r.seRange(-128, 127), Offset: r.seRange(-128, 127)}
+			}
+			if !chroma {
+				continue
+			}
+			table.Chroma[list][i] = [2]Weight{{Weight: 1 << table.ChromaLog2WeightDenom}, {Weight: 1 << table.ChromaLog2WeightDenom}}
+			if r.flag() {
+				for j := range table.Chroma[list][i] {
+					table.Chroma[list][i][j] = Weight{Weight: r.seRange(-128, 127), Offset: r.seRange(-128, 127)}
+				}
+			}
+		}
+	}
+	return table
+}
+
+// decRefPicMarking is a private function that reads dec_ref_pic_marking().
+func (r *rbsp) decRefPicMarking(idr bool) *DecRefPicMarking {
+	marking := &DecRefPicMarking{}
+	if idr {
+		marking.NoOutputOfPriorPics = r.flag()
+		marking.LongTermReference = r.flag()
+		return marking
+	}
+	marking.AdaptiveRefPicMarking = r.flag()
+	for marking.AdaptiveRefPicMarking && r.err == nil {
+		operation := MMCO{Operation: r.ueMax(6)}
+		if operation.Operation == 0 {
+			break
+		}
+		if len(marking.Operations) == maxModifications {
+			r.err = ErrCorrupt
+			break
+		}
+		switch operation.Operation {
+		case 1:
+			operation.DifferenceOfPicNums = r.ue() + 1
+		case 2:
+			operation.LongTermPicNum = r.ue()
+		case 3:
+			operation.DifferenceOfPicNums = r.ue() + 1
+			operation.LongTermFrameIdx = r.ue()
+		case 4:
+			operation.MaxLongTermFrameIdxPlus1 = r.ue()
+		case 6:
+			operation.LongTermFrameIdx = r.ue()
+		}
+		marking.Operations = append(marking.Operations, operation)
+	}
+	return marking
+}
@@ -0,0 +1,254 @@
+package h264
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sliceParameterSets adds three PPSs for the x264 SPS to testParameterSets:
+// 0 CABAC with deblocking control, 1 CABAC with weighted P prediction, bottom field
+// pic order and redundant_pic_cnt, 2 CAVLC with implicit weights and box-out slice groups
+func sliceParameterSets(t *testing.T) *ParameterSets {
+	sets := testParameterSets(t)
+	nals := [][]byte{
+		ppsBody((&bitWriter{}).ue(0).ue(0).flag(true).flag(false).ue(0), false, 0, true, false).nal(3, NALPPS),
+		ppsBody((&bitWriter{}).ue(1).ue(0).flag(true).flag(true).ue(0), true, 0, false, true).nal(3, NALPPS),
+		ppsBody((&bitWriter{}).ue(2).ue(0).flag(false).flag(false).ue(1).ue(4).flag(false).ue(5), false, 1, false, false).nal(3, NALPPS),
+	}
+	for _, nal := range nals {
+		if err := sets.Add(nal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sets
+}
+
+func TestParseSliceHeaderIDR(t *testing.T) {
+	sets := sliceParameterSets(t)
+	w := (&bitWriter{}).ue(0).ue(7).ue(0).u(4, 0).ue(1).u(6, 0)
+	w.flag(true).flag(false).se(-3).ue(0).se(1).se(-1)
+	offset := uint64(w.bits)
+	w.u(8, 0xab)
+	header, err := sets.ParseSliceHeader(w.nal(3, NALIDR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SliceHeader{
+		NALHeader:              NALHeader{RefIDC: 3, Type: NALIDR},
+		SliceType:              SliceI,
+		AllSameType:            true,
+		IDRPicID:               1,
+		NumRefIdxActive:        [2]uint32{3, 1},
+		DecRefPicMarking:       &DecRefPicMarking{NoOutputOfPriorPics: true},
+		SliceQPDelta:           -3,
+		SliceAlphaC0OffsetDiv2: 1,
+		SliceBetaOffsetDiv2:    -1,
+		DataOffset:             offset,
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("ParseSliceHeader() = %+v, want %+v", *header, *want)
+	}
+}
+
+func TestParseSliceHeaderP(t *testing.T) {
+	sets := sliceParameterSets(t)
+	w := (&bitWriter{}).ue(5).ue(0).ue(1).u(4, 3).u(6, 10).se(-1).ue(1)
+	// Two active references, list modifications and explicit weights
+	w.flag(true).ue(1)
+	w.flag(true).ue(0).ue(2).ue(2).ue(5).ue(3)
+	w.ue(5).ue(4).flag(true).se(40).se(-3).flag(false).flag(false).flag(true).se(10).se(1).se(20).se(-2)
+	// Adaptive marking, cabac_init_idc and slice_qp_delta
+	w.flag(true).ue(1).ue(4).ue(3).ue(0).ue(2).ue(0)
+	w.ue(2).se(4)
+	offset := uint64(w.bits)
+	header, err := sets.ParseSliceHeader(w.u(3, 0).nal(2, NALSlice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SliceHeader{
+		NALHeader:              NALHeader{RefIDC: 2, Type: NALSlice},
+		FirstMbInSlice:         5,
+		SliceType:              SliceP,
+		PPSID:                  1,
+		FrameNum:               3,
+		PicOrderCntLsb:         10,
+		DeltaPicOrderCntBottom: -1,
+		RedundantPicCnt:        1,
+		NumRefIdxActive:        [2]uint32{2, 1},
+		RefPicListModification: [2][]RefPicListModification{{{IDC: 0, Value: 2}, {IDC: 2, Value: 5}}},
+		PredWeightTable: &PredWeightTable{
+			LumaLog2WeightDenom:   5,
+			ChromaLog2WeightDenom: 4,
+			Luma:                  [2][]Weight{{{40, -3}, {32, 0}}},
+			Chroma:                [2][][2]Weight{{{{16, 0}, {16, 0}}, {{10, 1}, {20, -2}}}},
+		},
+		DecRefPicMarking: &DecRefPicMarking{
+			AdaptiveRefPicMarking: true,
+			Operations:            []MMCO{{Operation: 1, DifferenceOfPicNums: 5}, {Operation: 3, DifferenceOfPicNums: 1, LongTermFrameIdx: 2}},
+		},
+		CABACInitIDC: 2,
+		SliceQPDelta: 4,
+		DataOffset:   offset,
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("ParseSliceHeader() = %+v, want %+v", *header, *want)
+	}
+}
+
+func TestParseSliceHeaderB(t *testing.T) {
+	sets := sliceParameterSets(t)
+	w := (&bitWriter{}).ue(0).ue(1).ue(2).u(4, 9).u(6, 4).flag(true).flag(false)
+	// List 1 is modified, list 0 isn't
+	w.flag(false).flag(true).ue(1).ue(0).ue(3)
+	w.ue(0).ue(0).u(6, 0).u(2, 0)
+	// 3600 map units at a change rate of 6 make slice_group_change_cycle 10 bits
+	w.se(-2).u(10, 599)
+	header, err := sets.ParseSliceHeader(w.u(8, 0x5a).nal(0, NALSlice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.SliceType != SliceB || header.AllSameType || !header.DirectSpatialMvPred || header.NumRefIdxActive != [2]uint32{3, 1} {
+		t.Errorf("ParseSliceHeader() = %+v", *header)
+	}
+	if header.RefPicListModification[0] != nil || !reflect.DeepEqual(header.RefPicListModification[1], []RefPicListModification{{IDC: 1}}) {
+		t.Errorf("ParseSliceHeader() list modifications = %v", header.RefPicListModification)
+	}
+	if header.DecRefPicMarking != nil || header.SliceQPDelta != -2 || header.SliceGroupChangeCycle != 599 {
+		t.Errorf("ParseSliceHeader() = %+v", *header)
+	}
+	table := header.PredWeightTable
+	if table == nil || len(table.Luma[0]) != 3 || len(table.Luma[1]) != 1 || table.Luma[0][2] != (Weight{1, 0}) || table.Chroma[1][0] != [2]Weight{{1, 0}, {1, 0}} {
+		t.Errorf("ParseSliceHeader() implicit weights = %+v", table)
+	}
+}
+
+func TestParseSliceHeaderCapture(t *testing.T) {
+	units := captured(t)
+	sets := NewParameterSets()
+	for _, nal := range units[:2] {
+		if err := sets.Add(nal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// slice returns the header fields every slice of the capture has
+	slice := func(refIDC uint8, sliceType SliceType, frameNum, pocLsb uint32, active [2]uint32, qpDelta int32, offset uint64) *SliceHeader {
+		header := &SliceHeader{
+			NALHeader: NALHeader{RefIDC: refIDC, Type: NALSlice}, SliceType: sliceType, AllSameType: true,
+			FrameNum: frameNum, PicOrderCntLsb: pocLsb, DirectSpatialMvPred: sliceType == SliceB,
+			NumRefIdxActive: active, SliceQPDelta: qpDelta, DataOffset: offset,
+		}
+		if refIDC != 0 {
+			header.DecRefPicMarking = &DecRefPicMarking{}
+		}
+		return header
+	}
+	// mmco returns a marking that removes the short-term picture difference frames back
+	mmco := func(difference uint32) *DecRefPicMarking {
+		return &DecRefPicMarking{AdaptiveRefPicMarking: true, Operations: []MMCO{{Operation: 1, DifferenceOfPicNums: difference}}}
+	}
+	// weights returns count chroma weights of Cb and Cr, without an offset
+	weights := func(weight int32, count int) [][2]Weight {
+		out := make([][2]Weight, count)
+		for i := range out {
+			out[i] = [2]Weight{{Weight: weight}, {Weight: weight}}
+		}
+		return out
+	}
+	idr := slice(3, SliceI, 0, 0, [2]uint32{3, 1}, 18, 36)
+	idr.NALHeader.Type = NALIDR
+	p1 := slice(2, SliceP, 1, 2, [2]uint32{1, 1}, 20, 86)
+	p1.PredWeightTable = &PredWeightTable{
+		ChromaLog2WeightDenom: 6,
+		Luma:                  [2][]Weight{{{Weight: 1, Offset: 1}}},
+		Chroma:                [2][][2]Weight{{{{Weight: 64}, {Weight: 65, Offset: -2}}}},
+	}
+	p2 := slice(2, SliceP, 2, 10, [2]uint32{4, 1}, 20, 165)
+	p2.RefPicListModification[0] = []RefPicListModification{{0, 0}, {0, 15}, {0, 15}, {0, 0}}
+	p2.PredWeightTable = &PredWeightTable{
+		LumaLog2WeightDenom: 6, ChromaLog2WeightDenom: 6,
+		Luma:   [2][]Weight{{{Weight: 65, Offset: -1}, {Weight: 65, Offset: -2}, {Weight: 64}, {Weight: 64}}},
+		Chroma: [2][][2]Weight{append([][2]Weight{{{Weight: 57, Offset: 16}, {Weight: 64}}}, weights(64, 3)...)},
+	}
+	b1 := slice(2, SliceB, 3, 6, [2]uint32{2, 1}, 23, 48)
+	b1.DecRefPicMarking = mmco(3)
+	p3 := slice(2, SliceP, 4, 14, [2]uint32{4, 1}, 25, 90)
+	p3.RefPicListModification[0] = []RefPicListModification{{0, 1}, {0, 15}, {1, 0}, {0, 1}}
+	p3.PredWeightTable = &PredWeightTable{
+		Luma:   [2][]Weight{{{Weight: 1}, {Weight: 1, Offset: -1}, {Weight: 1}, {Weight: 1}}},
+		Chroma: [2][][2]Weight{weights(1, 4)},
+	}
+	p3.DecRefPicMarking = mmco(3)
+	p4 := slice(2, SliceP, 5, 18, [2]uint32{4, 1}, 25, 88)
+	p4.RefPicListModification[0] = []RefPicListModification{{0, 0}, {0, 15}, {0, 1}, {1, 0}}
+	p4.PredWeightTable = p3.PredWeightTable
+	p4.DecRefPicMarking = mmco(2)
+	// In decoding order, the non-reference B slices have implicit weights
+	want := []*SliceHeader{
+		idr, p1, p2, b1,
+		slice(0, SliceB, 4, 4, [2]uint32{1, 2}, 25, 40),
+		slice(0, SliceB, 4, 8, [2]uint32{2, 1}, 24, 40),
+		p3,
+		slice(0, SliceB, 5, 12, [2]uint32{2, 1}, 25, 40),
+		p4,
+		slice(0, SliceB, 6, 16, [2]uint32{2, 1}, 25, 40),
+	}
+	for i, nal := range units[3:] {
+		header, err := sets.ParseSliceHeader(nal)
+		if err != nil {
+			t.Errorf("slice %d: ParseSliceHeader() error = %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(header, want[i]) {
+			t.Errorf("slice %d: ParseSliceHeader() = %+v, want %+v", i, *header, *want[i])
+		}
+	}
+}
+
+func TestParseSliceHeaderErrors(t *testing.T) {
+	sets := sliceParameterSets(t)
+	mmco := (&bitWriter{}).ue(0).ue(5).ue(0).u(4, 1).u(6, 2).flag(false).flag(false).flag(true)
+	for i := 0; i <= maxModifications; i++ {
+		mmco.ue(2).ue(0)
+	}
+	tests := []struct {
+		name string
+		nal  []byte
+		err  error
+	}{
+		{"SPS", x264SPS, ErrNALType},
+		{"MVC", (&bitWriter{}).ue(0).nal(3, NALSliceExt), ErrUnsupported},
+		{"missing PPS", (&bitWriter{}).ue(0).ue(2).ue(9).nal(3, NALSlice), ErrMissingParameterSet},
+		{"slice type", (&bitWriter{}).ue(0).ue(10).nal(3, NALSlice), ErrCorrupt},
+		{"P slice in IDR", (&bitWriter{}).ue(0).ue(0).ue(0).nal(3, NALIDR), ErrCorrupt},
+		{"marking", mmco.nal(1, NALSlice), ErrCorrupt},
+	}
+	for _, test := range tests {
+		if _, err := sets.ParseSliceHeader(test.nal); err != test.err {
+			t.Errorf("%s: ParseSliceHeader() error = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestParseAnnexBStream(t *testing.T) {
+	pps := ppsBody((&bitWriter{}).ue(0).ue(0).flag(true).flag(false).ue(0), false, 0, true, false).nal(3, NALPPS)
+	idr := (&bitWriter{}).ue(0).ue(7).ue(0).u(4, 0).ue(0).u(6, 0).flag(false).flag(false).se(0).ue(1).u(16, 0).nal(3, NALIDR)
+	var stream []byte
+	for _, nal := range [][]byte{x264SPS, pps, idr} {
+		stream = append(append(stream, 0x00, 0x00, 0x00, 0x01), nal...)
+	}
+	sets := NewParameterSets()
+	var headers []*SliceHeader
+	for _, nal := range SplitAnnexB(stream) {
+		if err := sets.Add(nal); err != nil {
+			t.Fatal(err)
+		}
+		if header, err := sets.ParseSliceHeader(nal); err == nil {
+			headers = append(headers, header)
+		} else if err != ErrNALType {
+			t.Fatal(err)
+		}
+	}
+	if len(sets.SPS) != 1 || len(sets.PPS) != 1 || len(headers) != 1 || headers[0].DisableDeblockingFilterIDC != 1 {
+		t.Errorf("parsed %d SPS, %d PPS, slice headers %v", len(sets.SPS), len(sets.PPS), headers)
+	}
+}
@@ -0,0 +1,488 @@
+package h264
+
+// SPS is a sequence parameter set.
+//
+// ProfileIDC uint8					profile_idc, 66 Baseline, 77 Main, 100 High, ...
+// ConstraintFlags uint8			constraint_set0_flag to constraint_set5_flag, set0 in bit 5
+// LevelIDC uint8					level_idc, ten times the level
+// ID uint32						seq_parameter_set_id
+// ChromaFormatIDC uint32			chroma_format_idc, 0 monochrome, 1 4:2:0, 2 4:2:2, 3 4:4:4
+// SeparateColourPlane bool			Whether the colour planes of 4:4:4 are coded separately
+// BitDepthLuma uint32				Bit depth of the luma samples
+// BitDepthChroma uint32			Bit depth of the chroma samples
+// TransformBypass bool				qpprime_y_zero_transform_bypass_flag
+// ScalingMatrixPresent bool		Whether the SPS has scaling lists
+// ScalingLists4x4 [6][16]uint8		4x4 scaling lists in zig-zag order, with the fall-back rule applied
+// ScalingLists8x8 [6][64]uint8		8x8 scaling lists in zig-zag order, with the fall-back rule applied
+// Log2MaxFrameNum uint32			Length of frame_num in bits
+// PicOrderCntType uint32			pic_order_cnt_type
+// Log2MaxPicOrderCntLsb uint32		Length of pic_order_cnt_lsb in bits, for type 0
+// DeltaPicOrderAlwaysZero bool		delta_pic_order_always_zero_flag, for type 1
+// OffsetForNonRefPic int32			offset_for_non_ref_pic, for type 1
+// OffsetForTopToBottomField int32	offset_for_top_to_bottom_field, for type 1
+// OffsetForRefFrame []int32		offset_for_ref_frame, for type 1
+// MaxNumRefFrames uint32			max_num_ref_frames
+// GapsInFrameNumAllowed bool		gaps_in_frame_num_value_allowed_flag
+// PicWidthInMbs uint32				Width in macroblocks
+// PicHeightInMapUnits uint32		Height in slice group map units, macroblock pairs for interlaced video
+// FrameMbsOnly bool				Whether there are only frame macroblocks, no fields
+// MbAdaptiveFrameField bool		mb_adaptive_frame_field_flag
+// Direct8x8Inference bool			direct_8x8_inference_flag
+// FrameCropping bool				Whether the frame is cropped
+// CropLeft uint32					frame_crop_left_offset, in crop units
+// CropRight uint32					frame_crop_right_offset, in crop units
+// CropTop uint32					frame_crop_top_offset, in crop units
+// CropBottom uint32				frame_crop_bottom_offset, in crop units
+// VUI *VUI							Video usability information, nil if not present
+type SPS struct {
+	ProfileIDC                uint8
+	ConstraintFlags           uint8
+	LevelIDC                  uint8
+	ID                        uint32
+	ChromaFormatIDC           uint32
+	SeparateColourPlane       bool
+	BitDepthLuma              uint32
+	BitDepthChroma            uint32
+	TransformBypass           bool
+	ScalingMatrixPresent      bool
+	ScalingLists4x4           [6][16]uint8
+	ScalingLists8x8           [6][64]uint8
+	Log2MaxFrameNum           uint32
+	PicOrderCntType           uint32
+	Log2MaxPicOrderCntLsb     uint32
+	DeltaPicOrderAlwaysZero   bool
+	OffsetForNonRefPic        int32
+	OffsetForTopToBottomField int32
+	OffsetForRefFrame         []int32
+	MaxNumRefFrames           uint32
+	GapsInFrameNumAllowed     bool
+	PicWidthInMbs             uint32
+	PicHeightInMapUnits       uint32
+	FrameMbsOnly              bool
+	MbAdaptiveFrameField      bool
+	Direct8x8Inference        bool
+	FrameCropping             bool
+	CropLeft                  uint32
+	CropRight                 uint32
+	CropTop                   uint32
+	CropBottom                uint32
+	VUI                       *VUI
+}
+
+// VUI is the video usability information of an SPS. Fields that are not present have
+// their inferred values.
+//
+// AspectRatioIDC uint8					aspect_ratio_idc, 0 unspecified, 255 SARWidth:SARHeight
+// SARWidth uint16						Sample aspect ratio width
+// SARHeight uint16						Sample aspect ratio height
+// OverscanInfoPresent bool				Whether OverscanAppropriate is present
+// OverscanAppropriate bool				Whether the video may be overscanned
+// VideoFormat uint8					video_format, 5 unspecified
+// VideoFullRange bool					Whether the samples use the full range
+// ColourPrimaries uint8				colour_primaries, 2 unspecified
+// TransferCharacteristics uint8		transfer_characteristics, 2 unspecified
+// MatrixCoefficients uint8				matrix_coefficients, 2 unspecified
+// ChromaSampleLocTypeTop uint32		chroma_sample_loc_type_top_field
+// ChromaSampleLocTypeBottom uint32		chroma_sample_loc_type_bottom_field
+// TimingInfoPresent bool				Whether NumUnitsInTick and TimeScale are present
+// NumUnitsInTick uint32				num_units_in_tick
+// TimeScale uint32						time_scale, in Hz
+// FixedFrameRate bool					fixed_frame_rate_flag
+// NALHRD *HRD							HRD parameters of the NAL units, nil if not present
+// VCLHRD *HRD							HRD parameters of the VCL NAL units, nil if not present
+// LowDelayHRD bool						low_delay_hrd_flag
+// PicStructPresent bool				Whether picture timing SEI messages have pic_struct
+// BitstreamRestriction bool			Whether the fields below are present
+// MotionVectorsOverPicBoundaries bool	motion_vectors_over_pic_boundaries_flag
+// MaxBytesPerPicDenom uint32			max_bytes_per_pic_denom
+// MaxBitsPerMbDenom uint32				max_bits_per_mb_denom
+// Log2MaxMvLengthHorizontal uint32		log2_max_mv_length_horizontal
+// Log2MaxMvLengthVertical uint32		log2_max_mv_length_vertical
+// MaxNumReorderFrames uint32			max_num_reorder_frames
+// MaxDecFrameBuffering uint32			max_dec_frame_buffering
+type VUI struct {
+	AspectRatioIDC                 uint8
+	SARWidth                       uint16
+	SARHeight                      uint16
+	OverscanInfoPresent            bool
+	OverscanAppropriate            bool
+	VideoFormat                    uint8
+	VideoFullRange                 bool
+	ColourPrimaries                uint8
+	TransferCharacteristics        uint8
+	MatrixCoefficients             uint8
+	ChromaSampleLocTypeTop         uint32
+	ChromaSampleLocTypeBottom      uint32
+	TimingInfoPresent              bool
+	NumUnitsInTick                 uint32
+	TimeScale                      uint32
+	FixedFrameRate                 bool
+	NALHRD                         *HRD
+	VCLHRD                         *HRD
+	LowDelayHRD                    bool
+	PicStructPresent               bool
+	BitstreamRestriction           bool
+	MotionVectorsOverPicBoundaries bool
+	MaxBytesPerPicDenom            uint32
+	MaxBitsPerMbDenom              uint32
+	Log2MaxMvLengthHorizontal      uint32
+	Log2MaxMvLengthVertical        uint32
+	MaxNumReorderFrames            uint32
+	MaxDecFrameBuffering           uint32
+}
+
+// HRD is a set of hypothetical reference decoder parameters.
+//
+// BitRateScale uint8						bit_rate_scale
+// CPBSizeScale uint8						cpb_size_scale
+// CPB []CPB								The coded picture buffer specifications
+// InitialCPBRemovalDelayLength uint8		Length of initial_cpb_removal_delay in bits
+// CPBRemovalDelayLength uint8				Length of cpb_removal_delay in bits
+// DPBOutputDelayLength uint8				Length of dpb_output_delay in bits
+// TimeOffsetLength uint8					Length of time_offset in bits
+type HRD struct {
+	BitRateScale                 uint8
+	CPBSizeScale                 uint8
+	CPB                          []CPB
+	InitialCPBRemovalDelayLength uint8
+	CPBRemovalDelayLength        uint8
+	DPBOutputDelayLength         uint8
+	TimeOffsetLength             uint8
+}
+
+// CPB is the specification of one coded picture buffer of an HRD.
+//
+// BitRateValueMinus1 uint32	bit_rate_value_minus1
+// CPBSizeValueMinus1 uint32	cpb_size_value_minus1
+// CBR bool						Whether the buffer is constant bitrate
+type CPB struct {
+	BitRateValueMinus1 uint32
+	CPBSizeValueMinus1 uint32
+	CBR                bool
+}
+
+// sampleAspectRatios are the sample aspect ratios of aspect_ratio_idc 1 to 16.
+var sampleAspectRatios = [17][2]uint16{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// The default scaling lists of Table 7-3 and 7-4 in zig-zag order, and the flat list.
+var (
+	default4x4Intra = [16]uint8{6, 13, 13, 20, 20, 20, 28, 28, 28, 28, 32, 32, 32, 37, 37, 42}
+	default4x4Inter = [16]uint8{10, 14, 14, 20, 20, 20, 24, 24, 24, 24, 27, 27, 27, 30, 30, 34}
+	default8x8Intra = [64]uint8{
+		6, 10, 10, 13, 11, 13, 16, 16, 16, 16, 18, 18, 18, 18, 18, 23,
+		23, 23, 23, 23, 23, 25, 25, 25, 25, 25, 25, 25, 27, 27, 27, 27,
+		27, 27, 27, 27, 29, 29, 29, 29, 29, 29, 29, 31, 31, 31, 31, 31,
+		31, 33, 33, 33, 33, 33, 36, 36, 36, 36, 38, 38, 38, 40, 40, 42,
+	}
+	default8x8Inter = [64]uint8{
+		9, 13, 13, 15, 13, 15, 17, 17, 17, 17, 19, 19, 19, 19, 19, 21,
+		21, 21, 21, 21, 21, 22, 22, 22, 22, 22, 22, 22, 24, 24, 24, 24,
+		24, 24, 24, 24, 25, 25, 25, 25, 25, 25, 25, 27, 27, 27, 27, 27,
+		27, 28, 28, 28, 28, 28, 30, 30, 30, 30, 32, 32, 32, 33, 33, 35,
+	}
+)
+
+// highProfiles are the profile_idc values whose SPS has the chroma format, bit depths and scaling lists.
+var highProfiles = map[uint8]bool{100: true, 110: true, 122: true, 244: true, 44: true, 83: true, 86: true, 118: true, 128: true, 138: true, 139: true, 134: true, 135: true}
+
+// ParseSPS is a function that parses a sequence parameter set NAL unit.
+//
+// Returns ErrNALType if nal is not an SPS, ErrCorrupt if a syntax element is out of range.
+func ParseSPS(nal []byte) (*SPS, error) {
+	r, _, err := newRBSP(nal, NALSPS)
+	if err != nil {
+		return nil, err
+	}
+	sps := &SPS{
+		ProfileIDC:      uint8(r.u(8)),
+		ConstraintFlags: uint8(r.u(8) >> 2),
+		LevelIDC:        uint8(r.u(8)),
+		ID:              r.ueMax(31),
+		ChromaFormatIDC: 1,
+		BitDepthLuma:    8,
+		BitDepthChroma:  8,
+	}
+	// Without scaling lists every list is flat
+	for i := range sps.ScalingLists4x4 {
+		sps.ScalingLists4x4[i] = flat4x4()
+	}
+	for i := range sps.ScalingLists8x8 {
+		sps.ScalingLists8x8[i] = flat8x8()
+	}
+	if highProfiles[sps.ProfileIDC] {
+		sps.ChromaFormatIDC = r.ueMax(3)
+		if sps.ChromaFormatIDC == 3 {
+			sps.SeparateColourPlane = r.flag()
+		}
+		sps.BitDepthLuma = r.ueMax(6) + 8
+		sps.BitDepthChroma = r.ueMax(6) + 8
+		sps.TransformBypass = r.flag()
+		sps.ScalingMatrixPresent = r.flag()
+		if sps.ScalingMatrixPresent {
+			count := 8
+			if sps.ChromaFormatIDC == 3 {
+				count = 12
+			}
+			// Fall-back rule A, missing lists fall back to the defaults and the previous list
+			r.scalingLists(&sps.ScalingLists4x4, &sps.ScalingLists8x8, count, [2]*[16]uint8{&default4x4Intra, &default4x4Inter}, [2]*[64]uint8{&default8x8Intra, &default8x8Inter})
+		}
+	}
+	sps.Log2MaxFrameNum = r.ueMax(12) + 4
+	sps.PicOrderCntType = r.ueMax(2)
+	switch sps.PicOrderCntType {
+	case 0:
+		sps.Log2MaxPicOrderCntLsb = r.ueMax(12) + 4
+	case 1:
+		sps.DeltaPicOrderAlwaysZero = r.flag()
+		sps.OffsetForNonRefPic = r.se()
+		sps.OffsetForTopToBottomField = r.se()
+		cycle := r.ueMax(255)
+		if r.err == nil {
+			sps.OffsetForRefFrame = make([]int32, cycle)
+			for i := range sps.OffsetForRefFrame {
+				sps.OffsetForRefFrame[i] = r.se()
+			}
+		}
+	}
+	sps.MaxNumRefFrames = r.ue()
+	sps.GapsInFrameNumAllowed = r.flag()
+	sps.PicWidthInMbs = r.ueMax(1<<16) + 1
+	sps.PicHeightInMapUnits = r.ueMax(1<<16) + 1
+	sps.FrameMbsOnly = r.flag()
+	if !sps.FrameMbsOnly {
+		sps.MbAdaptiveFrameField = r.flag()
+	}
+	sps.Direct8x8Inference = r.flag()
+	sps.FrameCropping = r.flag()
+	if sps.FrameCropping {
+		sps.CropLeft = r.ue()
+		sps.CropRight = r.ue()
+		sps.CropTop = r.ue()
+		sps.CropBottom = r.ue()
+		unitX, unitY := sps.cropUnits()
+		if r.err == nil && ((uint64(sps.CropLeft)+uint64(sps.CropRight))*uint64(unitX) >= uint64(sps.PicWidthInMbs)*16 ||
+			(uint64(sps.CropTop)+uint64(sps.CropBottom))*uint64(unitY) >= uint64(sps.FrameHeightInMbs())*16) {
+			r.err = ErrCorrupt
+		}
+	}
+	if r.flag() {
+		sps.VUI = r.vui()
+	}
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return sps, nil
+}
+
+// vui is a private function that reads vui_parameters().
+func (r *rbsp) vui() *VUI {
+	vui := &VUI{VideoFormat: 5, ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2, MotionVectorsOverPicBoundaries: true, MaxBytesPerPicDenom: 2, MaxBitsPerMbDenom: 1, Log2MaxMvLengthHorizontal: 16, Log2MaxMvLengthVertical: 16}
+	if r.flag() {
+		vui.AspectRatioIDC = uint8(r.u(8))
+		if vui.AspectRatioIDC == 255 {
+			vui.SARWidth = uint16(r.u(16))
+			vui.SARHeight = uint16(r.u(16))
+		} else if int(vui.AspectRatioIDC) < len(sampleAspectRatios) {
+			vui.SARWidth = sampleAspectRatios[vui.AspectRatioIDC][0]
+			vui.SARHeight = sampleAspectRatios[vui.AspectRatioIDC][1]
+		}
+	}
+	vui.OverscanInfoPresent = r.flag()
+	if vui.OverscanInfoPresent {
+		vui.OverscanAppropriate = r.flag()
+	}
+	if r.flag() {
+		vui.VideoFormat = uint8(r.u(3))
+		vui.VideoFullRange = r.flag()
+		if r.flag() {
+			vui.ColourPrimaries = uint8(r.u(8))
+			vui.TransferCharacteristics = uint8(r.u(8))
+			vui.MatrixCoefficients = uint8(r.u(8))
+		}
+	}
+	if r.flag() {
+		vui.ChromaSampleLocTypeTop = r.ueMax(5)
+		vui.ChromaSampleLocTypeBottom = r.ueMax(5)
+	}
+	vui.TimingInfoPresent = r.flag()
+	if vui.TimingInfoPresent {
+		vui.NumUnitsInTick = r.u(32)
+		vui.TimeScale = r.u(32)
+		vui.FixedFrameRate = r.flag()
+	}
+	if r.flag() {
+		vui.NALHRD = r.hrd()
+	}
+	if r.flag() {
+		vui.VCLHRD = r.hrd()
+	}
+	if vui.NALHRD != nil || vui.VCLHRD != nil {
+		vui.LowDelayHRD = r.flag()
+	}
+	vui.PicStructPresent = r.flag()
+	vui.BitstreamRestriction = r.flag()
+	if vui.BitstreamRestriction {
+		vui.MotionVectorsOverPicBoundaries = r.flag()
+		vui.MaxBytesPerPicDenom = r.ueMax(16)
+		vui.MaxBitsPerMbDenom = r.ueMax(16)
+		vui.Log2MaxMvLengthHorizontal = r.ueMax(16)
+		vui.Log2MaxMvLengthVertical = r.ueMax(16)
+		vui.MaxNumReorderFrames = r.ue()
+		vui.MaxDecFrameBuffering = r.ue()
+	}
+	return vui
+}
+
+// hrd is a private function that reads hrd_parameters().
+func (r *rbsp) hrd() *HRD {
+	count := r.ueMax(31) + 1
+	hrd := &HRD{BitRateScale: uint8(r.u(4)), CPBSizeScale: uint8(r.u(4))}
+	if r.err != nil {
+		return hrd
+	}
+	hrd.CPB = make([]CPB, count)
+	for i := range hrd.CPB {
+		hrd.CPB[i].BitRateValueMinus1 = r.ue()
+		hrd.CPB[i].CPBSizeValueMinus1 = r.ue()
+		hrd.CPB[i].CBR = r.flag()
+	}
+	hrd.InitialCPBRemovalDelayLength = uint8(r.u(5)) + 1
+	hrd.CPBRemovalDelayLength = uint8(r.u(5)) + 1
+	hrd.DPBOutputDelayLength = uint8(r.u(5)) + 1
+	hrd.TimeOffsetLength = uint8(r.u(5))
+	return hrd
+}
+
+// scalingLists is a private function that reads the present flags and scaling_list() of an
+// SPS or PPS. A list that isn't present falls back to fallback for the first list of each
+// kind, and to the list before it of the same kind for the rest.
+func (r *rbsp) scalingLists(lists4x4 *[6][16]uint8, lists8x8 *[6][64]uint8, count int, fallback4x4 [2]*[16]uint8, fallback8x8 [2]*[64]uint8) {
+	for i := 0; i < count && r.err == nil; i++ {
+		present := r.flag()
+		if i < 6 {
+			switch {
+			case present && r.scalingList(lists4x4[i][:]):
+				lists4x4[i] = [2][16]uint8{default4x4Intra, default4x4Inter}[i/3]
+			case present:
+			case i == 0 || i == 3:
+				lists4x4[i] = *fallback4x4[i/3]
+			default:
+				lists4x4[i] = lists4x4[i-1]
+			}
+			continue
+		}
+		j := i - 6
+		switch {
+		case present && r.scalingList(lists8x8[j][:]):
+			lists8x8[j] = [2][64]uint8{default8x8Intra, default8x8Inter}[j%2]
+		case present:
+		case j < 2:
+			lists8x8[j] = *fallback8x8[j]
+		default:
+			lists8x8[j] = lists8x8[j-2]
+		}
+	}
+}
+
+// scalingList is a private function that reads scaling_list() into list, and returns
+// useDefaultScalingMatrixFlag.
+func (r *rbsp) scalingList(list []uint8) bool {
+	last, next := int32(8), int32(8)
+	for j := range list {
+		if next != 0 {
+			delta := r.seRange(-128, 127)
+			next = (last + delta + 256) % 256
+			if j == 0 && next == 0 {
+				return true
+			}
+		}
+		if next != 0 {
+			last = next
+		}
+		list[j] = uint8(last)
+	}
+	return false
+}
+
+// flat4x4 is a private function that returns the flat 4x4 scaling list.
+func flat4x4() (list [16]uint8) {
+	for i := range list {
+		list[i] = 16
+	}
+	return list
+}
+
+// flat8x8 is a private function that returns the flat 8x8 scaling list.
+func flat8x8() (list [64]uint8) {
+	for i := range list {
+		list[i] = 16
+	}
+	return list
+}
+
+// ChromaArrayType is a function that returns ChromaArrayType, the chroma format or 0 if the
+// colour planes are coded separately.
+func (sps *SPS) ChromaArrayType() uint32 {
+	if sps.SeparateColourPlane {
+		return 0
+	}
+	return sps.ChromaFormatIDC
+}
+
+// FrameHeightInMbs is a function that returns the height of a frame in macroblocks.
+func (sps *SPS) FrameHeightInMbs() uint32 {
+	if sps.FrameMbsOnly {
+		return sps.PicHeightInMapUnits
+	}
+	return sps.PicHeightInMapUnits * 2
+}
+
+// cropUnits is a private function that returns the horizontal and vertical crop units in samples.
+func (sps *SPS) cropUnits() (uint32, uint32) {
+	fields := uint32(1)
+	if !sps.FrameMbsOnly {
+		fields = 2
+	}
+	switch sps.ChromaArrayType() {
+	case 1:
+		return 2, 2 * fields
+	case 2:
+		return 2, fields
+	}
+	return 1, fields
+}
+
+// Width is a function that returns the width of the cropped frame in luma samples.
+func (sps *SPS) Width() int {
+	unitX, _ := sps.cropUnits()
+	return int(sps.PicWidthInMbs*16 - (sps.CropLeft+sps.CropRight)*unitX)
+}
+
+// Height is a function that returns the height of the cropped frame in luma samples.
+func (sps *SPS) Height() int {
+	_, unitY := sps.cropUnits()
+	return int(sps.FrameHeightInMbs()*16 - (sps.CropTop+sps.CropBottom)*unitY)
+}
+
+// FrameRate is a function that returns the frame rate in frames per second from the VUI
+// timing information, where a frame is two ticks. The second value is false if the SPS
+// has no timing information.
+func (sps *SPS) FrameRate() (float64, bool) {
+	if sps.VUI == nil || !sps.VUI.TimingInfoPresent || sps.VUI.NumUnitsInTick == 0 || sps.VUI.TimeScale == 0 {
+		return 0, false
+	}
+	return float64(sps.VUI.TimeScale) / float64(2*uint64(sps.VUI.NumUnitsInTick)), true
+}
+
+// BitRate is a function that returns the bitrate of the i-th coded picture buffer in bits per second.
+func (hrd *HRD) BitRate(i int) uint64 {
+	return (uint64(hrd.CPB[i].BitRateValueMinus1) + 1) << (6 + hrd.BitRateScale)
+}
+
+// CPBSize is a function that returns the size of the i-th coded picture buffer in bits.
+func (hrd *HRD) CPBSize(i int) uint64 {
+	return (uint64(hrd.CPB[i].CPBSizeValueMinus1) + 1) << (4 + hrd.CPBSizeScale)
+}
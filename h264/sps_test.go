@@ -0,0 +1,358 @@
+package h264
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+// x264SPS is an SPS in the form x264 writes for 1280x720 High profile at 30 frames per second,
+// with an emulation prevention byte in its VUI
+var x264SPS = []byte{
+	0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9, 0x40, 0x50, 0x05, 0xbb, 0x01, 0x10, 0x00, 0x00,
+	0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x03, 0xc0, 0xf1, 0x83, 0x19, 0x60,
+}
+
+// The SPSs of cameras and encoders below are from the tests of github.com/bluenviron/mediacommon
+// v1.9.2, pkg/codecs/h264/sps_test.go (MIT), see testdata/README.md
+var (
+	// scalingMatrixSPS has 4x4 scaling lists and falls back to the default 8x8 ones
+	scalingMatrixSPS = []byte{
+		103, 100, 0, 50, 173, 132, 1, 12, 32, 8, 97, 0, 67, 8, 2, 24, 64, 16, 194, 0, 132,
+		59, 80, 20, 0, 90, 211, 112, 16, 16, 20, 0, 0, 3, 0, 4, 0, 0, 3, 0, 162, 16,
+	}
+	// hikvisionSPS is a Main profile SPS with NAL and VCL HRD parameters
+	hikvisionSPS = []byte{
+		103, 77, 0, 41, 154, 100, 3, 192, 17, 63, 46, 2, 220, 4, 4, 5, 0, 0, 3, 3, 232, 0, 0,
+		195, 80, 232, 96, 0, 186, 180, 0, 2, 234, 196, 187, 203, 141, 12, 0, 23, 86, 128, 0,
+		93, 88, 151, 121, 112, 160,
+	}
+	// nvencSPS is a High profile SPS with constant bit rate NAL HRD parameters
+	nvencSPS = []byte{
+		103, 100, 0, 42, 172, 44, 172, 7, 128, 34, 126, 92, 5, 168, 8, 8, 10, 0, 0, 7, 208, 0,
+		3, 169, 129, 192, 0, 0, 76, 75, 0, 0, 38, 37, 173, 222, 92, 20,
+	}
+)
+
+// spsHead writes the SPS up to the frame size, for a profile without the chroma format
+func spsHead(w *bitWriter, profile uint8, id uint32) *bitWriter {
+	return w.u(8, uint64(profile)).u(8, 0xc0).u(8, 30).ue(id)
+}
+
+// spsTail writes the SPS from log2_max_frame_num_minus4 with pic_order_cnt_type 2
+func spsTail(w *bitWriter, widthMbs, heightMapUnits uint32, frameMbsOnly bool, crop []uint32) *bitWriter {
+	w.ue(0).ue(2).ue(1).flag(false).ue(widthMbs - 1).ue(heightMapUnits - 1).flag(frameMbsOnly)
+	if !frameMbsOnly {
+		w.flag(true)
+	}
+	w.flag(true).flag(crop != nil)
+	for _, offset := range crop {
+		w.ue(offset)
+	}
+	return w
+}
+
+func TestParseSPSx264(t *testing.T) {
+	sps, err := ParseSPS(x264SPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sps.ProfileIDC != 100 || sps.LevelIDC != 31 || sps.ChromaFormatIDC != 1 || sps.BitDepthLuma != 8 {
+		t.Errorf("ParseSPS() profile %d, level %d, chroma %d, depth %d", sps.ProfileIDC, sps.LevelIDC, sps.ChromaFormatIDC, sps.BitDepthLuma)
+	}
+	if sps.Log2MaxFrameNum != 4 || sps.PicOrderCntType != 0 || sps.Log2MaxPicOrderCntLsb != 6 || sps.MaxNumRefFrames != 4 {
+		t.Errorf("ParseSPS() frame num %d, poc type %d, poc lsb %d, refs %d", sps.Log2MaxFrameNum, sps.PicOrderCntType, sps.Log2MaxPicOrderCntLsb, sps.MaxNumRefFrames)
+	}
+	if sps.Width() != 1280 || sps.Height() != 720 {
+		t.Errorf("ParseSPS() size = %dx%d, want 1280x720", sps.Width(), sps.Height())
+	}
+	if rate, ok := sps.FrameRate(); !ok || rate != 30 {
+		t.Errorf("FrameRate() = %v, %v, want 30", rate, ok)
+	}
+	vui := sps.VUI
+	if vui.SARWidth != 1 || vui.SARHeight != 1 || !vui.BitstreamRestriction || vui.MaxNumReorderFrames != 2 || vui.MaxDecFrameBuffering != 4 {
+		t.Errorf("ParseSPS() VUI = %+v", *vui)
+	}
+	if sps.ScalingLists4x4[0] != flat4x4() || sps.ScalingLists8x8[1] != flat8x8() {
+		t.Error("ParseSPS() scaling lists are not flat without a scaling matrix")
+	}
+}
+
+func TestParseSPSCaptures(t *testing.T) {
+	x264 := captured(t)[0]
+	hikvisionHRD := &HRD{
+		BitRateScale: 4, CPBSizeScale: 3, CPB: []CPB{{BitRateValueMinus1: 11948, CPBSizeValueMinus1: 95585}},
+		InitialCPBRemovalDelayLength: 24, CPBRemovalDelayLength: 16, DPBOutputDelayLength: 6, TimeOffsetLength: 24,
+	}
+	tests := []struct {
+		name          string
+		nal           []byte
+		profile       uint8
+		level         uint8
+		width, height int
+		rate          float64
+		vui           VUI
+	}{
+		{"x264", x264, 100, 12, 320, 180, 10, VUI{
+			AspectRatioIDC: 1, SARWidth: 1, SARHeight: 1, VideoFormat: 5, VideoFullRange: true,
+			ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2,
+			TimingInfoPresent: true, NumUnitsInTick: 1, TimeScale: 20,
+			BitstreamRestriction: true, MotionVectorsOverPicBoundaries: true,
+			Log2MaxMvLengthHorizontal: 9, Log2MaxMvLengthVertical: 9, MaxNumReorderFrames: 2, MaxDecFrameBuffering: 4,
+		}},
+		{"scaling matrix", scalingMatrixSPS, 100, 50, 2560, 1440, 20, VUI{
+			VideoFormat: 5, VideoFullRange: true, ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1,
+			TimingInfoPresent: true, NumUnitsInTick: 1, TimeScale: 40, FixedFrameRate: true,
+			MotionVectorsOverPicBoundaries: true, MaxBytesPerPicDenom: 2, MaxBitsPerMbDenom: 1,
+			Log2MaxMvLengthHorizontal: 16, Log2MaxMvLengthVertical: 16,
+		}},
+		{"hikvision NAL and VCL HRD", hikvisionSPS, 77, 41, 1920, 1080, 25, VUI{
+			AspectRatioIDC: 1, SARWidth: 1, SARHeight: 1, VideoFormat: 5, VideoFullRange: true,
+			ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1,
+			TimingInfoPresent: true, NumUnitsInTick: 1000, TimeScale: 50000, FixedFrameRate: true,
+			NALHRD: hikvisionHRD, VCLHRD: hikvisionHRD, PicStructPresent: true,
+			MotionVectorsOverPicBoundaries: true, MaxBytesPerPicDenom: 2, MaxBitsPerMbDenom: 1,
+			Log2MaxMvLengthHorizontal: 16, Log2MaxMvLengthVertical: 16,
+		}},
+		{"nvenc NAL HRD", nvencSPS, 100, 42, 1920, 1080, 60, VUI{
+			AspectRatioIDC: 1, SARWidth: 1, SARHeight: 1, VideoFormat: 5,
+			ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1,
+			TimingInfoPresent: true, NumUnitsInTick: 1000, TimeScale: 120000, FixedFrameRate: true,
+			NALHRD: &HRD{
+				CPB:                          []CPB{{BitRateValueMinus1: 39061, CPBSizeValueMinus1: 156249, CBR: true}},
+				InitialCPBRemovalDelayLength: 24, CPBRemovalDelayLength: 16, DPBOutputDelayLength: 6, TimeOffsetLength: 24,
+			},
+			PicStructPresent: true, MotionVectorsOverPicBoundaries: true, MaxBytesPerPicDenom: 2, MaxBitsPerMbDenom: 1,
+			Log2MaxMvLengthHorizontal: 16, Log2MaxMvLengthVertical: 16,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sps, err := ParseSPS(tt.nal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sps.ProfileIDC != tt.profile || sps.LevelIDC != tt.level || sps.ChromaFormatIDC != 1 || sps.BitDepthLuma != 8 {
+				t.Errorf("ParseSPS() profile %d, level %d, chroma %d, depth %d", sps.ProfileIDC, sps.LevelIDC, sps.ChromaFormatIDC, sps.BitDepthLuma)
+			}
+			if sps.Width() != tt.width || sps.Height() != tt.height {
+				t.Errorf("ParseSPS() size = %dx%d, want %dx%d", sps.Width(), sps.Height(), tt.width, tt.height)
+			}
+			if rate, ok := sps.FrameRate(); !ok || rate != tt.rate {
+				t.Errorf("FrameRate() = %v, %v, want %v", rate, ok, tt.rate)
+			}
+			if !reflect.DeepEqual(*sps.VUI, tt.vui) {
+				t.Errorf("ParseSPS() VUI = %+v, want %+v", *sps.VUI, tt.vui)
+			}
+		})
+	}
+	sps, err := ParseSPS(x264)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sps.Log2MaxFrameNum != 4 || sps.PicOrderCntType != 0 || sps.Log2MaxPicOrderCntLsb != 6 || sps.MaxNumRefFrames != 4 || sps.CropBottom != 6 {
+		t.Errorf("ParseSPS() frame num %d, poc type %d, poc lsb %d, refs %d, crop %d", sps.Log2MaxFrameNum, sps.PicOrderCntType, sps.Log2MaxPicOrderCntLsb, sps.MaxNumRefFrames, sps.CropBottom)
+	}
+	sps, err = ParseSPS(scalingMatrixSPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sps.ScalingMatrixPresent || sps.PicOrderCntType != 2 || !sps.GapsInFrameNumAllowed || sps.Log2MaxFrameNum != 10 {
+		t.Errorf("ParseSPS() scaling matrix %v, poc type %d, gaps %v, frame num %d", sps.ScalingMatrixPresent, sps.PicOrderCntType, sps.GapsInFrameNumAllowed, sps.Log2MaxFrameNum)
+	}
+	if sps.ScalingLists4x4 != [6][16]uint8{flat4x4(), flat4x4(), flat4x4(), flat4x4(), flat4x4(), flat4x4()} {
+		t.Errorf("ParseSPS() 4x4 scaling lists = %v, want flat", sps.ScalingLists4x4)
+	}
+	// The 8x8 lists are not sent, so fall-back rule A gives the defaults
+	if sps.ScalingLists8x8[0] != default8x8Intra || sps.ScalingLists8x8[1] != default8x8Inter {
+		t.Errorf("ParseSPS() 8x8 scaling lists = %v, want the defaults", sps.ScalingLists8x8[:2])
+	}
+	if hrd := sps.VUI.NALHRD; hrd != nil {
+		t.Errorf("ParseSPS() NAL HRD = %+v, want nil", *hrd)
+	}
+	sps, err = ParseSPS(hikvisionSPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sps.VUI.NALHRD.BitRate(0) != 12235776 || sps.VUI.NALHRD.CPBSize(0) != 12235008 {
+		t.Errorf("HRD bit rate %d, CPB size %d, want 12235776, 12235008", sps.VUI.NALHRD.BitRate(0), sps.VUI.NALHRD.CPBSize(0))
+	}
+}
+
+func TestParseSPSFrameSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		write         func(w *bitWriter)
+		width, height int
+	}{
+		{
+			"1080p crop",
+			func(w *bitWriter) { spsTail(spsHead(w, 77, 0), 120, 68, true, []uint32{0, 0, 0, 4}) },
+			1920, 1080,
+		},
+		{
+			"interlaced 1080i crop",
+			func(w *bitWriter) { spsTail(spsHead(w, 77, 0), 120, 34, false, []uint32{0, 0, 0, 2}) },
+			1920, 1080,
+		},
+		{
+			"4:2:2 crop",
+			func(w *bitWriter) {
+				spsTail(spsHead(w, 122, 1).ue(2).ue(2).ue(2).flag(false).flag(false), 45, 36, true, []uint32{4, 4, 8, 8})
+			},
+			704, 560,
+		},
+		{
+			"monochrome crop",
+			func(w *bitWriter) {
+				spsTail(spsHead(w, 100, 2).ue(0).ue(0).ue(0).flag(false).flag(false), 10, 10, false, []uint32{1, 2, 3, 4})
+			},
+			157, 306,
+		},
+		{
+			"4:4:4 separate planes",
+			func(w *bitWriter) {
+				spsTail(spsHead(w, 244, 3).ue(3).flag(true).ue(2).ue(2).flag(true).flag(false), 4, 3, true, []uint32{1, 0, 0, 1})
+			},
+			63, 47,
+		},
+	}
+	for _, test := range tests {
+		w := &bitWriter{}
+		test.write(w)
+		sps, err := ParseSPS(w.flag(false).nal(3, NALSPS))
+		if err != nil {
+			t.Errorf("%s: ParseSPS() error = %v", test.name, err)
+			continue
+		}
+		if sps.Width() != test.width || sps.Height() != test.height {
+			t.Errorf("%s: size = %dx%d, want %dx%d", test.name, sps.Width(), sps.Height(), test.width, test.height)
+		}
+		if _, ok := sps.FrameRate(); ok {
+			t.Errorf("%s: FrameRate() ok without a VUI", test.name)
+		}
+	}
+}
+
+func TestParseSPSPicOrderCntType1(t *testing.T) {
+	w := spsHead(&bitWriter{}, 66, 31)
+	w.ue(12).ue(1).flag(true).se(-2).se(1).ue(3).se(2).se(-4).se(6)
+	w.ue(16).flag(true).ue(21).ue(17).flag(true).flag(false).flag(false).flag(false)
+	sps, err := ParseSPS(w.nal(3, NALSPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sps.ID != 31 || sps.ConstraintFlags != 0x30 || sps.Log2MaxFrameNum != 16 || !sps.DeltaPicOrderAlwaysZero {
+		t.Errorf("ParseSPS() = %+v", *sps)
+	}
+	if sps.OffsetForNonRefPic != -2 || sps.OffsetForTopToBottomField != 1 || len(sps.OffsetForRefFrame) != 3 || sps.OffsetForRefFrame[1] != -4 {
+		t.Errorf("ParseSPS() offsets %d %d %v", sps.OffsetForNonRefPic, sps.OffsetForTopToBottomField, sps.OffsetForRefFrame)
+	}
+	if sps.MaxNumRefFrames != 16 || !sps.GapsInFrameNumAllowed || sps.Width() != 352 || sps.Height() != 288 {
+		t.Errorf("ParseSPS() refs %d, gaps %v, size %dx%d", sps.MaxNumRefFrames, sps.GapsInFrameNumAllowed, sps.Width(), sps.Height())
+	}
+}
+
+func TestParseSPSVUI(t *testing.T) {
+	w := spsTail(spsHead(&bitWriter{}, 77, 0), 45, 36, true, nil).flag(true)
+	// Extended SAR, overscan, video signal with colour description, chroma location
+	w.flag(true).u(8, 255).u(16, 64).u(16, 45)
+	w.flag(true).flag(true)
+	w.flag(true).u(3, 2).flag(true).flag(true).u(8, 1).u(8, 1).u(8, 1)
+	w.flag(true).ue(1).ue(2)
+	// 25 fps fixed, NAL HRD with two CPBs, no VCL HRD
+	w.flag(true).u(32, 1).u(32, 50).flag(true)
+	w.flag(true).ue(1).u(4, 2).u(4, 3).ue(1562).ue(6249).flag(false).ue(3124).ue(12499).flag(true).u(5, 23).u(5, 15).u(5, 4).u(5, 24)
+	w.flag(false).flag(true).flag(true)
+	w.flag(true).flag(false).ue(2).ue(1).ue(16).ue(12).ue(0).ue(1)
+	sps, err := ParseSPS(w.nal(3, NALSPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vui := sps.VUI
+	if vui.AspectRatioIDC != 255 || vui.SARWidth != 64 || vui.SARHeight != 45 || !vui.OverscanAppropriate {
+		t.Errorf("VUI aspect ratio and overscan = %+v", *vui)
+	}
+	if vui.VideoFormat != 2 || !vui.VideoFullRange || vui.ColourPrimaries != 1 || vui.MatrixCoefficients != 1 || vui.ChromaSampleLocTypeBottom != 2 {
+		t.Errorf("VUI video signal = %+v", *vui)
+	}
+	if rate, ok := sps.FrameRate(); !ok || rate != 25 || !vui.FixedFrameRate {
+		t.Errorf("FrameRate() = %v, %v, want 25", rate, ok)
+	}
+	if vui.VCLHRD != nil || !vui.LowDelayHRD || !vui.PicStructPresent {
+		t.Errorf("VUI HRD flags = %+v", *vui)
+	}
+	hrd := vui.NALHRD
+	if hrd == nil || len(hrd.CPB) != 2 || !hrd.CPB[1].CBR || hrd.CPB[0].CBR {
+		t.Fatalf("VUI NAL HRD = %+v", hrd)
+	}
+	if hrd.BitRate(0) != 1563<<8 || hrd.CPBSize(1) != 12500<<7 {
+		t.Errorf("BitRate(0) = %d, CPBSize(1) = %d", hrd.BitRate(0), hrd.CPBSize(1))
+	}
+	if hrd.InitialCPBRemovalDelayLength != 24 || hrd.CPBRemovalDelayLength != 16 || hrd.DPBOutputDelayLength != 5 || hrd.TimeOffsetLength != 24 {
+		t.Errorf("HRD lengths = %+v", *hrd)
+	}
+	if vui.MotionVectorsOverPicBoundaries || vui.MaxBytesPerPicDenom != 2 || vui.Log2MaxMvLengthHorizontal != 16 || vui.Log2MaxMvLengthVertical != 12 || vui.MaxDecFrameBuffering != 1 {
+		t.Errorf("VUI bitstream restriction = %+v", *vui)
+	}
+}
+
+func TestParseSPSScalingLists(t *testing.T) {
+	w := spsHead(&bitWriter{}, 100, 0).ue(1).ue(0).ue(0).flag(false).flag(true)
+	// List 0 explicit, 1 not present, 2 useDefaultScalingMatrixFlag, 3 to 5 not present,
+	// list 6 explicit, 7 not present
+	w.flag(true).se(-4)
+	for i := 1; i < 16; i++ {
+		w.se(1)
+	}
+	w.flag(false).flag(true).se(-8).flag(false).flag(false).flag(false)
+	w.flag(true).se(4).se(-12)
+	w.flag(false)
+	spsTail(w, 20, 15, true, nil).flag(false)
+	sps, err := ParseSPS(w.nal(3, NALSPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list0 [16]uint8
+	for i := range list0 {
+		list0[i] = uint8(4 + i)
+	}
+	if sps.ScalingLists4x4[0] != list0 || sps.ScalingLists4x4[1] != list0 {
+		t.Errorf("ScalingLists4x4[0:2] = %v", sps.ScalingLists4x4[0:2])
+	}
+	if sps.ScalingLists4x4[2] != default4x4Intra || sps.ScalingLists4x4[3] != default4x4Inter || sps.ScalingLists4x4[5] != default4x4Inter {
+		t.Errorf("ScalingLists4x4[2:6] = %v", sps.ScalingLists4x4[2:])
+	}
+	// A delta that makes nextScale 0 repeats the last scale for the rest of the list
+	var list6 [64]uint8
+	for i := range list6 {
+		list6[i] = 12
+	}
+	if sps.ScalingLists8x8[0] != list6 || sps.ScalingLists8x8[1] != default8x8Inter {
+		t.Errorf("ScalingLists8x8[0:2] = %v", sps.ScalingLists8x8[0:2])
+	}
+}
+
+func TestParseSPSErrors(t *testing.T) {
+	valid := spsTail(spsHead(&bitWriter{}, 66, 0), 20, 15, true, nil).flag(false)
+	truncated := valid.nal(3, NALSPS)
+	tests := []struct {
+		name string
+		nal  []byte
+		err  error
+	}{
+		{"PPS", (&bitWriter{}).ue(0).nal(3, NALPPS), ErrNALType},
+		{"truncated", truncated[:4], io.ErrUnexpectedEOF},
+		{"id", spsHead(&bitWriter{}, 66, 32).nal(3, NALSPS), ErrCorrupt},
+		{"chroma format", spsHead(&bitWriter{}, 100, 0).ue(4).nal(3, NALSPS), ErrCorrupt},
+		{"poc type", spsHead(&bitWriter{}, 66, 0).ue(0).ue(3).nal(3, NALSPS), ErrCorrupt},
+		{"crop", spsTail(spsHead(&bitWriter{}, 66, 0), 2, 2, true, []uint32{8, 8, 0, 0}).flag(false).nal(3, NALSPS), ErrCorrupt},
+		// Without vui_parameters_present_flag the stop bit and the cabac_zero_words are read as an empty VUI
+		{"past the stop bit", append(spsTail(spsHead(&bitWriter{}, 66, 0), 2, 2, true, nil).nal(3, NALSPS), 0x00, 0x00), ErrCorrupt},
+	}
+	for _, test := range tests {
+		if _, err := ParseSPS(test.nal); err != test.err {
+			t.Errorf("%s: ParseSPS() error = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
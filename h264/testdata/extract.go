@@ -0,0 +1,165 @@
+//go:build ignore
+
+// Extract writes the parameter sets and samples of the first H.264 track of an MP4 file
+// as an Annex B byte stream, every NAL unit after a four byte start code.
+//
+//	go run extract.go sample.mp4 > x264.264
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// box returns the contents of the first box of the path inside data.
+func box(data []byte, path ...string) ([]byte, error) {
+	for len(path) > 0 {
+		found := false
+		for len(data) >= 8 {
+			size := uint64(binary.BigEndian.Uint32(data))
+			header := uint64(8)
+			switch size {
+			case 0:
+				size = uint64(len(data))
+			case 1:
+				if len(data) < 16 {
+					return nil, errors.New("truncated box")
+				}
+				size, header = binary.BigEndian.Uint64(data[8:]), 16
+			}
+			if size < header || size > uint64(len(data)) {
+				return nil, errors.New("invalid box size")
+			}
+			if string(data[4:8]) == path[0] {
+				data = data[header:size]
+				found = true
+				break
+			}
+			data = data[size:]
+		}
+		if !found {
+			return nil, fmt.Errorf("no %s box", path[0])
+		}
+		// The sample description and the visual sample entry have fields before their boxes
+		switch path[0] {
+		case "stsd":
+			data = data[8:]
+		case "avc1":
+			data = data[78:]
+		}
+		path = path[1:]
+	}
+	return data, nil
+}
+
+// table returns count big-endian fields of width bytes from data.
+func table(data []byte, count uint32, width int) []uint64 {
+	out := make([]uint64, count)
+	for i := range out {
+		if width == 8 {
+			out[i] = binary.BigEndian.Uint64(data[i*8:])
+		} else {
+			out[i] = uint64(binary.BigEndian.Uint32(data[i*4:]))
+		}
+	}
+	return out
+}
+
+func extract(file []byte) ([]byte, error) {
+	moov, err := box(file, "moov")
+	if err != nil {
+		return nil, err
+	}
+	stbl, err := box(moov, "trak", "mdia", "minf", "stbl")
+	if err != nil {
+		return nil, err
+	}
+	avcC, err := box(stbl, "stsd", "avc1", "avcC")
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	startCode := []byte{0, 0, 0, 1}
+	// The SPSs and then the PPSs of the decoder configuration record
+	lengthSize := int(avcC[4]&3) + 1
+	position := 5
+	for _, mask := range []byte{0x1f, 0xff} {
+		count := int(avcC[position] & mask)
+		position++
+		for i := 0; i < count; i++ {
+			length := int(binary.BigEndian.Uint16(avcC[position:]))
+			out = append(append(out, startCode...), avcC[position+2:position+2+length]...)
+			position += 2 + length
+		}
+	}
+	stsz, err := box(stbl, "stsz")
+	if err != nil {
+		return nil, err
+	}
+	stsc, err := box(stbl, "stsc")
+	if err != nil {
+		return nil, err
+	}
+	var offsets []uint64
+	if stco, err := box(stbl, "stco"); err == nil {
+		offsets = table(stco[8:], binary.BigEndian.Uint32(stco[4:]), 4)
+	} else if co64, err := box(stbl, "co64"); err == nil {
+		offsets = table(co64[8:], binary.BigEndian.Uint32(co64[4:]), 8)
+	} else {
+		return nil, err
+	}
+	count := binary.BigEndian.Uint32(stsz[8:])
+	sizes := table(stsz[12:], count, 4)
+	if fixed := binary.BigEndian.Uint32(stsz[4:]); fixed != 0 {
+		sizes = make([]uint64, count)
+		for i := range sizes {
+			sizes[i] = uint64(fixed)
+		}
+	}
+	runs := table(stsc[8:], binary.BigEndian.Uint32(stsc[4:])*3, 4)
+	sample := 0
+	for chunk, offset := range offsets {
+		perChunk := uint64(0)
+		for run := 0; run < len(runs); run += 3 {
+			if runs[run] <= uint64(chunk)+1 {
+				perChunk = runs[run+1]
+			}
+		}
+		for i := uint64(0); i < perChunk && sample < len(sizes); i++ {
+			data := file[offset : offset+sizes[sample]]
+			offset += sizes[sample]
+			sample++
+			// Each NAL unit of a sample has a length prefix instead of a start code
+			for len(data) >= lengthSize {
+				length := uint64(0)
+				for _, b := range data[:lengthSize] {
+					length = length<<8 | uint64(b)
+				}
+				data = data[lengthSize:]
+				out = append(append(out, startCode...), data[:length]...)
+				data = data[length:]
+			}
+		}
+	}
+	return out, nil
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run extract.go file.mp4 > file.264")
+		os.Exit(2)
+	}
+	file, err := os.ReadFile(os.Args[1])
+	if err == nil {
+		file, err = extract(file)
+	}
+	if err == nil {
+		_, err = os.Stdout.Write(file)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
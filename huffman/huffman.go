@@ -0,0 +1,394 @@
+// Package huffman decodes canonical Huffman codes from a bitreader.Reader.
+//
+// Codes are assigned canonically from their lengths, as described in RFC 1951
+// section 3.2.2, which is the scheme used by DEFLATE, JPEG and bzip2. Decoding
+// peeks bits from the reader and resolves a symbol with at most two table lookups.
+// Both bit orders are supported: in little-endian (LSB-first) mode the first bit
+// read is the most significant bit of the code, exactly as in DEFLATE. The lookup
+// tables of a bit order are built the first time a reader of that order decodes.
+package huffman
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+	"sort"
+	"sync"
+
+	"github.com/pektezol/bitreader"
+)
+
+// MaxCodeLength is the longest code length a Table supports.
+const MaxCodeLength = 32
+
+// rootBits is the maximum number of bits resolved by the first lookup.
+const rootBits = 9
+
+// maxEntries caps the lookup slots of one bit order, so a short list of long
+// code lengths can't force a huge allocation.
+const maxEntries = 1 << 20
+
+var (
+	// ErrOversubscribed is returned when the code lengths describe more codes than fit.
+	ErrOversubscribed = errors.New("huffman: over-subscribed code lengths")
+	// ErrInvalidCode is returned when the stream contains a code that is not in the table.
+	ErrInvalidCode = errors.New("huffman: invalid code")
+	// ErrEmpty is returned when decoding with a table that has no codes.
+	ErrEmpty = errors.New("huffman: table has no codes")
+	// ErrTooLarge is returned when the lookup tables of the code lengths would need more than maxEntries slots.
+	ErrTooLarge = errors.New("huffman: lookup table too large")
+)
+
+// entry is a lookup table slot.
+//
+// length uint8		Code length for a symbol, or 0 for an unused slot
+// link bool		Whether symbol is the index of a sub table instead of a symbol
+// symbol uint32	The decoded symbol, or the sub table index
+type entry struct {
+	length uint8
+	link   bool
+	symbol uint32
+}
+
+// subTable resolves codes longer than the root lookup.
+//
+// bits uint8		Number of bits indexed past the root bits
+// entries []entry	2^bits lookup slots
+type subTable struct {
+	bits    uint8
+	entries []entry
+}
+
+// order holds the lookup tables for one bit order.
+//
+// once sync.Once		Builds root and subs on first use
+// root []entry			2^rootBits lookup slots
+// subs []subTable		Sub tables linked from the root
+type order struct {
+	once sync.Once
+	root []entry
+	subs []subTable
+}
+
+// Table is a canonical Huffman decoding table.
+//
+// lengths []uint8		Code length of every symbol, 0 if the symbol is unused
+// codes []uint32		Canonical code of every symbol, MSB-first
+// maxLength uint8		Longest code length
+// rootBits uint8		Bits resolved by the root lookup
+// subBits map[uint32]uint8	Sub table size of every root prefix that has longer codes
+// msb, lsb order		Lookup tables for big-endian and little-endian readers
+type Table struct {
+	lengths   []uint8
+	codes     []uint32
+	count     int
+	maxLength uint8
+	rootBits  uint8
+	subBits   map[uint32]uint8
+	msb       order
+	lsb       order
+}
+
+// New is the main constructor that builds a Table from the code length of every symbol.
+// A length of 0 means the symbol is not used. Incomplete codes are accepted, decoding
+// one of their missing codes returns ErrInvalidCode.
+//
+// Returns an error if the lengths are over-subscribed or longer than MaxCodeLength,
+// and ErrTooLarge if their lookup tables would need more than 2^20 slots.
+func New(lengths []uint8) (*Table, error) {
+	var count [MaxCodeLength + 1]int
+	table := &Table{
+		lengths: append([]uint8(nil), lengths...),
+		codes:   make([]uint32, len(lengths)),
+	}
+	for _, length := range lengths {
+		if length > MaxCodeLength {
+			return nil, errors.New("huffman: code length should be between 0 and 32")
+		}
+		count[length]++
+		if length > table.maxLength {
+			table.maxLength = length
+		}
+	}
+	// Every length uses up 2^(maxLength-length) of the code space
+	var left uint64 = 1
+	for length := 1; length <= MaxCodeLength; length++ {
+		left <<= 1
+		if uint64(count[length]) > left {
+			return nil, ErrOversubscribed
+		}
+		left -= uint64(count[length])
+	}
+	var next [MaxCodeLength + 2]uint32
+	var code uint32
+	for length := 1; length <= MaxCodeLength; length++ {
+		code = (code + uint32(count[length-1])) << 1
+		if length == 1 {
+			code = 0
+		}
+		next[length] = code
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		table.codes[symbol] = next[length]
+		next[length]++
+		table.count++
+	}
+	table.rootBits = table.maxLength
+	if table.rootBits > rootBits {
+		table.rootBits = rootBits
+	}
+	// Sub table sizes are decided by the longest code sharing a root prefix
+	table.subBits = map[uint32]uint8{}
+	for symbol, length := range table.lengths {
+		if length <= table.rootBits {
+			continue
+		}
+		prefix := table.codes[symbol] >> (length - table.rootBits)
+		if length-table.rootBits > table.subBits[prefix] {
+			table.subBits[prefix] = length - table.rootBits
+		}
+	}
+	entries := 1 << table.rootBits
+	for _, size := range table.subBits {
+		entries += 1 << size
+		if entries > maxEntries {
+			return nil, ErrTooLarge
+		}
+	}
+	return table, nil
+}
+
+// NewFromFrequencies is a constructor that builds a Table for the given symbol
+// frequencies, with no code longer than maxLength bits.
+//
+// Returns an error if the symbols don't fit in maxLength bits.
+func NewFromFrequencies(frequencies []uint64, maxLength uint8) (*Table, error) {
+	lengths, err := LengthsFromFrequencies(frequencies, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	return New(lengths)
+}
+
+// LengthsFromFrequencies is a function that computes optimal length-limited code
+// lengths for the given symbol frequencies using the package-merge algorithm.
+// Symbols with a frequency of 0 get a length of 0. A single used symbol gets a length of 1.
+//
+// Returns an error if the used symbols don't fit in maxLength bits.
+func LengthsFromFrequencies(frequencies []uint64, maxLength uint8) ([]uint8, error) {
+	if maxLength < 1 || maxLength > MaxCodeLength {
+		return nil, errors.New("huffman: maximum code length should be between 1 and 32")
+	}
+	type item struct {
+		weight  uint64
+		symbols []int
+	}
+	lengths := make([]uint8, len(frequencies))
+	var leaves []item
+	for symbol, frequency := range frequencies {
+		if frequency != 0 {
+			leaves = append(leaves, item{weight: frequency, symbols: []int{symbol}})
+		}
+	}
+	switch {
+	case len(leaves) == 0:
+		return lengths, nil
+	case len(leaves) == 1:
+		lengths[leaves[0].symbols[0]] = 1
+		return lengths, nil
+	case uint64(len(leaves)) > uint64(1)<<maxLength:
+		return nil, errors.New("huffman: too many symbols for the maximum code length")
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return leaves[i].weight < leaves[j].weight
+	})
+	var list []item
+	for level := uint8(0); level < maxLength; level++ {
+		// Package adjacent pairs of the previous list and merge them with the leaves
+		packages := make([]item, 0, len(list)/2)
+		for i := 0; i+1 < len(list); i += 2 {
+			symbols := make([]int, 0, len(list[i].symbols)+len(list[i+1].symbols))
+			symbols = append(symbols, list[i].symbols...)
+			symbols = append(symbols, list[i+1].symbols...)
+			packages = append(packages, item{weight: list[i].weight + list[i+1].weight, symbols: symbols})
+		}
+		merged := make([]item, 0, len(leaves)+len(packages))
+		i, j := 0, 0
+		for i < len(leaves) || j < len(packages) {
+			if j == len(packages) || (i < len(leaves) && leaves[i].weight <= packages[j].weight) {
+				merged = append(merged, leaves[i])
+				i++
+			} else {
+				merged = append(merged, packages[j])
+				j++
+			}
+		}
+		list = merged
+	}
+	for _, it := range list[:2*len(leaves)-2] {
+		for _, symbol := range it.symbols {
+			lengths[symbol]++
+		}
+	}
+	return lengths, nil
+}
+
+// Lengths is a function that returns the code length of every symbol.
+func (table *Table) Lengths() []uint8 {
+	return append([]uint8(nil), table.lengths...)
+}
+
+// Code is a function that returns the canonical code and its length for a symbol.
+// The code is MSB-first, the first bit on the wire is the most significant bit.
+// A length of 0 means the symbol is not used.
+func (table *Table) Code(symbol int) (code uint32, length uint8) {
+	if symbol < 0 || symbol >= len(table.lengths) {
+		return 0, 0
+	}
+	return table.codes[symbol], table.lengths[symbol]
+}
+
+// MaxLength is a function that returns the longest code length in the table.
+func (table *Table) MaxLength() uint8 {
+	return table.maxLength
+}
+
+// Decode is a function that reads one code from the reader and returns its symbol.
+// The bit order is taken from the reader.
+//
+// Returns ErrInvalidCode if the bits don't match any code, and
+// io.EOF or io.ErrUnexpectedEOF if the stream ends before a full code.
+func (table *Table) Decode(reader *bitreader.Reader) (int, error) {
+	if table.count == 0 {
+		return 0, ErrEmpty
+	}
+	littleEndian := reader.LittleEndian()
+	lookup := &table.msb
+	if littleEndian {
+		lookup = &table.lsb
+	}
+	lookup.once.Do(func() { table.build(lookup, littleEndian) })
+	root := uint64(table.rootBits)
+	value, err := reader.PeekBits(root)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// Fewer than rootBits left, the last code may still be shorter
+			return table.decodeSlow(reader)
+		}
+		return 0, err
+	}
+	e := lookup.root[value]
+	if e.link {
+		sub := lookup.subs[e.symbol]
+		total := root + uint64(sub.bits)
+		value, err = reader.PeekBits(total)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return table.decodeSlow(reader)
+			}
+			return 0, err
+		}
+		if littleEndian {
+			value >>= root
+		}
+		e = sub.entries[value&(1<<sub.bits-1)]
+	}
+	if e.length == 0 {
+		return 0, ErrInvalidCode
+	}
+	if err := reader.SkipBits(uint64(e.length)); err != nil {
+		return 0, err
+	}
+	return int(e.symbol), nil
+}
+
+// TryDecode is a wrapper function that reads one code from the reader and returns its symbol.
+//
+// Returns int. Panics on invalid codes and overflow.
+func (table *Table) TryDecode(reader *bitreader.Reader) int {
+	symbol, err := table.Decode(reader)
+	if err != nil {
+		panic(err)
+	}
+	return symbol
+}
+
+// decodeSlow is a private function that decodes a code one bit at a time.
+// It is only used near the end of the stream where a full peek isn't possible.
+func (table *Table) decodeSlow(reader *bitreader.Reader) (int, error) {
+	var code uint32
+	for length := uint8(1); length <= table.maxLength; length++ {
+		bit, err := reader.ReadBits(1)
+		if err != nil {
+			if err == io.EOF && length > 1 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		code = code<<1 | uint32(bit)
+		for symbol, l := range table.lengths {
+			if l == length && table.codes[symbol] == code {
+				return symbol, nil
+			}
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// build is a private function that fills the lookup tables for one bit order.
+func (table *Table) build(out *order, littleEndian bool) {
+	root := table.rootBits
+	out.root = make([]entry, 1<<root)
+	prefixes := make([]uint32, 0, len(table.subBits))
+	for prefix := range table.subBits {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+	subIndex := map[uint32]int{}
+	for _, prefix := range prefixes {
+		size := table.subBits[prefix]
+		subIndex[prefix] = len(out.subs)
+		out.root[table.index(prefix, root, root, littleEndian)] = entry{link: true, symbol: uint32(len(out.subs))}
+		out.subs = append(out.subs, subTable{bits: size, entries: make([]entry, 1<<size)})
+	}
+	for symbol, length := range table.lengths {
+		if length == 0 {
+			continue
+		}
+		code := table.codes[symbol]
+		if length <= root {
+			fill(out.root, table.index(code, length, root, littleEndian), length, root, littleEndian, entry{length: length, symbol: uint32(symbol)})
+			continue
+		}
+		prefix := code >> (length - root)
+		sub := out.subs[subIndex[prefix]]
+		suffixLength := length - root
+		suffix := code & (1<<suffixLength - 1)
+		fill(sub.entries, table.index(suffix, suffixLength, sub.bits, littleEndian), suffixLength, sub.bits, littleEndian, entry{length: length, symbol: uint32(symbol)})
+	}
+}
+
+// index is a private function that returns the first slot a code of the given
+// length occupies in a lookup table indexed by width bits.
+func (table *Table) index(code uint32, length uint8, width uint8, littleEndian bool) uint32 {
+	if littleEndian {
+		return bits.Reverse32(code) >> (32 - length)
+	}
+	return code << (width - length)
+}
+
+// fill is a private function that writes e into every slot of a lookup table
+// that starts with a code of the given length.
+func fill(entries []entry, first uint32, length uint8, width uint8, littleEndian bool, e entry) {
+	span := uint32(1) << (width - length)
+	for k := uint32(0); k < span; k++ {
+		if littleEndian {
+			entries[first|k<<length] = e
+		} else {
+			entries[first|k] = e
+		}
+	}
+}
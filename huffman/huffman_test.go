@@ -0,0 +1,220 @@
+package huffman
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// writeCodes packs the codes of symbols into bytes, first bit of a code first.
+func writeCodes(t *testing.T, table *Table, symbols []int, littleEndian bool) []byte {
+	t.Helper()
+	var out []byte
+	var n uint
+	for _, symbol := range symbols {
+		code, length := table.Code(symbol)
+		if length == 0 {
+			t.Fatalf("symbol %d has no code", symbol)
+		}
+		for i := int(length) - 1; i >= 0; i-- {
+			if n%8 == 0 {
+				out = append(out, 0)
+			}
+			if code>>uint(i)&1 == 1 {
+				if littleEndian {
+					out[n/8] |= 1 << (n % 8)
+				} else {
+					out[n/8] |= 0x80 >> (n % 8)
+				}
+			}
+			n++
+		}
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		lengths   []uint8
+		wantCodes []uint32
+		wantErr   error
+	}{
+		{
+			// Example from RFC 1951 section 3.2.2
+			name:      "RFC1951",
+			lengths:   []uint8{3, 3, 3, 3, 3, 2, 4, 4},
+			wantCodes: []uint32{0b010, 0b011, 0b100, 0b101, 0b110, 0b00, 0b1110, 0b1111},
+		},
+		{
+			name:      "Unused",
+			lengths:   []uint8{1, 0, 2, 2},
+			wantCodes: []uint32{0b0, 0, 0b10, 0b11},
+		},
+		{
+			name:    "Oversubscribed",
+			lengths: []uint8{1, 1, 1},
+			wantErr: ErrOversubscribed,
+		},
+		{
+			name:    "20-bit codes",
+			lengths: []uint8{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 20},
+		},
+		{
+			// A single 32-bit code needs a sub table of 2^23 slots
+			name:    "Too large",
+			lengths: []uint8{1, 32},
+			wantErr: ErrTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := New(tt.lengths)
+			if err != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for symbol, want := range tt.wantCodes {
+				if got, _ := table.Code(symbol); got != want {
+					t.Errorf("Table.Code(%d) = %b, want %b", symbol, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTable_Decode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Long codes force sub table lookups
+	frequencies := make([]uint64, 300)
+	for i := range frequencies {
+		frequencies[i] = uint64(rng.Intn(1 << uint(i%20)))
+	}
+	tests := []struct {
+		name    string
+		lengths []uint8
+	}{
+		{name: "RFC1951", lengths: []uint8{3, 3, 3, 3, 3, 2, 4, 4}},
+		{name: "Single", lengths: []uint8{0, 1}},
+		{name: "Incomplete", lengths: []uint8{1, 0, 3, 3}},
+	}
+	lengths, err := LengthsFromFrequencies(frequencies, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests = append(tests, struct {
+		name    string
+		lengths []uint8
+	}{name: "Skewed", lengths: lengths})
+	for _, tt := range tests {
+		for _, littleEndian := range []bool{true, false} {
+			name := tt.name + "BE"
+			if littleEndian {
+				name = tt.name + "LE"
+			}
+			t.Run(name, func(t *testing.T) {
+				table, err := New(tt.lengths)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var used []int
+				for symbol, length := range tt.lengths {
+					if length != 0 {
+						used = append(used, symbol)
+					}
+				}
+				want := make([]int, 1000)
+				for i := range want {
+					want[i] = used[rng.Intn(len(used))]
+				}
+				reader := bitreader.NewReaderFromBytes(writeCodes(t, table, want, littleEndian), littleEndian)
+				got := make([]int, len(want))
+				for i := range got {
+					got[i], err = table.Decode(reader)
+					if err != nil {
+						t.Fatalf("Table.Decode() symbol %d error = %v", i, err)
+					}
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Table.Decode() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestTable_DecodeBuildsOneOrder(t *testing.T) {
+	table, err := New([]uint8{3, 3, 3, 3, 3, 2, 4, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Symbol 5 is code 00
+	if symbol, err := table.Decode(bitreader.NewReaderFromBytes([]byte{0}, false)); err != nil || symbol != 5 {
+		t.Fatalf("Table.Decode() = %d, %v", symbol, err)
+	}
+	if table.msb.root == nil || table.lsb.root != nil {
+		t.Errorf("built tables: msb %t, lsb %t, want only msb", table.msb.root != nil, table.lsb.root != nil)
+	}
+}
+
+func TestTable_DecodeInvalid(t *testing.T) {
+	table, err := New([]uint8{1, 0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Code 11 is missing from the table
+	reader := bitreader.NewReaderFromBytes([]byte{0b11000000}, false)
+	if _, err := table.Decode(reader); err != ErrInvalidCode {
+		t.Errorf("Table.Decode() error = %v, want %v", err, ErrInvalidCode)
+	}
+}
+
+func TestLengthsFromFrequencies(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequencies []uint64
+		maxLength   uint8
+		want        []uint8
+		wantErr     bool
+	}{
+		{
+			name:        "Unlimited",
+			frequencies: []uint64{1, 1, 2, 4},
+			maxLength:   15,
+			want:        []uint8{3, 3, 2, 1},
+		},
+		{
+			name:        "Limited",
+			frequencies: []uint64{1, 1, 2, 4},
+			maxLength:   2,
+			want:        []uint8{2, 2, 2, 2},
+		},
+		{
+			name:        "SingleSymbol",
+			frequencies: []uint64{0, 7, 0},
+			maxLength:   15,
+			want:        []uint8{0, 1, 0},
+		},
+		{
+			name:        "TooManySymbols",
+			frequencies: []uint64{1, 1, 1, 1, 1},
+			maxLength:   2,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LengthsFromFrequencies(tt.frequencies, tt.maxLength)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LengthsFromFrequencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("LengthsFromFrequencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+// Package inflate decodes DEFLATE (RFC 1951) and zlib (RFC 1950) streams
+// from a little-endian bitreader.Reader.
+//
+// Unlike compress/flate, the Decompressor exposes the structure of the stream:
+// the bit offset, type and Huffman code lengths of every block.
+package inflate
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+
+	"github.com/pektezol/bitreader"
+	"github.com/pektezol/bitreader/huffman"
+)
+
+// windowSize is the maximum distance a back reference can reach.
+const windowSize = 1 << 15
+
+var (
+	// ErrBigEndian is returned when the Reader is not in little-endian mode.
+	ErrBigEndian = errors.New("inflate: reader should be little-endian")
+	// ErrCorrupt is returned when the stream is not valid DEFLATE data.
+	ErrCorrupt = errors.New("inflate: corrupt input")
+	// ErrChecksum is returned when the zlib Adler-32 checksum doesn't match.
+	ErrChecksum = errors.New("inflate: invalid checksum")
+	// ErrDictionary is returned when a zlib stream needs a preset dictionary.
+	ErrDictionary = errors.New("inflate: preset dictionary is not supported")
+	// ErrHeader is returned when the zlib header is invalid.
+	ErrHeader = errors.New("inflate: invalid zlib header")
+)
+
+// BlockType is the BTYPE field of a block header.
+type BlockType uint8
+
+const (
+	Stored  BlockType = 0 // No compression
+	Fixed   BlockType = 1 // Compressed with the fixed Huffman codes
+	Dynamic BlockType = 2 // Compressed with Huffman codes stored in the block
+)
+
+// String is a function that returns the name of the block type.
+func (blockType BlockType) String() string {
+	switch blockType {
+	case Stored:
+		return "stored"
+	case Fixed:
+		return "fixed"
+	case Dynamic:
+		return "dynamic"
+	}
+	return fmt.Sprintf("BlockType(%d)", uint8(blockType))
+}
+
+// Block describes one decoded DEFLATE block.
+//
+// Final bool					Whether BFINAL was set
+// Type BlockType				The BTYPE of the block
+// Start uint64					Bit position of the block header in the Reader
+// DataStart uint64				Bit position of the first stored byte or compressed symbol
+// End uint64					Bit position right after the block
+// OutputStart int64			Offset of the first decompressed byte of the block
+// OutputEnd int64				Offset right after the last decompressed byte of the block
+// LiteralLengths []uint8		Literal/length code lengths, nil for stored blocks
+// DistanceLengths []uint8		Distance code lengths, nil for stored blocks
+// CodeLengthLengths []uint8	Code length code lengths, only for dynamic blocks
+// Literal, Distance *huffman.Table	Decoding tables, nil for stored blocks
+type Block struct {
+	Final             bool
+	Type              BlockType
+	Start             uint64
+	DataStart         uint64
+	End               uint64
+	OutputStart       int64
+	OutputEnd         int64
+	LiteralLengths    []uint8
+	DistanceLengths   []uint8
+	CodeLengthLengths []uint8
+	Literal           *huffman.Table
+	Distance          *huffman.Table
+}
+
+// Decompressor decodes a DEFLATE or zlib stream block by block.
+//
+// reader *bitreader.Reader	The little-endian Reader the stream is read from
+// window []byte			The last decompressed bytes back references can reach
+// pending []byte			Decompressed bytes not returned by Read yet
+// output int64				Total number of decompressed bytes
+// checksum hash.Hash32		Running Adler-32 of the output for zlib streams
+// final bool				Whether the final block has been decoded
+// err error				The sticky error returned by Read
+type Decompressor struct {
+	reader   *bitreader.Reader
+	window   []byte
+	pending  []byte
+	output   int64
+	checksum hash.Hash32
+	final    bool
+	err      error
+}
+
+// NewDecompressor is the main constructor that creates a Decompressor
+// for a raw DEFLATE stream starting at the current Reader position.
+//
+// Returns an error if the Reader is not little-endian.
+func NewDecompressor(reader *bitreader.Reader) (*Decompressor, error) {
+	if !reader.LittleEndian() {
+		return nil, ErrBigEndian
+	}
+	return &Decompressor{reader: reader}, nil
+}
+
+// NewZlibDecompressor is a constructor that reads a zlib header from the Reader
+// and creates a Decompressor for the DEFLATE stream that follows it. The Adler-32
+// trailer is verified after the final block.
+//
+// Returns an error if the Reader is not little-endian or the header is invalid.
+func NewZlibDecompressor(reader *bitreader.Reader) (*Decompressor, error) {
+	if !reader.LittleEndian() {
+		return nil, ErrBigEndian
+	}
+	if err := reader.AlignToByte(); err != nil {
+		return nil, err
+	}
+	cmf, err := reader.ReadBits(8)
+	if err != nil {
+		return nil, err
+	}
+	flg, err := reader.ReadBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if cmf&0x0f != 8 || cmf>>4 > 7 || (cmf<<8|flg)%31 != 0 {
+		return nil, ErrHeader
+	}
+	if flg&0x20 != 0 {
+		return nil, ErrDictionary
+	}
+	return &Decompressor{reader: reader, checksum: adler32.New()}, nil
+}
+
+// Inflate is a function that decompresses a whole raw DEFLATE stream
+// and returns the decompressed data together with every block.
+func Inflate(reader *bitreader.Reader) ([]byte, []*Block, error) {
+	decompressor, err := NewDecompressor(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decompressor.decompressAll()
+}
+
+// InflateZlib is a function that decompresses a whole zlib stream
+// and returns the decompressed data together with every block.
+func InflateZlib(reader *bitreader.Reader) ([]byte, []*Block, error) {
+	decompressor, err := NewZlibDecompressor(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decompressor.decompressAll()
+}
+
+// NextBlock is a function that decodes the next block and returns it
+// together with its decompressed data. The data is only valid until the next call.
+//
+// Returns io.EOF after the final block.
+func (decompressor *Decompressor) NextBlock() (*Block, []byte, error) {
+	if decompressor.final {
+		return nil, nil, io.EOF
+	}
+	reader := decompressor.reader
+	block := &Block{Start: reader.BitPosition(), OutputStart: decompressor.output}
+	header, err := reader.ReadBits(3)
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	block.Final = header&1 == 1
+	block.Type = BlockType(header >> 1)
+	var data []byte
+	switch block.Type {
+	case Stored:
+		data, err = decompressor.stored(block)
+	case Fixed:
+		block.LiteralLengths, block.DistanceLengths = fixedLiteralLengths, fixedDistanceLengths
+		block.Literal, block.Distance = fixedLiteral, fixedDistance
+		block.DataStart = reader.BitPosition()
+		data, err = decompressor.compressed(block)
+	case Dynamic:
+		err = decompressor.dynamicTables(block)
+		if err == nil {
+			block.DataStart = reader.BitPosition()
+			data, err = decompressor.compressed(block)
+		}
+	default:
+		err = ErrCorrupt
+	}
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	block.End = reader.BitPosition()
+	decompressor.output += int64(len(data))
+	block.OutputEnd = decompressor.output
+	if decompressor.checksum != nil {
+		decompressor.checksum.Write(data)
+	}
+	if block.Final {
+		decompressor.final = true
+		if err := decompressor.verifyChecksum(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return block, data, nil
+}
+
+// Read is a function that implements io.Reader over the decompressed data.
+func (decompressor *Decompressor) Read(p []byte) (int, error) {
+	for len(decompressor.pending) == 0 {
+		if decompressor.err != nil {
+			return 0, decompressor.err
+		}
+		_, data, err := decompressor.NextBlock()
+		if err != nil {
+			decompressor.err = err
+			continue
+		}
+		decompressor.pending = data
+	}
+	n := copy(p, decompressor.pending)
+	decompressor.pending = decompressor.pending[n:]
+	return n, nil
+}
+
+// decompressAll is a private function that decodes every remaining block.
+func (decompressor *Decompressor) decompressAll() ([]byte, []*Block, error) {
+	var out []byte
+	var blocks []*Block
+	for {
+		block, data, err := decompressor.NextBlock()
+		if err == io.EOF {
+			return out, blocks, nil
+		}
+		if err != nil {
+			return out, blocks, err
+		}
+		out = append(out, data...)
+		blocks = append(blocks, block)
+	}
+}
+
+// stored is a private function that reads the data of a stored block.
+func (decompressor *Decompressor) stored(block *Block) ([]byte, error) {
+	reader := decompressor.reader
+	if err := reader.AlignToByte(); err != nil {
+		return nil, err
+	}
+	length, err := reader.ReadBits(16)
+	if err != nil {
+		return nil, err
+	}
+	complement, err := reader.ReadBits(16)
+	if err != nil {
+		return nil, err
+	}
+	if length != ^complement&0xffff {
+		return nil, ErrCorrupt
+	}
+	block.DataStart = reader.BitPosition()
+	data := make([]byte, length)
+	for i := range data {
+		value, err := reader.ReadBits(8)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = byte(value)
+	}
+	decompressor.remember(data)
+	return data, nil
+}
+
+// dynamicTables is a private function that reads the Huffman tables of a dynamic block.
+func (decompressor *Decompressor) dynamicTables(block *Block) error {
+	reader := decompressor.reader
+	counts, err := reader.ReadBits(14)
+	if err != nil {
+		return err
+	}
+	literals := int(counts&0x1f) + 257
+	distances := int(counts>>5&0x1f) + 1
+	codeLengths := int(counts>>10) + 4
+	if literals > 286 || distances > 30 {
+		return ErrCorrupt
+	}
+	block.CodeLengthLengths = make([]uint8, 19)
+	for i := 0; i < codeLengths; i++ {
+		length, err := reader.ReadBits(3)
+		if err != nil {
+			return err
+		}
+		block.CodeLengthLengths[codeLengthOrder[i]] = uint8(length)
+	}
+	codeLengthTable, err := newTable(block.CodeLengthLengths)
+	if err != nil {
+		return err
+	}
+	lengths := make([]uint8, literals+distances)
+	for i := 0; i < len(lengths); {
+		symbol, err := codeLengthTable.Decode(reader)
+		if err != nil {
+			return huffmanError(err)
+		}
+		if symbol < 16 {
+			lengths[i] = uint8(symbol)
+			i++
+			continue
+		}
+		var repeat uint64
+		var value uint8
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return ErrCorrupt
+			}
+			value = lengths[i-1]
+			repeat, err = reader.ReadBits(2)
+			repeat += 3
+		case 17:
+			repeat, err = reader.ReadBits(3)
+			repeat += 3
+		default:
+			repeat, err = reader.ReadBits(7)
+			repeat += 11
+		}
+		if err != nil {
+			return err
+		}
+		if uint64(i)+repeat > uint64(len(lengths)) {
+			return ErrCorrupt
+		}
+		for ; repeat > 0; repeat-- {
+			lengths[i] = value
+			i++
+		}
+	}
+	block.LiteralLengths = lengths[:literals]
+	block.DistanceLengths = lengths[literals:]
+	if block.LiteralLengths[256] == 0 {
+		return ErrCorrupt
+	}
+	if block.Literal, err = newTable(block.LiteralLengths); err != nil {
+		return err
+	}
+	if block.Distance, err = newTable(block.DistanceLengths); err != nil {
+		return err
+	}
+	return nil
+}
+
+// compressed is a private function that decodes the symbols of a Huffman compressed block.
+func (decompressor *Decompressor) compressed(block *Block) ([]byte, error) {
+	reader := decompressor.reader
+	var out []byte
+	for {
+		symbol, err := block.Literal.Decode(reader)
+		if err != nil {
+			return nil, huffmanError(err)
+		}
+		switch {
+		case symbol < 256:
+			out = append(out, byte(symbol))
+			continue
+		case symbol == 256:
+			decompressor.remember(out)
+			return out, nil
+		case symbol > 285:
+			return nil, ErrCorrupt
+		}
+		length := int(lengthBase[symbol-257])
+		if extra := lengthExtra[symbol-257]; extra > 0 {
+			value, err := reader.ReadBits(uint64(extra))
+			if err != nil {
+				return nil, err
+			}
+			length += int(value)
+		}
+		symbol, err = block.Distance.Decode(reader)
+		if err != nil {
+			return nil, huffmanError(err)
+		}
+		if symbol > 29 {
+			return nil, ErrCorrupt
+		}
+		distance := int(distanceBase[symbol])
+		if extra := distanceExtra[symbol]; extra > 0 {
+			value, err := reader.ReadBits(uint64(extra))
+			if err != nil {
+				return nil, err
+			}
+			distance += int(value)
+		}
+		if distance > len(out)+len(decompressor.window) {
+			return nil, ErrCorrupt
+		}
+		for i := 0; i < length; i++ {
+			position := len(out) - distance
+			if position >= 0 {
+				out = append(out, out[position])
+			} else {
+				out = append(out, decompressor.window[len(decompressor.window)+position])
+			}
+		}
+	}
+}
+
+// remember is a private function that keeps the last windowSize bytes of output.
+func (decompressor *Decompressor) remember(data []byte) {
+	if len(data) >= windowSize {
+		decompressor.window = append(decompressor.window[:0], data[len(data)-windowSize:]...)
+		return
+	}
+	if overflow := len(decompressor.window) + len(data) - windowSize; overflow > 0 {
+		decompressor.window = append(decompressor.window[:0], decompressor.window[overflow:]...)
+	}
+	decompressor.window = append(decompressor.window, data...)
+}
+
+// verifyChecksum is a private function that checks the Adler-32 trailer of a zlib stream.
+func (decompressor *Decompressor) verifyChecksum() error {
+	if decompressor.checksum == nil {
+		return nil
+	}
+	reader := decompressor.reader
+	if err := reader.AlignToByte(); err != nil {
+		return err
+	}
+	var sum uint32
+	for i := 0; i < 4; i++ {
+		value, err := reader.ReadBits(8)
+		if err != nil {
+			return noEOF(err)
+		}
+		sum = sum<<8 | uint32(value)
+	}
+	if sum != decompressor.checksum.Sum32() {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// newTable is a private function that builds a Huffman table and rejects
+// incomplete codes, except for a single code of length 1, like zlib does.
+func newTable(lengths []uint8) (*huffman.Table, error) {
+	var used int
+	var space uint64
+	for _, length := range lengths {
+		if length != 0 {
+			used++
+			space += 1 << (16 - length)
+		}
+	}
+	if space != 1<<16 && !(used == 0 || used == 1 && space == 1<<15) {
+		return nil, ErrCorrupt
+	}
+	table, err := huffman.New(lengths)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return table, nil
+}
+
+// huffmanError is a private function that maps huffman decoding errors to ErrCorrupt.
+func huffmanError(err error) error {
+	if err == huffman.ErrInvalidCode || err == huffman.ErrEmpty {
+		return ErrCorrupt
+	}
+	return err
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
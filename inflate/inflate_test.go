@@ -0,0 +1,164 @@
+package inflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// corpus returns inputs that exercise every block type.
+func corpus() map[string][]byte {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 70000)
+	rng.Read(random)
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 2000)
+	skewed := make([]byte, 100000)
+	for i := range skewed {
+		skewed[i] = byte(rng.ExpFloat64() * 4)
+	}
+	return map[string][]byte{
+		"Empty":  {},
+		"Byte":   {'a'},
+		"Random": random,
+		"Text":   text,
+		"Skewed": skewed,
+	}
+}
+
+func deflate(t testing.TB, data []byte, level int) []byte {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(data)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestInflate(t *testing.T) {
+	levels := []int{flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression, flate.HuffmanOnly}
+	for name, data := range corpus() {
+		for _, level := range levels {
+			compressed := deflate(t, data, level)
+			got, blocks, err := Inflate(bitreader.NewReaderFromBytes(compressed, true))
+			if err != nil {
+				t.Fatalf("%s level %d: Inflate() error = %v", name, level, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%s level %d: Inflate() output differs from input", name, level)
+			}
+			if len(blocks) == 0 || !blocks[len(blocks)-1].Final {
+				t.Fatalf("%s level %d: Inflate() did not return the final block", name, level)
+			}
+			for i := 1; i < len(blocks); i++ {
+				if blocks[i].Start != blocks[i-1].End || blocks[i].OutputStart != blocks[i-1].OutputEnd {
+					t.Fatalf("%s level %d: block %d does not start where block %d ends", name, level, i, i-1)
+				}
+			}
+		}
+	}
+}
+
+func TestInflateBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		want   []BlockType
+	}{
+		{
+			// Stored block followed by a final fixed block encoding "a"
+			name:   "StoredFixed",
+			stream: []byte{0x00, 0x01, 0x00, 0xfe, 0xff, 'x', 0x4b, 0x04, 0x00},
+			want:   []BlockType{Stored, Fixed},
+		},
+		{
+			name:   "Dynamic",
+			stream: deflate(t, corpus()["Skewed"][:3000], flate.BestCompression),
+			want:   []BlockType{Dynamic, Fixed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, blocks, err := Inflate(bitreader.NewReaderFromBytes(tt.stream, true))
+			if err != nil {
+				t.Fatalf("Inflate() error = %v", err)
+			}
+			if len(blocks) != len(tt.want) {
+				t.Fatalf("Inflate() returned %d blocks, want %d", len(blocks), len(tt.want))
+			}
+			for i, block := range blocks {
+				if block.Type != tt.want[i] {
+					t.Errorf("block %d type = %v, want %v", i, block.Type, tt.want[i])
+				}
+				if block.Type != Stored && (block.Literal == nil || len(block.LiteralLengths) < 257) {
+					t.Errorf("block %d has no literal table", i)
+				}
+			}
+		})
+	}
+}
+
+func TestInflateZlib(t *testing.T) {
+	data := corpus()["Text"]
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write(data)
+	writer.Close()
+	got, _, err := InflateZlib(bitreader.NewReaderFromBytes(buf.Bytes(), true))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("InflateZlib() error = %v, output equal %v", err, bytes.Equal(got, data))
+	}
+	corrupt := append([]byte(nil), buf.Bytes()...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, _, err := InflateZlib(bitreader.NewReaderFromBytes(corrupt, true)); err != ErrChecksum {
+		t.Errorf("InflateZlib() error = %v, want %v", err, ErrChecksum)
+	}
+	if _, err := NewZlibDecompressor(bitreader.NewReaderFromBytes([]byte{0x78, 0x00}, true)); err != ErrHeader {
+		t.Errorf("NewZlibDecompressor() error = %v, want %v", err, ErrHeader)
+	}
+}
+
+func TestDecompressor_Read(t *testing.T) {
+	data := corpus()["Skewed"]
+	decompressor, err := NewDecompressor(bitreader.NewReaderFromBytes(deflate(t, data, flate.DefaultCompression), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(decompressor)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Decompressor.Read() error = %v, output equal %v", err, bytes.Equal(got, data))
+	}
+}
+
+func TestNewDecompressorBigEndian(t *testing.T) {
+	if _, err := NewDecompressor(bitreader.NewReaderFromBytes(nil, false)); err != ErrBigEndian {
+		t.Errorf("NewDecompressor() error = %v, want %v", err, ErrBigEndian)
+	}
+}
+
+func FuzzInflate(f *testing.F) {
+	for _, data := range corpus() {
+		if len(data) > 4096 {
+			data = data[:4096]
+		}
+		f.Add(deflate(f, data, flate.DefaultCompression))
+		f.Add(deflate(f, data, flate.NoCompression))
+		f.Add(deflate(f, data, flate.HuffmanOnly))
+	}
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		want, wantErr := io.ReadAll(flate.NewReader(bytes.NewReader(stream)))
+		got, _, err := Inflate(bitreader.NewReaderFromBytes(stream, true))
+		if (err != nil) != (wantErr != nil) {
+			t.Fatalf("Inflate() error = %v, compress/flate error = %v", err, wantErr)
+		}
+		if err == nil && !bytes.Equal(got, want) {
+			t.Fatalf("Inflate() output differs from compress/flate")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package inflate
+
+import "github.com/pektezol/bitreader/huffman"
+
+// codeLengthOrder is the order code length code lengths are stored in.
+var codeLengthOrder = [19]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// lengthBase and lengthExtra map length symbols 257-285 to match lengths.
+var lengthBase = [29]uint16{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+	35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258,
+}
+
+var lengthExtra = [29]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+	3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0,
+}
+
+// distanceBase and distanceExtra map distance symbols 0-29 to match distances.
+var distanceBase = [30]uint16{
+	1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+	257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577,
+}
+
+var distanceExtra = [30]uint8{
+	0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+	7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13,
+}
+
+// The fixed Huffman codes of BTYPE 1, from RFC 1951 section 3.2.6.
+var (
+	fixedLiteralLengths  = makeFixedLiteralLengths()
+	fixedDistanceLengths = makeFixedDistanceLengths()
+	fixedLiteral, _      = huffman.New(fixedLiteralLengths)
+	fixedDistance, _     = huffman.New(fixedDistanceLengths)
+)
+
+func makeFixedLiteralLengths() []uint8 {
+	lengths := make([]uint8, 288)
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	return lengths
+}
+
+func makeFixedDistanceLengths() []uint8 {
+	lengths := make([]uint8, 32)
+	for i := range lengths {
+		lengths[i] = 5
+	}
+	return lengths
+}
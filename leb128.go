@@ -0,0 +1,84 @@
+package bitreader
+
+import (
+	"errors"
+	"io"
+)
+
+// ReadULEB128 is a function that reads an unsigned LEB128 number, as used by
+// DWARF, WebAssembly and protobuf varints, from any bit position. Each byte
+// holds 7 bits of the value, least significant first, and the top bit of the
+// byte is set when more bytes follow.
+//
+// Returns io.EOF if there are no remaining bytes, io.ErrUnexpectedEOF if the
+// stream ends inside the number and an error if it doesn't fit 64 bits.
+func (reader *Reader) ReadULEB128() (uint64, error) {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := reader.ReadBytes(1)
+		if err != nil {
+			if err == io.EOF && shift > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if shift == 63 && b > 1 || shift > 63 {
+			return 0, errors.New("ReadULEB128() ERROR: Value overflows 64 bits")
+		}
+		value |= (b & 0x7f) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+}
+
+// ReadSLEB128 is a function that reads a signed LEB128 number from any bit position.
+// It is encoded like ReadULEB128 and sign-extended from the last bit of the last byte.
+//
+// Returns io.EOF if there are no remaining bytes, io.ErrUnexpectedEOF if the
+// stream ends inside the number and an error if it doesn't fit 64 bits.
+func (reader *Reader) ReadSLEB128() (int64, error) {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := reader.ReadBytes(1)
+		if err != nil {
+			if err == io.EOF && shift > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		// The last byte of a 64-bit value only has room for the sign, which has to fill the rest of the byte
+		if shift == 63 && b != 0 && b != 0x7f || shift > 63 {
+			return 0, errors.New("ReadSLEB128() ERROR: Value overflows 64 bits")
+		}
+		value |= (b & 0x7f) << shift
+		if b&0x80 == 0 {
+			if shift+7 < 64 && b&0x40 != 0 {
+				value |= ^uint64(0) << (shift + 7)
+			}
+			return int64(value), nil
+		}
+	}
+}
+
+// TryReadULEB128 is a wrapper function that returns the value of an unsigned LEB128 number.
+//
+// Returns uint64. Panics on overflow.
+func (reader *Reader) TryReadULEB128() uint64 {
+	value, err := reader.ReadULEB128()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadSLEB128 is a wrapper function that returns the value of a signed LEB128 number.
+//
+// Returns int64. Panics on overflow.
+func (reader *Reader) TryReadSLEB128() int64 {
+	value, err := reader.ReadSLEB128()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
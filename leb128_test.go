@@ -0,0 +1,135 @@
+package bitreader
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestReader_ReadULEB128(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		want    uint64
+		wantErr error
+	}{
+		// Examples from the DWARF specification
+		{name: "2", stream: []byte{0x02}, want: 2},
+		{name: "127", stream: []byte{0x7f}, want: 127},
+		{name: "128", stream: []byte{0x80, 0x01}, want: 128},
+		{name: "129", stream: []byte{0x81, 0x01}, want: 129},
+		{name: "12857", stream: []byte{0xb9, 0x64}, want: 12857},
+		{name: "Padded", stream: []byte{0x80, 0x80, 0x00}, want: 0},
+		{name: "Max", stream: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, want: math.MaxUint64},
+		{name: "Overflow", stream: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, wantErr: errAny},
+		{name: "TooLong", stream: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, wantErr: errAny},
+		{name: "Empty", stream: []byte{}, wantErr: io.EOF},
+		{name: "Short", stream: []byte{0x80}, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, true).ReadULEB128()
+			if !sameError(err, tt.wantErr) {
+				t.Errorf("ReadULEB128() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("ReadULEB128() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadSLEB128(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		want    int64
+		wantErr error
+	}{
+		// Examples from the DWARF specification
+		{name: "2", stream: []byte{0x02}, want: 2},
+		{name: "-2", stream: []byte{0x7e}, want: -2},
+		{name: "127", stream: []byte{0xff, 0x00}, want: 127},
+		{name: "-127", stream: []byte{0x81, 0x7f}, want: -127},
+		{name: "128", stream: []byte{0x80, 0x01}, want: 128},
+		{name: "-128", stream: []byte{0x80, 0x7f}, want: -128},
+		{name: "129", stream: []byte{0x81, 0x01}, want: 129},
+		{name: "-129", stream: []byte{0xff, 0x7e}, want: -129},
+		{name: "Max", stream: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, want: math.MaxInt64},
+		{name: "Min", stream: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f}, want: math.MinInt64},
+		{name: "Overflow", stream: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, wantErr: errAny},
+		{name: "Empty", stream: []byte{}, wantErr: io.EOF},
+		{name: "Short", stream: []byte{0xff}, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, true).ReadSLEB128()
+			if !sameError(err, tt.wantErr) {
+				t.Errorf("ReadSLEB128() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("ReadSLEB128() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_LEB128Unaligned(t *testing.T) {
+	random := rand.New(rand.NewSource(40))
+	for i := 0; i < 1000; i++ {
+		unsigned := random.Uint64() >> uint(random.Intn(64))
+		signed := int64(random.Uint64()) >> uint(random.Intn(64))
+		var data []byte
+		for value := unsigned; ; value >>= 7 {
+			if value < 0x80 {
+				data = append(data, byte(value))
+				break
+			}
+			data = append(data, byte(value)|0x80)
+		}
+		for value := signed; ; value >>= 7 {
+			if value >= -64 && value < 64 {
+				data = append(data, byte(value)&0x7f)
+				break
+			}
+			data = append(data, byte(value)|0x80)
+		}
+		// Shift everything by a few bits in the Reader's bit order
+		shift := uint(1 + random.Intn(7))
+		for _, le := range []bool{true, false} {
+			stream := make([]byte, len(data)+1)
+			for j, b := range data {
+				if le {
+					stream[j] |= b << shift
+					stream[j+1] |= b >> (8 - shift)
+				} else {
+					stream[j] |= b >> shift
+					stream[j+1] |= b << (8 - shift)
+				}
+			}
+			reader := NewReaderFromBytes(stream, le)
+			reader.SkipBits(uint64(shift))
+			if got := reader.TryReadULEB128(); got != unsigned {
+				t.Fatalf("LE=%v: ReadULEB128() = %d, want %d", le, got, unsigned)
+			}
+			if got := reader.TryReadSLEB128(); got != signed {
+				t.Fatalf("LE=%v: ReadSLEB128() = %d, want %d", le, got, signed)
+			}
+		}
+	}
+}
+
+// errAny stands for any error that is not io.EOF or io.ErrUnexpectedEOF.
+var errAny = errors.New("any error")
+
+// sameError reports whether err matches want, where errAny matches any other error.
+func sameError(err, want error) bool {
+	if want == errAny {
+		return err != nil && err != io.EOF && err != io.ErrUnexpectedEOF
+	}
+	return err == want
+}
@@ -0,0 +1,76 @@
+package bitreader
+
+import "fmt"
+
+// Limits is the policy a Reader enforces on length-driven reads, so that a
+// corrupted or hostile length field can't make it allocate or consume
+// an unbounded amount of memory. A zero field means no limit.
+//
+// MaxStringLength uint64	The most bytes a string read may consume, before decoding
+// MaxSliceLength uint64	The most bytes ReadBitsToSlice and ReadBytesToSlice may allocate
+// MaxTotalBits uint64		The most bits the Reader may consume in total
+type Limits struct {
+	MaxStringLength uint64
+	MaxSliceLength  uint64
+	MaxTotalBits    uint64
+}
+
+// LimitError is the error returned when a read would exceed one of the Limits.
+// It is returned before anything is allocated or consumed for that read.
+//
+// Op string			The function that was refused
+// Limit string			The name of the exceeded Limits field
+// Requested uint64		The amount the read needed
+// Max uint64			The configured limit
+type LimitError struct {
+	Op        string
+	Limit     string
+	Requested uint64
+	Max       uint64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%s ERROR: %s of %d exceeded, %d requested", err.Op, err.Limit, err.Max, err.Requested)
+}
+
+// SetLimits is a function that sets the Limits the Reader enforces.
+// Forks of the Reader inherit its Limits.
+func (reader *Reader) SetLimits(limits Limits) {
+	reader.limits = limits
+}
+
+// Limits is a function that returns the Limits the Reader enforces.
+func (reader *Reader) Limits() Limits {
+	return reader.limits
+}
+
+// checkBits is a private function that returns a LimitError if consuming
+// the given amount of bits would exceed MaxTotalBits.
+func (reader *Reader) checkBits(op string, bits uint64) error {
+	max := reader.limits.MaxTotalBits
+	if max == 0 {
+		return nil
+	}
+	if bits > max || reader.position > max-bits {
+		return &LimitError{Op: op, Limit: "MaxTotalBits", Requested: reader.position + bits, Max: max}
+	}
+	return nil
+}
+
+// checkSlice is a private function that returns a LimitError if allocating
+// a slice of the given amount of bytes would exceed MaxSliceLength.
+func (reader *Reader) checkSlice(op string, bytes uint64) error {
+	if max := reader.limits.MaxSliceLength; max != 0 && bytes > max {
+		return &LimitError{Op: op, Limit: "MaxSliceLength", Requested: bytes, Max: max}
+	}
+	return nil
+}
+
+// checkString is a private function that returns a LimitError if a string
+// of the given amount of bytes would exceed MaxStringLength.
+func (reader *Reader) checkString(op string, length uint64) error {
+	if max := reader.limits.MaxStringLength; max != 0 && length > max {
+		return &LimitError{Op: op, Limit: "MaxStringLength", Requested: length, Max: max}
+	}
+	return nil
+}
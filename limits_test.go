@@ -0,0 +1,143 @@
+package bitreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestReader_SetLimits(t *testing.T) {
+	limits := Limits{MaxStringLength: 4, MaxSliceLength: 2, MaxTotalBits: 48}
+	tests := []struct {
+		name      string
+		stream    []byte
+		read      func(reader *Reader) error
+		wantLimit string
+	}{
+		{
+			name:      "ReadString",
+			stream:    []byte("hello\x00"),
+			read:      func(reader *Reader) error { _, err := reader.ReadString(); return err },
+			wantLimit: "MaxStringLength",
+		},
+		{
+			name:   "ReadStringShort",
+			stream: []byte("hi\x00"),
+			read:   func(reader *Reader) error { _, err := reader.ReadString(); return err },
+		},
+		{
+			name:      "ReadStringLength",
+			stream:    []byte("hello\x00"),
+			read:      func(reader *Reader) error { _, err := reader.ReadStringLength(5); return err },
+			wantLimit: "MaxStringLength",
+		},
+		{
+			name:      "ReadBytesToSlice",
+			stream:    []byte{0x01, 0x02, 0x03},
+			read:      func(reader *Reader) error { _, err := reader.ReadBytesToSlice(1 << 40); return err },
+			wantLimit: "MaxSliceLength",
+		},
+		{
+			name:      "ReadBitsToSlice",
+			stream:    []byte{0x01, 0x02, 0x03},
+			read:      func(reader *Reader) error { _, err := reader.ReadBitsToSlice(17); return err },
+			wantLimit: "MaxSliceLength",
+		},
+		{
+			name:      "ReadBits",
+			stream:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			read:      func(reader *Reader) error { _, err := reader.ReadBits(64); return err },
+			wantLimit: "MaxTotalBits",
+		},
+		{
+			name:      "SkipBits",
+			stream:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+			read:      func(reader *Reader) error { return reader.SkipBits(49) },
+			wantLimit: "MaxTotalBits",
+		},
+		{
+			name:   "WithinLimits",
+			stream: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			read:   func(reader *Reader) error { return reader.SkipBits(48) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReaderFromBytes(tt.stream, true)
+			reader.SetLimits(limits)
+			err := tt.read(reader)
+			var limitErr *LimitError
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Errorf("%s error = %v, want nil", tt.name, err)
+				}
+				return
+			}
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+				t.Errorf("%s error = %v, want %s LimitError", tt.name, err, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestReader_LimitsFork(t *testing.T) {
+	limits := Limits{MaxTotalBits: 8}
+	reader := NewReaderFromBytes([]byte{0x01, 0x02}, false)
+	reader.SetLimits(limits)
+	fork, err := reader.Fork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.Limits() != limits {
+		t.Errorf("Reader.Fork().Limits() = %+v, want %+v", fork.Limits(), limits)
+	}
+}
+
+// FuzzLimits reads hostile length fields followed by arbitrary data and checks
+// that memory allocated stays bounded by the limits, not by the length fields.
+func FuzzLimits(f *testing.F) {
+	f.Add(uint32(1<<31), uint32(1<<30), []byte("HL2DEMO\x00"), true)
+	f.Add(uint32(5), uint32(40), []byte("player\x00name"), false)
+	f.Add(uint32(0xffffffff), uint32(0xffffffff), bytes.Repeat([]byte{0xff}, 4096), true)
+	const maxAlloc = 1 << 20
+	f.Fuzz(func(t *testing.T, bytesLength uint32, bitsLength uint32, data []byte, littleEndian bool) {
+		limits := Limits{MaxStringLength: 256, MaxSliceLength: 4096, MaxTotalBits: 1 << 16}
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint32(header, bytesLength)
+		binary.LittleEndian.PutUint32(header[4:], bitsLength)
+		reader := NewReaderFromBytes(append(header, data...), littleEndian)
+		reader.SetLimits(limits)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		length, _ := reader.ReadBits(32)
+		bits, _ := reader.ReadBits(32)
+		if out, err := reader.ReadBytesToSlice(length); uint64(len(out)) > limits.MaxSliceLength {
+			t.Fatalf("ReadBytesToSlice() returned %d bytes, error = %v", len(out), err)
+		}
+		if out, err := reader.ReadBitsToSlice(bits); uint64(len(out)) > limits.MaxSliceLength {
+			t.Fatalf("ReadBitsToSlice() returned %d bytes, error = %v", len(out), err)
+		}
+		for i := 0; i < 16; i++ {
+			text, err := reader.ReadString()
+			if uint64(len(text)) > limits.MaxStringLength {
+				t.Fatalf("ReadString() returned %d bytes", len(text))
+			}
+			if err != nil {
+				break
+			}
+		}
+		if text, _ := reader.ReadStringLength(uint64(length)); uint64(len(text)) > limits.MaxStringLength {
+			t.Fatalf("ReadStringLength() returned %d bytes", len(text))
+		}
+		reader.SkipBits(uint64(bits) << 8)
+		if reader.BitPosition() > limits.MaxTotalBits {
+			t.Fatalf("Reader.BitPosition() = %d, over MaxTotalBits", reader.BitPosition())
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > maxAlloc {
+			t.Fatalf("allocated %d bytes, want at most %d", allocated, maxAlloc)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+// Package lzss decompresses Valve's LZSS format used by Source engine demos and
+// network packets from a bitreader.Reader.
+//
+// A compressed buffer starts with the "LZSS" magic and the little-endian 32-bit size
+// of the decompressed data. The data is a sequence of command bytes, each followed
+// by up to 8 items. A clear command bit is a literal byte, a set bit is a back
+// reference of 2 to 16 bytes reaching up to 4096 bytes back. A back reference with
+// a length of 1 ends the stream.
+package lzss
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pektezol/bitreader"
+)
+
+const (
+	magic      = "LZSS"
+	windowSize = 1 << 12
+	// maxPrealloc caps how much of the header size is trusted for preallocation
+	maxPrealloc = 1 << 20
+)
+
+var (
+	// ErrMagic is returned when the Reader is not positioned at the "LZSS" magic.
+	ErrMagic = errors.New("lzss: missing LZSS magic")
+	// ErrCorrupt is returned when a back reference reaches before the start of the data.
+	ErrCorrupt = errors.New("lzss: corrupt input")
+	// ErrSize is returned when the decompressed size doesn't match the header.
+	ErrSize = errors.New("lzss: decompressed size mismatch")
+)
+
+// Decompressor decompresses an LZSS stream incrementally.
+//
+// reader *bitreader.Reader		The Reader the compressed data is read from
+// size uint32					The decompressed size from the header
+// total uint32					Number of bytes decompressed so far
+// window [windowSize]byte		The last decompressed bytes, as a ring buffer
+// command byte					The remaining bits of the current command byte
+// commandBits uint8			Number of items left for the current command byte
+// pending []byte				Decompressed bytes not returned by Read yet
+// err error					The sticky error returned by Read
+type Decompressor struct {
+	reader      *bitreader.Reader
+	size        uint32
+	total       uint32
+	window      [windowSize]byte
+	command     byte
+	commandBits uint8
+	pending     []byte
+	buffer      [16]byte
+	err         error
+}
+
+// IsLZSS is a function that reports whether the Reader is positioned
+// at the "LZSS" magic, without consuming anything.
+func IsLZSS(reader *bitreader.Reader) bool {
+	value, err := reader.PeekBits(32)
+	if err != nil {
+		return false
+	}
+	return value == magicValue(reader.LittleEndian())
+}
+
+// NewDecompressor is the main constructor that reads the LZSS header at the current
+// Reader position and creates a Decompressor for the data that follows it.
+// The Reader doesn't need to be byte-aligned.
+//
+// Returns ErrMagic if the header doesn't start with "LZSS".
+func NewDecompressor(reader *bitreader.Reader) (*Decompressor, error) {
+	if !IsLZSS(reader) {
+		return nil, ErrMagic
+	}
+	if err := reader.SkipBits(32); err != nil {
+		return nil, err
+	}
+	var size uint32
+	for i := 0; i < 4; i++ {
+		value, err := reader.ReadBits(8)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		size |= uint32(value) << (8 * i)
+	}
+	return &Decompressor{reader: reader, size: size}, nil
+}
+
+// Decompress is a function that decompresses the LZSS buffer at the current Reader
+// position and returns a new Reader over the result with the same endianness.
+func Decompress(reader *bitreader.Reader) (*bitreader.Reader, error) {
+	data, err := DecompressBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bitreader.NewReaderFromBytes(data, reader.LittleEndian()), nil
+}
+
+// DecompressBytes is a function that decompresses the LZSS buffer
+// at the current Reader position and returns the result.
+func DecompressBytes(reader *bitreader.Reader) ([]byte, error) {
+	decompressor, err := NewDecompressor(reader)
+	if err != nil {
+		return nil, err
+	}
+	capacity := decompressor.size
+	if capacity > maxPrealloc {
+		capacity = maxPrealloc
+	}
+	out := make([]byte, 0, capacity)
+	buf := make([]byte, windowSize)
+	for {
+		n, err := decompressor.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Size is a function that returns the decompressed size stored in the header.
+func (decompressor *Decompressor) Size() uint32 {
+	return decompressor.size
+}
+
+// Read is a function that implements io.Reader over the decompressed data.
+// It returns ErrSize as soon as the output would exceed the header size, or at the
+// end of the stream if the output is shorter.
+func (decompressor *Decompressor) Read(p []byte) (int, error) {
+	for len(decompressor.pending) == 0 {
+		if decompressor.err != nil {
+			return 0, decompressor.err
+		}
+		decompressor.pending, decompressor.err = decompressor.next()
+	}
+	n := copy(p, decompressor.pending)
+	decompressor.pending = decompressor.pending[n:]
+	return n, nil
+}
+
+// next is a private function that decodes one literal or back reference.
+func (decompressor *Decompressor) next() ([]byte, error) {
+	reader := decompressor.reader
+	if decompressor.commandBits == 0 {
+		value, err := reader.ReadBits(8)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		decompressor.command = byte(value)
+		decompressor.commandBits = 8
+	}
+	reference := decompressor.command&1 == 1
+	decompressor.command >>= 1
+	decompressor.commandBits--
+	if !reference {
+		value, err := reader.ReadBits(8)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		decompressor.buffer[0] = byte(value)
+		return decompressor.emit(decompressor.buffer[:1])
+	}
+	high, err := reader.ReadBits(8)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	low, err := reader.ReadBits(8)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	position := uint32(high<<4 | low>>4)
+	count := int(low&0x0f) + 1
+	if count == 1 {
+		if decompressor.total != decompressor.size {
+			return nil, ErrSize
+		}
+		return nil, io.EOF
+	}
+	if position >= decompressor.total {
+		return nil, ErrCorrupt
+	}
+	// total never exceeds size, so the difference can't wrap
+	if uint32(count) > decompressor.size-decompressor.total {
+		return nil, ErrSize
+	}
+	// Copy byte by byte, a reference may overlap the bytes it produces
+	out := decompressor.buffer[:count]
+	source := decompressor.total - position - 1
+	for i := range out {
+		out[i] = decompressor.window[(source+uint32(i))%windowSize]
+		decompressor.window[(decompressor.total+uint32(i))%windowSize] = out[i]
+	}
+	decompressor.total += uint32(count)
+	return out, nil
+}
+
+// emit is a private function that records literal bytes in the window.
+func (decompressor *Decompressor) emit(data []byte) ([]byte, error) {
+	if uint32(len(data)) > decompressor.size-decompressor.total {
+		return nil, ErrSize
+	}
+	for _, value := range data {
+		decompressor.window[decompressor.total%windowSize] = value
+		decompressor.total++
+	}
+	return data, nil
+}
+
+// magicValue is a private function that returns the value PeekBits(32)
+// yields for the "LZSS" magic in the given bit order.
+func magicValue(littleEndian bool) uint64 {
+	var value uint64
+	for i := 0; i < len(magic); i++ {
+		if littleEndian {
+			value |= uint64(magic[i]) << (8 * i)
+		} else {
+			value = value<<8 | uint64(magic[i])
+		}
+	}
+	return value
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package lzss
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// compress is a naive Valve LZSS compressor used to produce test streams.
+func compress(data []byte) []byte {
+	out := []byte(magic)
+	size := len(data)
+	out = append(out, byte(size), byte(size>>8), byte(size>>16), byte(size>>24))
+	var command int
+	var items int
+	flush := func() {
+		command = len(out)
+		out = append(out, 0)
+	}
+	flush()
+	item := func(reference bool, payload ...byte) {
+		if items == 8 {
+			flush()
+			items = 0
+		}
+		if reference {
+			out[command] |= 1 << items
+		}
+		out = append(out, payload...)
+		items++
+	}
+	for i := 0; i < len(data); {
+		bestLength, bestPosition := 0, 0
+		for position := 0; position < windowSize && position < i; position++ {
+			source := i - position - 1
+			length := 0
+			for length < 16 && i+length < len(data) && data[source+length] == data[i+length] {
+				length++
+			}
+			if length > bestLength {
+				bestLength, bestPosition = length, position
+			}
+		}
+		if bestLength >= 2 {
+			item(true, byte(bestPosition>>4), byte(bestPosition<<4)|byte(bestLength-1))
+			i += bestLength
+		} else {
+			item(false, data[i])
+			i++
+		}
+	}
+	item(true, 0, 0)
+	return out
+}
+
+func TestDecompress(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 5000)
+	rng.Read(random)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty", data: []byte{}},
+		{name: "Text", data: bytes.Repeat([]byte("player_spawn\x00entity_killed\x00"), 300)},
+		{name: "Overlap", data: bytes.Repeat([]byte{0xaa}, 100)},
+		{name: "Random", data: random},
+	}
+	for _, tt := range tests {
+		for _, littleEndian := range []bool{true, false} {
+			name := tt.name + "BE"
+			if littleEndian {
+				name = tt.name + "LE"
+			}
+			t.Run(name, func(t *testing.T) {
+				// Prefix the buffer with 3 bits to check unaligned decompression
+				compressed := compress(tt.data)
+				stream := make([]byte, len(compressed)+1)
+				for i, value := range compressed {
+					if littleEndian {
+						stream[i] |= value << 3
+						stream[i+1] |= value >> 5
+					} else {
+						stream[i] |= value >> 3
+						stream[i+1] |= value << 5
+					}
+				}
+				reader := bitreader.NewReaderFromBytes(stream, littleEndian)
+				reader.SkipBits(3)
+				if !IsLZSS(reader) {
+					t.Fatalf("IsLZSS() = false, want true")
+				}
+				got, err := Decompress(reader)
+				if err != nil {
+					t.Fatalf("Decompress() error = %v", err)
+				}
+				if got.LittleEndian() != littleEndian {
+					t.Errorf("Decompress() LittleEndian() = %v, want %v", got.LittleEndian(), littleEndian)
+				}
+				data, _ := got.ReadBytesToSlice(uint64(len(tt.data)))
+				if !bytes.Equal(data, tt.data) {
+					t.Errorf("Decompress() output differs from input")
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressor_Read(t *testing.T) {
+	data := bytes.Repeat([]byte("svc_PacketEntities"), 1000)
+	decompressor, err := NewDecompressor(bitreader.NewReaderFromBytes(compress(data), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressor.Size() != uint32(len(data)) {
+		t.Errorf("Decompressor.Size() = %v, want %v", decompressor.Size(), len(data))
+	}
+	got, err := io.ReadAll(decompressor)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Decompressor.Read() error = %v, output equal %v", err, bytes.Equal(got, data))
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	wrongSize := compress([]byte("abc"))
+	wrongSize[4] = 4
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr error
+	}{
+		{name: "Magic", stream: []byte("LZSX\x00\x00\x00\x00"), wantErr: ErrMagic},
+		{name: "Size", stream: wrongSize, wantErr: ErrSize},
+		{name: "Literal past size", stream: []byte("LZSS\x01\x00\x00\x00\x00ab"), wantErr: ErrSize},
+		{name: "Reference past size", stream: []byte("LZSS\x02\x00\x00\x00\x02a\x00\x0f"), wantErr: ErrSize},
+		{name: "Reference", stream: []byte("LZSS\x02\x00\x00\x00\x01\x00\x11"), wantErr: ErrCorrupt},
+		{name: "Truncated", stream: []byte("LZSS\x02\x00\x00\x00\x00a"), wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecompressBytes(bitreader.NewReaderFromBytes(tt.stream, true)); err != tt.wantErr {
+				t.Errorf("DecompressBytes() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Package lzw decodes variable-width Lempel-Ziv-Welch streams from a bitreader.Reader.
+//
+// Codes start at litWidth+1 bits and grow up to 12 bits. The bit order follows
+// the Reader: little-endian (LSB-first) Readers decode GIF streams and big-endian
+// (MSB-first) Readers decode TIFF and PDF streams. TIFF and PDF (with EarlyChange 1)
+// grow the code width one code early, which compress/lzw does not support.
+package lzw
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pektezol/bitreader"
+)
+
+const (
+	maxWidth    = 12
+	tableSize   = 1 << maxWidth
+	invalidCode = 0xffff
+)
+
+// ErrCorrupt is returned when the stream contains a code that is not in the table yet.
+var ErrCorrupt = errors.New("lzw: corrupt input")
+
+// Decoder decodes an LZW stream.
+//
+// reader *bitreader.Reader		The Reader codes are read from
+// litWidth uint8				Number of bits in a literal code
+// earlyChange bool				Whether the code width grows one code early
+// width uint8					Current code width in bits
+// clear, eof uint16			The clear and end of information codes
+// hi uint16					The last code added to the table
+// overflow uint16				The first code that needs width+1 bits
+// last uint16					The previous code, or invalidCode right after a clear
+// prefix, suffix				The code table, each code is its prefix code plus a suffix byte
+// output []byte				Scratch space a code is expanded into, from the end
+// pending []byte				Decoded bytes of output not returned by Read yet
+// err error					The sticky error returned by Read
+type Decoder struct {
+	reader      *bitreader.Reader
+	litWidth    uint8
+	earlyChange bool
+	width       uint8
+	clear       uint16
+	eof         uint16
+	hi          uint16
+	overflow    uint16
+	last        uint16
+	prefix      [tableSize]uint16
+	suffix      [tableSize]uint8
+	output      [tableSize]byte
+	pending     []byte
+	err         error
+}
+
+// NewDecoder is the main constructor that creates a Decoder for the stream
+// starting at the current Reader position. litWidth is the number of bits in a
+// literal code, 8 for TIFF and PDF and 2 to 8 for GIF. Set earlyChange for TIFF
+// and for PDF streams with EarlyChange 1.
+//
+// Returns an error if litWidth is not between 2 and 8.
+func NewDecoder(reader *bitreader.Reader, litWidth int, earlyChange bool) (*Decoder, error) {
+	if litWidth < 2 || litWidth > 8 {
+		return nil, errors.New("lzw: literal width should be between 2 and 8")
+	}
+	decoder := &Decoder{
+		reader:      reader,
+		litWidth:    uint8(litWidth),
+		earlyChange: earlyChange,
+		clear:       1 << litWidth,
+		eof:         1<<litWidth + 1,
+	}
+	decoder.reset()
+	return decoder, nil
+}
+
+// Decode is a function that decodes a whole LZW stream up to the end of information code.
+func Decode(reader *bitreader.Reader, litWidth int, earlyChange bool) ([]byte, error) {
+	decoder, err := NewDecoder(reader, litWidth, earlyChange)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decoder)
+}
+
+// Width is a function that returns the current code width in bits.
+func (decoder *Decoder) Width() int {
+	return int(decoder.width)
+}
+
+// Read is a function that implements io.Reader over the decoded data.
+// It returns io.EOF after the end of information code.
+func (decoder *Decoder) Read(p []byte) (int, error) {
+	for len(decoder.pending) == 0 {
+		if decoder.err != nil {
+			return 0, decoder.err
+		}
+		decoder.pending, decoder.err = decoder.decodeCode()
+	}
+	n := copy(p, decoder.pending)
+	decoder.pending = decoder.pending[n:]
+	return n, nil
+}
+
+// reset is a private function that empties the code table after a clear code.
+func (decoder *Decoder) reset() {
+	decoder.width = decoder.litWidth + 1
+	decoder.hi = decoder.eof
+	decoder.overflow = 1 << decoder.width
+	decoder.last = invalidCode
+}
+
+// decodeCode is a private function that reads one code and returns its expansion.
+// The expansion is a slice of decoder.output, valid until the next call.
+func (decoder *Decoder) decodeCode() ([]byte, error) {
+	code64, err := decoder.reader.ReadBits(uint64(decoder.width))
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	code := uint16(code64)
+	var out []byte
+	switch {
+	case code < decoder.clear:
+		out = decoder.output[len(decoder.output)-1:]
+		out[0] = byte(code)
+		if decoder.last != invalidCode {
+			decoder.suffix[decoder.hi] = byte(code)
+			decoder.prefix[decoder.hi] = decoder.last
+		}
+	case code == decoder.clear:
+		decoder.reset()
+		return nil, nil
+	case code == decoder.eof:
+		return nil, io.EOF
+	case code <= decoder.hi:
+		c := code
+		i := len(decoder.output) - 1
+		if code == decoder.hi && decoder.last != invalidCode {
+			// The code is being defined right now, its suffix is its own first byte
+			c = decoder.last
+			for c >= decoder.clear {
+				c = decoder.prefix[c]
+			}
+			decoder.output[i] = byte(c)
+			i--
+			c = decoder.last
+		}
+		for c >= decoder.clear {
+			decoder.output[i] = decoder.suffix[c]
+			i--
+			c = decoder.prefix[c]
+		}
+		decoder.output[i] = byte(c)
+		if decoder.last != invalidCode {
+			decoder.suffix[decoder.hi] = byte(c)
+			decoder.prefix[decoder.hi] = decoder.last
+		}
+		out = decoder.output[i:]
+	default:
+		return nil, ErrCorrupt
+	}
+	decoder.last, decoder.hi = code, decoder.hi+1
+	overflow := decoder.overflow
+	if decoder.earlyChange {
+		overflow--
+	}
+	if decoder.hi >= overflow {
+		if decoder.width == maxWidth {
+			// The table is full, keep using 12-bit codes until the next clear code
+			decoder.last = invalidCode
+			decoder.hi--
+		} else {
+			decoder.width++
+			decoder.overflow <<= 1
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,176 @@
+package lzw
+
+import (
+	"bytes"
+	"compress/lzw"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// encodeEarlyChange is a minimal MSB-first encoder with the TIFF early change quirk,
+// mirroring compress/lzw.Writer otherwise.
+func encodeEarlyChange(data []byte) []byte {
+	var out []byte
+	var bits uint32
+	var count uint
+	write := func(code uint16, width uint) {
+		bits = bits<<width | uint32(code)
+		count += width
+		for count >= 8 {
+			out = append(out, byte(bits>>(count-8)))
+			count -= 8
+		}
+	}
+	const clear, eof = 256, 257
+	var hi, overflow uint16
+	var width uint
+	var table map[uint32]uint16
+	reset := func() {
+		hi, overflow, width = eof, 512, 9
+		table = map[uint32]uint16{}
+	}
+	incHi := func() bool {
+		hi++
+		if hi+1 == overflow {
+			width++
+			overflow <<= 1
+		}
+		if hi == tableSize-2 {
+			write(clear, width)
+			reset()
+			return false
+		}
+		return true
+	}
+	reset()
+	write(clear, width)
+	saved := uint16(invalidCode)
+	for _, b := range data {
+		if saved == invalidCode {
+			saved = uint16(b)
+			continue
+		}
+		key := uint32(saved)<<8 | uint32(b)
+		if code, ok := table[key]; ok {
+			saved = code
+			continue
+		}
+		write(saved, width)
+		saved = uint16(b)
+		if incHi() {
+			table[key] = hi
+		}
+	}
+	if saved != invalidCode {
+		write(saved, width)
+		incHi()
+	}
+	write(eof, width)
+	if count > 0 {
+		out = append(out, byte(bits<<(8-count)))
+	}
+	return out
+}
+
+func testData() map[string][]byte {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 30000)
+	rng.Read(random)
+	// Small alphabets fill the 4096 entry table quickly
+	small := make([]byte, 100000)
+	for i := range small {
+		small[i] = byte(rng.Intn(4))
+	}
+	return map[string][]byte{
+		"Empty":  {},
+		"Text":   bytes.Repeat([]byte("TOBEORNOTTOBEORTOBEORNOT"), 500),
+		"Random": random,
+		"Small":  small,
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for name, data := range testData() {
+		for _, order := range []lzw.Order{lzw.LSB, lzw.MSB} {
+			litWidth := 8
+			input := data
+			if name == "Small" {
+				litWidth = 2
+			}
+			var buf bytes.Buffer
+			writer := lzw.NewWriter(&buf, order, litWidth)
+			writer.Write(input)
+			writer.Close()
+			got, err := Decode(bitreader.NewReaderFromBytes(buf.Bytes(), order == lzw.LSB), litWidth, false)
+			if err != nil {
+				t.Fatalf("%s order %d: Decode() error = %v", name, order, err)
+			}
+			if !bytes.Equal(got, input) {
+				t.Errorf("%s order %d: Decode() output differs from input", name, order)
+			}
+		}
+	}
+}
+
+func TestDecodeEarlyChange(t *testing.T) {
+	for name, data := range testData() {
+		got, err := Decode(bitreader.NewReaderFromBytes(encodeEarlyChange(data), false), 8, true)
+		if err != nil {
+			t.Fatalf("%s: Decode() error = %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: Decode() output differs from input", name)
+		}
+	}
+}
+
+func TestDecoderAllocations(t *testing.T) {
+	var buf bytes.Buffer
+	writer := lzw.NewWriter(&buf, lzw.LSB, 8)
+	writer.Write(testData()["Text"])
+	writer.Close()
+	reader := bitreader.NewReaderFromBytes(buf.Bytes(), true)
+	decoder, err := NewDecoder(reader, 8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := decoder.Read(p); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Decoder.Read() allocations = %v, want 0", allocs)
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr error
+	}{
+		{
+			// Clear code followed by code 300, which is not defined yet
+			name:    "UndefinedCode",
+			stream:  []byte{0x80, 0x4b, 0x00},
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "Truncated",
+			stream:  []byte{0x80},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bitreader.NewReaderFromBytes(tt.stream, false), 8, true); err != tt.wantErr {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
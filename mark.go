@@ -0,0 +1,97 @@
+package bitreader
+
+import "errors"
+
+// Mark is a position of a Reader that it can be rewound to, for speculative parsing
+// without a Fork. While a Mark is live, the bytes the Reader reads from its stream are
+// kept in a replay buffer, so that it works on any io.Reader. Only the bytes since the
+// oldest live Mark are kept, so Marks should be released once they are not needed.
+//
+// reader *Reader		The Reader the Mark belongs to
+// position uint64		Position of the Reader at the Mark
+// index uint8			Index into the current byte at the Mark
+// currentByte byte		The current byte at the Mark
+// offset uint64		Number of bytes read from the stream before the Mark
+type Mark struct {
+	reader      *Reader
+	position    uint64
+	index       uint8
+	currentByte byte
+	offset      uint64
+}
+
+// Mark is a function that returns a Mark at the current bit position, that the Reader
+// can be rewound to with Rewind until it is released with Release. Forks of the Reader
+// don't inherit its Marks.
+func (reader *Reader) Mark() *Mark {
+	if len(reader.marks) == 0 {
+		reader.replay = reader.replay[:0]
+		reader.replayStart = 0
+	}
+	mark := &Mark{
+		reader:      reader,
+		position:    reader.position,
+		index:       reader.index,
+		currentByte: reader.currentByte,
+		offset:      reader.replayStart + uint64(len(reader.replay)),
+	}
+	reader.marks = append(reader.marks, mark)
+	return mark
+}
+
+// Rewind is a function that moves the Reader back to a Mark, so that the bits consumed
+// since are read again. Marks made after it are released, the Mark itself stays live.
+//
+// Returns an error if the Mark is not live, or if a Checksum is attached, since hashers
+// can't take back the bits they have seen.
+func (reader *Reader) Rewind(mark *Mark) error {
+	i := reader.findMark(mark)
+	if i < 0 {
+		return errors.New("Rewind(mark) ERROR: Mark is not live on this Reader")
+	}
+	if len(reader.checksums) > 0 {
+		return errors.New("Rewind(mark) ERROR: Can't rewind while a Checksum is attached")
+	}
+	reader.marks = reader.marks[:i+1]
+	// The bytes read since the Mark go back in front of the stream
+	kept := mark.offset - reader.replayStart
+	reader.unread(reader.replay[kept:])
+	reader.replay = reader.replay[:kept]
+	reader.position = mark.position
+	reader.index = mark.index
+	reader.currentByte = mark.currentByte
+	return nil
+}
+
+// Release is a function that releases a Mark, so that the replay buffer doesn't keep
+// the bytes read since it any longer than other Marks need them.
+//
+// Returns an error if the Mark is not live.
+func (reader *Reader) Release(mark *Mark) error {
+	i := reader.findMark(mark)
+	if i < 0 {
+		return errors.New("Release(mark) ERROR: Mark is not live on this Reader")
+	}
+	reader.marks = append(reader.marks[:i], reader.marks[i+1:]...)
+	if len(reader.marks) == 0 {
+		reader.marks = nil
+		reader.replay = reader.replay[:0]
+		return nil
+	}
+	// Marks are in the order they were made, so the first one is the oldest
+	if drop := reader.marks[0].offset - reader.replayStart; drop > 0 {
+		reader.replay = reader.replay[:copy(reader.replay, reader.replay[drop:])]
+		reader.replayStart += drop
+	}
+	return nil
+}
+
+// findMark is a private function that returns the index of a live Mark, or -1.
+func (reader *Reader) findMark(mark *Mark) int {
+	for i, live := range reader.marks {
+		if live == mark {
+			return i
+		}
+	}
+	return -1
+}
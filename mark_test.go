@@ -0,0 +1,167 @@
+package bitreader
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader_Rewind(t *testing.T) {
+	random := rand.New(rand.NewSource(49))
+	data := make([]byte, 4000)
+	random.Read(data)
+	for _, le := range []bool{true, false} {
+		for round := 0; round < 30; round++ {
+			var reader *Reader
+			if round%2 == 0 {
+				reader = NewReaderFromBytes(data, le)
+			} else {
+				// Not seekable, and never more than one byte at a time
+				reader = NewReader(iotest.OneByteReader(bytes.NewReader(data)), le)
+			}
+			reader.SkipBits(uint64(random.Intn(100)))
+			mark := reader.Mark()
+			position := reader.BitPosition()
+			// Read the same values twice, with every way of consuming bits in between
+			var first []uint64
+			for pass := 0; pass < 2; pass++ {
+				source := rand.New(rand.NewSource(int64(round)))
+				var values []uint64
+				for op := 0; op < 10; op++ {
+					switch source.Intn(5) {
+					case 0:
+						value, _ := reader.ReadBits(uint64(source.Intn(64) + 1))
+						values = append(values, value)
+					case 1:
+						reader.SkipBits(uint64(source.Intn(3000)))
+					case 2:
+						packed := make([]uint32, source.Intn(100))
+						reader.ReadPackedUint32s(packed, uint(source.Intn(32)+1))
+						for _, value := range packed {
+							values = append(values, uint64(value))
+						}
+					case 3:
+						skipped, _ := reader.FindBits(0x2d, 7)
+						values = append(values, skipped)
+					case 4:
+						value, _ := reader.PeekBits(uint64(source.Intn(64) + 1))
+						values = append(values, value)
+					}
+					values = append(values, reader.BitPosition())
+				}
+				if pass == 0 {
+					first = values
+					if err := reader.Rewind(mark); err != nil {
+						t.Fatalf("Rewind() error = %v", err)
+					}
+					if reader.BitPosition() != position {
+						t.Fatalf("BitPosition() = %d after Rewind(), want %d", reader.BitPosition(), position)
+					}
+				} else if !equalUint64s(values, first) {
+					t.Errorf("le %v, round %d: values after Rewind() differ", le, round)
+				}
+			}
+			if err := reader.Release(mark); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+			// And the Reader carries on with the rest of the stream
+			reference := NewReaderFromBytes(data, le)
+			reference.SkipBits(reader.BitPosition())
+			want, wantErr := reference.ReadBits(40)
+			if got, err := reader.ReadBits(40); got != want || err != wantErr {
+				t.Errorf("ReadBits() after Release() = %#x, %v, want %#x, %v", got, err, want, wantErr)
+			}
+		}
+	}
+}
+
+func equalUint64s(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReader_MarkStack(t *testing.T) {
+	data := []byte("try A, then B, then C")
+	reader := NewReader(iotest.OneByteReader(bytes.NewReader(data)), false)
+	reader.SkipBits(3)
+	outer := reader.Mark()
+	reader.SkipBytes(4)
+	inner := reader.Mark()
+	reader.SkipBytes(6)
+	// Rewinding to the outer Mark releases the inner one
+	if err := reader.Rewind(outer); err != nil {
+		t.Fatalf("Rewind() error = %v", err)
+	}
+	if err := reader.Rewind(inner); err == nil {
+		t.Errorf("Rewind() to a released Mark error = nil")
+	}
+	if err := reader.Release(inner); err == nil {
+		t.Errorf("Release() of a released Mark error = nil")
+	}
+	// The outer Mark is still live
+	reader.SkipBytes(2)
+	if err := reader.Rewind(outer); err != nil {
+		t.Fatalf("second Rewind() error = %v", err)
+	}
+	if got := reader.TryReadBits(13); got != uint64(data[0])<<8&0x1f00|uint64(data[1]) {
+		t.Errorf("ReadBits() after Rewind() = %#x", got)
+	}
+	if err := reader.Release(outer); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := reader.Rewind(outer); err == nil {
+		t.Errorf("Rewind() to a released Mark error = nil")
+	}
+	fork, _ := reader.Fork()
+	if err := fork.Release(reader.Mark()); err == nil {
+		t.Errorf("Release() of another Reader's Mark error = nil")
+	}
+}
+
+func TestReader_MarkReplayBound(t *testing.T) {
+	data := make([]byte, 10000)
+	reader := NewReader(iotest.OneByteReader(bytes.NewReader(data)), true)
+	first := reader.Mark()
+	reader.SkipBytes(3000)
+	second := reader.Mark()
+	reader.SkipBytes(1000)
+	if len(reader.replay) != 4000 {
+		t.Errorf("replay holds %d bytes, want 4000", len(reader.replay))
+	}
+	// Only the bytes since the oldest live Mark are kept
+	reader.Release(first)
+	if len(reader.replay) != 1000 {
+		t.Errorf("replay holds %d bytes after releasing the oldest Mark, want 1000", len(reader.replay))
+	}
+	if err := reader.Rewind(second); err != nil || reader.BitPosition() != 3000*8 {
+		t.Errorf("Rewind() = %v, BitPosition() = %d, want 24000", err, reader.BitPosition())
+	}
+	reader.Release(second)
+	reader.SkipBytes(2000)
+	if len(reader.replay) != 0 || reader.marks != nil {
+		t.Errorf("replay holds %d bytes without Marks", len(reader.replay))
+	}
+}
+
+func TestReader_RewindChecksum(t *testing.T) {
+	reader := NewReaderFromBytes([]byte{1, 2, 3}, false)
+	mark := reader.Mark()
+	crc, _ := NewCRC(CRC8)
+	checksum := reader.BeginChecksum(crc)
+	reader.SkipBits(9)
+	if err := reader.Rewind(mark); err == nil {
+		t.Errorf("Rewind() with a Checksum attached error = nil")
+	}
+	reader.EndChecksum(checksum)
+	if err := reader.Rewind(mark); err != nil || reader.BitPosition() != 0 {
+		t.Errorf("Rewind() = %v, BitPosition() = %d", err, reader.BitPosition())
+	}
+}
@@ -0,0 +1,130 @@
+package mpegaudio
+
+import (
+	"time"
+
+	"github.com/pektezol/bitreader"
+)
+
+// ADTSHeader is the header of an ADTS frame, the transport of AAC in .aac files and MPEG-TS.
+//
+// MPEG2 bool					Whether the ID bit marks MPEG-2 AAC instead of MPEG-4
+// Protected bool				Whether a 16-bit CRC follows the header
+// Profile uint8				AAC profile, the MPEG-4 audio object type minus one, 1 is AAC LC
+// SampleRateIndex uint8		Index into the MPEG-4 sample rate table
+// SampleRate int				Sample rate in Hz
+// Private bool					The private bit
+// ChannelConfiguration uint8	MPEG-4 channel configuration, 0 means it is in the stream
+// Original bool				Whether the audio is an original
+// Home bool					The home bit
+// CopyrightBit bool			The current bit of the copyright identifier
+// CopyrightStart bool			Whether the copyright identifier starts in this frame
+// FrameLength int				Length of the frame in bytes, header included
+// BufferFullness uint16		Decoder buffer fullness, 0x7FF for VBR
+// RawDataBlocks int			Number of AAC raw data blocks in the frame
+// CRC uint16					The CRC of the header, if it is protected
+type ADTSHeader struct {
+	MPEG2                bool
+	Protected            bool
+	Profile              uint8
+	SampleRateIndex      uint8
+	SampleRate           int
+	Private              bool
+	ChannelConfiguration uint8
+	Original             bool
+	Home                 bool
+	CopyrightBit         bool
+	CopyrightStart       bool
+	FrameLength          int
+	BufferFullness       uint16
+	RawDataBlocks        int
+	CRC                  uint16
+}
+
+// adtsSampleRates are the MPEG-4 sample rates in Hz by index, the rest are reserved.
+var adtsSampleRates = [13]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// ParseADTSHeader is a function that reads the 7 byte ADTS header at the current Reader
+// position, and the CRC after it if the frame is protected. The Reader doesn't need to be
+// byte-aligned. The header is only consumed if it is valid.
+//
+// Returns ErrSync if there is no sync word, ErrHeader if a field has a reserved value.
+func ParseADTSHeader(reader *bitreader.Reader) (*ADTSHeader, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	value, err := reader.PeekBits(56)
+	if err != nil {
+		return nil, err
+	}
+	header, err := decodeADTSHeader(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := reader.SkipBits(56); err != nil {
+		return nil, err
+	}
+	if header.Protected {
+		crc, err := reader.ReadBits(16)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		header.CRC = uint16(crc)
+	}
+	return header, nil
+}
+
+// decodeADTSHeader is a private function that decodes the fields of a 56-bit ADTS header.
+func decodeADTSHeader(value uint64) (*ADTSHeader, error) {
+	if value>>44 != 0xfff {
+		return nil, ErrSync
+	}
+	header := &ADTSHeader{
+		MPEG2:                value>>43&1 == 1,
+		Protected:            value>>40&1 == 0,
+		Profile:              uint8(value >> 38 & 0x3),
+		SampleRateIndex:      uint8(value >> 34 & 0xf),
+		Private:              value>>33&1 == 1,
+		ChannelConfiguration: uint8(value >> 30 & 0x7),
+		Original:             value>>29&1 == 1,
+		Home:                 value>>28&1 == 1,
+		CopyrightBit:         value>>27&1 == 1,
+		CopyrightStart:       value>>26&1 == 1,
+		FrameLength:          int(value >> 13 & 0x1fff),
+		BufferFullness:       uint16(value >> 2 & 0x7ff),
+		RawDataBlocks:        int(value&0x3) + 1,
+	}
+	// The layer is always 0, it tells ADTS apart from MPEG audio
+	if value>>41&0x3 != 0 || int(header.SampleRateIndex) >= len(adtsSampleRates) || header.FrameLength < header.HeaderLength() {
+		return nil, ErrHeader
+	}
+	header.SampleRate = adtsSampleRates[header.SampleRateIndex]
+	return header, nil
+}
+
+// HeaderLength is a function that returns the length of the header in bytes, CRC included.
+func (header *ADTSHeader) HeaderLength() int {
+	if header.Protected {
+		return 9
+	}
+	return 7
+}
+
+// Samples is a function that returns the number of samples per channel in the frame.
+func (header *ADTSHeader) Samples() int {
+	return 1024 * header.RawDataBlocks
+}
+
+// Channels is a function that returns the number of channels, or 0 if the
+// channel configuration is in the stream.
+func (header *ADTSHeader) Channels() int {
+	if header.ChannelConfiguration == 7 {
+		return 8
+	}
+	return int(header.ChannelConfiguration)
+}
+
+// Duration is a function that returns how long the frame plays.
+func (header *ADTSHeader) Duration() time.Duration {
+	return samplesDuration(uint64(header.Samples()), header.SampleRate)
+}
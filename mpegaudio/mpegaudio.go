@@ -0,0 +1,242 @@
+// Package mpegaudio parses MPEG audio (MP3, MPEG-1, 2 and 2.5 Layer I, II and III) and
+// ADTS AAC frame headers from a big-endian bitreader.Reader.
+//
+// Both formats are a sequence of frames that start with a sync word of at least 11 set
+// bits, followed by a header that gives the length of the frame. ParseHeader and
+// ParseADTSHeader read one header, and a Scanner syncs onto a stream and walks its
+// frames, skipping ID3v2 tags and junk, and reads the Xing and VBRI headers of VBR MP3s.
+package mpegaudio
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/pektezol/bitreader"
+)
+
+var (
+	// ErrLittleEndian is returned when the Reader is not in big-endian mode.
+	ErrLittleEndian = errors.New("mpegaudio: reader should be big-endian")
+	// ErrSync is returned when the Reader is not at a frame sync word.
+	ErrSync = errors.New("mpegaudio: no frame sync")
+	// ErrHeader is returned when a frame header has a reserved or invalid value.
+	ErrHeader = errors.New("mpegaudio: invalid frame header")
+	// ErrFreeFormat is returned by a Scanner for free format MPEG audio, which has no frame length in its header.
+	ErrFreeFormat = errors.New("mpegaudio: free format bitrate is not supported")
+)
+
+// Version is the MPEG version of an MPEG audio frame.
+type Version uint8
+
+const (
+	MPEG1  Version = iota // MPEG-1, ISO/IEC 11172-3
+	MPEG2                 // MPEG-2 low sampling frequencies, ISO/IEC 13818-3
+	MPEG25                // MPEG-2.5, the unofficial extension to 8 kHz
+)
+
+// Layer is the layer of an MPEG audio frame.
+type Layer uint8
+
+const (
+	Layer1 Layer = 1 // Layer I
+	Layer2 Layer = 2 // Layer II, MP2
+	Layer3 Layer = 3 // Layer III, MP3
+)
+
+// ChannelMode is the channel mode of an MPEG audio frame.
+type ChannelMode uint8
+
+const (
+	Stereo      ChannelMode = iota // Two independent channels
+	JointStereo                    // Two channels coded together, see ModeExtension
+	DualChannel                    // Two unrelated mono channels
+	Mono                           // One channel
+)
+
+// Header is an MPEG audio frame header.
+//
+// Version Version			MPEG version
+// Layer Layer				Layer I, II or III
+// Protected bool			Whether a 16-bit CRC follows the header
+// BitrateIndex uint8		Index into the bitrate table, 0 is free format
+// Bitrate int				Bitrate in bits per second, 0 for free format
+// SampleRateIndex uint8	Index into the sample rate table
+// SampleRate int			Sample rate in Hz
+// Padding bool				Whether the frame has one extra slot
+// Private bool				The private bit
+// ChannelMode ChannelMode	Channel mode
+// ModeExtension uint8		Joint stereo coding, the bands for Layers I and II, intensity and M/S stereo for Layer III
+// Copyright bool			Whether the audio is copyrighted
+// Original bool			Whether the audio is an original
+// Emphasis uint8			De-emphasis to apply, 0 for none
+type Header struct {
+	Version         Version
+	Layer           Layer
+	Protected       bool
+	BitrateIndex    uint8
+	Bitrate         int
+	SampleRateIndex uint8
+	SampleRate      int
+	Padding         bool
+	Private         bool
+	ChannelMode     ChannelMode
+	ModeExtension   uint8
+	Copyright       bool
+	Original        bool
+	Emphasis        uint8
+}
+
+// bitrates are the bitrates in kbit/s by MPEG-1 or not, layer and index.
+var bitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// sampleRates are the sample rates in Hz by version and index.
+var sampleRates = [3][3]int{
+	{44100, 48000, 32000},
+	{22050, 24000, 16000},
+	{11025, 12000, 8000},
+}
+
+// ParseHeader is a function that reads the 4 byte MPEG audio frame header at the current
+// Reader position. The Reader doesn't need to be byte-aligned. The header is only consumed
+// if it is valid, so the caller can move on and look for the next sync word.
+//
+// Returns ErrSync if there is no sync word, ErrHeader if a field has a reserved value.
+func ParseHeader(reader *bitreader.Reader) (*Header, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	value, err := reader.PeekBits(32)
+	if err != nil {
+		return nil, err
+	}
+	header, err := decodeHeader(uint32(value))
+	if err != nil {
+		return nil, err
+	}
+	return header, reader.SkipBits(32)
+}
+
+// decodeHeader is a private function that decodes the fields of a 32-bit MPEG audio header.
+func decodeHeader(value uint32) (*Header, error) {
+	if value>>21 != 0x7ff {
+		return nil, ErrSync
+	}
+	header := &Header{
+		Protected:       value>>16&1 == 0,
+		BitrateIndex:    uint8(value >> 12 & 0xf),
+		SampleRateIndex: uint8(value >> 10 & 0x3),
+		Padding:         value>>9&1 == 1,
+		Private:         value>>8&1 == 1,
+		ChannelMode:     ChannelMode(value >> 6 & 0x3),
+		ModeExtension:   uint8(value >> 4 & 0x3),
+		Copyright:       value>>3&1 == 1,
+		Original:        value>>2&1 == 1,
+		Emphasis:        uint8(value & 0x3),
+	}
+	switch value >> 19 & 0x3 {
+	case 0:
+		header.Version = MPEG25
+	case 2:
+		header.Version = MPEG2
+	case 3:
+		header.Version = MPEG1
+	default:
+		return nil, ErrHeader
+	}
+	// The layer bits count down, 0 is reserved and the sync word of ADTS
+	layer := value >> 17 & 0x3
+	if layer == 0 || header.BitrateIndex == 15 || header.SampleRateIndex == 3 || header.Emphasis == 2 {
+		return nil, ErrHeader
+	}
+	header.Layer = Layer(4 - layer)
+	table := 0
+	if header.Version != MPEG1 {
+		table = 1
+	}
+	header.Bitrate = bitrates[table][header.Layer-1][header.BitrateIndex] * 1000
+	header.SampleRate = sampleRates[header.Version][header.SampleRateIndex]
+	return header, nil
+}
+
+// FrameLength is a function that returns the length of the frame in bytes, header
+// included, or 0 for free format.
+func (header *Header) FrameLength() int {
+	padding := 0
+	if header.Padding {
+		padding = 1
+	}
+	switch {
+	case header.Layer == Layer1:
+		return (12*header.Bitrate/header.SampleRate + padding) * 4
+	case header.Layer == Layer3 && header.Version != MPEG1:
+		return 72*header.Bitrate/header.SampleRate + padding
+	}
+	return 144*header.Bitrate/header.SampleRate + padding
+}
+
+// Samples is a function that returns the number of samples per channel in the frame.
+func (header *Header) Samples() int {
+	switch {
+	case header.Layer == Layer1:
+		return 384
+	case header.Layer == Layer3 && header.Version != MPEG1:
+		return 576
+	}
+	return 1152
+}
+
+// Channels is a function that returns the number of channels.
+func (header *Header) Channels() int {
+	if header.ChannelMode == Mono {
+		return 1
+	}
+	return 2
+}
+
+// Duration is a function that returns how long the frame plays.
+func (header *Header) Duration() time.Duration {
+	return samplesDuration(uint64(header.Samples()), header.SampleRate)
+}
+
+// SideInfoLength is a function that returns the length in bytes of the Layer III side
+// information after the header and CRC, where a Xing header is placed, or 0 for other layers.
+func (header *Header) SideInfoLength() int {
+	switch {
+	case header.Layer != Layer3:
+		return 0
+	case header.Version == MPEG1 && header.ChannelMode == Mono:
+		return 17
+	case header.Version == MPEG1:
+		return 32
+	case header.ChannelMode == Mono:
+		return 9
+	}
+	return 17
+}
+
+// samplesDuration is a private function that returns the duration of samples at a sample rate,
+// without overflowing for long streams.
+func samplesDuration(samples uint64, sampleRate int) time.Duration {
+	rate := uint64(sampleRate)
+	return time.Duration(samples/rate)*time.Second + time.Duration(samples%rate)*time.Second/time.Duration(rate)
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,363 @@
+package mpegaudio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pektezol/bitreader"
+)
+
+// There are no encoders in the test environment, so the streams are built from headers
+// worked out by hand from ISO/IEC 11172-3, 13818-3 and 13818-7.
+const (
+	mpeg1Layer3  = 0xfffb9064 // MPEG-1 Layer III, 128 kbit/s, 44100 Hz, joint stereo, 417 bytes
+	mpeg1Layer3P = 0xfffb9264 // The same with padding, 418 bytes
+	mpeg2Layer3  = 0xfff388c4 // MPEG-2 Layer III, 64 kbit/s, 16000 Hz, mono, 288 bytes
+)
+
+// mpegFrame builds an MPEG audio frame with a body of fill bytes.
+func mpegFrame(t *testing.T, value uint32, fill byte) []byte {
+	t.Helper()
+	header, err := decodeHeader(value)
+	if err != nil {
+		t.Fatalf("decodeHeader(%#x) error = %v", value, err)
+	}
+	frame := make([]byte, header.FrameLength())
+	binary.BigEndian.PutUint32(frame, value)
+	for i := 4; i < len(frame); i++ {
+		frame[i] = fill
+	}
+	return frame
+}
+
+// adtsFrame builds an AAC LC ADTS frame at 44100 Hz with two channels.
+func adtsFrame(length int, protected bool, blocks int) []byte {
+	absent := uint64(1)
+	if protected {
+		absent = 0
+	}
+	value := uint64(0xfff)<<44 | absent<<40 | 1<<38 | 4<<34 | 2<<30 | uint64(length)<<13 | 0x7ff<<2 | uint64(blocks-1)
+	frame := make([]byte, length)
+	for i := 0; i < 7; i++ {
+		frame[i] = byte(value >> (48 - 8*i))
+	}
+	return frame
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    uint32
+		want     *Header
+		length   int
+		samples  int
+		sideInfo int
+		wantErr  error
+	}{
+		{
+			name:  "MPEG1Layer3",
+			value: mpeg1Layer3,
+			want: &Header{
+				Version: MPEG1, Layer: Layer3, BitrateIndex: 9, Bitrate: 128000, SampleRate: 44100,
+				ChannelMode: JointStereo, ModeExtension: 2, Original: true,
+			},
+			length: 417, samples: 1152, sideInfo: 32,
+		},
+		{
+			name:  "Padding",
+			value: mpeg1Layer3P,
+			want: &Header{
+				Version: MPEG1, Layer: Layer3, BitrateIndex: 9, Bitrate: 128000, SampleRate: 44100, Padding: true,
+				ChannelMode: JointStereo, ModeExtension: 2, Original: true,
+			},
+			length: 418, samples: 1152, sideInfo: 32,
+		},
+		{
+			name:  "MPEG2Layer3",
+			value: mpeg2Layer3,
+			want: &Header{
+				Version: MPEG2, Layer: Layer3, BitrateIndex: 8, Bitrate: 64000, SampleRateIndex: 2, SampleRate: 16000,
+				ChannelMode: Mono, Original: true,
+			},
+			length: 288, samples: 576, sideInfo: 9,
+		},
+		{
+			name:    "MPEG25Layer3",
+			value:   0xffe318c0,
+			want:    &Header{Version: MPEG25, Layer: Layer3, BitrateIndex: 1, Bitrate: 8000, SampleRateIndex: 2, SampleRate: 8000, ChannelMode: Mono},
+			length:  72,
+			samples: 576, sideInfo: 9,
+		},
+		{
+			name:    "MPEG1Layer1",
+			value:   0xffffd200,
+			want:    &Header{Version: MPEG1, Layer: Layer1, BitrateIndex: 13, Bitrate: 416000, SampleRate: 44100, Padding: true},
+			length:  456,
+			samples: 384,
+		},
+		{
+			name:    "MPEG1Layer2Protected",
+			value:   0xfffca480,
+			want:    &Header{Version: MPEG1, Layer: Layer2, Protected: true, BitrateIndex: 10, Bitrate: 192000, SampleRateIndex: 1, SampleRate: 48000, ChannelMode: DualChannel},
+			length:  576,
+			samples: 1152,
+		},
+		{name: "NoSync", value: 0xff7b9064, wantErr: ErrSync},
+		{name: "ReservedVersion", value: 0xffeb9064, wantErr: ErrHeader},
+		{name: "ReservedLayer", value: 0xfff99064, wantErr: ErrHeader},
+		{name: "BadBitrate", value: 0xfffbf064, wantErr: ErrHeader},
+		{name: "ReservedSampleRate", value: 0xfffb9c64, wantErr: ErrHeader},
+		{name: "ReservedEmphasis", value: 0xfffb9066, wantErr: ErrHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := make([]byte, 5)
+			binary.BigEndian.PutUint32(stream, tt.value)
+			reader := bitreader.NewReaderFromBytes(stream, false)
+			got, err := ParseHeader(reader)
+			if err != tt.wantErr {
+				t.Fatalf("ParseHeader() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if reader.BitPosition() != 0 {
+					t.Errorf("ParseHeader() consumed %d bits of an invalid header", reader.BitPosition())
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeader() = %+v, want %+v", got, tt.want)
+			}
+			if got.FrameLength() != tt.length || got.Samples() != tt.samples || got.SideInfoLength() != tt.sideInfo {
+				t.Errorf("FrameLength(), Samples(), SideInfoLength() = %d, %d, %d, want %d, %d, %d",
+					got.FrameLength(), got.Samples(), got.SideInfoLength(), tt.length, tt.samples, tt.sideInfo)
+			}
+		})
+	}
+	if _, err := ParseHeader(bitreader.NewReaderFromBytes([]byte{0xff, 0xfb, 0x90, 0x64}, true)); err != ErrLittleEndian {
+		t.Errorf("ParseHeader() on little-endian error = %v, want %v", err, ErrLittleEndian)
+	}
+}
+
+func TestHeader_Duration(t *testing.T) {
+	header, _ := decodeHeader(mpeg1Layer3)
+	if got, want := header.Duration(), 1152*time.Second/44100; got != want {
+		t.Errorf("Duration() = %v, want %v", got, want)
+	}
+	if got, want := samplesDuration(1152*100000, 44100), 2612244897959*time.Nanosecond; got != want {
+		t.Errorf("samplesDuration() = %v, want %v", got, want)
+	}
+}
+
+func TestParseADTSHeader(t *testing.T) {
+	// A common AAC LC header, 371 bytes at 44100 Hz in stereo
+	reader := bitreader.NewReaderFromBytes([]byte{0xff, 0xf1, 0x50, 0x80, 0x2e, 0x7f, 0xfc, 0xaa}, false)
+	got, err := ParseADTSHeader(reader)
+	if err != nil {
+		t.Fatalf("ParseADTSHeader() error = %v", err)
+	}
+	want := &ADTSHeader{
+		Profile: 1, SampleRateIndex: 4, SampleRate: 44100, ChannelConfiguration: 2,
+		FrameLength: 371, BufferFullness: 0x7ff, RawDataBlocks: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseADTSHeader() = %+v, want %+v", got, want)
+	}
+	if got.Samples() != 1024 || got.Channels() != 2 || reader.BitPosition() != 56 {
+		t.Errorf("Samples(), Channels(), BitPosition() = %d, %d, %d, want 1024, 2, 56", got.Samples(), got.Channels(), reader.BitPosition())
+	}
+	// A protected frame with 4 raw data blocks has its CRC read
+	frame := adtsFrame(100, true, 4)
+	frame[7], frame[8] = 0x12, 0x34
+	reader = bitreader.NewReaderFromBytes(frame, false)
+	if got, err = ParseADTSHeader(reader); err != nil {
+		t.Fatalf("ParseADTSHeader() error = %v", err)
+	}
+	if !got.Protected || got.CRC != 0x1234 || got.HeaderLength() != 9 || got.Samples() != 4096 || reader.BitPosition() != 72 {
+		t.Errorf("ParseADTSHeader() = %+v at %d", got, reader.BitPosition())
+	}
+	for name, stream := range map[string][]byte{
+		"Layer":       {0xff, 0xf3, 0x50, 0x80, 0x2e, 0x7f, 0xfc},
+		"SampleRate":  {0xff, 0xf1, 0x74, 0x80, 0x2e, 0x7f, 0xfc},
+		"ShortLength": {0xff, 0xf1, 0x50, 0x80, 0x00, 0xdf, 0xfc},
+	} {
+		if _, err := ParseADTSHeader(bitreader.NewReaderFromBytes(stream, false)); err != ErrHeader {
+			t.Errorf("%s: ParseADTSHeader() error = %v, want %v", name, err, ErrHeader)
+		}
+	}
+}
+
+// xingFrame builds the Xing frame of an MPEG-1 Layer III stream.
+func xingFrame(t *testing.T, frames, size uint32) []byte {
+	t.Helper()
+	frame := mpegFrame(t, mpeg1Layer3, 0)
+	xing := frame[4+32:]
+	copy(xing, "Xing")
+	binary.BigEndian.PutUint32(xing[4:], XingFrames|XingBytes|XingTOC|XingQuality)
+	binary.BigEndian.PutUint32(xing[8:], frames)
+	binary.BigEndian.PutUint32(xing[12:], size)
+	for i := 0; i < 100; i++ {
+		xing[16+i] = byte(i * 256 / 100)
+	}
+	binary.BigEndian.PutUint32(xing[116:], 57)
+	return frame
+}
+
+func TestScanner(t *testing.T) {
+	var stream []byte
+	// An ID3v2 tag with a sync word in it, which is skipped as a whole
+	stream = append(stream, 'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20)
+	stream = append(stream, bytes.Repeat([]byte{0xff}, 20)...)
+	// Junk with an invalid header
+	stream = append(stream, 0x00, 0xff, 0xfb, 0xf0, 0x00)
+	stream = append(stream, xingFrame(t, 4, 1668)...)
+	// Frame data full of sync words, then junk and a header of another version between frames
+	stream = append(stream, mpegFrame(t, mpeg1Layer3, 0xff)...)
+	stream = append(stream, mpegFrame(t, mpeg1Layer3P, 0xff)...)
+	stream = append(stream, 0x12, 0xff, 0xf3, 0x88, 0xc4, 0x34)
+	stream = append(stream, mpegFrame(t, mpeg1Layer3, 0xff)...)
+	stream = append(stream, mpegFrame(t, mpeg1Layer3, 0x00)...)
+	// An ID3v1 tag at the end
+	stream = append(stream, append([]byte("TAG"), make([]byte, 125)...)...)
+	for offset := uint64(0); offset < 8; offset += 3 {
+		// Frames are found on the byte boundaries of the start
+		shifted := make([]byte, len(stream)+1)
+		for i, b := range stream {
+			shifted[i] |= b >> offset
+			shifted[i+1] |= b << (8 - offset)
+		}
+		if offset == 0 {
+			shifted = stream
+		}
+		reader := bitreader.NewReaderFromBytes(shifted, false)
+		reader.SkipBits(offset)
+		scanner, err := NewScanner(reader)
+		if err != nil {
+			t.Fatalf("NewScanner() error = %v", err)
+		}
+		var offsets []uint64
+		var lengths []int
+		for {
+			frame, err := scanner.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Offset=%d: Next() error = %v", offset, err)
+			}
+			offsets = append(offsets, (frame.Offset-offset)/8)
+			lengths = append(lengths, frame.Length)
+			if len(offsets) == 1 && frame.Xing == nil {
+				t.Fatalf("Offset=%d: first frame has no Xing header", offset)
+			}
+		}
+		if want := []uint64{35, 452, 869, 1293, 1710}; !reflect.DeepEqual(offsets, want) {
+			t.Errorf("Offset=%d: frame offsets = %v, want %v", offset, offsets, want)
+		}
+		if want := []int{417, 417, 418, 417, 417}; !reflect.DeepEqual(lengths, want) {
+			t.Errorf("Offset=%d: frame lengths = %v, want %v", offset, lengths, want)
+		}
+		if scanner.Frames() != 4 || scanner.Duration() != 4*1152*time.Second/44100 {
+			t.Errorf("Offset=%d: Frames(), Duration() = %d, %v", offset, scanner.Frames(), scanner.Duration())
+		}
+		// 5 bytes of junk before the first frame, 6 between frames, and the ID3v1 tag
+		if scanner.Skipped() != 5+6+128 {
+			t.Errorf("Offset=%d: Skipped() = %d, want %d", offset, scanner.Skipped(), 5+6+128)
+		}
+		xing := scanner.Xing()
+		if xing == nil || xing.Tag != "Xing" || xing.Frames != 4 || xing.Bytes != 1668 || len(xing.TOC) != 100 || xing.TOC[50] != 128 || xing.Quality != 57 {
+			t.Fatalf("Offset=%d: Xing() = %+v", offset, xing)
+		}
+		header, _ := decodeHeader(mpeg1Layer3)
+		if xing.Duration(header) != scanner.Duration() {
+			t.Errorf("Offset=%d: Xing().Duration() = %v, want %v", offset, xing.Duration(header), scanner.Duration())
+		}
+	}
+}
+
+func TestScanner_VBRI(t *testing.T) {
+	first := mpegFrame(t, mpeg2Layer3, 0)
+	vbri := first[4+32:]
+	copy(vbri, "VBRI")
+	binary.BigEndian.PutUint16(vbri[4:], 1)
+	binary.BigEndian.PutUint16(vbri[6:], 1104)
+	binary.BigEndian.PutUint16(vbri[8:], 75)
+	binary.BigEndian.PutUint32(vbri[10:], 576)
+	binary.BigEndian.PutUint32(vbri[14:], 2)
+	stream := append(first, append(mpegFrame(t, mpeg2Layer3, 1), mpegFrame(t, mpeg2Layer3, 2)...)...)
+	scanner, _ := NewScanner(bitreader.NewReaderFromBytes(stream, false))
+	for {
+		if _, err := scanner.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+	}
+	want := &VBRIHeader{Version: 1, Delay: 1104, Quality: 75, Bytes: 576, Frames: 2}
+	if !reflect.DeepEqual(scanner.VBRI(), want) || scanner.Xing() != nil {
+		t.Errorf("VBRI(), Xing() = %+v, %+v, want %+v, nil", scanner.VBRI(), scanner.Xing(), want)
+	}
+	if scanner.Frames() != 2 || scanner.Duration() != 2*576*time.Second/16000 {
+		t.Errorf("Frames(), Duration() = %d, %v", scanner.Frames(), scanner.Duration())
+	}
+}
+
+func TestScanner_ADTS(t *testing.T) {
+	var stream []byte
+	stream = append(stream, adtsFrame(371, false, 1)...)
+	stream = append(stream, 0xff, 0xff)
+	stream = append(stream, adtsFrame(200, true, 2)...)
+	stream = append(stream, adtsFrame(9, true, 1)...)
+	scanner, _ := NewScanner(bitreader.NewReaderFromBytes(stream, false))
+	var samples []int
+	for {
+		frame, err := scanner.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if frame.ADTS == nil || frame.MPEG != nil {
+			t.Fatalf("Next() = %+v, want an ADTS frame", frame)
+		}
+		samples = append(samples, frame.Samples())
+	}
+	if !reflect.DeepEqual(samples, []int{1024, 2048, 1024}) {
+		t.Errorf("Samples() = %v, want [1024 2048 1024]", samples)
+	}
+	if scanner.Duration() != 4096*time.Second/44100 || scanner.Skipped() != 2 {
+		t.Errorf("Duration(), Skipped() = %v, %d", scanner.Duration(), scanner.Skipped())
+	}
+}
+
+func TestScanner_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr error
+	}{
+		{name: "Truncated", stream: mpegFrame(t, mpeg1Layer3, 0)[:300], wantErr: io.ErrUnexpectedEOF},
+		{name: "FreeFormat", stream: []byte{0xff, 0xfb, 0x00, 0x64, 0x00}, wantErr: ErrFreeFormat},
+		{name: "TruncatedTag", stream: []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20, 0}, wantErr: io.ErrUnexpectedEOF},
+		{name: "Empty", stream: nil, wantErr: io.EOF},
+		{name: "Junk", stream: []byte{0xff, 0xe0, 0x00, 0xff}, wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner, _ := NewScanner(bitreader.NewReaderFromBytes(tt.stream, false))
+			_, err := scanner.Next()
+			if err != tt.wantErr {
+				t.Fatalf("Next() error = %v, want %v", err, tt.wantErr)
+			}
+			// The error is sticky
+			if _, err := scanner.Next(); err != tt.wantErr {
+				t.Errorf("Next() again error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewScanner(bitreader.NewReaderFromBytes(nil, true)); err != ErrLittleEndian {
+		t.Errorf("NewScanner() error = %v, want %v", err, ErrLittleEndian)
+	}
+}
@@ -0,0 +1,402 @@
+package mpegaudio
+
+import (
+	"io"
+	"time"
+
+	"github.com/pektezol/bitreader"
+)
+
+const (
+	XingFrames  = 0x1 // The Xing header has the number of frames
+	XingBytes   = 0x2 // The Xing header has the number of bytes
+	XingTOC     = 0x4 // The Xing header has a seek table
+	XingQuality = 0x8 // The Xing header has a quality indicator
+)
+
+// Frame is one frame found by a Scanner, with either MPEG or ADTS set.
+//
+// Offset uint64		Bit position of the frame in the Reader
+// MPEG *Header			The header of an MPEG audio frame
+// ADTS *ADTSHeader		The header of an ADTS frame
+// Length int			Length of the frame in bytes, header included
+// Xing *XingHeader		The Xing or Info header of the first frame of an MP3
+// VBRI *VBRIHeader		The VBRI header of the first frame of an MP3
+type Frame struct {
+	Offset uint64
+	MPEG   *Header
+	ADTS   *ADTSHeader
+	Length int
+	Xing   *XingHeader
+	VBRI   *VBRIHeader
+}
+
+// XingHeader is the header LAME and other encoders put in the first frame of an MP3,
+// in place of audio, to give the length of the stream and a seek table. Fields not
+// in Flags are zero.
+//
+// Tag string			"Xing" for VBR streams, "Info" for CBR streams
+// Flags uint32			Which of the fields are present
+// Frames uint32		Number of audio frames, without the frame of the header
+// Bytes uint32			Size of the stream in bytes
+// TOC []byte			Seek table of 100 entries, the byte position of each percent of the duration in 1/256ths
+// Quality uint32		Encoder quality indicator, 0 is best
+type XingHeader struct {
+	Tag     string
+	Flags   uint32
+	Frames  uint32
+	Bytes   uint32
+	TOC     []byte
+	Quality uint32
+}
+
+// VBRIHeader is the header the Fraunhofer encoder puts in the first frame of an MP3,
+// in place of audio, to give the length of the stream.
+//
+// Version uint16		Version of the header
+// Delay uint16			Encoder delay
+// Quality uint16		Encoder quality indicator
+// Bytes uint32			Size of the stream in bytes
+// Frames uint32		Number of audio frames
+type VBRIHeader struct {
+	Version uint16
+	Delay   uint16
+	Quality uint16
+	Bytes   uint32
+	Frames  uint32
+}
+
+// Scanner walks the frames of an MPEG audio or ADTS stream. It skips ID3v2 tags at the
+// start and resyncs over junk between frames. Once it has found the first frame, the
+// following frames must have the same format, version, layer and sample rate, so that
+// sync words inside frame data are not taken for frames.
+//
+// reader *bitreader.Reader		The stream
+// alignment uint64				Bit position of byte boundaries, modulo 8
+// started bool					Whether the ID3v2 tags were skipped
+// first *Frame					The first frame, following frames must match it
+// frames uint64				Number of audio frames
+// samples uint64				Number of samples per channel in the audio frames
+// skipped uint64				Bits skipped looking for frames
+// xing *XingHeader				The Xing header of the stream
+// vbri *VBRIHeader				The VBRI header of the stream
+// err error					The sticky error returned by Next
+type Scanner struct {
+	reader    *bitreader.Reader
+	alignment uint64
+	started   bool
+	first     *Frame
+	frames    uint64
+	samples   uint64
+	skipped   uint64
+	xing      *XingHeader
+	vbri      *VBRIHeader
+	err       error
+}
+
+// NewScanner is the main constructor that creates a Scanner for the stream at the
+// current Reader position. Frames are expected on the byte boundaries of that position.
+//
+// Returns ErrLittleEndian if the Reader is not big-endian.
+func NewScanner(reader *bitreader.Reader) (*Scanner, error) {
+	if reader.LittleEndian() {
+		return nil, ErrLittleEndian
+	}
+	return &Scanner{reader: reader, alignment: reader.BitPosition() % 8}, nil
+}
+
+// Next is a function that returns the next frame and moves the Reader past it.
+// The first frame of an MP3 may be a Xing or VBRI frame, which has no audio and
+// doesn't count towards Frames and Duration.
+//
+// Returns io.EOF when there are no more frames, io.ErrUnexpectedEOF if the last frame
+// is cut short, and ErrFreeFormat for free format MPEG audio. A sync word too close to
+// the end of the stream for a whole header is taken as junk.
+func (scanner *Scanner) Next() (*Frame, error) {
+	if scanner.err != nil {
+		return nil, scanner.err
+	}
+	if !scanner.started {
+		scanner.started = true
+		if err := scanner.skipID3(); err != nil {
+			scanner.err = err
+			return nil, err
+		}
+	}
+	for {
+		// Count the bits skipped at the end of the stream as well
+		start := scanner.reader.BitPosition()
+		_, err := scanner.reader.FindBits(0x7ff, 11)
+		scanner.skipped += scanner.reader.BitPosition() - start
+		if err != nil {
+			scanner.err = err
+			return nil, err
+		}
+		frame, err := scanner.parse()
+		if err == ErrSync || err == ErrHeader {
+			// Not a frame, look for the next sync word on a byte boundary
+			skip := 8 - (scanner.reader.BitPosition()-scanner.alignment)%8
+			if err := scanner.reader.SkipBits(skip); err != nil {
+				scanner.err = err
+				return nil, err
+			}
+			scanner.skipped += skip
+			continue
+		}
+		if err != nil {
+			scanner.err = err
+			return nil, err
+		}
+		return frame, nil
+	}
+}
+
+// Frames is a function that returns the number of audio frames returned so far.
+func (scanner *Scanner) Frames() uint64 {
+	return scanner.frames
+}
+
+// Duration is a function that returns the duration of the audio frames returned so far.
+// After the last frame it is the duration of the stream.
+func (scanner *Scanner) Duration() time.Duration {
+	if scanner.first == nil {
+		return 0
+	}
+	return samplesDuration(scanner.samples, scanner.first.SampleRate())
+}
+
+// Skipped is a function that returns how many bytes were skipped between frames
+// and before the first frame, ID3v2 tags excluded.
+func (scanner *Scanner) Skipped() uint64 {
+	return scanner.skipped / 8
+}
+
+// Xing is a function that returns the Xing header of the stream, or nil.
+func (scanner *Scanner) Xing() *XingHeader {
+	return scanner.xing
+}
+
+// VBRI is a function that returns the VBRI header of the stream, or nil.
+func (scanner *Scanner) VBRI() *VBRIHeader {
+	return scanner.vbri
+}
+
+// SampleRate is a function that returns the sample rate of the frame in Hz.
+func (frame *Frame) SampleRate() int {
+	if frame.ADTS != nil {
+		return frame.ADTS.SampleRate
+	}
+	return frame.MPEG.SampleRate
+}
+
+// Samples is a function that returns the number of samples per channel in the frame,
+// 0 for a Xing or VBRI frame.
+func (frame *Frame) Samples() int {
+	switch {
+	case frame.Xing != nil || frame.VBRI != nil:
+		return 0
+	case frame.ADTS != nil:
+		return frame.ADTS.Samples()
+	}
+	return frame.MPEG.Samples()
+}
+
+// Duration is a function that returns how long the frame plays.
+func (frame *Frame) Duration() time.Duration {
+	return samplesDuration(uint64(frame.Samples()), frame.SampleRate())
+}
+
+// Duration is a function that returns the duration of the stream from the number of
+// frames in the header and the header of its first frame, or 0 without XingFrames.
+func (xing *XingHeader) Duration(header *Header) time.Duration {
+	return samplesDuration(uint64(xing.Frames)*uint64(header.Samples()), header.SampleRate)
+}
+
+// Duration is a function that returns the duration of the stream from the number of
+// frames in the header and the header of its first frame.
+func (vbri *VBRIHeader) Duration(header *Header) time.Duration {
+	return samplesDuration(uint64(vbri.Frames)*uint64(header.Samples()), header.SampleRate)
+}
+
+// skipID3 is a private function that skips the ID3v2 tags at the start of the stream.
+func (scanner *Scanner) skipID3() error {
+	for {
+		if magic, err := scanner.reader.PeekBits(24); err != nil || magic != 0x494433 {
+			// "ID3", a stream too short for a tag is left to the frame search
+			return nil
+		}
+		if err := scanner.reader.SkipBits(40); err != nil {
+			return noEOF(err)
+		}
+		flags, err := scanner.reader.ReadBits(8)
+		if err != nil {
+			return noEOF(err)
+		}
+		// The size is 4 bytes of 7 bits each, so that it has no sync word
+		var size uint64
+		for i := 0; i < 4; i++ {
+			b, err := scanner.reader.ReadBits(8)
+			if err != nil {
+				return noEOF(err)
+			}
+			size = size<<7 | b&0x7f
+		}
+		if flags&0x10 != 0 {
+			size += 10
+		}
+		if err := scanner.reader.SkipBytes(size); err != nil {
+			return noEOF(err)
+		}
+	}
+}
+
+// parse is a private function that reads the frame at a sync word on a byte boundary.
+//
+// Returns ErrSync or ErrHeader if it is not a frame that fits the stream.
+func (scanner *Scanner) parse() (*Frame, error) {
+	reader := scanner.reader
+	if (reader.BitPosition()-scanner.alignment)%8 != 0 {
+		return nil, ErrSync
+	}
+	frame := &Frame{Offset: reader.BitPosition()}
+	// Check the header before consuming it, the next frame may start inside it
+	value, err := reader.PeekBits(56)
+	if err == io.ErrUnexpectedEOF {
+		// MPEG audio headers are only 32 bits, the rest is taken as junk
+		value, err = reader.PeekBits(32)
+		value <<= 24
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+	var body int
+	if value>>41&0x3 == 0 {
+		if frame.ADTS, err = decodeADTSHeader(value); err != nil {
+			return nil, err
+		}
+		frame.Length = frame.ADTS.FrameLength
+		body = frame.ADTS.FrameLength - frame.ADTS.HeaderLength()
+	} else {
+		if frame.MPEG, err = decodeHeader(uint32(value >> 24)); err != nil {
+			return nil, err
+		}
+		frame.Length = frame.MPEG.FrameLength()
+		body = frame.Length - 4
+	}
+	if !scanner.matches(frame) {
+		return nil, ErrHeader
+	}
+	if frame.MPEG != nil && frame.MPEG.Bitrate == 0 {
+		return nil, ErrFreeFormat
+	}
+	if frame.ADTS != nil {
+		_, err = ParseADTSHeader(reader)
+	} else {
+		_, err = ParseHeader(reader)
+	}
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if scanner.first == nil && frame.MPEG != nil && frame.MPEG.Layer == Layer3 {
+		data, err := reader.ReadBytesToSlice(uint64(body))
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		frame.Xing, frame.VBRI = parseInfo(frame.MPEG, data)
+		scanner.xing, scanner.vbri = frame.Xing, frame.VBRI
+	} else if err := reader.SkipBytes(uint64(body)); err != nil {
+		return nil, noEOF(err)
+	}
+	if scanner.first == nil {
+		scanner.first = frame
+	}
+	if samples := frame.Samples(); samples > 0 {
+		scanner.frames++
+		scanner.samples += uint64(samples)
+	}
+	return frame, nil
+}
+
+// matches is a private function that reports whether a frame has the format of the first frame.
+func (scanner *Scanner) matches(frame *Frame) bool {
+	first := scanner.first
+	switch {
+	case first == nil:
+		return true
+	case frame.ADTS != nil:
+		return first.ADTS != nil && frame.ADTS.MPEG2 == first.ADTS.MPEG2 &&
+			frame.ADTS.Profile == first.ADTS.Profile && frame.ADTS.SampleRateIndex == first.ADTS.SampleRateIndex
+	}
+	return first.MPEG != nil && frame.MPEG.Version == first.MPEG.Version &&
+		frame.MPEG.Layer == first.MPEG.Layer && frame.MPEG.SampleRateIndex == first.MPEG.SampleRateIndex
+}
+
+// parseInfo is a private function that looks for a Xing or VBRI header in the data after
+// the header of the first frame of an MP3.
+func parseInfo(header *Header, data []byte) (*XingHeader, *VBRIHeader) {
+	offset := header.SideInfoLength()
+	if header.Protected {
+		offset += 2
+	}
+	if offset+8 <= len(data) {
+		reader := bitreader.NewReaderFromBytes(data[offset:], false)
+		if tag, _ := reader.ReadStringLength(4); tag == "Xing" || tag == "Info" {
+			if xing, err := parseXing(reader, tag); err == nil {
+				return xing, nil
+			}
+		}
+	}
+	// VBRI is always 32 bytes after the header
+	if len(data) >= 32+26 {
+		reader := bitreader.NewReaderFromBytes(data[32:], false)
+		if tag, _ := reader.ReadStringLength(4); tag == "VBRI" {
+			vbri := &VBRIHeader{
+				Version: uint16(reader.TryReadBits(16)),
+				Delay:   uint16(reader.TryReadBits(16)),
+				Quality: uint16(reader.TryReadBits(16)),
+				Bytes:   uint32(reader.TryReadBits(32)),
+				Frames:  uint32(reader.TryReadBits(32)),
+			}
+			return nil, vbri
+		}
+	}
+	return nil, nil
+}
+
+// parseXing is a private function that reads the fields of a Xing header after its tag.
+func parseXing(reader *bitreader.Reader, tag string) (*XingHeader, error) {
+	flags, err := reader.ReadBits(32)
+	if err != nil {
+		return nil, err
+	}
+	xing := &XingHeader{Tag: tag, Flags: uint32(flags)}
+	if flags&XingFrames != 0 {
+		frames, err := reader.ReadBits(32)
+		if err != nil {
+			return nil, err
+		}
+		xing.Frames = uint32(frames)
+	}
+	if flags&XingBytes != 0 {
+		bytes, err := reader.ReadBits(32)
+		if err != nil {
+			return nil, err
+		}
+		xing.Bytes = uint32(bytes)
+	}
+	if flags&XingTOC != 0 {
+		if xing.TOC, err = reader.ReadBytesToSlice(100); err != nil {
+			return nil, err
+		}
+	}
+	if flags&XingQuality != 0 {
+		quality, err := reader.ReadBits(32)
+		if err != nil {
+			return nil, err
+		}
+		xing.Quality = uint32(quality)
+	}
+	return xing, nil
+}
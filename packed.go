@@ -0,0 +1,151 @@
+package bitreader
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+// packedChunk is how many bytes bulk unpacking reads from the stream at once.
+const packedChunk = 4096
+
+// ReadPackedUints is a function that reads len(dst) unsigned integers of width bits
+// each, packed back to back, into dst. It gives the same values as calling ReadBits(width)
+// len(dst) times, but unpacks whole bytes at a time, which is much faster for large arrays.
+//
+// Returns an error if width is not between 1 and 64, or if there are not enough
+// remaining bits, in which case the contents of dst are unspecified.
+func (reader *Reader) ReadPackedUints(dst []uint64, width uint) error {
+	return readPacked(reader, "ReadPackedUints(dst, width)", dst, width)
+}
+
+// ReadPackedUint32s is a function that reads len(dst) unsigned integers
+// of up to 32 bits each, packed back to back, into dst.
+//
+// Returns an error if width is not between 1 and 32, or if there are not enough remaining bits.
+func (reader *Reader) ReadPackedUint32s(dst []uint32, width uint) error {
+	return readPacked(reader, "ReadPackedUint32s(dst, width)", dst, width)
+}
+
+// ReadPackedUint16s is a function that reads len(dst) unsigned integers
+// of up to 16 bits each, packed back to back, into dst.
+//
+// Returns an error if width is not between 1 and 16, or if there are not enough remaining bits.
+func (reader *Reader) ReadPackedUint16s(dst []uint16, width uint) error {
+	return readPacked(reader, "ReadPackedUint16s(dst, width)", dst, width)
+}
+
+// ReadPackedUint8s is a function that reads len(dst) unsigned integers
+// of up to 8 bits each, packed back to back, into dst.
+//
+// Returns an error if width is not between 1 and 8, or if there are not enough remaining bits.
+func (reader *Reader) ReadPackedUint8s(dst []uint8, width uint) error {
+	return readPacked(reader, "ReadPackedUint8s(dst, width)", dst, width)
+}
+
+// readPacked is a private function that unpacks fixed-width integers through a 64-bit
+// accumulator. Little-endian Readers keep the first bit at the bottom of the accumulator,
+// big-endian Readers at the top of the valid bits.
+func readPacked[T Unsigned](reader *Reader, op string, dst []T, width uint) error {
+	var zero T
+	if width < 1 || width > uint(unsafe.Sizeof(zero))*8 {
+		return errors.New(op + " ERROR: Width should be between 1 and the size of the type")
+	}
+	if uint64(len(dst)) > math.MaxUint64/uint64(width) {
+		return errors.New(op + " ERROR: Too many values")
+	}
+	total := uint64(len(dst)) * uint64(width)
+	if total == 0 {
+		return nil
+	}
+	if err := reader.checkBits(op, total); err != nil {
+		return err
+	}
+	var acc uint64
+	var count uint // Valid bits in acc
+	// Start with the bits left in the current byte, as if they were already consumed
+	last := reader.currentByte
+	if reader.index != 0 {
+		count = uint(8 - reader.index)
+		if reader.littleEndian {
+			acc = uint64(reader.currentByte >> reader.index)
+		} else {
+			acc = uint64(reader.currentByte) & (1<<count - 1)
+		}
+		reader.position += uint64(count)
+		reader.index = 0
+	}
+	var needed uint64 // Bytes still to read from the stream
+	if total > uint64(count) {
+		needed = (total - uint64(count) + 7) / 8
+	}
+	var chunk []byte
+	// take returns the next bits, at most 32 at a time so that a new byte always fits in acc
+	take := func(bits uint) (uint64, error) {
+		for count < bits {
+			if len(chunk) == 0 {
+				size := needed
+				if size > packedChunk {
+					size = packedChunk
+				}
+				buffer := reader.scratchBuffer(int(size))
+				n, err := reader.readFull(buffer)
+				needed -= uint64(n)
+				reader.commit(buffer[:n])
+				reader.position += uint64(n) * 8
+				if err != nil {
+					return 0, err
+				}
+				chunk = buffer[:n]
+			}
+			last = chunk[0]
+			chunk = chunk[1:]
+			if reader.littleEndian {
+				acc |= uint64(last) << count
+			} else {
+				acc = acc<<8 | uint64(last)
+			}
+			count += 8
+		}
+		var value uint64
+		if reader.littleEndian {
+			value = acc & (1<<bits - 1)
+			acc >>= bits
+		} else {
+			value = acc >> (count - bits) & (1<<bits - 1)
+		}
+		count -= bits
+		return value, nil
+	}
+	for i := range dst {
+		if width <= 32 {
+			value, err := take(width)
+			if err != nil {
+				return err
+			}
+			dst[i] = T(value)
+			continue
+		}
+		first, err := take(32)
+		if err != nil {
+			return err
+		}
+		second, err := take(width - 32)
+		if err != nil {
+			return err
+		}
+		if reader.littleEndian {
+			dst[i] = T(first | second<<32)
+		} else {
+			dst[i] = T(first<<(width-32) | second)
+		}
+	}
+	// Exactly the needed bytes were read, so fewer than 8 bits are left over
+	// and they are the last bits of the last byte, continue from there
+	if count > 0 {
+		reader.currentByte = last
+		reader.index = uint8(8 - count)
+		reader.position -= uint64(count)
+	}
+	return nil
+}
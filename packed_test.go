@@ -0,0 +1,189 @@
+package bitreader
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader_ReadPackedUints(t *testing.T) {
+	random := rand.New(rand.NewSource(35))
+	stream := make([]byte, 1<<14)
+	random.Read(stream)
+	for _, le := range []bool{true, false} {
+		for width := uint(1); width <= 64; width++ {
+			for _, offset := range []uint64{0, 1, 3, 7, 8, 13} {
+				count := random.Intn(600)
+				name := fmt.Sprintf("LE=%v/Width=%d/Offset=%d", le, width, offset)
+				want := NewReaderFromBytes(stream, le)
+				want.SkipBits(offset)
+				values := make([]uint64, count)
+				for i := range values {
+					values[i], _ = want.ReadBits(uint64(width))
+				}
+				// Use a one byte reader so chunks come back short
+				reader := NewReader(iotest.OneByteReader(bytes.NewReader(stream)), le)
+				reader.SkipBits(offset)
+				got := make([]uint64, count)
+				if err := reader.ReadPackedUints(got, width); err != nil {
+					t.Fatalf("%s: ReadPackedUints() error = %v", name, err)
+				}
+				if !reflect.DeepEqual(got, values) {
+					t.Fatalf("%s: ReadPackedUints() = %v, want %v", name, got, values)
+				}
+				if reader.BitPosition() != want.BitPosition() {
+					t.Fatalf("%s: BitPosition() = %d, want %d", name, reader.BitPosition(), want.BitPosition())
+				}
+				gotNext, _ := reader.ReadBits(11)
+				wantNext, _ := want.ReadBits(11)
+				if gotNext != wantNext {
+					t.Fatalf("%s: ReadBits(11) after = %d, want %d", name, gotNext, wantNext)
+				}
+			}
+		}
+	}
+}
+
+func TestReader_ReadPackedSmall(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		le      bool
+		read    func(reader *Reader) (interface{}, error)
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:   "Uint8sLE",
+			stream: []byte{0b10001101, 0b00000010},
+			le:     true,
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint8, 3)
+				return dst, reader.ReadPackedUint8s(dst, 3)
+			},
+			want: []uint8{5, 1, 2},
+		},
+		{
+			name:   "Uint8sBE",
+			stream: []byte{0b10100101, 0b00000000},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint8, 3)
+				return dst, reader.ReadPackedUint8s(dst, 3)
+			},
+			want: []uint8{5, 1, 2},
+		},
+		{
+			name:   "Uint16sBE",
+			stream: []byte{0xab, 0xcd, 0xef},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint16, 2)
+				return dst, reader.ReadPackedUint16s(dst, 12)
+			},
+			want: []uint16{0xabc, 0xdef},
+		},
+		{
+			name:   "Uint32sLE",
+			stream: []byte{0x78, 0x56, 0x34, 0x12, 0xff},
+			le:     true,
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint32, 1)
+				return dst, reader.ReadPackedUint32s(dst, 32)
+			},
+			want: []uint32{0x12345678},
+		},
+		{
+			name:   "Empty",
+			stream: []byte{},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := []uint64{}
+				return dst, reader.ReadPackedUints(dst, 7)
+			},
+			want: []uint64{},
+		},
+		{
+			name:   "WidthTooWide",
+			stream: []byte{0xff, 0xff, 0xff},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint16, 1)
+				return dst, reader.ReadPackedUint16s(dst, 17)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "WidthZero",
+			stream: []byte{0xff},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint64, 1)
+				return dst, reader.ReadPackedUints(dst, 0)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Short",
+			stream: []byte{0xff, 0xff},
+			read: func(reader *Reader) (interface{}, error) {
+				dst := make([]uint8, 3)
+				return dst, reader.ReadPackedUint8s(dst, 6)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read(NewReaderFromBytes(tt.stream, tt.le))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadPackedLimits(t *testing.T) {
+	reader := NewReaderFromBytes(make([]byte, 64), true)
+	reader.SetLimits(Limits{MaxTotalBits: 100})
+	if err := reader.ReadPackedUints(make([]uint64, 11), 10); err == nil {
+		t.Errorf("ReadPackedUints() error = nil, want limit error")
+	}
+	if err := reader.ReadPackedUints(make([]uint64, 10), 10); err != nil {
+		t.Errorf("ReadPackedUints() error = %v, want nil", err)
+	}
+}
+
+func benchmarkPacked(b *testing.B, width uint, packed bool) {
+	const count = 1 << 14
+	stream := make([]byte, count*int(width)/8+8)
+	rand.New(rand.NewSource(1)).Read(stream)
+	dst := make([]uint64, count)
+	b.SetBytes(int64(len(stream)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader := NewReaderFromBytes(stream, true)
+		if packed {
+			if err := reader.ReadPackedUints(dst, width); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		for j := range dst {
+			value, err := reader.ReadBits(uint64(width))
+			if err != nil {
+				b.Fatal(err)
+			}
+			dst[j] = value
+		}
+	}
+}
+
+func BenchmarkReadPackedUints(b *testing.B) {
+	for _, width := range []uint{5, 12, 33} {
+		b.Run(fmt.Sprintf("Width=%d/Packed", width), func(b *testing.B) { benchmarkPacked(b, width, true) })
+		b.Run(fmt.Sprintf("Width=%d/ReadBits", width), func(b *testing.B) { benchmarkPacked(b, width, false) })
+	}
+}
@@ -0,0 +1,302 @@
+// Package protowire reads the protobuf wire format from a bitreader.Reader.
+//
+// It only knows about tags, wire types and the encoding of values, not about
+// message schemas. Messages are read in place from any bit position, so protobuf
+// payloads embedded at unaligned offsets, like the ones inside Source 2 and CS:GO
+// demos, don't have to be copied to a []byte first. Embedded messages are read
+// with sub-Decoders that share the Reader.
+package protowire
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/pektezol/bitreader"
+)
+
+// MaxNumber is the largest valid field number.
+const MaxNumber Number = 1<<29 - 1
+
+var (
+	// ErrCorrupt is returned when a tag or varint is not valid.
+	ErrCorrupt = errors.New("protowire: corrupt input")
+	// ErrTruncated is returned when a field extends past the end of its message.
+	ErrTruncated = errors.New("protowire: field extends past the end of the message")
+	// ErrType is returned when a value is read with the wrong wire type, or twice.
+	ErrType = errors.New("protowire: value doesn't match the wire type")
+)
+
+// Number is a field number.
+type Number int32
+
+// Type is a wire type.
+type Type int8
+
+const (
+	VarintType     Type = 0 // int32, int64, uint32, uint64, sint32, sint64, bool and enum
+	Fixed64Type    Type = 1 // fixed64, sfixed64 and double
+	BytesType      Type = 2 // string, bytes, embedded messages and packed repeated fields
+	StartGroupType Type = 3 // Start of a deprecated group
+	EndGroupType   Type = 4 // End of a deprecated group
+	Fixed32Type    Type = 5 // fixed32, sfixed32 and float
+)
+
+// Decoder reads the fields of one message.
+//
+// reader *bitreader.Reader		The Reader the message is read from
+// end uint64					Bit position right after the message
+// number Number				Field number of the current field
+// typ Type						Wire type of the current field
+// pending bool					Whether the value of the current field hasn't been read yet
+// skipTo uint64				Bit position right after the last embedded message handed out
+type Decoder struct {
+	reader  *bitreader.Reader
+	end     uint64
+	number  Number
+	typ     Type
+	pending bool
+	skipTo  uint64
+}
+
+// NewDecoder is the main constructor that creates a Decoder for a message of
+// length bytes starting at the current Reader position.
+func NewDecoder(reader *bitreader.Reader, length uint64) *Decoder {
+	start := reader.BitPosition()
+	end := start + length*8
+	if length > (math.MaxUint64-start)/8 {
+		end = math.MaxUint64
+	}
+	return &Decoder{reader: reader, end: end, skipTo: start}
+}
+
+// Next is a function that moves to the next field and returns its number and wire
+// type. The value of the previous field is skipped if it wasn't read.
+//
+// Returns io.EOF at the end of the message.
+func (decoder *Decoder) Next() (Number, Type, error) {
+	if decoder.pending {
+		if err := decoder.Skip(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if position := decoder.reader.BitPosition(); position < decoder.skipTo {
+		if err := decoder.reader.SkipBits(decoder.skipTo - position); err != nil {
+			return 0, 0, noEOF(err)
+		}
+	}
+	if decoder.reader.BitPosition() >= decoder.end {
+		return 0, 0, io.EOF
+	}
+	tag, err := decoder.readVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	number := tag >> 3
+	typ := Type(tag & 7)
+	if number == 0 || number > uint64(MaxNumber) || typ > Fixed32Type {
+		return 0, 0, ErrCorrupt
+	}
+	decoder.number = Number(number)
+	decoder.typ = typ
+	decoder.pending = typ != EndGroupType
+	return decoder.number, typ, nil
+}
+
+// Varint is a function that reads the value of a varint field.
+func (decoder *Decoder) Varint() (uint64, error) {
+	if err := decoder.take(VarintType); err != nil {
+		return 0, err
+	}
+	return decoder.readVarint()
+}
+
+// Sint is a function that reads the value of a zigzag encoded sint32 or sint64 field.
+func (decoder *Decoder) Sint() (int64, error) {
+	value, err := decoder.Varint()
+	if err != nil {
+		return 0, err
+	}
+	return DecodeZigZag(value), nil
+}
+
+// Fixed32 is a function that reads the value of a fixed32 field.
+func (decoder *Decoder) Fixed32() (uint32, error) {
+	if err := decoder.take(Fixed32Type); err != nil {
+		return 0, err
+	}
+	value, err := decoder.readFixed(4)
+	return uint32(value), err
+}
+
+// Fixed64 is a function that reads the value of a fixed64 field.
+func (decoder *Decoder) Fixed64() (uint64, error) {
+	if err := decoder.take(Fixed64Type); err != nil {
+		return 0, err
+	}
+	return decoder.readFixed(8)
+}
+
+// Bytes is a function that reads the value of a length-delimited field into a new slice.
+func (decoder *Decoder) Bytes() ([]byte, error) {
+	length, err := decoder.readLength()
+	if err != nil {
+		return nil, err
+	}
+	value, err := decoder.reader.ReadBytesToSlice(length)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	return value, nil
+}
+
+// Message is a function that returns a Decoder for the embedded message in a
+// length-delimited field, reading it in place from the same Reader. The parent
+// Decoder skips whatever is left of the embedded message on its next call to Next.
+func (decoder *Decoder) Message() (*Decoder, error) {
+	length, err := decoder.readLength()
+	if err != nil {
+		return nil, err
+	}
+	start := decoder.reader.BitPosition()
+	decoder.skipTo = start + length*8
+	return &Decoder{reader: decoder.reader, end: decoder.skipTo, skipTo: start}, nil
+}
+
+// Skip is a function that skips the value of the current field. For a group,
+// every field up to the matching end group is skipped.
+func (decoder *Decoder) Skip() error {
+	if !decoder.pending {
+		return ErrType
+	}
+	switch decoder.typ {
+	case VarintType:
+		_, err := decoder.Varint()
+		return err
+	case Fixed32Type, Fixed64Type:
+		size := uint64(4)
+		if decoder.typ == Fixed64Type {
+			size = 8
+		}
+		decoder.pending = false
+		if err := decoder.check(size); err != nil {
+			return err
+		}
+		return noEOF(decoder.reader.SkipBytes(size))
+	case BytesType:
+		length, err := decoder.readLength()
+		if err != nil {
+			return err
+		}
+		return noEOF(decoder.reader.SkipBytes(length))
+	}
+	number := decoder.number
+	decoder.pending = false
+	for {
+		fieldNumber, typ, err := decoder.Next()
+		if err == io.EOF {
+			return ErrTruncated
+		} else if err != nil {
+			return err
+		}
+		if typ == EndGroupType {
+			if fieldNumber != number {
+				return ErrCorrupt
+			}
+			return nil
+		}
+		if err := decoder.Skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// Remaining is a function that returns the number of bits left in the message.
+func (decoder *Decoder) Remaining() uint64 {
+	position := decoder.reader.BitPosition()
+	if position < decoder.skipTo {
+		position = decoder.skipTo
+	}
+	if position >= decoder.end {
+		return 0
+	}
+	return decoder.end - position
+}
+
+// DecodeZigZag is a function that decodes a zigzag encoded signed value.
+func DecodeZigZag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+// take is a private function that checks the current field has a value of the
+// wire type that hasn't been read yet, and marks it as read.
+func (decoder *Decoder) take(typ Type) error {
+	if !decoder.pending || decoder.typ != typ {
+		return ErrType
+	}
+	decoder.pending = false
+	return nil
+}
+
+// check is a private function that checks bytes more bytes fit in the message.
+func (decoder *Decoder) check(bytes uint64) error {
+	if bytes > decoder.Remaining()/8 {
+		return ErrTruncated
+	}
+	return nil
+}
+
+// readVarint is a private function that reads a varint inside the message.
+func (decoder *Decoder) readVarint() (uint64, error) {
+	value, err := decoder.reader.ReadULEB128()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, ErrCorrupt
+	}
+	if decoder.reader.BitPosition() > decoder.end {
+		return 0, ErrTruncated
+	}
+	return value, nil
+}
+
+// readLength is a private function that reads the length of a length-delimited
+// field and checks the value fits in the message.
+func (decoder *Decoder) readLength() (uint64, error) {
+	if err := decoder.take(BytesType); err != nil {
+		return 0, err
+	}
+	length, err := decoder.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if err := decoder.check(length); err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// readFixed is a private function that reads a little-endian value of size bytes,
+// one byte at a time so it doesn't depend on the Reader's bit order.
+func (decoder *Decoder) readFixed(size uint64) (uint64, error) {
+	if err := decoder.check(size); err != nil {
+		return 0, err
+	}
+	var value uint64
+	for i := uint64(0); i < size; i++ {
+		b, err := decoder.reader.ReadBytes(1)
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		value |= b << (8 * i)
+	}
+	return value, nil
+}
+
+// noEOF is a private function that turns io.EOF into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package protowire
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// appendVarint appends value as a varint.
+func appendVarint(data []byte, value uint64) []byte {
+	for value >= 0x80 {
+		data = append(data, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(data, byte(value))
+}
+
+// appendTag appends the tag of a field.
+func appendTag(data []byte, number Number, typ Type) []byte {
+	return appendVarint(data, uint64(number)<<3|uint64(typ))
+}
+
+// appendBytes appends a length-delimited field.
+func appendBytes(data []byte, number Number, value []byte) []byte {
+	data = appendVarint(appendTag(data, number, BytesType), uint64(len(value)))
+	return append(data, value...)
+}
+
+// testMessage builds a message with every wire type:
+//
+//	1: varint 150, 2: sint -3, 3: fixed32, 4: fixed64, 5: string,
+//	6: embedded message {1: 7, 2: "hi"}, 7: group {1: 1}, 8: varint 1
+func testMessage() []byte {
+	var data []byte
+	data = appendVarint(appendTag(data, 1, VarintType), 150)
+	data = appendVarint(appendTag(data, 2, VarintType), 5)
+	data = append(appendTag(data, 3, Fixed32Type), 0x78, 0x56, 0x34, 0x12)
+	data = append(appendTag(data, 4, Fixed64Type), 0x18, 0x2d, 0x44, 0x54, 0xfb, 0x21, 0x09, 0x40)
+	data = appendBytes(data, 5, []byte("demo"))
+	var inner []byte
+	inner = appendVarint(appendTag(inner, 1, VarintType), 7)
+	inner = appendBytes(inner, 2, []byte("hi"))
+	data = appendBytes(data, 6, inner)
+	data = appendTag(data, 7, StartGroupType)
+	data = appendVarint(appendTag(data, 1, VarintType), 1)
+	data = appendTag(data, 7, EndGroupType)
+	data = appendVarint(appendTag(data, 8, VarintType), 1)
+	return data
+}
+
+// shift returns data moved by bits bits in the bit order of the Reader, with a trailing byte.
+func shift(data []byte, bits uint, le bool) []byte {
+	out := make([]byte, len(data)+2)
+	for i, b := range data {
+		if le {
+			out[i] |= b << bits
+			out[i+1] |= b >> (8 - bits)
+		} else {
+			out[i] |= b >> bits
+			out[i+1] |= b << (8 - bits)
+		}
+	}
+	return out
+}
+
+func TestDecoder(t *testing.T) {
+	data := testMessage()
+	for _, le := range []bool{true, false} {
+		for offset := uint(0); offset < 8; offset++ {
+			reader := bitreader.NewReaderFromBytes(shift(data, offset, le), le)
+			reader.SkipBits(uint64(offset))
+			decoder := NewDecoder(reader, uint64(len(data)))
+			var got []interface{}
+			for {
+				number, typ, err := decoder.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("LE=%v/Offset=%d: Next() error = %v", le, offset, err)
+				}
+				var value interface{}
+				switch number {
+				case 1, 8:
+					value, err = decoder.Varint()
+				case 2:
+					value, err = decoder.Sint()
+				case 3:
+					value, err = decoder.Fixed32()
+				case 4:
+					var bits uint64
+					bits, err = decoder.Fixed64()
+					value = math.Float64frombits(bits)
+				case 5:
+					var text []byte
+					text, err = decoder.Bytes()
+					value = string(text)
+				case 6:
+					var inner *Decoder
+					inner, err = decoder.Message()
+					if err == nil {
+						// Only read the first field, the parent skips the rest
+						inner.Next()
+						value, err = inner.Varint()
+					}
+				case 7:
+					value, err = typ, decoder.Skip()
+				}
+				if err != nil {
+					t.Fatalf("LE=%v/Offset=%d: field %d error = %v", le, offset, number, err)
+				}
+				got = append(got, value)
+			}
+			want := []interface{}{uint64(150), int64(-3), uint32(0x12345678), math.Pi, "demo", uint64(7), StartGroupType, uint64(1)}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("LE=%v/Offset=%d: fields = %v, want %v", le, offset, got, want)
+			}
+			if position := reader.BitPosition(); position != uint64(offset)+uint64(len(data))*8 {
+				t.Fatalf("LE=%v/Offset=%d: BitPosition() = %d, want end of message", le, offset, position)
+			}
+		}
+	}
+}
+
+func TestDecoder_SkipAll(t *testing.T) {
+	data := append(testMessage(), 0xaa)
+	reader := bitreader.NewReaderFromBytes(data, true)
+	decoder := NewDecoder(reader, uint64(len(data)-1))
+	fields := 0
+	for {
+		_, _, err := decoder.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		fields++
+	}
+	if fields != 8 {
+		t.Errorf("Next() found %d fields, want 8", fields)
+	}
+	if next, _ := reader.ReadBytes(1); next != 0xaa {
+		t.Errorf("ReadBytes(1) after = %#x, want 0xaa", next)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		length  uint64
+		read    func(decoder *Decoder) error
+		wantErr error
+	}{
+		{
+			name:    "WrongType",
+			data:    appendVarint(appendTag(nil, 1, VarintType), 1),
+			read:    func(decoder *Decoder) error { _, err := decoder.Fixed32(); return err },
+			wantErr: ErrType,
+		},
+		{
+			name:    "ReadTwice",
+			data:    appendVarint(appendTag(nil, 1, VarintType), 1),
+			read:    func(decoder *Decoder) error { decoder.Varint(); _, err := decoder.Varint(); return err },
+			wantErr: ErrType,
+		},
+		{
+			name:    "FieldZero",
+			data:    appendVarint(appendTag(nil, 0, VarintType), 1),
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "InvalidType",
+			data:    []byte{0x0e, 0x00},
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "BytesPastMessage",
+			data:    appendBytes(nil, 1, []byte("long")),
+			length:  4,
+			read:    func(decoder *Decoder) error { _, err := decoder.Bytes(); return err },
+			wantErr: ErrTruncated,
+		},
+		{
+			name:    "FixedPastMessage",
+			data:    append(appendTag(nil, 1, Fixed64Type), 1, 2, 3, 4),
+			read:    func(decoder *Decoder) error { _, err := decoder.Fixed64(); return err },
+			wantErr: ErrTruncated,
+		},
+		{
+			name:    "VarintPastMessage",
+			data:    []byte{0x08, 0x80, 0x01},
+			length:  2,
+			read:    func(decoder *Decoder) error { _, err := decoder.Varint(); return err },
+			wantErr: ErrTruncated,
+		},
+		{
+			name:    "VarintOverflow",
+			data:    []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+			read:    func(decoder *Decoder) error { _, err := decoder.Varint(); return err },
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "UnterminatedGroup",
+			data:    appendVarint(appendTag(appendTag(nil, 1, StartGroupType), 2, VarintType), 1),
+			read:    func(decoder *Decoder) error { return decoder.Skip() },
+			wantErr: ErrTruncated,
+		},
+		{
+			name:    "MismatchedGroup",
+			data:    appendTag(appendTag(nil, 1, StartGroupType), 2, EndGroupType),
+			read:    func(decoder *Decoder) error { return decoder.Skip() },
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "ShortStream",
+			data:    []byte{0x08},
+			length:  10,
+			read:    func(decoder *Decoder) error { _, err := decoder.Varint(); return err },
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length := tt.length
+			if length == 0 {
+				length = uint64(len(tt.data))
+			}
+			decoder := NewDecoder(bitreader.NewReaderFromBytes(tt.data, true), length)
+			_, _, err := decoder.Next()
+			if err == nil && tt.read != nil {
+				err = tt.read(decoder)
+			}
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeZigZag(t *testing.T) {
+	for value, want := range map[uint64]int64{0: 0, 1: -1, 2: 1, 3: -2, 4294967294: 2147483647, 4294967295: -2147483648, math.MaxUint64: math.MinInt64} {
+		if got := DecodeZigZag(value); got != want {
+			t.Errorf("DecodeZigZag(%d) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add(testMessage())
+	f.Add([]byte{0x0b, 0x0b, 0x0c, 0x0c})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoder := NewDecoder(bitreader.NewReaderFromBytes(data, true), uint64(len(data)))
+		// Every field is skipped, nested messages are walked, it should always end without panicking
+		var walk func(decoder *Decoder, depth int)
+		walk = func(decoder *Decoder, depth int) {
+			for {
+				_, typ, err := decoder.Next()
+				if err != nil {
+					return
+				}
+				if typ == BytesType && depth < 8 {
+					if inner, err := decoder.Message(); err == nil {
+						walk(inner, depth+1)
+					}
+				}
+			}
+		}
+		walk(decoder, 0)
+		if decoder.Remaining() > uint64(len(data))*8 {
+			t.Fatalf("Remaining() = %d, more than the message", decoder.Remaining())
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package bitreader
+
+import (
+	"errors"
+	"math"
+)
+
+// QuantizedFlags are the encoding flags of a quantized float.
+type QuantizedFlags uint8
+
+const (
+	QuantizedRoundDown      QuantizedFlags = 1 << 0 // A leading bit selects the low value exactly
+	QuantizedRoundUp        QuantizedFlags = 1 << 1 // A leading bit selects the high value exactly
+	QuantizedEncodeZero     QuantizedFlags = 1 << 2 // A leading bit selects zero exactly
+	QuantizedEncodeIntegers QuantizedFlags = 1 << 3 // Integers in the range are encoded exactly
+)
+
+// QuantizedFloat decodes floats quantized onto a range the way Valve's
+// CQuantizedFloat does in Source 2 entity properties. The range and flags are
+// adjusted once when it is created, like the game does, so a QuantizedFloat
+// can be reused for every value of a property.
+//
+// Bits uint8					Number of bits in the quantized value, 0 for unquantized float32s
+// Low, High float32			The range after applying the rounding flags
+// Flags QuantizedFlags			The flags that are still needed after validation
+// decMul float32				Multiplier from a step count to a fraction of the range
+type QuantizedFloat struct {
+	Bits   uint8
+	Low    float32
+	High   float32
+	Flags  QuantizedFlags
+	decMul float32
+}
+
+// NewQuantizedFloat is the constructor that prepares a QuantizedFloat for values of
+// bits bits between low and high. A bits of 0 or 32 and above means the values are
+// plain float32s, as the game does.
+//
+// Returns an error if both rounding flags are set or the range can't be quantized.
+func NewQuantizedFloat(bits uint8, low, high float32, flags QuantizedFlags) (*QuantizedFloat, error) {
+	if bits == 0 || bits >= 32 {
+		return &QuantizedFloat{}, nil
+	}
+	quantized := &QuantizedFloat{Bits: bits, Low: low, High: high, Flags: flags}
+	if err := quantized.validateFlags(); err != nil {
+		return nil, err
+	}
+	steps := uint64(1) << quantized.Bits
+	if quantized.Flags&QuantizedRoundDown != 0 {
+		offset := (quantized.High - quantized.Low) / float32(steps)
+		quantized.High -= offset
+	} else if quantized.Flags&QuantizedRoundUp != 0 {
+		offset := (quantized.High - quantized.Low) / float32(steps)
+		quantized.Low += offset
+	}
+	if quantized.Flags&QuantizedEncodeIntegers != 0 {
+		delta := quantized.High - quantized.Low
+		if delta < 1 {
+			delta = 1
+		}
+		// Grow the bit count until every integer in the range gets its own step
+		span := uint64(1) << uint(math.Ceil(math.Log2(float64(delta))))
+		for uint64(1)<<quantized.Bits <= span {
+			quantized.Bits++
+		}
+		if quantized.Bits >= 32 {
+			return nil, errors.New("NewQuantizedFloat(bits, low, high, flags) ERROR: Range is too large to encode integers")
+		}
+		steps = uint64(1) << quantized.Bits
+		offset := float32(span) / float32(steps)
+		quantized.High = quantized.Low + float32(span) - offset
+	}
+	highLowMul := quantized.highLowMul()
+	if highLowMul == 0 {
+		return nil, errors.New("NewQuantizedFloat(bits, low, high, flags) ERROR: Range can't be quantized")
+	}
+	quantized.decMul = 1 / float32(steps-1)
+	// Drop the flags whose value is already hit exactly by a step
+	if quantized.Flags&QuantizedRoundDown != 0 && quantized.quantize(quantized.Low, highLowMul) == quantized.Low {
+		quantized.Flags &^= QuantizedRoundDown
+	}
+	if quantized.Flags&QuantizedRoundUp != 0 && quantized.quantize(quantized.High, highLowMul) == quantized.High {
+		quantized.Flags &^= QuantizedRoundUp
+	}
+	if quantized.Flags&QuantizedEncodeZero != 0 && quantized.quantize(0, highLowMul) == 0 {
+		quantized.Flags &^= QuantizedEncodeZero
+	}
+	return quantized, nil
+}
+
+// Read is a function that reads one quantized float from the Reader.
+//
+// Returns an error if there are not enough remaining bits.
+func (quantized *QuantizedFloat) Read(reader *Reader) (float32, error) {
+	if quantized.Bits == 0 {
+		value, err := reader.ReadBits(32)
+		if err != nil {
+			return 0, err
+		}
+		return math.Float32frombits(uint32(value)), nil
+	}
+	if quantized.Flags&QuantizedRoundDown != 0 {
+		if exact, err := reader.ReadBool(); err != nil || exact {
+			return quantized.Low, err
+		}
+	}
+	if quantized.Flags&QuantizedRoundUp != 0 {
+		if exact, err := reader.ReadBool(); err != nil || exact {
+			return quantized.High, err
+		}
+	}
+	if quantized.Flags&QuantizedEncodeZero != 0 {
+		if exact, err := reader.ReadBool(); err != nil || exact {
+			return 0, err
+		}
+	}
+	value, err := reader.ReadBits(uint64(quantized.Bits))
+	if err != nil {
+		return 0, err
+	}
+	// Round after every operation like the game, instead of fusing them
+	scaled := float32((quantized.High - quantized.Low) * float32(value))
+	return quantized.Low + float32(scaled*quantized.decMul), nil
+}
+
+// ReadQuantizedFloat is a function that reads a float quantized onto [low, high]
+// with bits bits, following Valve's CQuantizedFloat. When reading many values
+// with the same encoding, create a QuantizedFloat once with NewQuantizedFloat instead.
+//
+// Returns an error if the encoding is invalid or there are not enough remaining bits.
+func (reader *Reader) ReadQuantizedFloat(bits uint8, low, high float32, flags QuantizedFlags) (float32, error) {
+	quantized, err := NewQuantizedFloat(bits, low, high, flags)
+	if err != nil {
+		return 0, err
+	}
+	return quantized.Read(reader)
+}
+
+// ReadFixedPoint is a function that reads a Qm.n fixed-point number of intBits+fracBits
+// bits and returns its value. When signed is set the value is two's complement and
+// intBits includes the sign bit. Values of up to 53 bits are exact.
+//
+// Returns an error if the total is 0 or more than 64 bits, or there are not enough remaining bits.
+func (reader *Reader) ReadFixedPoint(intBits, fracBits uint8, signed bool) (float64, error) {
+	bits := uint64(intBits) + uint64(fracBits)
+	if bits < 1 || bits > 64 {
+		return 0, errors.New("ReadFixedPoint(intBits, fracBits, signed) ERROR: Total bits should be between 1 and 64")
+	}
+	value, err := reader.ReadBits(bits)
+	if err != nil {
+		return 0, err
+	}
+	if signed && bits < 64 && value>>(bits-1) == 1 {
+		value |= math.MaxUint64 << bits
+	}
+	if signed {
+		return math.Ldexp(float64(int64(value)), -int(fracBits)), nil
+	}
+	return math.Ldexp(float64(value), -int(fracBits)), nil
+}
+
+// TryReadQuantizedFloat is a wrapper function that returns the value of a quantized float.
+//
+// Returns float32. Panics on overflow.
+func (reader *Reader) TryReadQuantizedFloat(bits uint8, low, high float32, flags QuantizedFlags) float32 {
+	value, err := reader.ReadQuantizedFloat(bits, low, high, flags)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadFixedPoint is a wrapper function that returns the value of a fixed-point number.
+//
+// Returns float64. Panics on overflow.
+func (reader *Reader) TryReadFixedPoint(intBits, fracBits uint8, signed bool) float64 {
+	value, err := reader.ReadFixedPoint(intBits, fracBits, signed)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// validateFlags is a private function that drops the flags that can't apply to the range,
+// turning encode zero into a rounding flag when zero is one of the ends.
+func (quantized *QuantizedFloat) validateFlags() error {
+	if quantized.Flags == 0 {
+		return nil
+	}
+	if quantized.Low == 0 && quantized.Flags&QuantizedRoundDown != 0 || quantized.High == 0 && quantized.Flags&QuantizedRoundUp != 0 {
+		quantized.Flags &^= QuantizedEncodeZero
+	}
+	if quantized.Low == 0 && quantized.Flags&QuantizedEncodeZero != 0 {
+		quantized.Flags |= QuantizedRoundDown
+		quantized.Flags &^= QuantizedEncodeZero
+	}
+	if quantized.High == 0 && quantized.Flags&QuantizedEncodeZero != 0 {
+		quantized.Flags |= QuantizedRoundUp
+		quantized.Flags &^= QuantizedEncodeZero
+	}
+	if quantized.Low > 0 || quantized.High < 0 {
+		quantized.Flags &^= QuantizedEncodeZero
+	}
+	if quantized.Flags&QuantizedEncodeIntegers != 0 {
+		quantized.Flags &^= QuantizedRoundUp | QuantizedRoundDown | QuantizedEncodeZero
+	}
+	if quantized.Flags&(QuantizedRoundDown|QuantizedRoundUp) == QuantizedRoundDown|QuantizedRoundUp {
+		return errors.New("NewQuantizedFloat(bits, low, high, flags) ERROR: Round down and round up are mutually exclusive")
+	}
+	return nil
+}
+
+// highLowMul is a private function that returns the multiplier from the range to
+// step counts, backed off slightly when rounding would overshoot the last step.
+func (quantized *QuantizedFloat) highLowMul() float32 {
+	span := quantized.High - quantized.Low
+	high := float32(uint32(1)<<quantized.Bits - 1)
+	if span == 0 {
+		return high
+	}
+	mul := high / span
+	if float32(mul*span) > high {
+		for _, factor := range []float32{0.9999, 0.99, 0.9, 0.8, 0.7} {
+			mul = float32(high/span) * factor
+			if float32(mul*span) <= high {
+				break
+			}
+		}
+	}
+	return mul
+}
+
+// quantize is a private function that returns the value the encoder would
+// produce for value, used to find the flags that are not needed.
+func (quantized *QuantizedFloat) quantize(value, highLowMul float32) float32 {
+	if value < quantized.Low {
+		return quantized.Low
+	} else if value > quantized.High {
+		return quantized.High
+	}
+	step := uint32(float32((value - quantized.Low) * highLowMul))
+	fraction := float32(float32(step) * quantized.decMul)
+	return quantized.Low + float32((quantized.High-quantized.Low)*fraction)
+}
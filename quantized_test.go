@@ -0,0 +1,141 @@
+package bitreader
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewQuantizedFloat(t *testing.T) {
+	tests := []struct {
+		name      string
+		bits      uint8
+		low, high float32
+		flags     QuantizedFlags
+		want      QuantizedFloat
+		wantErr   bool
+	}{
+		{name: "Plain", bits: 8, low: 0, high: 1, want: QuantizedFloat{Bits: 8, Low: 0, High: 1}},
+		{name: "NoScale", bits: 32, low: -1, high: 1, flags: QuantizedEncodeZero, want: QuantizedFloat{}},
+		{name: "EncodeZeroKept", bits: 8, low: -1, high: 1, flags: QuantizedEncodeZero, want: QuantizedFloat{Bits: 8, Low: -1, High: 1, Flags: QuantizedEncodeZero}},
+		{name: "EncodeZeroOutside", bits: 8, low: 1, high: 2, flags: QuantizedEncodeZero, want: QuantizedFloat{Bits: 8, Low: 1, High: 2}},
+		// Zero at the low end becomes round down, which shrinks the range and is then not needed
+		{name: "EncodeZeroAtLow", bits: 8, low: 0, high: 256, flags: QuantizedEncodeZero, want: QuantizedFloat{Bits: 8, Low: 0, High: 255}},
+		{name: "RoundUp", bits: 4, low: 0, high: 16, flags: QuantizedRoundUp, want: QuantizedFloat{Bits: 4, Low: 1, High: 16}},
+		{name: "EncodeIntegers", bits: 4, low: 0, high: 100, flags: QuantizedEncodeIntegers | QuantizedRoundDown, want: QuantizedFloat{Bits: 8, Low: 0, High: 127.5, Flags: QuantizedEncodeIntegers}},
+		{name: "BothRounding", bits: 8, low: -1, high: 1, flags: QuantizedRoundDown | QuantizedRoundUp, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewQuantizedFloat(tt.bits, tt.low, tt.high, tt.flags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewQuantizedFloat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Bits != tt.want.Bits || got.Low != tt.want.Low || got.High != tt.want.High || got.Flags != tt.want.Flags {
+				t.Errorf("NewQuantizedFloat() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadQuantizedFloat(t *testing.T) {
+	tests := []struct {
+		name      string
+		stream    []byte
+		le        bool
+		bits      uint8
+		low, high float32
+		flags     QuantizedFlags
+		want      float32
+		wantErr   bool
+	}{
+		{name: "High", stream: []byte{0xff}, bits: 8, low: 0, high: 1, want: 1},
+		{name: "Low", stream: []byte{0x00}, bits: 8, low: -5, high: 5, want: -5},
+		{name: "Middle", stream: []byte{0x80}, bits: 8, low: 0, high: 1, want: 0.5019608},
+		{name: "Negative", stream: []byte{0x40}, bits: 8, low: -1, high: 1, want: -0.49803919},
+		{name: "ZeroBit", stream: []byte{0x80}, bits: 8, low: -1, high: 1, flags: QuantizedEncodeZero, want: 0},
+		{name: "ZeroBitUnset", stream: []byte{0x7f, 0x80}, bits: 8, low: -1, high: 1, flags: QuantizedEncodeZero, want: 1},
+		{name: "RoundUpRange", stream: []byte{0x00}, bits: 4, low: 0, high: 16, flags: QuantizedRoundUp, want: 1},
+		{name: "Integers", stream: []byte{0x02}, bits: 4, low: 0, high: 100, flags: QuantizedEncodeIntegers, want: 1},
+		// Like the game, float32 rounding makes this one step off the integer
+		{name: "IntegersLarge", stream: []byte{0xc6}, bits: 4, low: 0, high: 100, flags: QuantizedEncodeIntegers, want: math.Nextafter32(99, 100)},
+		{name: "LittleEndian", stream: []byte{0x34, 0x02}, le: true, bits: 10, low: 0, high: 1023, want: 564},
+		{name: "NoScale", stream: []byte{0x3f, 0xc0, 0x00, 0x00}, bits: 0, low: 0, high: 1, want: 1.5},
+		{name: "Short", stream: []byte{0xff}, bits: 12, low: 0, high: 1, wantErr: true},
+		{name: "BothRounding", stream: []byte{0xff}, bits: 4, low: -1, high: 1, flags: QuantizedRoundDown | QuantizedRoundUp, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, tt.le).ReadQuantizedFloat(tt.bits, tt.low, tt.high, tt.flags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadQuantizedFloat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ReadQuantizedFloat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantizedFloat_Read(t *testing.T) {
+	// Every step of a 12 bit encoding against the float32 reference formula
+	quantized, err := NewQuantizedFloat(12, -4096, 4096, 0)
+	if err != nil {
+		t.Fatalf("NewQuantizedFloat() error = %v", err)
+	}
+	decMul := float32(1) / 4095
+	for step := 0; step < 1<<12; step++ {
+		reader := NewReaderFromBytes([]byte{byte(step >> 4), byte(step << 4)}, false)
+		got, err := quantized.Read(reader)
+		scaled := float32(float32(8192) * float32(step))
+		want := float32(-4096) + float32(scaled*decMul)
+		if err != nil || got != want {
+			t.Fatalf("Read(%d) = %v, %v, want %v", step, got, err, want)
+		}
+	}
+}
+
+func TestReader_ReadFixedPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		le      bool
+		intBits uint8
+		frac    uint8
+		signed  bool
+		want    float64
+		wantErr bool
+	}{
+		{name: "Q1.15Min", stream: []byte{0x80, 0x00}, intBits: 1, frac: 15, signed: true, want: -1},
+		{name: "Q1.15Half", stream: []byte{0x40, 0x00}, intBits: 1, frac: 15, signed: true, want: 0.5},
+		{name: "Q1.15Max", stream: []byte{0x7f, 0xff}, intBits: 1, frac: 15, signed: true, want: 32767.0 / 32768},
+		{name: "UQ8.8", stream: []byte{0x01, 0x80}, intBits: 8, frac: 8, want: 1.5},
+		{name: "Q16.16LE", stream: []byte{0x00, 0x80, 0xff, 0xff}, le: true, intBits: 16, frac: 16, signed: true, want: -0.5},
+		{name: "Q3.2", stream: []byte{0xf8}, intBits: 3, frac: 2, signed: true, want: -0.25},
+		{name: "Integer", stream: []byte{0xff}, intBits: 8, signed: true, want: -1},
+		{name: "Fraction", stream: []byte{0xc0}, frac: 2, want: 0.75},
+		{name: "Q32.32", stream: []byte{0xff, 0xff, 0xff, 0xfe, 0x40, 0x00, 0x00, 0x00}, intBits: 32, frac: 32, signed: true, want: -1.75},
+		{name: "Empty", stream: []byte{0xff}, wantErr: true},
+		{name: "TooWide", stream: make([]byte, 9), intBits: 33, frac: 32, wantErr: true},
+		{name: "Short", stream: []byte{0xff}, intBits: 8, frac: 8, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, tt.le).ReadFixedPoint(tt.intBits, tt.frac, tt.signed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFixedPoint() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ReadFixedPoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := math.Float64bits(NewReaderFromBytes([]byte{0x80}, false).TryReadFixedPoint(1, 7, false)); got != math.Float64bits(1) {
+		t.Errorf("TryReadFixedPoint() = %v, want 1", math.Float64frombits(got))
+	}
+}
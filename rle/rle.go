@@ -0,0 +1,179 @@
+// Package rle decodes the Parquet RLE / bit-packing hybrid encoding from a
+// little-endian bitreader.Reader.
+//
+// The encoding stores definition levels, repetition levels and dictionary indices
+// as a sequence of runs. Each run starts with a ULEB128 header: an even header is
+// an RLE run of header>>1 copies of one value stored in ceil(width/8) bytes, an odd
+// header is header>>1 groups of 8 values bit-packed LSB-first.
+//
+// The encoding has no end marker, the number of values comes from the page header.
+package rle
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/pektezol/bitreader"
+)
+
+// MaxWidth is the maximum bit width of a value.
+const MaxWidth = 32
+
+var (
+	// ErrBigEndian is returned when the Reader is not in little-endian mode.
+	ErrBigEndian = errors.New("rle: reader should be little-endian")
+	// ErrCorrupt is returned when a run header or value is not valid.
+	ErrCorrupt = errors.New("rle: corrupt input")
+	// ErrWidth is returned when the bit width is larger than MaxWidth.
+	ErrWidth = errors.New("rle: bit width should be at most 32")
+)
+
+// Decoder decodes values of the RLE / bit-packing hybrid encoding.
+//
+// reader *bitreader.Reader		The Reader runs are read from
+// width uint					Bit width of every value
+// remaining uint64				Values left in the current run
+// packed bool					Whether the current run is bit-packed
+// value uint32					The repeated value of the current RLE run
+// err error					The sticky error returned by Decode
+type Decoder struct {
+	reader    *bitreader.Reader
+	width     uint
+	remaining uint64
+	packed    bool
+	value     uint32
+	err       error
+}
+
+// NewDecoder is the main constructor that creates a Decoder for runs starting
+// at the current Reader position, with values of width bits. A width of 0 is valid
+// and decodes zeros, as used for columns without nesting or nulls.
+//
+// Returns an error if the Reader is not little-endian or width is larger than MaxWidth.
+func NewDecoder(reader *bitreader.Reader, width uint) (*Decoder, error) {
+	if !reader.LittleEndian() {
+		return nil, ErrBigEndian
+	}
+	if width > MaxWidth {
+		return nil, ErrWidth
+	}
+	return &Decoder{reader: reader, width: width}, nil
+}
+
+// NewLengthDecoder is the constructor for runs prefixed with their 4 byte little-endian
+// length, as definition and repetition levels are stored in data page v1. The runs are
+// read from the Reader up front so the Reader is positioned after them.
+func NewLengthDecoder(reader *bitreader.Reader, width uint) (*Decoder, error) {
+	if !reader.LittleEndian() {
+		return nil, ErrBigEndian
+	}
+	length, err := reader.ReadBytes(4)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	data, err := reader.ReadBytesToSlice(uint64(length))
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	return NewDecoder(bitreader.NewReaderFromBytes(data, true), width)
+}
+
+// NewDictionaryDecoder is the constructor for dictionary indices, where the runs are
+// preceded by one byte holding the bit width.
+func NewDictionaryDecoder(reader *bitreader.Reader) (*Decoder, error) {
+	if !reader.LittleEndian() {
+		return nil, ErrBigEndian
+	}
+	width, err := reader.ReadBytes(1)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	return NewDecoder(reader, uint(width))
+}
+
+// Decode is a function that decodes the next len(dst) values into dst.
+//
+// Returns the number of values decoded, which is less than len(dst) only with an error.
+// The error is io.EOF if the runs ended exactly on a run boundary, io.ErrUnexpectedEOF
+// if they ended inside a run.
+func (decoder *Decoder) Decode(dst []uint32) (int, error) {
+	n := 0
+	for n < len(dst) {
+		if decoder.err != nil {
+			return n, decoder.err
+		}
+		if decoder.remaining == 0 {
+			decoder.err = decoder.nextRun()
+			continue
+		}
+		count := uint64(len(dst) - n)
+		if count > decoder.remaining {
+			count = decoder.remaining
+		}
+		values := dst[n : n+int(count)]
+		if !decoder.packed {
+			for i := range values {
+				values[i] = decoder.value
+			}
+		} else if decoder.width == 0 {
+			for i := range values {
+				values[i] = 0
+			}
+		} else if err := decoder.reader.ReadPackedUint32s(values, decoder.width); err != nil {
+			decoder.err = noEOF(err)
+			continue
+		}
+		decoder.remaining -= count
+		n += int(count)
+	}
+	return n, nil
+}
+
+// Width is a function that returns the bit width of the values.
+func (decoder *Decoder) Width() uint {
+	return decoder.width
+}
+
+// nextRun is a private function that reads the header of the next run,
+// and the repeated value if it is an RLE run.
+func (decoder *Decoder) nextRun() error {
+	header, err := decoder.reader.ReadULEB128()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return err
+	} else if err != nil {
+		return ErrCorrupt
+	}
+	count := header >> 1
+	if count == 0 || count > math.MaxUint64/8 {
+		return ErrCorrupt
+	}
+	if header&1 == 1 {
+		decoder.packed = true
+		decoder.remaining = count * 8
+		return nil
+	}
+	decoder.packed = false
+	decoder.remaining = count
+	decoder.value = 0
+	if decoder.width > 0 {
+		// The value is stored in whole little-endian bytes
+		value, err := decoder.reader.ReadBytes(uint64(decoder.width+7) / 8)
+		if err != nil {
+			return noEOF(err)
+		}
+		decoder.value = uint32(value)
+	}
+	if decoder.width < MaxWidth && decoder.value>>decoder.width != 0 {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// noEOF is a private function that turns io.EOF into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
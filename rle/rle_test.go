@@ -0,0 +1,185 @@
+package rle
+
+import (
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// appendULEB128 appends value as an unsigned LEB128 run header.
+func appendULEB128(data []byte, value uint64) []byte {
+	for value >= 0x80 {
+		data = append(data, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(data, byte(value))
+}
+
+// encode encodes values with width bits each, alternating between RLE runs for
+// repeated values and bit-packed runs for everything else, like parquet-mr does.
+func encode(values []uint32, width uint) []byte {
+	var data []byte
+	for i := 0; i < len(values); {
+		run := 1
+		for i+run < len(values) && values[i+run] == values[i] {
+			run++
+		}
+		if run >= 8 {
+			data = appendULEB128(data, uint64(run)<<1)
+			for b := uint(0); b < (width+7)/8; b++ {
+				data = append(data, byte(values[i]>>(8*b)))
+			}
+			i += run
+			continue
+		}
+		groups := 1
+		for i+groups*8 < len(values) && groups < 8 {
+			groups++
+		}
+		data = appendULEB128(data, uint64(groups)<<1|1)
+		var acc uint64
+		var count uint
+		for j := 0; j < groups*8; j++ {
+			var value uint32
+			if i+j < len(values) {
+				value = values[i+j]
+			}
+			acc |= uint64(value) << count
+			for count += width; count >= 8; count -= 8 {
+				data = append(data, byte(acc))
+				acc >>= 8
+			}
+		}
+		i += groups * 8
+	}
+	return data
+}
+
+func TestDecoder_Spec(t *testing.T) {
+	// The bit-packing example from the Parquet encodings documentation
+	reader := bitreader.NewReaderFromBytes([]byte{0x03, 0x88, 0xc6, 0xfa, 0x0a, 0x05}, true)
+	decoder, err := NewDecoder(reader, 3)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	got := make([]uint32, 13)
+	if n, err := decoder.Decode(got); n != len(got) || err != nil {
+		t.Fatalf("Decode() = %d, %v, want %d, nil", n, err, len(got))
+	}
+	want := []uint32{0, 1, 2, 3, 4, 5, 6, 7, 5, 5, 5, 5, 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %v, want %v", got, want)
+	}
+	if n, err := decoder.Decode(got[:1]); n != 0 || err != io.EOF {
+		t.Errorf("Decode() at end = %d, %v, want 0, EOF", n, err)
+	}
+}
+
+func TestDecoder_Random(t *testing.T) {
+	random := rand.New(rand.NewSource(36))
+	for width := uint(0); width <= MaxWidth; width++ {
+		values := make([]uint32, 1+random.Intn(3000))
+		for i := range values {
+			if i > 0 && random.Intn(4) != 0 {
+				values[i] = values[i-1]
+				continue
+			}
+			values[i] = uint32(random.Uint64() & (1<<width - 1))
+		}
+		decoder, err := NewDecoder(bitreader.NewReaderFromBytes(encode(values, width), true), width)
+		if err != nil {
+			t.Fatalf("NewDecoder(%d) error = %v", width, err)
+		}
+		got := make([]uint32, len(values))
+		// Decode in uneven pieces so runs are split between calls
+		for n := 0; n < len(got); {
+			size := 1 + random.Intn(37)
+			if n+size > len(got) {
+				size = len(got) - n
+			}
+			read, err := decoder.Decode(got[n : n+size])
+			if err != nil {
+				t.Fatalf("Width=%d: Decode() error = %v", width, err)
+			}
+			n += read
+		}
+		if !reflect.DeepEqual(got, values) {
+			t.Fatalf("Width=%d: Decode() = %v, want %v", width, got, values)
+		}
+	}
+}
+
+func TestNewLengthDecoder(t *testing.T) {
+	runs := encode([]uint32{1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1}, 1)
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, uint32(len(runs)))
+	data = append(append(data, runs...), 0xab)
+	reader := bitreader.NewReaderFromBytes(data, true)
+	decoder, err := NewLengthDecoder(reader, 1)
+	if err != nil {
+		t.Fatalf("NewLengthDecoder() error = %v", err)
+	}
+	got := make([]uint32, 11)
+	if _, err := decoder.Decode(got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if want := []uint32{1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %v, want %v", got, want)
+	}
+	if next, _ := reader.ReadBytes(1); next != 0xab {
+		t.Errorf("ReadBytes(1) after = %#x, want 0xab", next)
+	}
+}
+
+func TestNewDictionaryDecoder(t *testing.T) {
+	data := append([]byte{10}, encode([]uint32{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 3, 999}, 10)...)
+	decoder, err := NewDictionaryDecoder(bitreader.NewReaderFromBytes(data, true))
+	if err != nil {
+		t.Fatalf("NewDictionaryDecoder() error = %v", err)
+	}
+	if decoder.Width() != 10 {
+		t.Errorf("Width() = %d, want 10", decoder.Width())
+	}
+	got := make([]uint32, 10)
+	if _, err := decoder.Decode(got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if want := []uint32{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 3, 999}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %v, want %v", got, want)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		width   uint
+		le      bool
+		wantErr error
+	}{
+		{name: "BigEndian", stream: []byte{0x02, 0x01}, width: 1, wantErr: ErrBigEndian},
+		{name: "Width", stream: []byte{0x02, 0x01}, width: 33, le: true, wantErr: ErrWidth},
+		{name: "EmptyRun", stream: []byte{0x00}, width: 1, le: true, wantErr: ErrCorrupt},
+		{name: "ValueTooWide", stream: []byte{0x04, 0x08}, width: 3, le: true, wantErr: ErrCorrupt},
+		{name: "HeaderOverflow", stream: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, width: 1, le: true, wantErr: ErrCorrupt},
+		{name: "ShortHeader", stream: []byte{0x80}, width: 1, le: true, wantErr: io.ErrUnexpectedEOF},
+		{name: "ShortValue", stream: []byte{0x04, 0xff}, width: 12, le: true, wantErr: io.ErrUnexpectedEOF},
+		{name: "ShortPacked", stream: []byte{0x03, 0xff}, width: 4, le: true, wantErr: io.ErrUnexpectedEOF},
+		{name: "Empty", stream: []byte{}, width: 4, le: true, wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := NewDecoder(bitreader.NewReaderFromBytes(tt.stream, tt.le), tt.width)
+			if err == nil {
+				_, err = decoder.Decode(make([]uint32, 8))
+			}
+			if err != tt.wantErr {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package snappy decompresses Snappy data, as used by CS:GO and Source 2 demos,
+// from a bitreader.Reader.
+//
+// Both the raw block format and the framing format are supported. A block is a
+// varint of the decompressed size followed by literals and back references.
+// The framing format splits a stream into CRC-checked chunks of up to 64 KiB
+// and is decompressed incrementally by FrameReader.
+package snappy
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/pektezol/bitreader"
+)
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	chunkCompressed   = 0x00
+	chunkUncompressed = 0x01
+	chunkPadding      = 0xfe
+	chunkStream       = 0xff
+	streamIdentifier  = "sNaPpY"
+
+	// maxPrealloc caps how much of the declared size is trusted for preallocation
+	maxPrealloc = 1 << 20
+	// maxChunkSize is the largest decompressed chunk in the framing format
+	maxChunkSize = 1 << 16
+)
+
+var (
+	// ErrCorrupt is returned when the data is not valid Snappy data.
+	ErrCorrupt = errors.New("snappy: corrupt input")
+	// ErrChecksum is returned when a chunk CRC doesn't match.
+	ErrChecksum = errors.New("snappy: invalid checksum")
+	// ErrUnsupported is returned for reserved unskippable chunk types.
+	ErrUnsupported = errors.New("snappy: unsupported chunk type")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// DecodedLen is a function that reads the varint size at the start of a block.
+func DecodedLen(reader *bitreader.Reader) (uint64, error) {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := reader.ReadBits(8)
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		if shift == 28 && b > 0x0f {
+			// Sizes are limited to 32 bits
+			return 0, ErrCorrupt
+		}
+		value |= (b & 0x7f) << shift
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+}
+
+// Decompress is a function that decompresses the Snappy block at the current Reader
+// position and returns a new Reader over the result with the same endianness.
+func Decompress(reader *bitreader.Reader) (*bitreader.Reader, error) {
+	data, err := DecompressBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bitreader.NewReaderFromBytes(data, reader.LittleEndian()), nil
+}
+
+// DecompressBytes is a function that decompresses the Snappy block
+// at the current Reader position and returns the result.
+// The Reader doesn't need to be byte-aligned.
+func DecompressBytes(reader *bitreader.Reader) ([]byte, error) {
+	length, err := DecodedLen(reader)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlock(reader, length, nil)
+}
+
+// decodeBlock is a private function that decodes the elements of a block until
+// length bytes have been produced, appending them to dst.
+func decodeBlock(reader *bitreader.Reader, length uint64, dst []byte) ([]byte, error) {
+	capacity := length
+	if capacity > maxPrealloc {
+		capacity = maxPrealloc
+	}
+	if dst == nil {
+		dst = make([]byte, 0, capacity)
+	}
+	start := len(dst)
+	for uint64(len(dst)-start) < length {
+		tag, err := reader.ReadBits(8)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		var size, offset uint64
+		switch tag & 0x03 {
+		case tagLiteral:
+			size = tag >> 2
+			if size >= 60 {
+				// Sizes of 60-63 mean the size is in the next 1-4 bytes
+				size, err = readLittleEndian(reader, int(size-59))
+				if err != nil {
+					return nil, err
+				}
+			}
+			size++
+			if uint64(len(dst)-start)+size > length {
+				return nil, ErrCorrupt
+			}
+			for ; size > 0; size-- {
+				value, err := reader.ReadBits(8)
+				if err != nil {
+					return nil, noEOF(err)
+				}
+				dst = append(dst, byte(value))
+			}
+			continue
+		case tagCopy1:
+			low, err := reader.ReadBits(8)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			size = 4 + (tag>>2)&0x07
+			offset = (tag&0xe0)<<3 | low
+		case tagCopy2:
+			size = 1 + tag>>2
+			offset, err = readLittleEndian(reader, 2)
+		case tagCopy4:
+			size = 1 + tag>>2
+			offset, err = readLittleEndian(reader, 4)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 || offset > uint64(len(dst)-start) || uint64(len(dst)-start)+size > length {
+			return nil, ErrCorrupt
+		}
+		// Copy byte by byte, a copy may overlap the bytes it produces
+		source := len(dst) - int(offset)
+		for i := 0; i < int(size); i++ {
+			dst = append(dst, dst[source+i])
+		}
+	}
+	return dst, nil
+}
+
+// FrameReader decompresses a Snappy framing format stream incrementally.
+//
+// reader *bitreader.Reader		The Reader the framed stream is read from
+// started bool					Whether the stream identifier has been read
+// pending []byte				Decompressed bytes not returned by Read yet
+// buffer []byte				Reused storage for decompressed chunks
+// err error					The sticky error returned by Read
+type FrameReader struct {
+	reader  *bitreader.Reader
+	started bool
+	pending []byte
+	buffer  []byte
+	err     error
+}
+
+// NewFrameReader is the main constructor that creates a FrameReader
+// for the framed stream starting at the current Reader position.
+func NewFrameReader(reader *bitreader.Reader) *FrameReader {
+	return &FrameReader{reader: reader}
+}
+
+// Read is a function that implements io.Reader over the decompressed stream.
+func (frameReader *FrameReader) Read(p []byte) (int, error) {
+	for len(frameReader.pending) == 0 {
+		if frameReader.err != nil {
+			return 0, frameReader.err
+		}
+		frameReader.pending, frameReader.err = frameReader.nextChunk()
+	}
+	n := copy(p, frameReader.pending)
+	frameReader.pending = frameReader.pending[n:]
+	return n, nil
+}
+
+// nextChunk is a private function that reads one chunk and returns its decompressed data.
+func (frameReader *FrameReader) nextChunk() ([]byte, error) {
+	reader := frameReader.reader
+	chunkType, err := reader.ReadBits(8)
+	if err != nil {
+		// A stream may end between chunks
+		return nil, err
+	}
+	length, err := readLittleEndian(reader, 3)
+	if err != nil {
+		return nil, err
+	}
+	if !frameReader.started && chunkType != chunkStream {
+		return nil, ErrCorrupt
+	}
+	switch {
+	case chunkType == chunkStream:
+		if length != uint64(len(streamIdentifier)) {
+			return nil, ErrCorrupt
+		}
+		for i := 0; i < len(streamIdentifier); i++ {
+			value, err := reader.ReadBits(8)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			if byte(value) != streamIdentifier[i] {
+				return nil, ErrCorrupt
+			}
+		}
+		frameReader.started = true
+		return nil, nil
+	case chunkType == chunkCompressed || chunkType == chunkUncompressed:
+		if length < 4 {
+			return nil, ErrCorrupt
+		}
+		checksum, err := readLittleEndian(reader, 4)
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		if chunkType == chunkCompressed {
+			start := reader.BitPosition()
+			decodedLen, err := DecodedLen(reader)
+			if err != nil {
+				return nil, err
+			}
+			if decodedLen > maxChunkSize {
+				return nil, ErrCorrupt
+			}
+			data, err = decodeBlock(reader, decodedLen, frameReader.buffer[:0])
+			if err != nil {
+				return nil, err
+			}
+			if reader.BitPosition()-start != (length-4)*8 {
+				return nil, ErrCorrupt
+			}
+		} else {
+			if length-4 > maxChunkSize {
+				return nil, ErrCorrupt
+			}
+			data = frameReader.buffer[:0]
+			for i := uint64(0); i < length-4; i++ {
+				value, err := reader.ReadBits(8)
+				if err != nil {
+					return nil, noEOF(err)
+				}
+				data = append(data, byte(value))
+			}
+		}
+		frameReader.buffer = data
+		if maskedCRC(data) != uint32(checksum) {
+			return nil, ErrChecksum
+		}
+		return data, nil
+	case chunkType == chunkPadding || chunkType >= 0x80:
+		if err := reader.SkipBytes(length); err != nil {
+			return nil, noEOF(err)
+		}
+		return nil, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// maskedCRC is a private function that returns the masked CRC-32C of the framing format.
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crcTable)
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+// readLittleEndian is a private function that reads a little-endian value of the
+// given number of bytes, independent of the Reader bit order.
+func readLittleEndian(reader *bitreader.Reader, bytes int) (uint64, error) {
+	var value uint64
+	for i := 0; i < bytes; i++ {
+		b, err := reader.ReadBits(8)
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		value |= b << (8 * i)
+	}
+	return value, nil
+}
+
+// noEOF is a private function that turns io.EOF in the middle of a stream into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
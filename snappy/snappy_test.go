@@ -0,0 +1,85 @@
+package snappy
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pektezol/bitreader"
+)
+
+// The testdata files were produced by github.com/golang/snappy, .snappy with
+// Encode and .sz with the framing format writer.
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecompress(t *testing.T) {
+	for _, name := range []string{"text", "random"} {
+		for _, littleEndian := range []bool{true, false} {
+			want := readTestdata(t, name+".bin")
+			reader, err := Decompress(bitreader.NewReaderFromBytes(readTestdata(t, name+".snappy"), littleEndian))
+			if err != nil {
+				t.Fatalf("%s: Decompress() error = %v", name, err)
+			}
+			if reader.LittleEndian() != littleEndian {
+				t.Errorf("%s: Decompress() LittleEndian() = %v, want %v", name, reader.LittleEndian(), littleEndian)
+			}
+			got, _ := reader.ReadBytesToSlice(uint64(len(want)))
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: Decompress() output differs from reference", name)
+			}
+		}
+	}
+}
+
+func TestDecompressBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		want    []byte
+		wantErr error
+	}{
+		{name: "Empty", stream: []byte{0x00}, want: []byte{}},
+		{name: "Literal", stream: []byte{0x03, 0x08, 'a', 'b', 'c'}, want: []byte("abc")},
+		{name: "OverlappingCopy", stream: []byte{0x08, 0x00, 'a', 0x0d, 0x01}, want: []byte("aaaaaaaa")},
+		{name: "OffsetTooFar", stream: []byte{0x08, 0x00, 'a', 0x0d, 0x02}, wantErr: ErrCorrupt},
+		{name: "Truncated", stream: []byte{0x03, 0x08, 'a'}, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecompressBytes(bitreader.NewReaderFromBytes(tt.stream, true))
+			if err != tt.wantErr {
+				t.Fatalf("DecompressBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("DecompressBytes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	for _, name := range []string{"text", "random"} {
+		want := readTestdata(t, name+".bin")
+		got, err := io.ReadAll(NewFrameReader(bitreader.NewReaderFromBytes(readTestdata(t, name+".sz"), true)))
+		if err != nil {
+			t.Fatalf("%s: FrameReader.Read() error = %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: FrameReader.Read() output differs from reference", name)
+		}
+	}
+	corrupt := readTestdata(t, "random.sz")
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := io.ReadAll(NewFrameReader(bitreader.NewReaderFromBytes(corrupt, true))); err != ErrChecksum {
+		t.Errorf("FrameReader.Read() error = %v, want %v", err, ErrChecksum)
+	}
+}
@@ -0,0 +1,258 @@
+package bitreader
+
+import (
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the text encoding of a string.
+type Encoding uint8
+
+const (
+	UTF8        Encoding = iota // UTF-8, validated
+	UTF16LE                     // UTF-16 with little-endian code units
+	UTF16BE                     // UTF-16 with big-endian code units
+	Latin1                      // ISO 8859-1, every byte is the code point of the same value
+	Windows1252                 // Windows code page 1252, Latin-1 with printable characters in 0x80 to 0x9F
+)
+
+// InvalidPolicy decides what happens to bytes that are not valid in the Encoding.
+type InvalidPolicy uint8
+
+const (
+	ReplaceInvalid InvalidPolicy = iota // Invalid bytes become U+FFFD, one per byte or unpaired surrogate
+	RejectInvalid                       // Invalid bytes make the read fail
+)
+
+// LengthPrefix is the size of the length in front of a Pascal-style string.
+type LengthPrefix uint8
+
+const (
+	Prefix8      LengthPrefix = iota // 8-bit length
+	Prefix16                         // 16-bit length in the Reader's endianness
+	Prefix32                         // 32-bit length in the Reader's endianness
+	PrefixVarint                     // Unsigned LEB128 length, as in protobuf and .NET BinaryWriter
+)
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252, utf8.RuneError marks the undefined ones.
+var windows1252 = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+// ReadEncodedString is a function that reads a null-terminated string in the given
+// Encoding and returns it as UTF-8. The terminator is one zero byte, or one zero
+// code unit for UTF-16. It works from any bit position, and like ReadString it
+// stops at the end of the stream with an error.
+//
+// Returns an error if there are no remaining bits, or the text is invalid with RejectInvalid.
+func (reader *Reader) ReadEncodedString(encoding Encoding, policy InvalidPolicy) (string, error) {
+	const op = "ReadEncodedString(encoding, policy)"
+	if encoding > Windows1252 {
+		return "", errors.New(op + " ERROR: Unknown encoding")
+	}
+	unit := encoding.unitSize()
+	var raw []byte
+	for {
+		var zero uint64
+		for i := uint64(0); i < unit; i++ {
+			value, err := reader.ReadBytes(1)
+			if err != nil {
+				return "", err
+			}
+			zero |= value
+			raw = append(raw, byte(value))
+		}
+		if zero == 0 {
+			raw = raw[:uint64(len(raw))-unit]
+			break
+		}
+		if err := reader.checkString(op, uint64(len(raw))); err != nil {
+			return "", err
+		}
+	}
+	return decodeText(op, raw, encoding, policy)
+}
+
+// ReadEncodedStringLength is a function that reads length bytes as a string in the given
+// Encoding and returns it as UTF-8. Like ReadStringLength, the string ends at the first
+// zero byte, or zero code unit for UTF-16, and the rest of the bytes are skipped.
+//
+// Returns an error if there are not enough remaining bits, length is odd for UTF-16
+// or the text is invalid with RejectInvalid.
+func (reader *Reader) ReadEncodedStringLength(encoding Encoding, policy InvalidPolicy, length uint64) (string, error) {
+	const op = "ReadEncodedStringLength(encoding, policy, length)"
+	raw, err := reader.readText(op, encoding, length)
+	if err != nil {
+		return "", err
+	}
+	unit := int(encoding.unitSize())
+	for i := 0; i < len(raw); i += unit {
+		if raw[i] == 0 && (unit == 1 || raw[i+1] == 0) {
+			raw = raw[:i]
+			break
+		}
+	}
+	return decodeText(op, raw, encoding, policy)
+}
+
+// ReadPrefixedString is a function that reads a Pascal-style string, a length in bytes
+// followed by that many bytes in the given Encoding, and returns it as UTF-8.
+// Unlike ReadEncodedStringLength, zero bytes are kept.
+//
+// Returns an error if there are not enough remaining bits, the length is odd for UTF-16
+// or the text is invalid with RejectInvalid.
+func (reader *Reader) ReadPrefixedString(prefix LengthPrefix, encoding Encoding, policy InvalidPolicy) (string, error) {
+	const op = "ReadPrefixedString(prefix, encoding, policy)"
+	var length uint64
+	var err error
+	switch prefix {
+	case Prefix8:
+		length, err = reader.ReadBits(8)
+	case Prefix16:
+		length, err = reader.ReadBits(16)
+	case Prefix32:
+		length, err = reader.ReadBits(32)
+	case PrefixVarint:
+		length, err = reader.ReadULEB128()
+	default:
+		return "", errors.New(op + " ERROR: Unknown length prefix")
+	}
+	if err != nil {
+		return "", err
+	}
+	raw, err := reader.readText(op, encoding, length)
+	if err != nil {
+		return "", err
+	}
+	return decodeText(op, raw, encoding, policy)
+}
+
+// TryReadEncodedString is a wrapper function that returns a null-terminated string in the given Encoding.
+//
+// Returns string. Panics on overflow.
+func (reader *Reader) TryReadEncodedString(encoding Encoding, policy InvalidPolicy) string {
+	text, err := reader.ReadEncodedString(encoding, policy)
+	if err != nil {
+		panic(err)
+	}
+	return text
+}
+
+// TryReadEncodedStringLength is a wrapper function that returns a string of length bytes in the given Encoding.
+//
+// Returns string. Panics on overflow.
+func (reader *Reader) TryReadEncodedStringLength(encoding Encoding, policy InvalidPolicy, length uint64) string {
+	text, err := reader.ReadEncodedStringLength(encoding, policy, length)
+	if err != nil {
+		panic(err)
+	}
+	return text
+}
+
+// TryReadPrefixedString is a wrapper function that returns a length-prefixed string in the given Encoding.
+//
+// Returns string. Panics on overflow.
+func (reader *Reader) TryReadPrefixedString(prefix LengthPrefix, encoding Encoding, policy InvalidPolicy) string {
+	text, err := reader.ReadPrefixedString(prefix, encoding, policy)
+	if err != nil {
+		panic(err)
+	}
+	return text
+}
+
+// unitSize is a private function that returns the size of a code unit in bytes.
+func (encoding Encoding) unitSize() uint64 {
+	if encoding == UTF16LE || encoding == UTF16BE {
+		return 2
+	}
+	return 1
+}
+
+// readText is a private function that reads the raw bytes of a string of length bytes.
+func (reader *Reader) readText(op string, encoding Encoding, length uint64) ([]byte, error) {
+	if encoding > Windows1252 {
+		return nil, errors.New(op + " ERROR: Unknown encoding")
+	}
+	if length%encoding.unitSize() != 0 {
+		return nil, errors.New(op + " ERROR: Length should be a whole number of code units")
+	}
+	if err := reader.checkString(op, length); err != nil {
+		return nil, err
+	}
+	var raw []byte
+	for i := uint64(0); i < length; i++ {
+		value, err := reader.ReadBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, byte(value))
+	}
+	return raw, nil
+}
+
+// decodeText is a private function that converts raw bytes in the Encoding to UTF-8.
+func decodeText(op string, raw []byte, encoding Encoding, policy InvalidPolicy) (string, error) {
+	var out []rune
+	switch encoding {
+	case UTF8:
+		if utf8.Valid(raw) {
+			return string(raw), nil
+		}
+		if policy == RejectInvalid {
+			return "", errors.New(op + " ERROR: Invalid text in the encoding")
+		}
+		for len(raw) > 0 {
+			r, size := utf8.DecodeRune(raw)
+			out = append(out, r)
+			raw = raw[size:]
+		}
+	case Latin1:
+		out = make([]rune, len(raw))
+		for i, b := range raw {
+			out[i] = rune(b)
+		}
+	case Windows1252:
+		out = make([]rune, len(raw))
+		for i, b := range raw {
+			out[i] = rune(b)
+			if b >= 0x80 && b < 0xa0 {
+				out[i] = windows1252[b-0x80]
+				if out[i] == utf8.RuneError && policy == RejectInvalid {
+					return "", errors.New(op + " ERROR: Invalid text in the encoding")
+				}
+			}
+		}
+	case UTF16LE, UTF16BE:
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			if encoding == UTF16LE {
+				units[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+			} else {
+				units[i] = uint16(raw[2*i])<<8 | uint16(raw[2*i+1])
+			}
+		}
+		out = utf16.Decode(units)
+		if policy == RejectInvalid {
+			// utf16.Decode turns every unpaired surrogate into one U+FFFD, so compare the counts
+			replaced := 0
+			for _, r := range out {
+				if r == utf8.RuneError {
+					replaced++
+				}
+			}
+			for _, unit := range units {
+				if unit == utf8.RuneError {
+					replaced--
+				}
+			}
+			if replaced != 0 {
+				return "", errors.New(op + " ERROR: Invalid text in the encoding")
+			}
+		}
+	default:
+		return "", errors.New(op + " ERROR: Unknown encoding")
+	}
+	return string(out), nil
+}
@@ -0,0 +1,154 @@
+package bitreader
+
+import (
+	"testing"
+)
+
+func TestReader_ReadEncodedString(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   []byte
+		encoding Encoding
+		policy   InvalidPolicy
+		want     string
+		wantErr  bool
+	}{
+		{name: "UTF8", stream: []byte("héllo\x00rest"), encoding: UTF8, want: "héllo"},
+		{name: "UTF8Invalid", stream: []byte{'a', 0xff, 0xc3, 'b', 0x00}, encoding: UTF8, want: "a��b"},
+		{name: "UTF8Reject", stream: []byte{'a', 0xff, 0x00}, encoding: UTF8, policy: RejectInvalid, wantErr: true},
+		{name: "UTF16LE", stream: []byte{'h', 0x00, 0xe9, 0x00, 0x3d, 0xd8, 0x00, 0xde, 0x00, 0x00}, encoding: UTF16LE, want: "hé😀"},
+		{name: "UTF16BE", stream: []byte{0x00, 'h', 0x00, 0xe9, 0xd8, 0x3d, 0xde, 0x00, 0x00, 0x00}, encoding: UTF16BE, want: "hé😀"},
+		// A zero byte inside a code unit doesn't end the string
+		{name: "UTF16ZeroByte", stream: []byte{0x00, 0x01, 0x00, 0x00}, encoding: UTF16LE, want: "Ā"},
+		{name: "UTF16Unpaired", stream: []byte{0x3d, 0xd8, 'a', 0x00, 0x00, 0x00}, encoding: UTF16LE, want: "�a"},
+		{name: "UTF16UnpairedReject", stream: []byte{0x3d, 0xd8, 0x00, 0x00}, encoding: UTF16LE, policy: RejectInvalid, wantErr: true},
+		{name: "UTF16ReplacementKept", stream: []byte{0xfd, 0xff, 0x00, 0x00}, encoding: UTF16LE, policy: RejectInvalid, want: "�"},
+		{name: "Latin1", stream: []byte{'c', 'a', 'f', 0xe9, 0x80, 0x00}, encoding: Latin1, want: "café\u0080"},
+		{name: "Windows1252", stream: []byte{0x80, 0x93, 'x', 0x94, 0xe9, 0x00}, encoding: Windows1252, want: "€“x”é"},
+		{name: "Windows1252Undefined", stream: []byte{0x81, 0x00}, encoding: Windows1252, want: "�"},
+		{name: "Windows1252Reject", stream: []byte{0x8d, 0x00}, encoding: Windows1252, policy: RejectInvalid, wantErr: true},
+		{name: "NoTerminator", stream: []byte("abc"), encoding: UTF8, wantErr: true},
+		{name: "UnknownEncoding", stream: []byte{0x00}, encoding: 9, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, true).ReadEncodedString(tt.encoding, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadEncodedString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ReadEncodedString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadEncodedStringLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   []byte
+		encoding Encoding
+		length   uint64
+		want     string
+		wantErr  bool
+	}{
+		{name: "Padded", stream: []byte{'a', 'b', 0x00, 'x', '!'}, encoding: UTF8, length: 4, want: "ab"},
+		{name: "Full", stream: []byte{'a', 'b', 'c', '!'}, encoding: Latin1, length: 3, want: "abc"},
+		{name: "UTF16Padded", stream: []byte{0x00, 'a', 0x00, 0x00, 0xff, 0xff, '!'}, encoding: UTF16BE, length: 6, want: "a"},
+		{name: "OddLength", stream: []byte{0x00, 'a', 0x00}, encoding: UTF16BE, length: 3, wantErr: true},
+		{name: "Short", stream: []byte{'a'}, encoding: UTF8, length: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReaderFromBytes(tt.stream, false)
+			got, err := reader.ReadEncodedStringLength(tt.encoding, ReplaceInvalid, tt.length)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadEncodedStringLength() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ReadEncodedStringLength() = %q, want %q", got, tt.want)
+			}
+			if next := reader.TryReadBits(8); next != '!' {
+				t.Errorf("ReadBits(8) after = %q, want '!'", rune(next))
+			}
+		})
+	}
+}
+
+func TestReader_ReadPrefixedString(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   []byte
+		le       bool
+		prefix   LengthPrefix
+		encoding Encoding
+		want     string
+		wantErr  bool
+	}{
+		{name: "Prefix8", stream: []byte{0x03, 'a', 0x00, 'b'}, prefix: Prefix8, encoding: UTF8, want: "a\x00b"},
+		{name: "Prefix16LE", stream: []byte{0x02, 0x00, 'h', 'i'}, le: true, prefix: Prefix16, encoding: UTF8, want: "hi"},
+		{name: "Prefix16BE", stream: []byte{0x00, 0x02, 'h', 'i'}, prefix: Prefix16, encoding: UTF8, want: "hi"},
+		{name: "Prefix32UTF16", stream: []byte{0x04, 0x00, 0x00, 0x00, 0xac, 0x20, 0x21, 0x00}, le: true, prefix: Prefix32, encoding: UTF16LE, want: "€!"},
+		{name: "Varint", stream: append([]byte{0x82, 0x01}, make([]byte, 130)...), prefix: PrefixVarint, encoding: Latin1, want: string(make([]byte, 130))},
+		{name: "Windows1252", stream: []byte{0x02, 0x99, 0x85}, prefix: Prefix8, encoding: Windows1252, want: "™…"},
+		{name: "Empty", stream: []byte{0x00}, prefix: Prefix8, encoding: UTF16LE, want: ""},
+		{name: "OddUTF16", stream: []byte{0x01, 'a'}, prefix: Prefix8, encoding: UTF16LE, wantErr: true},
+		{name: "Short", stream: []byte{0x05, 'a'}, prefix: Prefix8, encoding: UTF8, wantErr: true},
+		{name: "UnknownPrefix", stream: []byte{0x01, 'a'}, prefix: 7, encoding: UTF8, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReaderFromBytes(tt.stream, tt.le).ReadPrefixedString(tt.prefix, tt.encoding, ReplaceInvalid)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadPrefixedString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ReadPrefixedString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_EncodedStringUnaligned(t *testing.T) {
+	// "Ĳ€" in UTF-16BE with a terminator, read 3 bits into the stream
+	text := []byte{0x01, 0x32, 0x20, 0xac, 0x00, 0x00}
+	for _, le := range []bool{true, false} {
+		stream := make([]byte, len(text)+1)
+		for i, b := range text {
+			if le {
+				stream[i] |= b << 3
+				stream[i+1] |= b >> 5
+			} else {
+				stream[i] |= b >> 3
+				stream[i+1] |= b << 5
+			}
+		}
+		reader := NewReaderFromBytes(stream, le)
+		reader.SkipBits(3)
+		if got := reader.TryReadEncodedString(UTF16BE, RejectInvalid); got != "Ĳ€" {
+			t.Errorf("LE=%v: ReadEncodedString() = %q, want %q", le, got, "Ĳ€")
+		}
+		if reader.BitPosition() != 3+48 {
+			t.Errorf("LE=%v: BitPosition() = %d, want %d", le, reader.BitPosition(), 3+48)
+		}
+	}
+}
+
+func TestReader_EncodedStringLimits(t *testing.T) {
+	reader := NewReaderFromBytes([]byte("abcdef\x00"), true)
+	reader.SetLimits(Limits{MaxStringLength: 4})
+	if _, err := reader.ReadEncodedString(UTF8, ReplaceInvalid); err == nil {
+		t.Errorf("ReadEncodedString() error = nil, want limit error")
+	}
+	reader = NewReaderFromBytes([]byte{0xff, 0xff, 0xff, 0xff, 'a'}, true)
+	reader.SetLimits(Limits{MaxStringLength: 4})
+	if _, err := reader.ReadPrefixedString(Prefix32, UTF8, ReplaceInvalid); err == nil {
+		t.Errorf("ReadPrefixedString() error = nil, want limit error")
+	}
+}
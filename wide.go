@@ -0,0 +1,158 @@
+package bitreader
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Alignment selects where ReadBitsInto places bits that don't fill a whole byte.
+type Alignment uint8
+
+const (
+	// AlignLeft puts the bits first, zero padding the end of the last byte.
+	// In little-endian mode the result is the value as a little-endian integer.
+	AlignLeft Alignment = iota
+	// AlignRight puts the zero padding first, so the bits end with the last byte.
+	// In big-endian mode the result is the value as a big-endian integer.
+	AlignRight
+)
+
+// ReadBigInt is a function that reads the specified amount of bits and returns
+// them as a non-negative *big.Int. It is the same value ReadBits would return
+// if it wasn't limited to 64 bits: the first bit read is the least significant
+// in little-endian mode and the most significant in big-endian mode.
+//
+// Returns an error if bits is 0 or there are not enough remaining bits.
+func (reader *Reader) ReadBigInt(bits uint64) (*big.Int, error) {
+	if bits < 1 {
+		return nil, errors.New("ReadBigInt(bits) ERROR: Bits number should be at least 1")
+	}
+	if err := reader.checkBits("ReadBigInt(bits)", bits); err != nil {
+		return nil, err
+	}
+	value := new(big.Int)
+	chunk := new(big.Int)
+	var done uint64
+	for done < bits {
+		size := bits - done
+		if size > 64 {
+			size = 64
+		}
+		part, err := reader.ReadBits(size)
+		if err != nil {
+			return nil, err
+		}
+		chunk.SetUint64(part)
+		if reader.littleEndian {
+			value.Or(value, chunk.Lsh(chunk, uint(done)))
+		} else {
+			value.Or(value.Lsh(value, uint(size)), chunk)
+		}
+		done += size
+	}
+	return value, nil
+}
+
+// ReadUint128 is a function that reads 128 bits and returns them as the
+// high and low 64 bits of one unsigned integer, in the Reader's endianness.
+//
+// Returns an error if there are not enough remaining bits.
+func (reader *Reader) ReadUint128() (hi, lo uint64, err error) {
+	if err := reader.checkBits("ReadUint128()", 128); err != nil {
+		return 0, 0, err
+	}
+	first, err := reader.ReadBits(64)
+	if err != nil {
+		return 0, 0, err
+	}
+	second, err := reader.ReadBits(64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if reader.littleEndian {
+		return second, first, nil
+	}
+	return first, second, nil
+}
+
+// ReadBitsInto is a function that reads exactly the specified amount of bits
+// into the first (bits+7)/8 bytes of dst, leaving the rest of dst untouched.
+// Whole bytes are stored as ReadBytes(1) would return them. If bits is not a
+// multiple of 8, align decides which byte is partial and where its bits go,
+// in the Reader's bit order, with the padding bits set to 0.
+//
+// Returns an error if dst is too small or there are not enough remaining bits,
+// in which case the contents of dst are unspecified.
+func (reader *Reader) ReadBitsInto(dst []byte, bits uint64, align Alignment) error {
+	if align != AlignLeft && align != AlignRight {
+		return errors.New("ReadBitsInto(dst, bits, align) ERROR: Unknown alignment")
+	}
+	bytes := bits / 8
+	extra := bits % 8
+	if extra != 0 {
+		bytes++
+	}
+	if uint64(len(dst)) < bytes {
+		return errors.New("ReadBitsInto(dst, bits, align) ERROR: Destination is too small")
+	}
+	if err := reader.checkBits("ReadBitsInto(dst, bits, align)", bits); err != nil {
+		return err
+	}
+	out := dst[:bytes]
+	if extra != 0 && align == AlignRight {
+		if err := reader.readPartial(&out[0], extra, true); err != nil {
+			return err
+		}
+		out = out[1:]
+	}
+	whole := bits / 8
+	for i := uint64(0); i < whole; i++ {
+		val, err := reader.ReadBytes(1)
+		if err != nil {
+			return err
+		}
+		out[i] = byte(val)
+	}
+	if extra != 0 && align == AlignLeft {
+		return reader.readPartial(&out[whole], extra, false)
+	}
+	return nil
+}
+
+// TryReadBigInt is a wrapper function that returns the value of bits as a *big.Int.
+//
+// Returns *big.Int. Panics on overflow.
+func (reader *Reader) TryReadBigInt(bits uint64) *big.Int {
+	value, err := reader.ReadBigInt(bits)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// TryReadUint128 is a wrapper function that returns the high and low 64 bits of 128 bits.
+//
+// Returns uint64, uint64. Panics on overflow.
+func (reader *Reader) TryReadUint128() (uint64, uint64) {
+	hi, lo, err := reader.ReadUint128()
+	if err != nil {
+		panic(err)
+	}
+	return hi, lo
+}
+
+// readPartial is a private function that reads fewer than 8 bits into one byte.
+// The bits take the first positions of the byte in the Reader's bit order,
+// or the last positions if last is set.
+func (reader *Reader) readPartial(dst *byte, bits uint64, last bool) error {
+	val, err := reader.ReadBits(bits)
+	if err != nil {
+		return err
+	}
+	// Little-endian bytes start at the lowest bit, big-endian at the highest
+	if reader.littleEndian == last {
+		val <<= 8 - bits
+	}
+	*dst = byte(val)
+	return nil
+}
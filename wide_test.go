@@ -0,0 +1,133 @@
+package bitreader
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestReader_ReadBigInt(t *testing.T) {
+	stream := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10, 0xf0}
+	tests := []struct {
+		name    string
+		le      bool
+		skip    uint64
+		bits    uint64
+		want    string
+		wantErr bool
+	}{
+		{name: "128BE", bits: 128, want: "123456789abcdeffedcba9876543210"},
+		{name: "128LE", le: true, bits: 128, want: "1032547698badcfeefcdab8967452301"},
+		{name: "132BE", bits: 132, want: "123456789abcdeffedcba9876543210f"},
+		{name: "132LE", le: true, bits: 132, want: "1032547698badcfeefcdab8967452301"},
+		{name: "UnalignedBE", skip: 4, bits: 72, want: "123456789abcdeffed"},
+		{name: "UnalignedLE", le: true, skip: 4, bits: 72, want: "cfeefcdab896745230"},
+		{name: "Small", bits: 3, want: "0"},
+		{name: "Zero", bits: 0, wantErr: true},
+		{name: "Short", bits: 137, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReaderFromBytes(stream, tt.le)
+			reader.SkipBits(tt.skip)
+			got, err := reader.ReadBigInt(tt.bits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadBigInt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.Text(16) != tt.want {
+				t.Errorf("ReadBigInt() = %s, want %s", got.Text(16), tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadUint128(t *testing.T) {
+	stream := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10}
+	hi, lo, err := NewReaderFromBytes(stream, false).ReadUint128()
+	if err != nil || hi != 0x0123456789abcdef || lo != 0xfedcba9876543210 {
+		t.Errorf("ReadUint128() BE = %#x, %#x, %v", hi, lo, err)
+	}
+	hi, lo, err = NewReaderFromBytes(stream, true).ReadUint128()
+	if err != nil || hi != 0x1032547698badcfe || lo != 0xefcdab8967452301 {
+		t.Errorf("ReadUint128() LE = %#x, %#x, %v", hi, lo, err)
+	}
+	if _, _, err := NewReaderFromBytes(stream[:15], true).ReadUint128(); err == nil {
+		t.Errorf("ReadUint128() error = nil, want EOF")
+	}
+}
+
+func TestReader_ReadBitsInto(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		le      bool
+		bits    uint64
+		align   Alignment
+		size    int
+		want    []byte
+		wantErr bool
+	}{
+		{name: "LeftBE", stream: []byte{0xab, 0xcd}, bits: 12, align: AlignLeft, size: 2, want: []byte{0xab, 0xc0}},
+		{name: "RightBE", stream: []byte{0xab, 0xcd}, bits: 12, align: AlignRight, size: 2, want: []byte{0x0a, 0xbc}},
+		{name: "LeftLE", stream: []byte{0xab, 0xcd}, le: true, bits: 12, align: AlignLeft, size: 2, want: []byte{0xab, 0x0d}},
+		{name: "RightLE", stream: []byte{0xab, 0xcd}, le: true, bits: 12, align: AlignRight, size: 2, want: []byte{0xb0, 0xda}},
+		{name: "Whole", stream: []byte{0xab, 0xcd}, bits: 16, align: AlignRight, size: 3, want: []byte{0xab, 0xcd, 0xff}},
+		{name: "Nothing", stream: []byte{}, bits: 0, size: 1, want: []byte{0xff}},
+		{name: "TooSmall", stream: []byte{0xab, 0xcd}, bits: 9, size: 1, wantErr: true},
+		{name: "Short", stream: []byte{0xab}, bits: 9, size: 2, wantErr: true},
+		{name: "BadAlign", stream: []byte{0xab}, bits: 4, align: 2, size: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]byte, tt.size)
+			for i := range got {
+				got[i] = 0xff
+			}
+			err := NewReaderFromBytes(tt.stream, tt.le).ReadBitsInto(got, tt.bits, tt.align)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadBitsInto() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadBitsInto() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadBitsIntoBigInt(t *testing.T) {
+	// Right-aligned big-endian and left-aligned little-endian output are the integer bytes
+	random := rand.New(rand.NewSource(37))
+	stream := make([]byte, 64)
+	random.Read(stream)
+	for bits := uint64(1); bits <= 300; bits++ {
+		for _, le := range []bool{true, false} {
+			want := NewReaderFromBytes(stream, le)
+			want.SkipBits(bits % 7)
+			value := want.TryReadBigInt(bits)
+			reader := NewReaderFromBytes(stream, le)
+			reader.SkipBits(bits % 7)
+			dst := make([]byte, (bits+7)/8)
+			align := AlignRight
+			if le {
+				align = AlignLeft
+			}
+			if err := reader.ReadBitsInto(dst, bits, align); err != nil {
+				t.Fatalf("ReadBitsInto(%d) error = %v", bits, err)
+			}
+			if le {
+				for i, j := 0, len(dst)-1; i < j; i, j = i+1, j-1 {
+					dst[i], dst[j] = dst[j], dst[i]
+				}
+			}
+			if got := new(big.Int).SetBytes(dst); got.Cmp(value) != 0 {
+				t.Fatalf("LE=%v: ReadBitsInto(%d) = %x, want %x", le, bits, got, value)
+			}
+			if reader.BitPosition() != want.BitPosition() {
+				t.Fatalf("LE=%v: BitPosition() = %d, want %d", le, reader.BitPosition(), want.BitPosition())
+			}
+		}
+	}
+}